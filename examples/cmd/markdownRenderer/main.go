@@ -18,7 +18,6 @@ import (
 	"github.com/rstudio/platform-lib/v4/examples/cmd/markdownRenderer/notifytypes"
 	"github.com/rstudio/platform-lib/v4/examples/cmd/markdownRenderer/queuetypes"
 	"github.com/rstudio/platform-lib/v4/examples/cmd/markdownRenderer/runners"
-	"github.com/rstudio/platform-lib/v4/examples/cmd/markdownRenderer/storage"
 	"github.com/rstudio/platform-lib/v4/examples/cmd/markdownRenderer/store"
 	"github.com/rstudio/platform-lib/v4/pkg/rscache"
 	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/broadcaster"
@@ -30,6 +29,7 @@ import (
 	"github.com/rstudio/platform-lib/v4/pkg/rsqueue/metrics"
 	"github.com/rstudio/platform-lib/v4/pkg/rsqueue/queue"
 	"github.com/rstudio/platform-lib/v4/pkg/rsqueue/runnerfactory"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/chunknotify"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
 )
 
@@ -119,7 +119,7 @@ func main() {
 	matcher := listener.NewMatcher("NotifyType")
 	matcher.Register(notifytypes.NotifyTypeQueue, &store.DbQueueNotification{})
 	matcher.Register(notifytypes.NotifyTypeWorkComplete, &agenttypes.WorkCompleteNotification{})
-	matcher.Register(notifytypes.NotifyTypeChunk, &notifytypes.ChunkNotification{})
+	chunknotify.Register(matcher, notifytypes.NotifyTypeChunk)
 
 	// Create a broadcaster listener to receive notifications. Notifications can only
 	// be received by one listener, but a broadcaster (see below) can be used to pass
@@ -158,11 +158,18 @@ func main() {
 	// directory in "chunks" not larger than a configured size. The chunk waiter is used
 	// by the file storage server when processing chunked files to determine when new
 	// chunks are ready for reading.
-	waiter := storage.NewExampleChunkWaiter(notifyBroadcaster)
+	waiter := chunknotify.NewWaiter(chunknotify.WaiterArgs{
+		Broadcaster: notifyBroadcaster,
+		NotifyType:  notifytypes.NotifyTypeChunk,
+	})
 	// The notifier is used to connect the storage server with the notification system.
 	// When writing chunked files, the notifier is used to notify when new chunks have
 	// been written.
-	notifier := storage.NewExampleChunkNotifier(exampleStore)
+	notifier := chunknotify.NewNotifier(chunknotify.NotifierArgs{
+		Sender:     exampleStore,
+		Channel:    notifytypes.ChannelMessages,
+		NotifyType: notifytypes.NotifyTypeChunk,
+	})
 	// Create storage server for storing data on disk.
 	fileStorage := file.NewStorageServer(file.StorageServerArgs{
 		Dir:          "data",
@@ -189,7 +196,7 @@ func main() {
 	// matches the address of the item the queue is waiting on. Since we don't enforce the
 	// implementation of the chunk notifications, we need the matcher to couple the
 	// notification to the queue.
-	chunkMatcher := &storage.ExampleChunkMatcher{}
+	chunkMatcher := &chunknotify.Matcher{}
 
 	// Start the job queue. The stop channel is used to gracefully shut down the queue.
 	stopQueue := make(chan bool)
@@ -2,11 +2,6 @@ package notifytypes
 
 // Copyright (C) 2022 by RStudio, PBC
 
-import (
-	"github.com/google/uuid"
-	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listener"
-)
-
 // NotifyTypeQueue: Queue work is ready
 // NotifyTypeWorkComplete: Addressed work is complete
 // NotifyTypeChunk: Chunked download chunk is ready
@@ -26,22 +21,3 @@ const (
 	ChannelLeader   = "leader"
 	ChannelFollower = "follower"
 )
-
-// ChunkNotification is a notification type sent when new chunks are available
-// for an asset stored in chunks.
-type ChunkNotification struct {
-	listener.GenericNotification
-	Address string
-	Chunk   uint64
-}
-
-func NewChunkNotification(address string, chunk uint64) *ChunkNotification {
-	return &ChunkNotification{
-		GenericNotification: listener.GenericNotification{
-			NotifyGuid: uuid.New().String(),
-			NotifyType: NotifyTypeChunk,
-		},
-		Address: address,
-		Chunk:   chunk,
-	}
-}
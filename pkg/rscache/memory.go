@@ -30,7 +30,9 @@ type MemoryCacheConfig struct {
 // NewMemoryCache - A memory cache isn't bound to a disk cache. It's possible that the two could
 // be out of sync. Since our goal is immutability, this shouldn't matter, but,
 // for example, you can delete a cached item from the disk and it will remain
-// in memory.
+// in memory. To invalidate memory caches on every node when items are written
+// or removed, wrap the storage server with `changefeed.StorageServer` and run
+// a `changefeed.Subscriber` on each node.
 func NewMemoryCache(cfg MemoryCacheConfig) MemoryCache {
 	m := memoryCache{
 		ttl:       cfg.TTL,
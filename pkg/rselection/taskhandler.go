@@ -164,3 +164,32 @@ func (*IntervalSchedule) Type() uint8 {
 func (c *IntervalSchedule) Next() <-chan time.Time {
 	return c.Ticker
 }
+
+// FuncTask is a scheduled task that runs a function. It can be registered
+// with a `TaskHandler` so that only the leader runs it.
+type FuncTask struct {
+	GenericTask
+	run func(ctx context.Context) error
+}
+
+// NewFuncTask returns a scheduled task that calls `run` on `schedule`. Errors
+// returned by `run` are logged.
+func NewFuncTask(name string, schedule Schedule, run func(ctx context.Context) error) *FuncTask {
+	return &FuncTask{
+		GenericTask: GenericTask{
+			TaskName:     name,
+			TaskType:     TaskTypeScheduled,
+			TaskSchedule: schedule,
+		},
+		run: run,
+	}
+}
+
+func (t *FuncTask) Run(ctx context.Context, b broadcaster.Broadcaster) {
+	start := time.Now()
+	err := t.run(ctx)
+	if err != nil {
+		slog.Error("error while running task", "task", t.Name(), "error", err)
+	}
+	slog.Debug("ran task", "task", t.Name(), "duration", time.Since(start))
+}
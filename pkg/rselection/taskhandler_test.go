@@ -199,3 +199,20 @@ func (s *TaskHandlerSuite) TestOnTaskRunFires(c *check.C) {
 
 	handler.Stop()
 }
+
+func (s *TaskHandlerSuite) TestFuncTask(c *check.C) {
+	tick := make(chan time.Time)
+	var ran int
+	task := NewFuncTask("func", &IntervalSchedule{Ticker: tick}, func(ctx context.Context) error {
+		ran++
+		return fmt.Errorf("task error")
+	})
+	c.Check(task.Name(), check.Equals, "func")
+	c.Check(task.Type(), check.Equals, TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(tick))
+
+	// Errors are logged
+	task.Run(context.Background(), nil)
+	task.Run(context.Background(), nil)
+	c.Check(ran, check.Equals, 2)
+}
@@ -0,0 +1,170 @@
+package archive
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ArchiveSuite struct{}
+
+var _ = check.Suite(&ArchiveSuite{})
+
+// newSource returns a server with a plain item, a chunked item with
+// metadata, and an item in another dir.
+func newSource(c *check.C) rsstorage.StorageServer {
+	ctx := context.Background()
+	server := memtest.NewServer("source", 4)
+	_, _, err := server.Put(ctx, servertest.StringResolver("plain data"), "dir", "plain")
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, servertest.StringResolver("chunked data"), "dir", "chunked", 12, types.Metadata{
+		types.MetadataContentType: "text/plain",
+	})
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("other data"), "other", "item")
+	c.Assert(err, check.IsNil)
+	return server
+}
+
+func export(c *check.C, args ExporterArgs) (*Manifest, *bytes.Buffer) {
+	var buf bytes.Buffer
+	m, err := NewExporter(args).Export(context.Background(), &buf)
+	c.Assert(err, check.IsNil)
+	return m, &buf
+}
+
+func (s *ArchiveSuite) TestRoundTrip(c *check.C) {
+	ctx := context.Background()
+	source := newSource(c)
+
+	for _, compression := range []Compression{CompressionNone, CompressionZstd} {
+		m, buf := export(c, ExporterArgs{Server: source, Compression: compression})
+		c.Check(m.Version, check.Equals, ManifestVersion)
+		c.Check(m.Since.IsZero(), check.Equals, true)
+		c.Assert(m.Items, check.HasLen, 3)
+		c.Check(m.Items[0].Dir, check.Equals, "dir")
+		c.Check(m.Items[0].Address, check.Equals, "chunked")
+		c.Check(m.Items[0].Chunked, check.Equals, true)
+		c.Check(m.Items[0].Size, check.Equals, int64(12))
+		c.Check(m.Items[0].Metadata, check.DeepEquals, types.Metadata{types.MetadataContentType: "text/plain"})
+		c.Check(m.Items[1].Address, check.Equals, "plain")
+		c.Check(m.Items[1].Chunked, check.Equals, false)
+		sum := sha256.Sum256([]byte("plain data"))
+		c.Check(m.Items[1].SHA256, check.Equals, hex.EncodeToString(sum[:]))
+
+		destination := memtest.NewServer("destination", 4)
+		result, err := NewImporter(ImporterArgs{Server: destination}).Import(ctx, bytes.NewReader(buf.Bytes()))
+		c.Assert(err, check.IsNil)
+		c.Check(result.Imported, check.Equals, 3)
+		c.Check(result.Failed, check.Equals, 0)
+		c.Check(result.Manifest, check.DeepEquals, m)
+
+		c.Check(servertest.ReadItem(c, destination, "dir", "plain"), check.Equals, "plain data")
+		c.Check(servertest.ReadItem(c, destination, "dir", "chunked"), check.Equals, "chunked data")
+		c.Check(servertest.ReadItem(c, destination, "other", "item"), check.Equals, "other data")
+		ok, chunked, _, _, meta, err := rsstorage.CheckWithMetadata(ctx, destination, "dir", "chunked")
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(chunked, check.NotNil)
+		c.Check(meta, check.DeepEquals, types.Metadata{types.MetadataContentType: "text/plain"})
+
+		read, err := ReadManifest(bytes.NewReader(buf.Bytes()))
+		c.Assert(err, check.IsNil)
+		c.Check(read, check.DeepEquals, m)
+	}
+}
+
+func (s *ArchiveSuite) TestExportPrefix(c *check.C) {
+	m, _ := export(c, ExporterArgs{Server: newSource(c), Prefix: "other/"})
+	c.Assert(m.Items, check.HasLen, 1)
+	c.Check(m.Items[0].Address, check.Equals, "item")
+}
+
+func (s *ArchiveSuite) TestExportIncremental(c *check.C) {
+	ctx := context.Background()
+	source := newSource(c)
+	full, _ := export(c, ExporterArgs{Server: source})
+	c.Check(full.Items, check.HasLen, 3)
+
+	time.Sleep(10 * time.Millisecond)
+	_, _, err := source.Put(ctx, servertest.StringResolver("new data"), "dir", "new")
+	c.Assert(err, check.IsNil)
+
+	m, buf := export(c, ExporterArgs{Server: source, Since: full.Started})
+	c.Check(m.Since, check.Equals, full.Started)
+	c.Assert(m.Items, check.HasLen, 1)
+	c.Check(m.Items[0].Address, check.Equals, "new")
+
+	// Incremental archives are imported on top of earlier ones
+	destination := memtest.NewServer("destination", 4)
+	result, err := NewImporter(ImporterArgs{Server: destination}).Import(ctx, buf)
+	c.Assert(err, check.IsNil)
+	c.Check(result.Imported, check.Equals, 1)
+	c.Check(servertest.ReadItem(c, destination, "dir", "new"), check.Equals, "new data")
+}
+
+func (s *ArchiveSuite) TestExportInvalidCompression(c *check.C) {
+	_, err := NewExporter(ExporterArgs{
+		Server:      newSource(c),
+		Compression: "lz4",
+	}).Export(context.Background(), io.Discard)
+	c.Check(err, check.ErrorMatches, `invalid compression "lz4"`)
+}
+
+func (s *ArchiveSuite) TestVerify(c *check.C) {
+	ctx := context.Background()
+	_, buf := export(c, ExporterArgs{Server: newSource(c)})
+
+	result, err := NewImporter(ImporterArgs{VerifyOnly: true}).Import(ctx, bytes.NewReader(buf.Bytes()))
+	c.Assert(err, check.IsNil)
+	c.Check(result.Imported, check.Equals, 3)
+	c.Check(result.Failed, check.Equals, 0)
+
+	// Corrupt an item
+	corrupt := bytes.Replace(buf.Bytes(), []byte("plain data"), []byte("PLAIN DATA"), 1)
+	result, err = NewImporter(ImporterArgs{VerifyOnly: true}).Import(ctx, bytes.NewReader(corrupt))
+	c.Check(err, check.ErrorMatches, "dir=dir and address=plain does not match the manifest")
+	c.Check(result.Imported, check.Equals, 2)
+	c.Check(result.Failed, check.Equals, 1)
+
+	// Corrupt items are removed after they are imported
+	destination := memtest.NewServer("destination", 4)
+	result, err = NewImporter(ImporterArgs{Server: destination}).Import(ctx, bytes.NewReader(corrupt))
+	c.Check(err, check.ErrorMatches, "dir=dir and address=plain does not match the manifest")
+	c.Check(result.Failed, check.Equals, 1)
+	ok, _, _, _, err := destination.Check(ctx, "dir", "plain")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(servertest.ReadItem(c, destination, "other", "item"), check.Equals, "other data")
+}
+
+func (s *ArchiveSuite) TestImportErrors(c *check.C) {
+	ctx := context.Background()
+	importer := NewImporter(ImporterArgs{VerifyOnly: true})
+
+	// Without a manifest
+	_, err := importer.Import(ctx, bytes.NewReader(nil))
+	c.Check(err, check.ErrorMatches, "the archive has no manifest")
+	_, err = ReadManifest(bytes.NewReader(nil))
+	c.Check(err, check.ErrorMatches, "the archive has no manifest")
+
+	// Not an archive
+	_, err = importer.Import(ctx, strings.NewReader(strings.Repeat("not a tar archive", 100)))
+	c.Check(err, check.ErrorMatches, "error reading archive: .*")
+}
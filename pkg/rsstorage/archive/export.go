@@ -0,0 +1,199 @@
+package archive
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Exporter writes the items in a storage server to a tar archive. Each item
+// is an entry of the archive, and a manifest of the items with their sizes
+// and SHA-256 checksums is the last entry. Chunked items are recorded as
+// chunked, so they are imported as chunked. Chunked items that are still
+// being written are not exported.
+type Exporter struct {
+	server      rsstorage.StorageServer
+	prefix      string
+	since       time.Time
+	compression Compression
+	now         func() time.Time
+}
+
+type ExporterArgs struct {
+	Server rsstorage.StorageServer
+
+	// Prefix limits the export to the items with paths that start with it.
+	// See `types.EnumerateOptions`.
+	Prefix string
+
+	// Since makes the export incremental. Only the items modified at or after
+	// it are exported. Use the `Started` time of the last export's manifest.
+	// Items removed since the last export are not recorded.
+	Since time.Time
+
+	// Compression defaults to `CompressionNone`.
+	Compression Compression
+}
+
+func NewExporter(args ExporterArgs) *Exporter {
+	e := &Exporter{
+		server:      args.Server,
+		prefix:      args.Prefix,
+		since:       args.Since,
+		compression: args.Compression,
+		now:         time.Now,
+	}
+	if e.compression == "" {
+		e.compression = CompressionNone
+	}
+	return e
+}
+
+// Export writes the archive and returns its manifest. Since an archive is a
+// stream, any error ends the export, and the archive is incomplete.
+func (e *Exporter) Export(ctx context.Context, w io.Writer) (*Manifest, error) {
+	m := &Manifest{
+		Version: ManifestVersion,
+		Started: e.now().UTC(),
+		Since:   e.since,
+		Items:   make([]ManifestItem, 0),
+	}
+
+	var zw *zstd.Encoder
+	switch e.compression {
+	case CompressionNone:
+	case CompressionZstd:
+		var err error
+		if zw, err = zstd.NewWriter(w); err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("invalid compression %q", e.compression)
+	}
+
+	tw := tar.NewWriter(w)
+	opts := types.EnumerateOptions{Prefix: e.prefix}
+	for item, err := range rsstorage.EnumerateItems(ctx, e.server, opts) {
+		if err != nil {
+			return nil, fmt.Errorf("error enumerating items: %w", err)
+		}
+		if !e.since.IsZero() && item.ModTime.Before(e.since) {
+			continue
+		}
+		mi, ok, err := e.exportItem(ctx, tw, item)
+		if err != nil {
+			return nil, fmt.Errorf("error exporting dir=%s and address=%s: %w", item.Dir, item.Address, err)
+		} else if ok {
+			m.Items = append(m.Items, mi)
+		}
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ManifestName,
+		Size:     int64(len(b)),
+		Mode:     0644,
+		ModTime:  m.Started,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tw.Write(b); err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// exportItem writes an item to the archive. It returns false if the item
+// was removed since it was enumerated, or is a chunked item still being
+// written.
+func (e *Exporter) exportItem(ctx context.Context, tw *tar.Writer, item types.StoredItem) (ManifestItem, bool, error) {
+	ok, chunked, _, _, err := e.server.Check(ctx, item.Dir, item.Address)
+	if err != nil {
+		return ManifestItem{}, false, err
+	} else if !ok {
+		return ManifestItem{}, false, nil
+	} else if chunked != nil && !chunked.Complete {
+		slog.Debug("Skipping incomplete chunked item", "dir", item.Dir, "address", item.Address)
+		return ManifestItem{}, false, nil
+	}
+
+	r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, e.server, item.Dir, item.Address)
+	if err != nil {
+		return ManifestItem{}, false, err
+	} else if !ok {
+		return ManifestItem{}, false, nil
+	}
+	defer r.Close()
+
+	mi := ManifestItem{
+		Dir:      item.Dir,
+		Address:  item.Address,
+		Chunked:  chunked != nil,
+		Size:     sz,
+		ModTime:  mod.UTC(),
+		Metadata: meta,
+	}
+	records := map[string]string{
+		paxDir:     mi.Dir,
+		paxAddress: mi.Address,
+		paxChunked: strconv.FormatBool(mi.Chunked),
+	}
+	if len(meta) > 0 {
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return ManifestItem{}, false, err
+		}
+		records[paxMetadata] = string(b)
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       ItemsPrefix + mi.path(),
+		Size:       sz,
+		Mode:       0644,
+		ModTime:    mi.ModTime,
+		Format:     tar.FormatPAX,
+		PAXRecords: records,
+	})
+	if err != nil {
+		return ManifestItem{}, false, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tw, h), r)
+	if err != nil {
+		return ManifestItem{}, false, err
+	} else if n != sz {
+		return ManifestItem{}, false, fmt.Errorf("read %d bytes, expected %d", n, sz)
+	}
+	mi.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return mi, true, nil
+}
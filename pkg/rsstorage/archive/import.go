@@ -0,0 +1,195 @@
+package archive
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Importer restores the items in an archive written by `Exporter` into a
+// storage server of any type. Compressed archives are detected. Chunked
+// items are written with `PutChunked`, and the other items with `Put`.
+// Metadata is restored when the server implements
+// `rsstorage.ObjectMetadataServer`.
+type Importer struct {
+	server     rsstorage.StorageServer
+	verifyOnly bool
+}
+
+type ImporterArgs struct {
+	// Server is not used when `VerifyOnly` is set.
+	Server rsstorage.StorageServer
+
+	// VerifyOnly reads the archive and verifies its items against the
+	// manifest without writing them.
+	VerifyOnly bool
+}
+
+func NewImporter(args ImporterArgs) *Importer {
+	return &Importer{
+		server:     args.Server,
+		verifyOnly: args.VerifyOnly,
+	}
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	Manifest *Manifest
+
+	// Imported is the number of items written to the server. In
+	// verification-only mode, it is the number of items verified.
+	Imported int
+
+	// Failed is the number of items that did not match the manifest. The
+	// errors are returned by `Import`.
+	Failed int
+}
+
+// written records an item read from the archive.
+type written struct {
+	size   int64
+	sha256 string
+}
+
+// Import reads the archive and writes its items to the server. Since the
+// manifest is the last entry of the archive, items are verified once they
+// are all written, and items that do not match the manifest are then
+// removed from the server. Errors for individual items are joined and
+// returned with the result.
+func (i *Importer) Import(ctx context.Context, r io.Reader) (ImportResult, error) {
+	tr, closer, err := newTarReader(r)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer closer()
+
+	var m *Manifest
+	items := make(map[string]written)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return ImportResult{}, fmt.Errorf("error reading archive: %w", err)
+		}
+		if err = ctx.Err(); err != nil {
+			return ImportResult{}, err
+		}
+
+		if hdr.Name == ManifestName {
+			if m, err = decodeManifest(tr); err != nil {
+				return ImportResult{}, err
+			}
+			continue
+		} else if !strings.HasPrefix(hdr.Name, ItemsPrefix) {
+			continue
+		}
+
+		item := types.StoredItem{
+			Dir:     hdr.PAXRecords[paxDir],
+			Address: hdr.PAXRecords[paxAddress],
+		}
+		w, err := i.importItem(ctx, tr, hdr, item)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("error importing dir=%s and address=%s: %w", item.Dir, item.Address, err)
+		}
+		items[item.Path()] = w
+	}
+	if m == nil {
+		return ImportResult{}, errors.New("the archive has no manifest")
+	}
+
+	result := ImportResult{Manifest: m}
+	var errs []error
+	for _, mi := range m.Items {
+		p := mi.path()
+		w, ok := items[p]
+		delete(items, p)
+		if ok && w.size == mi.Size && w.sha256 == mi.SHA256 {
+			result.Imported++
+			continue
+		}
+
+		result.Failed++
+		if !ok {
+			errs = append(errs, fmt.Errorf("dir=%s and address=%s is missing from the archive", mi.Dir, mi.Address))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("dir=%s and address=%s does not match the manifest", mi.Dir, mi.Address))
+		if !i.verifyOnly {
+			errs = append(errs, i.server.Remove(ctx, mi.Dir, mi.Address))
+		}
+	}
+	for p := range items {
+		result.Failed++
+		errs = append(errs, fmt.Errorf("%s is not in the manifest", p))
+	}
+	return result, errors.Join(errs...)
+}
+
+// importItem writes an item to the server, or only reads it in
+// verification-only mode, and returns its size and checksum.
+func (i *Importer) importItem(ctx context.Context, r io.Reader, hdr *tar.Header, item types.StoredItem) (written, error) {
+	if item.Address == "" {
+		return written{}, fmt.Errorf("entry %s has no address", hdr.Name)
+	}
+	chunked, err := strconv.ParseBool(hdr.PAXRecords[paxChunked])
+	if err != nil {
+		return written{}, fmt.Errorf("entry %s has an invalid chunked record: %w", hdr.Name, err)
+	}
+	var meta types.Metadata
+	if b, ok := hdr.PAXRecords[paxMetadata]; ok {
+		if err = json.Unmarshal([]byte(b), &meta); err != nil {
+			return written{}, fmt.Errorf("entry %s has invalid metadata: %w", hdr.Name, err)
+		}
+	}
+
+	// The entry is hashed and counted as it is read. The limit is applied
+	// last so that the resolver does not read the entry again once it is
+	// consumed, since chunked writes may return before the resolver does.
+	h := sha256.New()
+	cr := &countingReader{r: io.TeeReader(r, h)}
+	entry := io.LimitReader(cr, hdr.Size)
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, entry)
+		return "", "", err
+	}
+
+	if i.verifyOnly {
+		_, _, err = resolve(io.Discard)
+	} else {
+		// Metadata is dropped when the server cannot store it
+		err = rsstorage.PutCopy(ctx, i.server, resolve, item.Dir, item.Address, chunked, uint64(hdr.Size), meta)
+	}
+	if err != nil {
+		return written{}, err
+	}
+	return written{
+		size:   cr.n,
+		sha256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
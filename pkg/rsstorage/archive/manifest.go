@@ -0,0 +1,135 @@
+package archive
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const (
+	// ManifestName is the name of the manifest, which is the last entry of
+	// an archive.
+	ManifestName = "manifest.json"
+
+	// ItemsPrefix prefixes the names of the entries holding items.
+	ItemsPrefix = "items/"
+
+	// ManifestVersion is the version of the manifests written by `Exporter`.
+	ManifestVersion = 1
+
+	// PAX records of item entries. The names of entries are only for humans
+	// reading an archive, since they cannot distinguish the dir from the
+	// address.
+	paxDir      = "RSSTORAGE.dir"
+	paxAddress  = "RSSTORAGE.address"
+	paxChunked  = "RSSTORAGE.chunked"
+	paxMetadata = "RSSTORAGE.metadata"
+)
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Compression is how an archive is compressed.
+type Compression string
+
+const (
+	// CompressionNone writes a tar archive.
+	CompressionNone Compression = "none"
+
+	// CompressionZstd writes a tar archive compressed with zstd.
+	CompressionZstd Compression = "zstd"
+)
+
+// Manifest lists the items in an archive.
+type Manifest struct {
+	Version int `json:"version"`
+
+	// Started is when the export started. Pass it as `Since` to the next
+	// export to write an incremental archive.
+	Started time.Time `json:"started"`
+
+	// Since is zero for full exports. Incremental exports only include the
+	// items modified at or after it.
+	Since time.Time `json:"since,omitzero"`
+
+	Items []ManifestItem `json:"items"`
+}
+
+// ManifestItem describes an item in an archive.
+type ManifestItem struct {
+	Dir      string         `json:"dir"`
+	Address  string         `json:"address"`
+	Chunked  bool           `json:"chunked,omitempty"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mod_time"`
+	SHA256   string         `json:"sha256"`
+	Metadata types.Metadata `json:"metadata,omitempty"`
+}
+
+func (i ManifestItem) path() string {
+	return types.StoredItem{Dir: i.Dir, Address: i.Address}.Path()
+}
+
+// ReadManifest reads an archive to its end and returns its manifest, e.g.,
+// to find when the last export started. Item checksums are not verified;
+// see `Importer` for verification.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	tr, closer, err := newTarReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the archive has no manifest")
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading archive: %w", err)
+		}
+		if hdr.Name == ManifestName {
+			return decodeManifest(tr)
+		}
+	}
+}
+
+func decodeManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %w", err)
+	}
+	if m.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// newTarReader returns a reader for a tar archive, which is decompressed
+// when it starts with the zstd signature. The returned function releases the
+// decompressor.
+func newTarReader(r io.Reader) (*tar.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("error reading archive: %w", err)
+	}
+	if !bytes.Equal(head, zstdMagic) {
+		return tar.NewReader(br), func() {}, nil
+	}
+
+	zr, err := zstd.NewReader(br)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading archive: %w", err)
+	}
+	return tar.NewReader(zr), zr.Close, nil
+}
@@ -0,0 +1,167 @@
+package chunknotify
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/broadcaster"
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listener"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const (
+	// DefaultNotifyType is the notification type used for chunk notifications
+	// when none is configured.
+	DefaultNotifyType = uint8(3)
+
+	// DefaultChannel is the channel on which chunk notifications are sent when
+	// none is configured.
+	DefaultChannel = "messages"
+
+	// DefaultPollInterval is the longest a waiter waits for a notification
+	// before returning so the caller checks for the chunk again.
+	DefaultPollInterval = time.Second
+)
+
+// ChunkNotification is sent when new chunks are available for an item stored
+// in chunks.
+type ChunkNotification struct {
+	listener.GenericNotification
+	Address string
+	Chunk   uint64
+}
+
+// NewChunkNotification returns a notification of the given type announcing
+// that a chunk is ready.
+func NewChunkNotification(notifyType uint8, address string, chunk uint64) *ChunkNotification {
+	return &ChunkNotification{
+		GenericNotification: listener.GenericNotification{
+			NotifyGuid: uuid.New().String(),
+			NotifyType: notifyType,
+		},
+		Address: address,
+		Chunk:   chunk,
+	}
+}
+
+// Register registers the `ChunkNotification` type with a matcher so
+// listeners can decode chunk notifications.
+func Register(matcher listener.TypeMatcher, notifyType uint8) {
+	matcher.Register(notifyType, &ChunkNotification{})
+}
+
+// Sender sends notifications. It is implemented by `notifier.Notifier`.
+type Sender interface {
+	Notify(ctx context.Context, channelName string, notification interface{}) error
+}
+
+type NotifierArgs struct {
+	Sender Sender
+
+	// Channel defaults to `DefaultChannel`.
+	Channel string
+
+	// NotifyType defaults to `DefaultNotifyType`.
+	NotifyType uint8
+}
+
+// Notifier implements `rsstorage.ChunkNotifier` by sending a
+// `ChunkNotification` for each chunk written.
+type Notifier struct {
+	sender     Sender
+	channel    string
+	notifyType uint8
+}
+
+func NewNotifier(args NotifierArgs) *Notifier {
+	if args.Channel == "" {
+		args.Channel = DefaultChannel
+	}
+	if args.NotifyType == 0 {
+		args.NotifyType = DefaultNotifyType
+	}
+	return &Notifier{
+		sender:     args.Sender,
+		channel:    args.Channel,
+		notifyType: args.NotifyType,
+	}
+}
+
+func (n *Notifier) Notify(ctx context.Context, c *types.ChunkNotification) error {
+	return n.sender.Notify(ctx, n.channel, NewChunkNotification(n.notifyType, c.Address, c.Chunk))
+}
+
+type WaiterArgs struct {
+	Broadcaster broadcaster.Broadcaster
+
+	// NotifyType defaults to `DefaultNotifyType`.
+	NotifyType uint8
+
+	// PollInterval defaults to `DefaultPollInterval`. Notifications can be
+	// missed, e.g., when a chunk is written just before the waiter subscribes,
+	// so the waiter never waits longer than this interval. The storage server
+	// then looks for the chunk again.
+	PollInterval time.Duration
+}
+
+// Waiter implements `rsstorage.ChunkWaiter` by subscribing to the
+// `ChunkNotification` for the awaited chunk.
+type Waiter struct {
+	broadcaster  broadcaster.Broadcaster
+	notifyType   uint8
+	pollInterval time.Duration
+}
+
+func NewWaiter(args WaiterArgs) *Waiter {
+	if args.NotifyType == 0 {
+		args.NotifyType = DefaultNotifyType
+	}
+	if args.PollInterval == 0 {
+		args.PollInterval = DefaultPollInterval
+	}
+	return &Waiter{
+		broadcaster:  args.Broadcaster,
+		notifyType:   args.NotifyType,
+		pollInterval: args.PollInterval,
+	}
+}
+
+// WaitForChunk returns when the chunk is ready, when the context is done, or
+// after the shorter of the poll interval and the notification's timeout.
+func (w *Waiter) WaitForChunk(ctx context.Context, c *types.ChunkNotification) {
+	wait := w.pollInterval
+	if c.Timeout > 0 {
+		wait = min(wait, c.Timeout)
+	}
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	chunks := w.broadcaster.SubscribeOne(w.notifyType, func(n listener.Notification) bool {
+		if cn, ok := n.(*ChunkNotification); ok {
+			return cn.Address == c.Address && cn.Chunk >= c.Chunk
+		}
+		return false
+	})
+	defer w.broadcaster.Unsubscribe(chunks)
+
+	select {
+	case <-chunks:
+	case <-timeout.C:
+	case <-ctx.Done():
+	}
+}
+
+// Matcher implements `queue.DatabaseQueueChunkMatcher`, matching chunk
+// notifications for an address.
+type Matcher struct{}
+
+func (m *Matcher) Match(n listener.Notification, address string) bool {
+	if cn, ok := n.(*ChunkNotification); ok {
+		return cn.Address == address
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package chunknotify
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/broadcaster"
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listener"
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/notifier"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ChunkNotifySuite struct{}
+
+var _ = check.Suite(&ChunkNotifySuite{})
+
+type fakeListener struct {
+	items chan listener.Notification
+	errs  chan error
+}
+
+func (f *fakeListener) Listen() (chan listener.Notification, chan error, error) {
+	return f.items, f.errs, nil
+}
+
+func (f *fakeListener) Stop() {}
+
+func (f *fakeListener) IP() string {
+	return ""
+}
+
+// fakeSender delivers notifications directly to a listener.
+type fakeSender struct {
+	channel string
+	items   chan listener.Notification
+}
+
+func (f *fakeSender) Notify(ctx context.Context, channelName string, n interface{}) error {
+	f.channel = channelName
+	f.items <- n.(listener.Notification)
+	return nil
+}
+
+type fakeProvider struct {
+	channel string
+	msg     []byte
+}
+
+func (f *fakeProvider) Notify(ctx context.Context, channelName string, msg []byte) error {
+	f.channel = channelName
+	f.msg = msg
+	return nil
+}
+
+func newBroadcaster(c *check.C) (*broadcaster.NotificationBroadcaster, chan listener.Notification) {
+	l := &fakeListener{
+		items: make(chan listener.Notification, listener.MaxChannelSize),
+		errs:  make(chan error),
+	}
+	b, err := broadcaster.NewNotificationBroadcaster(l, make(chan bool))
+	c.Assert(err, check.IsNil)
+	return b, l.items
+}
+
+func (s *ChunkNotifySuite) TestRegister(c *check.C) {
+	m := listener.NewMatcher("NotifyType")
+	Register(m, DefaultNotifyType)
+	t, err := m.Type(DefaultNotifyType)
+	c.Assert(err, check.IsNil)
+	c.Check(t, check.FitsTypeOf, &ChunkNotification{})
+}
+
+func (s *ChunkNotifySuite) TestNotifier(c *check.C) {
+	provider := &fakeProvider{}
+	n := NewNotifier(NotifierArgs{
+		Sender: notifier.NewNotifier(notifier.Args{Provider: provider}),
+	})
+	err := n.Notify(context.Background(), &types.ChunkNotification{Address: "abc", Chunk: 2})
+	c.Assert(err, check.IsNil)
+	c.Check(provider.channel, check.Equals, DefaultChannel)
+
+	var cn ChunkNotification
+	c.Assert(json.Unmarshal(provider.msg, &cn), check.IsNil)
+	c.Check(cn.NotifyType, check.Equals, DefaultNotifyType)
+	c.Check(cn.NotifyGuid, check.Not(check.Equals), "")
+	c.Check(cn.Address, check.Equals, "abc")
+	c.Check(cn.Chunk, check.Equals, uint64(2))
+
+	n = NewNotifier(NotifierArgs{
+		Sender:     notifier.NewNotifier(notifier.Args{Provider: provider}),
+		Channel:    "chunks",
+		NotifyType: 9,
+	})
+	err = n.Notify(context.Background(), &types.ChunkNotification{Address: "abc", Chunk: 2})
+	c.Assert(err, check.IsNil)
+	c.Check(provider.channel, check.Equals, "chunks")
+	c.Assert(json.Unmarshal(provider.msg, &cn), check.IsNil)
+	c.Check(cn.NotifyType, check.Equals, uint8(9))
+}
+
+func (s *ChunkNotifySuite) TestWaitForChunk(c *check.C) {
+	b, items := newBroadcaster(c)
+	sender := &fakeSender{items: items}
+	n := NewNotifier(NotifierArgs{Sender: sender})
+	w := NewWaiter(WaiterArgs{Broadcaster: b, PollInterval: time.Minute})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WaitForChunk(context.Background(), &types.ChunkNotification{
+			Address: "abc",
+			Chunk:   2,
+			Timeout: time.Minute,
+		})
+	}()
+
+	// Notifications for other addresses and earlier chunks are ignored. Keep
+	// notifying since the waiter may not have subscribed yet.
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	ignored := time.After(50 * time.Millisecond)
+	for ignoring := true; ignoring; {
+		c.Assert(n.Notify(context.Background(), &types.ChunkNotification{Address: "def", Chunk: 3}), check.IsNil)
+		c.Assert(n.Notify(context.Background(), &types.ChunkNotification{Address: "abc", Chunk: 1}), check.IsNil)
+		select {
+		case <-done:
+			c.Fatal("waiter returned for the wrong chunk")
+		case <-ignored:
+			ignoring = false
+		case <-tick.C:
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		c.Assert(n.Notify(context.Background(), &types.ChunkNotification{Address: "abc", Chunk: 3}), check.IsNil)
+		select {
+		case <-done:
+			c.Check(sender.channel, check.Equals, DefaultChannel)
+			return
+		case <-timeout:
+			c.Fatal("timed out waiting for the waiter")
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *ChunkNotifySuite) TestWaitForChunkPolls(c *check.C) {
+	b, _ := newBroadcaster(c)
+	w := NewWaiter(WaiterArgs{Broadcaster: b, PollInterval: 10 * time.Millisecond})
+
+	// Without a notification, the waiter returns after the poll interval
+	start := time.Now()
+	w.WaitForChunk(context.Background(), &types.ChunkNotification{
+		Address: "abc",
+		Timeout: time.Minute,
+	})
+	c.Check(time.Since(start) < time.Minute, check.Equals, true)
+
+	// Or after the timeout, when it is shorter
+	w = NewWaiter(WaiterArgs{Broadcaster: b, PollInterval: time.Minute})
+	start = time.Now()
+	w.WaitForChunk(context.Background(), &types.ChunkNotification{
+		Address: "abc",
+		Timeout: 10 * time.Millisecond,
+	})
+	c.Check(time.Since(start) < time.Minute, check.Equals, true)
+
+	// Or when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.WaitForChunk(ctx, &types.ChunkNotification{Address: "abc", Timeout: time.Minute})
+}
+
+func (s *ChunkNotifySuite) TestMatcher(c *check.C) {
+	m := &Matcher{}
+	c.Check(m.Match(NewChunkNotification(DefaultNotifyType, "abc", 1), "abc"), check.Equals, true)
+	c.Check(m.Match(NewChunkNotification(DefaultNotifyType, "abc", 1), "def"), check.Equals, false)
+	c.Check(m.Match(&listener.GenericNotification{NotifyType: DefaultNotifyType}, "abc"), check.Equals, false)
+}
@@ -14,6 +14,7 @@ import (
 var (
 	ErrNoChunkMetadata = errors.New("metadata not found for chunked asset")
 	ErrNoChunk         = errors.New("chunk not found for chunked asset")
+	ErrInvalidRange    = errors.New("requested range is not satisfiable")
 )
 
 const (
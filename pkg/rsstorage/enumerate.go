@@ -0,0 +1,64 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// ItemEnumerator is implemented by storage servers that can stream their
+// items without listing them all in memory first.
+type ItemEnumerator interface {
+	// EnumerateItems streams the items in storage with their size and
+	// modification time. Items are listed in an order specific to the
+	// server, and each item has a `Token` to resume the enumeration after
+	// it. An error ends the enumeration.
+	EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error]
+}
+
+// EnumerateItems streams the items of a storage server. Servers that do not
+// implement `ItemEnumerator` are listed with `Enumerate`, and each item is
+// checked for its size and modification time. The token of an item listed
+// this way is its path.
+func EnumerateItems(ctx context.Context, server StorageServer, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	if e, ok := server.(ItemEnumerator); ok {
+		return e.EnumerateItems(ctx, opts)
+	}
+
+	return func(yield func(types.StoredItem, error) bool) {
+		items, err := server.Enumerate(ctx)
+		if err != nil {
+			yield(types.StoredItem{}, err)
+			return
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Path() < items[j].Path()
+		})
+
+		for _, item := range items {
+			p := item.Path()
+			if !strings.HasPrefix(p, opts.Prefix) || p <= opts.After {
+				continue
+			}
+			ok, _, sz, mod, err := server.Check(ctx, item.Dir, item.Address)
+			if err != nil {
+				yield(types.StoredItem{}, err)
+				return
+			} else if !ok {
+				// Removed since it was enumerated
+				continue
+			}
+			item.Size = sz
+			item.ModTime = mod
+			item.Token = p
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
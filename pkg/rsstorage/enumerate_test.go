@@ -0,0 +1,65 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type EnumerateSuite struct{}
+
+var _ = check.Suite(&EnumerateSuite{})
+
+func (s *EnumerateSuite) TestEnumerateItems(c *check.C) {
+	ctx := context.Background()
+	mod := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := &DummyStorageServer{
+		EnumItems: []types.StoredItem{
+			{Dir: "dir", Address: "b"},
+			{Dir: "dir", Address: "a", Chunked: true},
+			{Address: "c"},
+			{Dir: "other", Address: "gone"},
+		},
+		GetMap: map[string]GetResult{
+			"a": {GetOk: true, GetChunked: &types.ChunksInfo{}, GetSize: 10, GetModTime: mod},
+			"b": {GetOk: true, GetSize: 20, GetModTime: mod},
+			"c": {GetOk: true, GetSize: 30, GetModTime: mod},
+		},
+	}
+
+	list := func(opts types.EnumerateOptions) []types.StoredItem {
+		items := make([]types.StoredItem, 0)
+		for item, err := range EnumerateItems(ctx, server, opts) {
+			c.Assert(err, check.IsNil)
+			items = append(items, item)
+		}
+		return items
+	}
+
+	// Sorted by path, and items that have been removed are skipped
+	items := list(types.EnumerateOptions{})
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Address: "c", Size: 30, ModTime: mod, Token: "c"},
+		{Dir: "dir", Address: "a", Chunked: true, Size: 10, ModTime: mod, Token: "dir/a"},
+		{Dir: "dir", Address: "b", Size: 20, ModTime: mod, Token: "dir/b"},
+	})
+	c.Check(list(types.EnumerateOptions{After: "dir/a"}), check.DeepEquals, items[2:])
+	c.Check(list(types.EnumerateOptions{Prefix: "dir/"}), check.DeepEquals, items[1:])
+
+	// Errors
+	server.EnumErr = errors.New("enumerate error")
+	for _, err := range EnumerateItems(ctx, server, types.EnumerateOptions{}) {
+		c.Check(err, check.ErrorMatches, "enumerate error")
+	}
+	server.EnumErr = nil
+	server.GetMap["a"] = GetResult{GetErr: errors.New("check error")}
+	for _, err := range EnumerateItems(ctx, server, types.EnumerateOptions{Prefix: "dir/"}) {
+		c.Check(err, check.ErrorMatches, "check error")
+	}
+}
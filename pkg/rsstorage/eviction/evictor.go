@@ -0,0 +1,241 @@
+package eviction
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+)
+
+const (
+	DefaultHighWatermark = 0.9
+	DefaultLowWatermark  = 0.8
+
+	// The number of objects listed from the store at a time
+	pageSize = 100
+)
+
+// InFlightChecker reports whether work for an address is in a queue. It is
+// satisfied by `queue.Queue` in `pkg/rsqueue`.
+type InFlightChecker interface {
+	IsAddressInQueue(ctx context.Context, address string) (bool, error)
+}
+
+// Evictor removes the least recently used objects from a storage server when
+// its usage exceeds a budget. Access times are those recorded in an
+// `rsstorage.EvictionStore` by a `rsstorage.MetadataStorageServer` with the
+// same name.
+type Evictor struct {
+	name          string
+	server        rsstorage.StorageServer
+	store         rsstorage.EvictionStore
+	queue         InFlightChecker
+	queueAddress  func(dir, address string) string
+	maxBytes      datasize.ByteSize
+	highWatermark float64
+	lowWatermark  float64
+}
+
+type EvictorArgs struct {
+	// Name is the cache name used by the `MetadataStorageServer`.
+	Name string
+
+	// Server is the storage server to evict objects from. This is usually
+	// the server wrapped by the `MetadataStorageServer`.
+	Server rsstorage.StorageServer
+
+	Store rsstorage.EvictionStore
+
+	// Queue is optional. When set, objects with work in the queue are never
+	// evicted. QueueAddress maps an object to the address of its work, and
+	// defaults to the object's address.
+	Queue        InFlightChecker
+	QueueAddress func(dir, address string) string
+
+	// MaxBytes is the budget. Defaults to the size reported by the server's
+	// `CalculateUsage`.
+	MaxBytes datasize.ByteSize
+
+	// Objects are evicted when usage exceeds HighWatermark of the budget,
+	// until usage drops below LowWatermark of the budget. Default to
+	// `DefaultHighWatermark` and `DefaultLowWatermark`.
+	HighWatermark float64
+	LowWatermark  float64
+}
+
+func NewEvictor(args EvictorArgs) *Evictor {
+	e := &Evictor{
+		name:          args.Name,
+		server:        args.Server,
+		store:         args.Store,
+		queue:         args.Queue,
+		queueAddress:  args.QueueAddress,
+		maxBytes:      args.MaxBytes,
+		highWatermark: args.HighWatermark,
+		lowWatermark:  args.LowWatermark,
+	}
+	if e.queueAddress == nil {
+		e.queueAddress = func(dir, address string) string {
+			return address
+		}
+	}
+	if e.highWatermark == 0 {
+		e.highWatermark = DefaultHighWatermark
+	}
+	if e.lowWatermark == 0 {
+		e.lowWatermark = DefaultLowWatermark
+	}
+	return e
+}
+
+// EvictResult summarizes an eviction.
+type EvictResult struct {
+	// UsedBytes is the usage before eviction, and Budget is the budget it
+	// was compared with.
+	UsedBytes datasize.ByteSize
+	Budget    datasize.ByteSize
+
+	// Checked is the number of objects considered for eviction.
+	Checked int
+
+	// Evicted is the number of objects removed, and EvictedBytes is their
+	// total size.
+	Evicted      int
+	EvictedBytes datasize.ByteSize
+
+	// InFlight is the number of objects that were kept because they have
+	// work in the queue.
+	InFlight int
+}
+
+// Evict removes the least recently used objects if usage exceeds the high
+// watermark, until usage drops below the low watermark. Usage is calculated
+// once, and reduced by the size of each object removed.
+func (e *Evictor) Evict(ctx context.Context) (EvictResult, error) {
+	result := EvictResult{}
+
+	usage, err := e.server.CalculateUsage()
+	if err != nil {
+		return result, err
+	}
+	result.UsedBytes = usage.UsedBytes
+	result.Budget = e.maxBytes
+	if result.Budget == 0 {
+		result.Budget = usage.SizeBytes
+	}
+	if result.Budget == 0 {
+		slog.Debug("No budget for eviction", "name", e.name)
+		return result, nil
+	}
+
+	high := datasize.ByteSize(float64(result.Budget) * e.highWatermark)
+	low := datasize.ByteSize(float64(result.Budget) * e.lowWatermark)
+	if usage.UsedBytes <= high {
+		return result, nil
+	}
+
+	used := usage.UsedBytes
+	offset := 0
+	for used > low {
+		objects, err := e.store.CacheObjectsLeastRecentlyUsed(e.name, offset, pageSize)
+		if err != nil {
+			return result, err
+		} else if len(objects) == 0 {
+			break
+		}
+
+		for _, object := range objects {
+			if used <= low {
+				break
+			}
+			if err = ctx.Err(); err != nil {
+				return result, err
+			}
+			result.Checked++
+			sz, evicted, err := e.evict(ctx, object.Key)
+			if err != nil {
+				return result, fmt.Errorf("error evicting %s: %w", object.Key, err)
+			} else if !evicted {
+				// Kept, so it is listed again by the next query
+				result.InFlight++
+				offset++
+				continue
+			}
+			result.Evicted++
+			result.EvictedBytes += sz
+			used -= min(sz, used)
+		}
+	}
+
+	if used > low {
+		slog.Warn("Unable to evict enough objects to reach the low watermark", "name", e.name, "used", used, "low", low)
+	}
+	return result, nil
+}
+
+// evict removes an object unless it has work in the queue. Returns the size
+// of the object and whether it was removed.
+func (e *Evictor) evict(ctx context.Context, key string) (datasize.ByteSize, bool, error) {
+	dir, address := splitKey(key)
+
+	if e.queue != nil {
+		inFlight, err := e.queue.IsAddressInQueue(ctx, e.queueAddress(dir, address))
+		if err != nil {
+			return 0, false, err
+		} else if inFlight {
+			return 0, false, nil
+		}
+	}
+
+	ok, _, sz, _, err := e.server.Check(ctx, dir, address)
+	if err != nil {
+		return 0, false, err
+	}
+	if ok {
+		err = e.server.Remove(ctx, dir, address)
+		if err != nil {
+			return 0, false, err
+		}
+		slog.Debug("Evicted object", "name", e.name, "dir", dir, "address", address, "size", sz)
+	}
+
+	// Remove the record even if the object was already gone
+	err = e.store.CacheObjectRemove(e.name, key)
+	if err != nil {
+		return 0, false, err
+	}
+	return datasize.ByteSize(sz), true, nil
+}
+
+// splitKey splits a key recorded by `MetadataStorageServer` into its dir and
+// address.
+func splitKey(key string) (string, string) {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// NewEvictTask returns a scheduled task that evicts objects.
+func NewEvictTask(name string, evictor *Evictor, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := evictor.Evict(ctx)
+		slog.Debug("evicted objects",
+			"task", name,
+			"used", result.UsedBytes,
+			"budget", result.Budget,
+			"checked", result.Checked,
+			"evicted", result.Evicted,
+			"evicted_bytes", result.EvictedBytes,
+			"in_flight", result.InFlight)
+		return err
+	})
+}
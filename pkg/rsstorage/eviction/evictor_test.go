@@ -0,0 +1,256 @@
+package eviction
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type EvictorSuite struct{}
+
+var _ = check.Suite(&EvictorSuite{})
+
+type fakeStore struct {
+	objects   map[string]time.Time
+	listErr   error
+	removeErr error
+}
+
+func (s *fakeStore) CacheObjectEnsureExists(cacheName, key string) error {
+	if _, ok := s.objects[key]; !ok {
+		s.objects[key] = time.Now()
+	}
+	return nil
+}
+
+func (s *fakeStore) CacheObjectMarkUse(cacheName, key string, accessTime time.Time) error {
+	s.objects[key] = accessTime
+	return nil
+}
+
+func (s *fakeStore) CacheObjectsLeastRecentlyUsed(cacheName string, offset, limit int) ([]rsstorage.CacheObject, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	objects := make([]rsstorage.CacheObject, 0)
+	for key, accessTime := range s.objects {
+		objects = append(objects, rsstorage.CacheObject{Key: key, AccessTime: accessTime})
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].AccessTime.Before(objects[j].AccessTime)
+	})
+	objects = objects[min(offset, len(objects)):]
+	return objects[:min(limit, len(objects))], nil
+}
+
+func (s *fakeStore) CacheObjectRemove(cacheName, key string) error {
+	if s.removeErr != nil {
+		return s.removeErr
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+type fakeQueue struct {
+	addresses map[string]bool
+}
+
+func (q *fakeQueue) IsAddressInQueue(ctx context.Context, address string) (bool, error) {
+	return q.addresses[address], nil
+}
+
+func newMemoryServer(maxBytes datasize.ByteSize) rsstorage.StorageServer {
+	return memtest.New(memory.StorageServerArgs{
+		ChunkSize: 4096,
+		Class:     "eviction",
+		MaxBytes:  maxBytes,
+	})
+}
+
+func sizedResolver(n int) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewReader(make([]byte, n)))
+		return "", "", err
+	}
+}
+
+// populate writes ten 100 byte objects through a `MetadataStorageServer`,
+// and records their use in order, so "dir/0" is the least recently used.
+func populate(c *check.C, server rsstorage.StorageServer, store *fakeStore) {
+	ctx := context.Background()
+	meta := rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
+		Name:   "cache",
+		Server: server,
+		Store:  store,
+	})
+	start := time.Now()
+	for i := range 10 {
+		address := string(rune('0' + i))
+		_, _, err := meta.Put(ctx, sizedResolver(100), "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(store.CacheObjectMarkUse("cache", "dir/"+address, start.Add(time.Duration(i)*time.Second)), check.IsNil)
+	}
+}
+
+func (s *EvictorSuite) TestNew(c *check.C) {
+	e := NewEvictor(EvictorArgs{Name: "cache"})
+	c.Check(e.highWatermark, check.Equals, DefaultHighWatermark)
+	c.Check(e.lowWatermark, check.Equals, DefaultLowWatermark)
+	c.Check(e.queueAddress("dir", "address"), check.Equals, "address")
+}
+
+func (s *EvictorSuite) TestEvict(c *check.C) {
+	ctx := context.Background()
+	server := newMemoryServer(1000)
+	store := &fakeStore{objects: make(map[string]time.Time)}
+	populate(c, server, store)
+
+	// Below the high watermark
+	e := NewEvictor(EvictorArgs{
+		Name:          "cache",
+		Server:        server,
+		Store:         store,
+		HighWatermark: 1,
+		LowWatermark:  0.5,
+	})
+	result, err := e.Evict(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, EvictResult{UsedBytes: 1000, Budget: 1000})
+
+	// Evicts the least recently used objects down to the low watermark
+	e.highWatermark = 0.9
+	result, err = e.Evict(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, EvictResult{
+		UsedBytes:    1000,
+		Budget:       1000,
+		Checked:      5,
+		Evicted:      5,
+		EvictedBytes: 500,
+	})
+	for i := range 10 {
+		address := string(rune('0' + i))
+		c.Check(servertest.Exists(c, server, "dir", address), check.Equals, i >= 5, check.Commentf("address %s", address))
+		_, recorded := store.objects["dir/"+address]
+		c.Check(recorded, check.Equals, i >= 5)
+	}
+}
+
+func (s *EvictorSuite) TestEvictInFlight(c *check.C) {
+	ctx := context.Background()
+	server := newMemoryServer(0)
+	store := &fakeStore{objects: make(map[string]time.Time)}
+	populate(c, server, store)
+
+	// The budget is from the server by default
+	e := NewEvictor(EvictorArgs{Name: "cache", Server: server, Store: store})
+	result, err := e.Evict(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, EvictResult{UsedBytes: 1000})
+
+	// Objects with work in the queue are kept
+	e = NewEvictor(EvictorArgs{
+		Name:     "cache",
+		Server:   server,
+		Store:    store,
+		MaxBytes: 1000,
+		Queue:    &fakeQueue{addresses: map[string]bool{"work-0": true, "work-2": true}},
+		QueueAddress: func(dir, address string) string {
+			return "work-" + address
+		},
+		HighWatermark: 0.9,
+		LowWatermark:  0.75,
+	})
+	result, err = e.Evict(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, EvictResult{
+		UsedBytes:    1000,
+		Budget:       1000,
+		Checked:      5,
+		Evicted:      3,
+		EvictedBytes: 300,
+		InFlight:     2,
+	})
+	for i, kept := range []bool{true, false, true, false, false, true} {
+		c.Check(servertest.Exists(c, server, "dir", string(rune('0'+i))), check.Equals, kept)
+	}
+}
+
+func (s *EvictorSuite) TestEvictErrors(c *check.C) {
+	ctx := context.Background()
+	server := newMemoryServer(1000)
+	store := &fakeStore{objects: make(map[string]time.Time)}
+	populate(c, server, store)
+	e := NewEvictor(EvictorArgs{
+		Name:          "cache",
+		Server:        server,
+		Store:         store,
+		HighWatermark: 0.5,
+		LowWatermark:  0.5,
+	})
+
+	store.listErr = errors.New("list error")
+	_, err := e.Evict(ctx)
+	c.Check(err, check.ErrorMatches, "list error")
+
+	store.listErr = nil
+	store.removeErr = errors.New("remove error")
+	result, err := e.Evict(ctx)
+	c.Check(err, check.ErrorMatches, "error evicting dir/0: remove error")
+	c.Check(result.Evicted, check.Equals, 0)
+
+	// Objects that are already gone are still forgotten
+	store.removeErr = nil
+	result, err = e.Evict(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result.Evicted, check.Equals, 5)
+	c.Check(result.EvictedBytes, check.Equals, datasize.ByteSize(400))
+}
+
+func (s *EvictorSuite) TestSplitKey(c *check.C) {
+	for key, expected := range map[string][2]string{
+		"dir/address":     {"dir", "address"},
+		"a/b/c":           {"a/b", "c"},
+		"/address":        {"", "address"},
+		"address-no-dirs": {"", "address-no-dirs"},
+	} {
+		dir, address := splitKey(key)
+		c.Check([2]string{dir, address}, check.Equals, expected)
+	}
+}
+
+func (s *EvictorSuite) TestEvictTask(c *check.C) {
+	ctx := context.Background()
+	server := newMemoryServer(1000)
+	store := &fakeStore{objects: make(map[string]time.Time)}
+	populate(c, server, store)
+
+	ticker := make(chan time.Time)
+	evictor := NewEvictor(EvictorArgs{Name: "cache", Server: server, Store: store})
+	task := NewEvictTask("evict", evictor, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "evict")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	task.Run(ctx, nil)
+	c.Check(servertest.Exists(c, server, "dir", "0"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir", "9"), check.Equals, true)
+}
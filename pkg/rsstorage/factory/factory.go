@@ -0,0 +1,252 @@
+package factory
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	encryptClient "github.com/aws/amazon-s3-encryption-client-go/v3/client"
+	"github.com/aws/amazon-s3-encryption-client-go/v3/materials"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/postgres"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/s3server"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type ConfigArgs struct {
+	// Class names the storage, e.g., "packages". It is the class of file and
+	// PostgreSQL servers, the name of the metadata cache, and is included in
+	// errors.
+	Class  string
+	Config *rsstorage.Config
+
+	// Type selects the section of the configuration to use. Defaults to the
+	// only section that is set.
+	Type types.StorageType
+
+	Waiter   rsstorage.ChunkWaiter
+	Notifier rsstorage.ChunkNotifier
+
+	// Pool is optional. When set, PostgreSQL storage uses it instead of
+	// connecting to `ConfigPostgres.URL`.
+	Pool *pgxpool.Pool
+
+	// AWSConfig is optional. When set, S3 storage uses it instead of the
+	// configuration loaded from the environment and shared config files.
+	AWSConfig *aws.Config
+
+	// Store is optional. When set, the server is wrapped in a
+	// `rsstorage.MetadataStorageServer` that records access to items.
+	Store rsstorage.CacheStore
+}
+
+// NewFromConfig returns the storage server described by a configuration. The
+// configuration is validated first, and all its problems are reported
+// together. Unless `SkipValidation` is set, S3 storage is validated by
+// writing, checking, and removing an object, and PostgreSQL storage by
+// connecting to the database.
+//
+// When `ConfigS3.KeyID` is set, objects are encrypted on the client with the
+// KMS key. Shared config files are always loaded for S3, so
+// `ConfigS3.EnableSharedConfig` has no effect.
+func NewFromConfig(ctx context.Context, args ConfigArgs) (rsstorage.StorageServer, error) {
+	storageType, err := validate(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage configuration for class %s: %w", args.Class, err)
+	}
+
+	cfg := args.Config
+	var server rsstorage.StorageServer
+	switch storageType {
+	case rsstorage.StorageTypeFile:
+		server = file.NewStorageServer(file.StorageServerArgs{
+			Dir:          cfg.File.Location,
+			ChunkSize:    cfg.ChunkSizeBytes,
+			Waiter:       args.Waiter,
+			Notifier:     args.Notifier,
+			Class:        args.Class,
+			CacheTimeout: cfg.CacheTimeout,
+		})
+	case rsstorage.StorageTypeS3:
+		server, err = newS3Server(ctx, args)
+	case rsstorage.StorageTypePostgres:
+		server, err = newPostgresServer(ctx, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Store != nil {
+		server = rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
+			Name:   args.Class,
+			Server: server,
+			Store:  args.Store,
+		})
+	}
+	return server, nil
+}
+
+// validate returns the type of storage configured, or an error that joins
+// every problem with the configuration.
+func validate(args ConfigArgs) (types.StorageType, error) {
+	cfg := args.Config
+	if cfg == nil {
+		return "", errors.New("no configuration")
+	}
+
+	var errs []error
+	storageType := args.Type
+	if storageType == "" {
+		var configured []types.StorageType
+		if cfg.File != nil {
+			configured = append(configured, rsstorage.StorageTypeFile)
+		}
+		if cfg.S3 != nil {
+			configured = append(configured, rsstorage.StorageTypeS3)
+		}
+		if cfg.Postgres != nil {
+			configured = append(configured, rsstorage.StorageTypePostgres)
+		}
+		switch len(configured) {
+		case 0:
+			return "", errors.New("no File, S3, or Postgres configuration section")
+		case 1:
+			storageType = configured[0]
+		default:
+			return "", fmt.Errorf("more than one configuration section %v; choose one with the type", configured)
+		}
+	}
+
+	switch storageType {
+	case rsstorage.StorageTypeFile:
+		if cfg.File == nil {
+			errs = append(errs, errors.New("missing File configuration section"))
+		} else if cfg.File.Location == "" {
+			errs = append(errs, errors.New("missing File location"))
+		}
+	case rsstorage.StorageTypeS3:
+		if cfg.S3 == nil {
+			errs = append(errs, errors.New("missing S3 configuration section"))
+		} else if cfg.S3.Bucket == "" {
+			errs = append(errs, errors.New("missing S3 bucket"))
+		}
+	case rsstorage.StorageTypePostgres:
+		if cfg.Postgres == nil {
+			errs = append(errs, errors.New("missing Postgres configuration section"))
+		} else if cfg.Postgres.URL == "" && args.Pool == nil {
+			errs = append(errs, errors.New("missing Postgres URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported storage type %q", storageType))
+	}
+
+	if cfg.ChunkSizeBytes == 0 {
+		errs = append(errs, errors.New("the chunk size must be greater than zero"))
+	}
+	if args.Waiter == nil {
+		errs = append(errs, errors.New("missing chunk waiter"))
+	}
+	if args.Notifier == nil {
+		errs = append(errs, errors.New("missing chunk notifier"))
+	}
+	return storageType, errors.Join(errs...)
+}
+
+func newS3Server(ctx context.Context, args ConfigArgs) (rsstorage.StorageServer, error) {
+	cfg := args.Config.S3
+
+	var awsCfg aws.Config
+	if args.AWSConfig != nil {
+		awsCfg = args.AWSConfig.Copy()
+	} else {
+		var opts []func(*awsConfig.LoadOptions) error
+		if cfg.Profile != "" {
+			opts = append(opts, awsConfig.WithSharedConfigProfile(cfg.Profile))
+		}
+		var err error
+		if awsCfg, err = awsConfig.LoadDefaultConfig(ctx, opts...); err != nil {
+			return nil, fmt.Errorf("error loading AWS configuration for class %s: %w", args.Class, err)
+		}
+	}
+	if cfg.Region != "" {
+		awsCfg.Region = cfg.Region
+	}
+
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = cfg.S3ForcePathStyle
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		if cfg.DisableSSL {
+			o.EndpointOptions.DisableHTTPS = true
+		}
+	})
+
+	var svc s3server.S3Wrapper
+	var err error
+	if cfg.KeyID != "" {
+		cmm, cmmErr := materials.NewCryptographicMaterialsManager(materials.NewKmsKeyring(kms.NewFromConfig(awsCfg), cfg.KeyID))
+		if cmmErr != nil {
+			return nil, fmt.Errorf("error creating KMS materials manager for class %s: %w", args.Class, cmmErr)
+		}
+		client, clientErr := encryptClient.New(s3Client, cmm)
+		if clientErr != nil {
+			return nil, fmt.Errorf("error creating S3 encryption client for class %s: %w", args.Class, clientErr)
+		}
+		svc, err = s3server.NewEncryptedS3Wrapper(client)
+	} else {
+		svc, err = s3server.NewS3Wrapper(s3Client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	server := s3server.NewStorageServer(s3server.StorageServerArgs{
+		Bucket:    cfg.Bucket,
+		Prefix:    cfg.Prefix,
+		Svc:       svc,
+		ChunkSize: args.Config.ChunkSizeBytes,
+		Waiter:    args.Waiter,
+		Notifier:  args.Notifier,
+	})
+	if !cfg.SkipValidation {
+		if err = server.(*s3server.StorageServer).Validate(ctx); err != nil {
+			return nil, fmt.Errorf("error validating S3 storage for class %s: %w", args.Class, err)
+		}
+	}
+	return server, nil
+}
+
+func newPostgresServer(ctx context.Context, args ConfigArgs) (rsstorage.StorageServer, error) {
+	cfg := args.Config.Postgres
+
+	pool := args.Pool
+	if pool == nil {
+		var err error
+		if pool, err = pgxpool.New(ctx, cfg.URL); err != nil {
+			return nil, fmt.Errorf("error creating PostgreSQL pool for class %s: %w", args.Class, err)
+		}
+	}
+	if !cfg.SkipValidation {
+		if err := pool.Ping(ctx); err != nil {
+			return nil, fmt.Errorf("error validating PostgreSQL storage for class %s: %w", args.Class, err)
+		}
+	}
+
+	return postgres.NewStorageServer(postgres.StorageServerArgs{
+		ChunkSize: args.Config.ChunkSizeBytes,
+		Waiter:    args.Waiter,
+		Notifier:  args.Notifier,
+		Class:     args.Class,
+		Pool:      pool,
+	}), nil
+}
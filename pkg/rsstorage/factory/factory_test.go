@@ -0,0 +1,194 @@
+package factory
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jarcoal/httpmock"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/postgres"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/s3server"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type FactorySuite struct{}
+
+var _ = check.Suite(&FactorySuite{})
+
+func newArgs(cfg *rsstorage.Config) ConfigArgs {
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	return ConfigArgs{
+		Class:    "packages",
+		Config:   cfg,
+		Waiter:   wn,
+		Notifier: wn,
+	}
+}
+
+func newAWSConfig(client *http.Client) *aws.Config {
+	return &aws.Config{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  client,
+		// Fail fast on errors
+		RetryMaxAttempts: 1,
+	}
+}
+
+func (s *FactorySuite) TestValidate(c *check.C) {
+	ctx := context.Background()
+	_, err := NewFromConfig(ctx, newArgs(nil))
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: no configuration")
+
+	_, err = NewFromConfig(ctx, newArgs(&rsstorage.Config{ChunkSizeBytes: 4096}))
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: no File, S3, or Postgres configuration section")
+
+	_, err = NewFromConfig(ctx, newArgs(&rsstorage.Config{
+		ChunkSizeBytes: 4096,
+		File:           &rsstorage.ConfigFile{Location: c.MkDir()},
+		S3:             &rsstorage.ConfigS3{Bucket: "bucket"},
+	}))
+	c.Check(err, check.ErrorMatches, `invalid storage configuration for class packages: more than one configuration section \[file s3\]; choose one with the type`)
+
+	args := newArgs(&rsstorage.Config{File: &rsstorage.ConfigFile{}})
+	args.Waiter = nil
+	_, err = NewFromConfig(ctx, args)
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: missing File location\n"+
+		"the chunk size must be greater than zero\n"+
+		"missing chunk waiter")
+
+	args = newArgs(&rsstorage.Config{ChunkSizeBytes: 4096, File: &rsstorage.ConfigFile{Location: c.MkDir()}})
+	args.Type = rsstorage.StorageTypeS3
+	_, err = NewFromConfig(ctx, args)
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: missing S3 configuration section")
+
+	args.Type = "ftp"
+	_, err = NewFromConfig(ctx, args)
+	c.Check(err, check.ErrorMatches, `invalid storage configuration for class packages: unsupported storage type "ftp"`)
+
+	_, err = NewFromConfig(ctx, newArgs(&rsstorage.Config{ChunkSizeBytes: 4096, S3: &rsstorage.ConfigS3{}}))
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: missing S3 bucket")
+
+	_, err = NewFromConfig(ctx, newArgs(&rsstorage.Config{ChunkSizeBytes: 4096, Postgres: &rsstorage.ConfigPostgres{}}))
+	c.Check(err, check.ErrorMatches, "invalid storage configuration for class packages: missing Postgres URL")
+}
+
+func (s *FactorySuite) TestFile(c *check.C) {
+	dir := c.MkDir()
+	args := newArgs(&rsstorage.Config{
+		ChunkSizeBytes: 4096,
+		File:           &rsstorage.ConfigFile{Location: dir},
+		// Ignored, since the type is chosen
+		S3: &rsstorage.ConfigS3{},
+	})
+	args.Type = rsstorage.StorageTypeFile
+	server, err := NewFromConfig(context.Background(), args)
+	c.Assert(err, check.IsNil)
+	c.Check(server, check.FitsTypeOf, &file.StorageServer{})
+	c.Check(server.Dir(), check.Equals, dir)
+
+	// Wrapped to record access to items
+	args.Store = &servertest.FakeCacheStore{}
+	server, err = NewFromConfig(context.Background(), args)
+	c.Assert(err, check.IsNil)
+	c.Assert(server, check.FitsTypeOf, &rsstorage.MetadataStorageServer{})
+	c.Check(server.(*rsstorage.MetadataStorageServer).StorageServer, check.FitsTypeOf, &file.StorageServer{})
+}
+
+func (s *FactorySuite) TestS3(c *check.C) {
+	client := &http.Client{}
+	httpmock.ActivateNonDefault(client)
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterRegexpResponder(http.MethodPut, regexp.MustCompile(`^https://s3\.test/bucket/prefix/temp/validate\..*\.txt`),
+		httpmock.NewStringResponder(http.StatusOK, ""))
+	httpmock.RegisterRegexpResponder(http.MethodHead, regexp.MustCompile(`^https://s3\.test/bucket/prefix/temp/validate\..*\.txt`),
+		httpmock.NewStringResponder(http.StatusOK, ""))
+	httpmock.RegisterRegexpResponder(http.MethodDelete, regexp.MustCompile(`^https://s3\.test/bucket/prefix/temp/validate\..*\.txt`),
+		httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+	args := newArgs(&rsstorage.Config{
+		ChunkSizeBytes: 4096,
+		S3: &rsstorage.ConfigS3{
+			Bucket:           "bucket",
+			Prefix:           "prefix",
+			Endpoint:         "https://s3.test",
+			S3ForcePathStyle: true,
+		},
+	})
+	args.AWSConfig = newAWSConfig(client)
+	server, err := NewFromConfig(context.Background(), args)
+	c.Assert(err, check.IsNil)
+	c.Check(server, check.FitsTypeOf, &s3server.StorageServer{})
+	c.Check(server.Locate("dir", "address"), check.Equals, "s3://bucket/prefix/dir/address")
+	info := httpmock.GetCallCountInfo()
+	c.Check(info["PUT =~^https://s3\\.test/bucket/prefix/temp/validate\\..*\\.txt"], check.Equals, 1)
+	c.Check(info["HEAD =~^https://s3\\.test/bucket/prefix/temp/validate\\..*\\.txt"], check.Equals, 1)
+	c.Check(info["DELETE =~^https://s3\\.test/bucket/prefix/temp/validate\\..*\\.txt"], check.Equals, 1)
+
+	// Validation is skipped
+	httpmock.Reset()
+	args.Config.S3.SkipValidation = true
+	_, err = NewFromConfig(context.Background(), args)
+	c.Assert(err, check.IsNil)
+	c.Check(httpmock.GetTotalCallCount(), check.Equals, 0)
+}
+
+func (s *FactorySuite) TestS3ValidationError(c *check.C) {
+	client := &http.Client{}
+	httpmock.ActivateNonDefault(client)
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterNoResponder(httpmock.NewErrorResponder(errors.New("access denied")))
+
+	args := newArgs(&rsstorage.Config{
+		ChunkSizeBytes: 4096,
+		S3: &rsstorage.ConfigS3{
+			Bucket:           "bucket",
+			Endpoint:         "https://s3.test",
+			S3ForcePathStyle: true,
+		},
+	})
+	args.AWSConfig = newAWSConfig(client)
+	_, err := NewFromConfig(context.Background(), args)
+	c.Check(err, check.ErrorMatches, "(?s)error validating S3 storage for class packages: .*access denied.*")
+
+	// Objects are encrypted with the KMS key, so validation starts with
+	// generating a data key
+	httpmock.Reset()
+	httpmock.RegisterResponder(http.MethodPost, "https://kms.us-east-1.amazonaws.com/",
+		httpmock.NewErrorResponder(errors.New("kms unavailable")))
+	args.Config.S3.KeyID = "7ddec34f-7c3e-4875-a348-de761fc28b4f"
+	_, err = NewFromConfig(context.Background(), args)
+	c.Check(err, check.ErrorMatches, "(?s)error validating S3 storage for class packages: .*kms unavailable.*")
+}
+
+func (s *FactorySuite) TestPostgres(c *check.C) {
+	args := newArgs(&rsstorage.Config{
+		ChunkSizeBytes: 4096,
+		Postgres: &rsstorage.ConfigPostgres{
+			URL:            "postgres://localhost/storage",
+			SkipValidation: true,
+		},
+	})
+	server, err := NewFromConfig(context.Background(), args)
+	c.Assert(err, check.IsNil)
+	c.Check(server, check.FitsTypeOf, &postgres.StorageServer{})
+
+	args.Config.Postgres.URL = "invalid://"
+	_, err = NewFromConfig(context.Background(), args)
+	c.Check(err, check.ErrorMatches, "error creating PostgreSQL pool for class packages: .*")
+}
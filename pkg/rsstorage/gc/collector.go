@@ -0,0 +1,282 @@
+package gc
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/eviction"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const DefaultMinAge = 24 * time.Hour
+
+// StagingRemover is implemented by storage servers that write data to
+// staging files before moving it into place, e.g., `file.StorageServer`.
+type StagingRemover interface {
+	// RemoveStaging removes the staging files last modified before `before`,
+	// and returns the number of files removed and their total size.
+	RemoveStaging(ctx context.Context, before time.Time) (int, int64, error)
+}
+
+// Collector removes data left behind by writes that never finished:
+//   - Chunked items with an `info.json` that is not complete.
+//   - Orphaned chunks, which are directories of chunks without an
+//     `info.json`. These are left behind by S3 and PostgreSQL when removing
+//     a chunked item fails.
+//   - Staging files, for servers that implement `StagingRemover`.
+//
+// Only data older than a minimum age is removed, so writes in progress are
+// not interrupted. Chunked items are also kept while their address has work
+// in the queue.
+type Collector struct {
+	server       rsstorage.StorageServer
+	queue        eviction.InFlightChecker
+	queueAddress func(dir, address string) string
+	minAge       time.Duration
+	now          func() time.Time
+}
+
+type CollectorArgs struct {
+	Server rsstorage.StorageServer
+
+	// Queue is optional. When set, chunked items with work in the queue are
+	// never removed. QueueAddress maps an item to the address of its work,
+	// and defaults to the item's address.
+	Queue        eviction.InFlightChecker
+	QueueAddress func(dir, address string) string
+
+	// MinAge is the time since data was last written before it is removed.
+	// Defaults to `DefaultMinAge`.
+	MinAge time.Duration
+}
+
+func NewCollector(args CollectorArgs) *Collector {
+	c := &Collector{
+		server:       args.Server,
+		queue:        args.Queue,
+		queueAddress: args.QueueAddress,
+		minAge:       args.MinAge,
+		now:          time.Now,
+	}
+	if c.queueAddress == nil {
+		c.queueAddress = func(dir, address string) string {
+			return address
+		}
+	}
+	if c.minAge == 0 {
+		c.minAge = DefaultMinAge
+	}
+	return c
+}
+
+// CollectResult summarizes a collection.
+type CollectResult struct {
+	// Checked is the number of chunked items and orphaned chunk directories
+	// considered for removal.
+	Checked int
+
+	// Incomplete is the number of incomplete chunked items removed.
+	Incomplete int
+
+	// Orphaned is the number of orphaned chunk directories removed.
+	Orphaned int
+
+	// Staging is the number of staging files removed.
+	Staging int
+
+	// InFlight is the number of items that were kept because they have
+	// work in the queue.
+	InFlight int
+
+	// ReclaimedBytes is the total size of the data removed.
+	ReclaimedBytes datasize.ByteSize
+}
+
+// orphan is a directory of chunks without an `info.json`.
+type orphan struct {
+	chunks  []types.StoredItem
+	modTime time.Time
+	size    int64
+
+	// other is set when the directory holds files that are not chunks
+	other bool
+}
+
+// Collect removes the incomplete chunked items, orphaned chunks, and staging
+// files older than the minimum age. Errors removing individual items do not
+// stop the collection; they are joined and returned once it is done.
+func (c *Collector) Collect(ctx context.Context) (CollectResult, error) {
+	result := CollectResult{}
+	before := c.now().Add(-c.minAge)
+	var errs []error
+
+	if s, ok := c.server.(StagingRemover); ok {
+		removed, reclaimed, err := s.RemoveStaging(ctx, before)
+		result.Staging += removed
+		result.ReclaimedBytes += datasize.ByteSize(reclaimed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error removing staging files: %w", err))
+		}
+	}
+
+	orphans := make(map[string]*orphan)
+	for item, err := range rsstorage.EnumerateItems(ctx, c.server, types.EnumerateOptions{}) {
+		if err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+
+		if item.Chunked {
+			result.Checked++
+			err = c.collectIncomplete(ctx, item, before, &result)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error collecting dir=%s and address=%s: %w", item.Dir, item.Address, err))
+			}
+			continue
+		}
+
+		// Items that are not part of a chunked item are listed as they are
+		// stored, so orphaned chunks are listed on their own. Chunk names
+		// sort before most other names, so other files in a directory of
+		// chunks are usually listed after the chunks.
+		o, ok := orphans[item.Dir]
+		if !isChunkName(item.Address) || item.Dir == "" {
+			if ok {
+				o.other = true
+			}
+			continue
+		}
+		if !ok {
+			o = &orphan{}
+			orphans[item.Dir] = o
+		}
+		o.chunks = append(o.chunks, item)
+		o.size += item.Size
+		if item.ModTime.After(o.modTime) {
+			o.modTime = item.ModTime
+		}
+	}
+
+	for chunkDir, o := range orphans {
+		if o.other {
+			continue
+		}
+		result.Checked++
+		err := c.collectOrphan(ctx, chunkDir, o, before, &result)
+		if err != nil {
+			dir, address := path.Split(chunkDir)
+			errs = append(errs, fmt.Errorf("error collecting dir=%s and address=%s: %w", strings.TrimSuffix(dir, "/"), address, err))
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// inFlight returns true if the item has work in the queue.
+func (c *Collector) inFlight(ctx context.Context, dir, address string) (bool, error) {
+	if c.queue == nil {
+		return false, nil
+	}
+	return c.queue.IsAddressInQueue(ctx, c.queueAddress(dir, address))
+}
+
+func (c *Collector) collectIncomplete(ctx context.Context, item types.StoredItem, before time.Time, result *CollectResult) error {
+	ok, chunked, _, _, err := c.server.Check(ctx, item.Dir, item.Address)
+	if err != nil {
+		return err
+	} else if !ok || chunked == nil || chunked.Complete || !chunked.ModTime.Before(before) {
+		return nil
+	}
+
+	inFlight, err := c.inFlight(ctx, item.Dir, item.Address)
+	if err != nil {
+		return err
+	} else if inFlight {
+		result.InFlight++
+		return nil
+	}
+
+	if err = c.server.Remove(ctx, item.Dir, item.Address); err != nil {
+		return err
+	}
+	slog.Debug("Removed incomplete chunked item", "dir", item.Dir, "address", item.Address, "modified", chunked.ModTime)
+	result.Incomplete++
+	result.ReclaimedBytes += datasize.ByteSize(item.Size)
+	return nil
+}
+
+// collectOrphan removes orphaned chunks. Servers that do not store
+// modification times, e.g., PostgreSQL, list chunks without them; these
+// chunks are never removed, since their age is not known.
+func (c *Collector) collectOrphan(ctx context.Context, chunkDir string, o *orphan, before time.Time, result *CollectResult) error {
+	if o.modTime.IsZero() || !o.modTime.Before(before) {
+		return nil
+	}
+
+	dir, address := path.Split(chunkDir)
+	dir = strings.TrimSuffix(dir, "/")
+	inFlight, err := c.inFlight(ctx, dir, address)
+	if err != nil {
+		return err
+	} else if inFlight {
+		result.InFlight++
+		return nil
+	}
+
+	// Make sure a chunked write has not started since the chunks were listed
+	ok, _, _, _, err := c.server.Check(ctx, chunkDir, "info.json")
+	if err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	for _, chunk := range o.chunks {
+		if err = c.server.Remove(ctx, chunk.Dir, chunk.Address); err != nil {
+			return err
+		}
+	}
+	slog.Debug("Removed orphaned chunks", "dir", dir, "address", address, "chunks", len(o.chunks), "modified", o.modTime)
+	result.Orphaned++
+	result.ReclaimedBytes += datasize.ByteSize(o.size)
+	return nil
+}
+
+// isChunkName returns true for the names of chunk files, e.g., `00000001`.
+func isChunkName(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCollectTask returns a scheduled task that collects garbage.
+func NewCollectTask(name string, collector *Collector, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := collector.Collect(ctx)
+		slog.Debug("collected garbage",
+			"task", name,
+			"checked", result.Checked,
+			"incomplete", result.Incomplete,
+			"orphaned", result.Orphaned,
+			"staging", result.Staging,
+			"in_flight", result.InFlight,
+			"reclaimed_bytes", result.ReclaimedBytes)
+		return err
+	})
+}
@@ -0,0 +1,186 @@
+package gc
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type CollectorSuite struct{}
+
+var _ = check.Suite(&CollectorSuite{})
+
+type fakeQueue struct {
+	inFlight map[string]bool
+	err      error
+}
+
+func (q *fakeQueue) IsAddressInQueue(ctx context.Context, address string) (bool, error) {
+	return q.inFlight[address], q.err
+}
+
+// putIncomplete stores the first chunk of a chunked item that was never
+// completed.
+func putIncomplete(c *check.C, server rsstorage.StorageServer, dir, address string, modTime time.Time) {
+	ctx := context.Background()
+	info, err := json.Marshal(types.ChunksInfo{ChunkSize: 4, FileSize: 8, NumChunks: 2, ModTime: modTime})
+	c.Assert(err, check.IsNil)
+	chunkDir := filepath.Join(dir, address)
+	_, _, err = server.Put(ctx, servertest.StringResolver(string(info)), chunkDir, "info.json")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("abcd"), chunkDir, "00000001")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *CollectorSuite) TestNew(c *check.C) {
+	server := memtest.NewServer("gc", 4)
+	collector := NewCollector(CollectorArgs{Server: server})
+	c.Check(collector.minAge, check.Equals, DefaultMinAge)
+	c.Check(collector.queueAddress("dir", "address"), check.Equals, "address")
+}
+
+func (s *CollectorSuite) TestCollect(c *check.C) {
+	ctx := context.Background()
+	server := memtest.NewServer("gc", 4)
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	// Complete items are kept
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "item")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver("abcdefgh"), "dir", "chunked", 8)
+	c.Assert(err, check.IsNil)
+
+	putIncomplete(c, server, "dir", "incomplete", old)
+	putIncomplete(c, server, "dir", "recent", now)
+	putIncomplete(c, server, "dir", "queued", old)
+
+	// Orphaned chunks
+	_, _, err = server.Put(ctx, servertest.StringResolver("abcd"), "dir/orphan", "00000001")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("efgh"), "dir/orphan", "00000002")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("abcd"), "dir/notchunks", "00000001")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("data"), "dir/notchunks", "other")
+	c.Assert(err, check.IsNil)
+
+	queue := &fakeQueue{inFlight: map[string]bool{"queued": true}}
+	collector := NewCollector(CollectorArgs{
+		Server: server,
+		Queue:  queue,
+		MinAge: time.Hour,
+	})
+
+	// Too recent for the orphaned chunks
+	result, err := collector.Collect(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, CollectResult{
+		Checked:        5,
+		Incomplete:     1,
+		InFlight:       1,
+		ReclaimedBytes: 4,
+	})
+	c.Check(servertest.Exists(c, server, "dir", "incomplete"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir", "recent"), check.Equals, true)
+	c.Check(servertest.Exists(c, server, "dir", "queued"), check.Equals, true)
+	c.Check(servertest.Exists(c, server, "dir/orphan", "00000001"), check.Equals, true)
+
+	collector.now = func() time.Time {
+		return now.Add(2 * time.Hour)
+	}
+	result, err = collector.Collect(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, CollectResult{
+		Checked:        4,
+		Incomplete:     1,
+		Orphaned:       1,
+		InFlight:       1,
+		ReclaimedBytes: 12,
+	})
+	c.Check(servertest.Exists(c, server, "dir", "recent"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir/orphan", "00000001"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir/orphan", "00000002"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir/notchunks", "00000001"), check.Equals, true)
+	c.Check(servertest.Exists(c, server, "dir", "item"), check.Equals, true)
+	c.Check(servertest.Exists(c, server, "dir", "chunked"), check.Equals, true)
+
+	// Queue errors
+	queue.err = errors.New("queue error")
+	_, err = collector.Collect(ctx)
+	c.Check(err, check.ErrorMatches, "error collecting dir=dir and address=queued: queue error")
+}
+
+func (s *CollectorSuite) TestCollectStaging(c *check.C) {
+	ctx := context.Background()
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	dir := c.MkDir()
+	server := file.NewStorageServer(file.StorageServerArgs{
+		Dir:       dir,
+		ChunkSize: 4,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     "gc",
+	})
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	c.Assert(os.WriteFile(filepath.Join(dir, file.StagingPrefix+"123"), []byte("abandoned"), 0600), check.IsNil)
+	c.Assert(os.Chtimes(filepath.Join(dir, file.StagingPrefix+"123"), old, old), check.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, file.StagingPrefix+"456"), []byte("writing"), 0600), check.IsNil)
+	putIncomplete(c, server, "dir", "incomplete", old)
+
+	collector := NewCollector(CollectorArgs{
+		Server: server,
+		MinAge: time.Hour,
+	})
+	result, err := collector.Collect(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, CollectResult{
+		Checked:        1,
+		Incomplete:     1,
+		Staging:        1,
+		ReclaimedBytes: datasize.ByteSize(len("abandoned") + len("abcd")),
+	})
+	_, err = os.Stat(filepath.Join(dir, file.StagingPrefix+"123"))
+	c.Check(os.IsNotExist(err), check.Equals, true)
+	_, err = os.Stat(filepath.Join(dir, file.StagingPrefix+"456"))
+	c.Check(err, check.IsNil)
+	c.Check(servertest.Exists(c, server, "dir", "incomplete"), check.Equals, false)
+}
+
+func (s *CollectorSuite) TestCollectTask(c *check.C) {
+	ctx := context.Background()
+	server := memtest.NewServer("gc", 4)
+	putIncomplete(c, server, "dir", "incomplete", time.Now().Add(-2*time.Hour))
+
+	ticker := make(chan time.Time)
+	collector := NewCollector(CollectorArgs{Server: server, MinAge: time.Hour})
+	task := NewCollectTask("gc", collector, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "gc")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	task.Run(ctx, nil)
+	c.Check(servertest.Exists(c, server, "dir", "incomplete"), check.Equals, false)
+}
@@ -0,0 +1,388 @@
+package httphandler
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Paths served by the handler, relative to where it is mounted.
+const (
+	PathObject = "/object"
+	PathItems  = "/items"
+	PathUsage  = "/usage"
+	PathFlush  = "/flush"
+)
+
+// Query parameters.
+const (
+	ParamDir     = "dir"
+	ParamAddress = "address"
+	ParamOffset  = "offset"
+	ParamLength  = "length"
+	ParamSize    = "size"
+	ParamChunked = "chunked"
+	ParamPrefix  = "prefix"
+	ParamAfter   = "after"
+)
+
+// Headers describing an item. `HeaderChunks` is the JSON encoded
+// `types.ChunksInfo` of a chunked item, and `HeaderMetadata` is the JSON
+// encoded `types.Metadata` of an item, which is also sent when writing an
+// item. `HeaderDir` and `HeaderAddress` are sent as trailers when writing an
+// item, so that the writer can choose the address after the data is written.
+const (
+	HeaderSize     = "X-Storage-Size"
+	HeaderModified = "X-Storage-Modified"
+	HeaderChunks   = "X-Storage-Chunks"
+	HeaderMetadata = "X-Storage-Metadata"
+	HeaderDir      = "X-Storage-Dir"
+	HeaderAddress  = "X-Storage-Address"
+)
+
+// Operation names the storage server operation of a request.
+type Operation string
+
+const (
+	OperationCheck      Operation = "check"
+	OperationGet        Operation = "get"
+	OperationPut        Operation = "put"
+	OperationPutChunked Operation = "put_chunked"
+	OperationRemove     Operation = "remove"
+	OperationEnumerate  Operation = "enumerate"
+	OperationUsage      Operation = "usage"
+	OperationFlush      Operation = "flush"
+)
+
+// AuthorizeFunc decides whether a request may run an operation. Return an
+// error to reject the request with a 403 status. Unlike storage errors, the
+// error message is sent to the client to explain the rejection, so it must
+// not include anything the client may not see.
+type AuthorizeFunc func(r *http.Request, op Operation) error
+
+// PutResult is the response to a write.
+type PutResult struct {
+	Dir     string `json:"dir"`
+	Address string `json:"address"`
+}
+
+// EnumerateLine is a line in the response to an enumeration. Items are
+// written as lines of JSON as they are enumerated, and an error that ends the
+// enumeration is written as a final line with `Error` set. The error itself
+// is logged rather than sent.
+type EnumerateLine struct {
+	types.StoredItem
+	Error string `json:",omitempty"`
+}
+
+type handler struct {
+	server    rsstorage.StorageServer
+	authorize AuthorizeFunc
+}
+
+type HandlerArgs struct {
+	Server rsstorage.StorageServer
+
+	// Authorize is optional. When set, it is called before each operation.
+	Authorize AuthorizeFunc
+}
+
+// NewHandler returns an `http.Handler` that serves a storage server to
+// `servers/httpclient.StorageServer`. Mount it with `http.StripPrefix` to
+// serve it under a path.
+func NewHandler(args HandlerArgs) http.Handler {
+	h := &handler{
+		server:    args.Server,
+		authorize: args.Authorize,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("HEAD "+PathObject, h.handle(OperationCheck, h.check))
+	mux.HandleFunc("GET "+PathObject, h.handle(OperationGet, h.get))
+	mux.HandleFunc("PUT "+PathObject, h.handlePut)
+	mux.HandleFunc("DELETE "+PathObject, h.handle(OperationRemove, h.remove))
+	mux.HandleFunc("GET "+PathItems, h.handle(OperationEnumerate, h.enumerate))
+	mux.HandleFunc("GET "+PathUsage, h.handle(OperationUsage, h.usage))
+	mux.HandleFunc("POST "+PathFlush, h.handle(OperationFlush, h.flush))
+	return mux
+}
+
+// statusError is an error with an HTTP status.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (h *handler) handle(op Operation, fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.authorize != nil {
+			if err := h.authorize(r, op); err != nil {
+				// Sent deliberately. See `AuthorizeFunc`.
+				slog.Debug("Rejected storage request", "operation", op, "error", err)
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		q := r.URL.Query()
+		err := checkPath(q.Get(ParamDir), q.Get(ParamAddress))
+		if err == nil {
+			err = fn(w, r)
+		}
+		if err == nil {
+			return
+		}
+		var se *statusError
+		if errors.As(err, &se) {
+			slog.Debug("Error serving storage request", "operation", op, "status", se.status, "error", err)
+			http.Error(w, se.Error(), se.status)
+		} else if errors.Is(err, rsstorage.ErrInvalidRange) {
+			slog.Debug("Error serving storage request", "operation", op, "error", err)
+			http.Error(w, rsstorage.ErrInvalidRange.Error(), http.StatusRequestedRangeNotSatisfiable)
+		} else if errors.Is(err, rsstorage.ErrMetadataNotSupported) {
+			slog.Debug("Error serving storage request", "operation", op, "error", err)
+			http.Error(w, rsstorage.ErrMetadataNotSupported.Error(), http.StatusNotImplemented)
+		} else {
+			// Backend errors may describe the storage, so they are only logged
+			slog.Error("Error serving storage request", "operation", op, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+// checkPath rejects a dir and address that would name an item outside of
+// the storage server, e.g., `../secret` with the file server.
+func checkPath(dir, address string) error {
+	for _, p := range []string{dir, address} {
+		if path.IsAbs(p) || filepath.IsAbs(p) {
+			return &statusError{status: http.StatusBadRequest, err: fmt.Errorf("invalid path %q: must be relative", p)}
+		}
+		for _, elem := range strings.FieldsFunc(p, isSeparator) {
+			if elem == ".." {
+				return &statusError{status: http.StatusBadRequest, err: fmt.Errorf("invalid path %q: must not contain '..'", p)}
+			}
+		}
+	}
+	if joined := path.Join(dir, address); joined != "" && !filepath.IsLocal(joined) {
+		return &statusError{status: http.StatusBadRequest, err: fmt.Errorf("invalid path %q", joined)}
+	}
+	return nil
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+func (h *handler) handlePut(w http.ResponseWriter, r *http.Request) {
+	op := OperationPut
+	if r.URL.Query().Get(ParamChunked) == "true" {
+		op = OperationPutChunked
+	}
+	h.handle(op, h.put)(w, r)
+}
+
+// writeHeaders describes an item with headers.
+func writeHeaders(w http.ResponseWriter, chunked *types.ChunksInfo, sz int64, mod time.Time, meta types.Metadata) error {
+	w.Header().Set(HeaderSize, strconv.FormatInt(sz, 10))
+	if !mod.IsZero() {
+		w.Header().Set(HeaderModified, mod.UTC().Format(time.RFC3339Nano))
+	}
+	if chunked != nil {
+		b, err := json.Marshal(chunked)
+		if err != nil {
+			return err
+		}
+		w.Header().Set(HeaderChunks, string(b))
+	}
+	if len(meta) > 0 {
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		w.Header().Set(HeaderMetadata, string(b))
+	}
+	return nil
+}
+
+func (h *handler) check(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(r.Context(), h.server, q.Get(ParamDir), q.Get(ParamAddress))
+	if err != nil {
+		return err
+	} else if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	if err = writeHeaders(w, chunked, sz, mod, meta); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	dir, address := q.Get(ParamDir), q.Get(ParamAddress)
+
+	var f io.ReadCloser
+	var chunked *types.ChunksInfo
+	var sz int64
+	var mod time.Time
+	var meta types.Metadata
+	var ok bool
+	var err error
+	if q.Has(ParamOffset) {
+		offset, length, parseErr := parseRange(q.Get(ParamOffset), q.Get(ParamLength))
+		if parseErr != nil {
+			return &statusError{status: http.StatusBadRequest, err: parseErr}
+		}
+		f, chunked, sz, mod, ok, err = rsstorage.GetRange(r.Context(), h.server, dir, address, offset, length)
+	} else {
+		f, chunked, sz, mod, meta, ok, err = rsstorage.GetWithMetadata(r.Context(), h.server, dir, address)
+	}
+	if err != nil {
+		return err
+	} else if !ok {
+		return &statusError{status: http.StatusNotFound, err: fmt.Errorf("the object with dir=%s and address=%s does not exist", dir, address)}
+	}
+	defer f.Close()
+
+	if err = writeHeaders(w, chunked, sz, mod, meta); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, f); err != nil {
+		// The status has been sent, so the client sees a truncated body
+		slog.Debug("Error writing storage object", "dir", dir, "address", address, "error", err)
+	}
+	return nil
+}
+
+func parseRange(offsetParam, lengthParam string) (int64, int64, error) {
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid offset: %w", err)
+	}
+	length := int64(-1)
+	if lengthParam != "" {
+		if length, err = strconv.ParseInt(lengthParam, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid length: %w", err)
+		}
+	}
+	return offset, length, nil
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	resolve := func(writer io.Writer) (string, string, error) {
+		_, err := io.Copy(writer, r.Body)
+		if err != nil {
+			return "", "", err
+		}
+		// Trailers are read with the end of the body
+		dir, address := r.Trailer.Get(HeaderDir), r.Trailer.Get(HeaderAddress)
+		if err = checkPath(dir, address); err != nil {
+			return "", "", err
+		}
+		return dir, address, nil
+	}
+
+	var meta types.Metadata
+	if v := r.Header.Get(HeaderMetadata); v != "" {
+		if err := json.Unmarshal([]byte(v), &meta); err != nil {
+			return &statusError{status: http.StatusBadRequest, err: fmt.Errorf("invalid metadata: %w", err)}
+		}
+	}
+
+	// Metadata is rejected before the body is read, so a client that sent
+	// `Expect: 100-continue` can retry without it
+	var dir, address string
+	var err error
+	if q.Get(ParamChunked) == "true" {
+		sz, parseErr := strconv.ParseUint(q.Get(ParamSize), 10, 64)
+		if parseErr != nil {
+			return &statusError{status: http.StatusBadRequest, err: fmt.Errorf("invalid size: %w", parseErr)}
+		}
+		dir, address, err = rsstorage.PutChunkedWithMetadata(r.Context(), h.server, resolve, q.Get(ParamDir), q.Get(ParamAddress), sz, meta)
+	} else {
+		dir, address, err = rsstorage.PutWithMetadata(r.Context(), h.server, resolve, q.Get(ParamDir), q.Get(ParamAddress), meta)
+	}
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, PutResult{Dir: dir, Address: address})
+}
+
+func (h *handler) remove(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	if err := h.server.Remove(r.Context(), q.Get(ParamDir), q.Get(ParamAddress)); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *handler) enumerate(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	opts := types.EnumerateOptions{Prefix: q.Get(ParamPrefix), After: q.Get(ParamAfter)}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for item, err := range rsstorage.EnumerateItems(r.Context(), h.server, opts) {
+		line := EnumerateLine{StoredItem: item}
+		if err != nil {
+			// Like other backend errors, the error is only logged
+			slog.Error("Error enumerating storage items", "error", err)
+			line = EnumerateLine{Error: http.StatusText(http.StatusInternalServerError)}
+		}
+		if encErr := enc.Encode(line); encErr != nil {
+			slog.Debug("Error writing storage enumeration", "error", encErr)
+			return nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (h *handler) usage(w http.ResponseWriter, r *http.Request) error {
+	usage, err := h.server.CalculateUsage()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, usage)
+}
+
+func (h *handler) flush(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	h.server.Flush(r.Context(), q.Get(ParamDir), q.Get(ParamAddress))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,313 @@
+package httphandler
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type HandlerSuite struct{}
+
+var _ = check.Suite(&HandlerSuite{})
+
+func put(c *check.C, server rsstorage.StorageServer, dir, address, data string) {
+	_, _, err := server.Put(context.Background(), func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, data)
+		return "", "", err
+	}, dir, address)
+	c.Assert(err, check.IsNil)
+}
+
+func serve(h http.Handler, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, body))
+	return w
+}
+
+func (s *HandlerSuite) TestObject(c *check.C) {
+	server := memtest.NewServer("handler", 4096)
+	h := NewHandler(HandlerArgs{Server: server})
+	put(c, server, "dir", "address", "some data")
+
+	w := serve(h, http.MethodHead, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Header().Get(HeaderSize), check.Equals, "9")
+	c.Check(w.Header().Get(HeaderModified), check.Not(check.Equals), "")
+	c.Check(w.Header().Get(HeaderChunks), check.Equals, "")
+
+	w = serve(h, http.MethodHead, "/object?dir=dir&address=missing", nil)
+	c.Check(w.Code, check.Equals, http.StatusNotFound)
+
+	w = serve(h, http.MethodGet, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Body.String(), check.Equals, "some data")
+
+	w = serve(h, http.MethodGet, "/object?dir=dir&address=address&offset=5&length=2", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Body.String(), check.Equals, "da")
+
+	w = serve(h, http.MethodGet, "/object?dir=dir&address=address&offset=20", nil)
+	c.Check(w.Code, check.Equals, http.StatusRequestedRangeNotSatisfiable)
+
+	w = serve(h, http.MethodGet, "/object?dir=dir&address=address&offset=x", nil)
+	c.Check(w.Code, check.Equals, http.StatusBadRequest)
+
+	w = serve(h, http.MethodGet, "/object?dir=dir&address=missing", nil)
+	c.Check(w.Code, check.Equals, http.StatusNotFound)
+
+	w = serve(h, http.MethodPut, "/object?dir=dir&address=new", strings.NewReader("new data"))
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	var result PutResult
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &result), check.IsNil)
+	c.Check(result, check.Equals, PutResult{Dir: "dir", Address: "new"})
+
+	w = serve(h, http.MethodPut, "/object?dir=dir&address=chunked&chunked=true&size=8", strings.NewReader("new data"))
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	w = serve(h, http.MethodHead, "/object?dir=dir&address=chunked", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	chunked := &types.ChunksInfo{}
+	c.Assert(json.Unmarshal([]byte(w.Header().Get(HeaderChunks)), chunked), check.IsNil)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(chunked.FileSize, check.Equals, uint64(8))
+
+	w = serve(h, http.MethodPut, "/object?dir=dir&address=chunked&chunked=true", strings.NewReader("new data"))
+	c.Check(w.Code, check.Equals, http.StatusBadRequest)
+
+	w = serve(h, http.MethodDelete, "/object?dir=dir&address=new", nil)
+	c.Check(w.Code, check.Equals, http.StatusNoContent)
+	ok, _, _, _, err := server.Check(context.Background(), "dir", "new")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	w = serve(h, http.MethodPost, "/flush?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusNoContent)
+
+	w = serve(h, http.MethodPatch, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *HandlerSuite) TestMetadata(c *check.C) {
+	server := memtest.NewServer("handler", 4096)
+	h := NewHandler(HandlerArgs{Server: server})
+	meta := `{"content-type":"text/plain"}`
+
+	for _, target := range []string{
+		"/object?dir=dir&address=address",
+		"/object?dir=dir&address=chunked&chunked=true&size=9",
+	} {
+		r := httptest.NewRequest(http.MethodPut, target, strings.NewReader("some data"))
+		r.Header.Set(HeaderMetadata, meta)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		c.Check(w.Code, check.Equals, http.StatusOK)
+	}
+	for _, address := range []string{"address", "chunked"} {
+		for _, method := range []string{http.MethodHead, http.MethodGet} {
+			w := serve(h, method, "/object?dir=dir&address="+address, nil)
+			c.Check(w.Code, check.Equals, http.StatusOK)
+			c.Check(w.Header().Get(HeaderMetadata), check.Equals, meta)
+		}
+	}
+
+	// Ranges and items without metadata have no metadata header
+	w := serve(h, http.MethodGet, "/object?dir=dir&address=address&offset=5", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Header().Get(HeaderMetadata), check.Equals, "")
+	put(c, server, "dir", "plain", "some data")
+	w = serve(h, http.MethodHead, "/object?dir=dir&address=plain", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Header().Get(HeaderMetadata), check.Equals, "")
+
+	r := httptest.NewRequest(http.MethodPut, "/object?dir=dir&address=invalid", strings.NewReader("some data"))
+	r.Header.Set(HeaderMetadata, "not json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	c.Check(w.Code, check.Equals, http.StatusBadRequest)
+
+	// Servers that cannot store metadata reject it before reading the body
+	dummy := &rsstorage.DummyStorageServer{}
+	h = NewHandler(HandlerArgs{Server: dummy})
+	body := strings.NewReader("some data")
+	r = httptest.NewRequest(http.MethodPut, "/object?dir=dir&address=address", body)
+	r.Header.Set(HeaderMetadata, meta)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	c.Check(w.Code, check.Equals, http.StatusNotImplemented)
+	c.Check(w.Body.String(), check.Equals, rsstorage.ErrMetadataNotSupported.Error()+"\n")
+	c.Check(body.Len(), check.Equals, 9)
+	c.Check(dummy.PutCalled, check.Equals, 0)
+}
+
+func (s *HandlerSuite) TestEnumerate(c *check.C) {
+	server := memtest.NewServer("handler", 4096)
+	h := NewHandler(HandlerArgs{Server: server})
+	put(c, server, "a", "1", "one")
+	put(c, server, "a", "2", "two")
+	put(c, server, "b", "3", "three")
+
+	lines := func(body *bytes.Buffer) []EnumerateLine {
+		result := make([]EnumerateLine, 0)
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			var line EnumerateLine
+			c.Assert(json.Unmarshal(scanner.Bytes(), &line), check.IsNil)
+			result = append(result, line)
+		}
+		return result
+	}
+
+	w := serve(h, http.MethodGet, "/items", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Header().Get("Content-Type"), check.Equals, "application/x-ndjson")
+	items := lines(w.Body)
+	c.Assert(items, check.HasLen, 3)
+	c.Check(items[0].Dir, check.Equals, "a")
+	c.Check(items[0].Address, check.Equals, "1")
+	c.Check(items[2].Size, check.Equals, int64(5))
+
+	w = serve(h, http.MethodGet, "/items?prefix=a/&after="+items[0].Token, nil)
+	items = lines(w.Body)
+	c.Assert(items, check.HasLen, 1)
+	c.Check(items[0].Address, check.Equals, "2")
+
+	// An error ends the enumeration with an error line
+	h = NewHandler(HandlerArgs{Server: &rsstorage.DummyStorageServer{EnumErr: errors.New("enumerate error")}})
+	w = serve(h, http.MethodGet, "/items", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	c.Check(lines(w.Body), check.DeepEquals, []EnumerateLine{{Error: "Internal Server Error"}})
+}
+
+func (s *HandlerSuite) TestUsage(c *check.C) {
+	server := memtest.NewServer("handler", 4096)
+	h := NewHandler(HandlerArgs{Server: server})
+	put(c, server, "dir", "address", "some data")
+
+	w := serve(h, http.MethodGet, "/usage", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	var usage types.Usage
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &usage), check.IsNil)
+	expected, err := server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, expected.UsedBytes)
+}
+
+func (s *HandlerSuite) TestAuthorize(c *check.C) {
+	server := memtest.NewServer("handler", 4096)
+	put(c, server, "dir", "address", "some data")
+
+	var ops []Operation
+	h := NewHandler(HandlerArgs{
+		Server: server,
+		Authorize: func(r *http.Request, op Operation) error {
+			ops = append(ops, op)
+			if op != OperationCheck && op != OperationGet && r.Header.Get("Authorization") != "Bearer token" {
+				return errors.New("read only")
+			}
+			return nil
+		},
+	})
+
+	w := serve(h, http.MethodGet, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusOK)
+	w = serve(h, http.MethodDelete, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusForbidden)
+	c.Check(strings.TrimSpace(w.Body.String()), check.Equals, "read only")
+	w = serve(h, http.MethodPut, "/object?dir=dir&address=chunked&chunked=true&size=4", strings.NewReader("data"))
+	c.Check(w.Code, check.Equals, http.StatusForbidden)
+
+	req := httptest.NewRequest(http.MethodDelete, "/object?dir=dir&address=address", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	c.Check(w.Code, check.Equals, http.StatusNoContent)
+
+	c.Check(ops, check.DeepEquals, []Operation{OperationGet, OperationRemove, OperationPutChunked, OperationRemove})
+}
+
+func (s *HandlerSuite) TestPathTraversal(c *check.C) {
+	base := c.MkDir()
+	secret := filepath.Join(base, "secret")
+	c.Assert(os.WriteFile(secret, []byte("secret"), 0600), check.IsNil)
+	root := filepath.Join(base, "root")
+	c.Assert(os.Mkdir(root, 0700), check.IsNil)
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	h := NewHandler(HandlerArgs{Server: file.NewStorageServer(file.StorageServerArgs{
+		Dir:       root,
+		ChunkSize: 4096,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     "handler",
+	})})
+
+	for _, query := range []string{
+		"dir=..&address=secret",
+		"dir=&address=../secret",
+		"dir=dir/../..&address=secret",
+		"dir=" + url.QueryEscape(base) + "&address=secret",
+		"dir=dir&address=" + url.QueryEscape(secret),
+		"dir=..\\&address=secret",
+	} {
+		w := serve(h, http.MethodGet, "/object?"+query, nil)
+		c.Check(w.Code, check.Equals, http.StatusBadRequest, check.Commentf(query))
+		c.Check(w.Body.String(), check.Not(check.Matches), "(?s).*secret\n.*")
+
+		w = serve(h, http.MethodPut, "/object?"+query, strings.NewReader("overwritten"))
+		c.Check(w.Code, check.Equals, http.StatusBadRequest, check.Commentf(query))
+
+		w = serve(h, http.MethodDelete, "/object?"+query, nil)
+		c.Check(w.Code, check.Equals, http.StatusBadRequest, check.Commentf(query))
+	}
+
+	// Addresses chosen with trailers are checked, too
+	r := httptest.NewRequest(http.MethodPut, "/object?dir=", strings.NewReader("overwritten"))
+	r.Trailer = http.Header{HeaderDir: {".."}, HeaderAddress: {"secret"}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	c.Check(w.Code, check.Equals, http.StatusBadRequest)
+
+	b, err := os.ReadFile(secret)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "secret")
+}
+
+func (s *HandlerSuite) TestInternalError(c *check.C) {
+	h := NewHandler(HandlerArgs{Server: &failingServer{}})
+
+	// Backend errors are not sent to clients
+	w := serve(h, http.MethodGet, "/object?dir=dir&address=address", nil)
+	c.Check(w.Code, check.Equals, http.StatusInternalServerError)
+	c.Check(w.Body.String(), check.Equals, "Internal Server Error\n")
+}
+
+type failingServer struct {
+	rsstorage.StorageServer
+}
+
+func (f *failingServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return nil, nil, 0, time.Time{}, false, errors.New("open /var/lib/storage/dir/address: permission denied")
+}
@@ -3,7 +3,10 @@ package internal
 // Copyright (C) 2022 by RStudio, PBC
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +27,22 @@ type DefaultChunkUtils struct {
 	Notifier    rsstorage.ChunkNotifier
 	PollTimeout time.Duration
 	MaxAttempts int
+
+	// Concurrency is the number of chunks that may be uploaded at once. When
+	// greater than one, each chunk is buffered in memory while it uploads.
+	// Chunk notifications are still sent in chunk order.
+	Concurrency int
+
+	// ReadAhead is the number of chunks to prefetch while reading. When
+	// greater than one, upcoming chunks are buffered in memory while earlier
+	// chunks are read.
+	ReadAhead int
+
+	// Resume enables resuming interrupted writes. When enabled, WriteChunked
+	// keeps any valid chunks left behind by an earlier incomplete write of the
+	// same size and only writes the remaining chunks. Chunks are also kept when
+	// a write fails so that it can be resumed later.
+	Resume bool
 }
 
 func (w *DefaultChunkUtils) WriteChunked(
@@ -32,6 +51,19 @@ func (w *DefaultChunkUtils) WriteChunked(
 	address string,
 	sz uint64,
 	resolve types.Resolver,
+) error {
+	return w.WriteChunkedWithMetadata(ctx, dir, address, sz, nil, resolve)
+}
+
+// WriteChunkedWithMetadata writes a chunked asset like WriteChunked, and
+// records the user metadata in its `info.json`.
+func (w *DefaultChunkUtils) WriteChunkedWithMetadata(
+	ctx context.Context,
+	dir string,
+	address string,
+	sz uint64,
+	meta types.Metadata,
+	resolve types.Resolver,
 ) (err error) {
 
 	// Determine number of chunks we will need to create
@@ -40,37 +72,54 @@ func (w *DefaultChunkUtils) WriteChunked(
 		numChunks++
 	}
 
-	// Clear the directory if it already exists
-	err = w.Server.Remove(ctx, dir, address)
-	if err != nil {
-		err = fmt.Errorf("unable to clear directory before writing: %w", err)
-		return
-	}
-
-	// Write an `info.json` to the directory
+	// The `info.json` we will write to the directory
 	info := types.ChunksInfo{
 		ChunkSize: w.ChunkSize,
 		NumChunks: numChunks,
 		FileSize:  sz,
 		ModTime:   time.Now(),
+		Metadata:  meta.Normalize(),
+	}
+	chunkDir := filepath.Join(dir, address)
+
+	// When resuming, count the chunks that were already written
+	var resumed uint64
+	if w.Resume {
+		var resumes uint64
+		resumed, resumes, err = w.writtenChunks(ctx, chunkDir, &info)
+		if err != nil {
+			err = fmt.Errorf("unable to inspect existing chunks before writing: %w", err)
+			return
+		}
+		if resumed > 0 {
+			info.Resumes = resumes + 1
+			slog.Debug("resuming chunked write", "address", address, "chunks", resumed, "total", numChunks)
+		}
 	}
+
+	// Clear the directory if it already exists
+	if resumed == 0 {
+		err = w.Server.Remove(ctx, dir, address)
+		if err != nil {
+			err = fmt.Errorf("unable to clear directory before writing: %w", err)
+			return
+		}
+	}
+
+	// Write an `info.json` to the directory
 	infoResolver := func(writer io.Writer) (dir, address string, err error) {
 		en := json.NewEncoder(writer)
 		err = en.Encode(&info)
 		return
 	}
-	chunkDir := filepath.Join(dir, address)
 	_, _, err = w.Server.Put(ctx, infoResolver, chunkDir, "info.json")
 	if err != nil {
 		return
 	}
 
-	// Pipe results from resolver (pW) to chunk writer (pR)
-	pR, pW := io.Pipe()
-
-	// Clean up on error
+	// Clean up on error, unless the chunks are kept to resume later
 	defer func() {
-		if err != nil {
+		if err != nil && !w.Resume {
 			removeErr := w.Server.Remove(ctx, dir, address)
 			if removeErr != nil {
 				err = errors.Join(err, removeErr)
@@ -78,10 +127,128 @@ func (w *DefaultChunkUtils) WriteChunked(
 		}
 	}()
 
+	// Checksums of the chunks that were already written
+	info.Checksums = make([]string, numChunks)
+	if resumed > 0 {
+		err = w.storedChecksums(ctx, chunkDir, info.Checksums[:resumed])
+		if err != nil {
+			return
+		}
+	}
+
+	// Write the remaining chunks
+	if resumed < numChunks {
+		err = w.writeRemaining(ctx, address, chunkDir, &info, resumed, resolve)
+		if err != nil {
+			return
+		}
+	}
+
+	// Update `info.json` when complete
+	info.Complete = true
+	info.ModTime = time.Now()
+	_, _, err = w.Server.Put(ctx, infoResolver, chunkDir, "info.json")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// writtenChunks returns the number of leading chunks that were already written
+// for an incomplete chunked asset with the same layout as `info`, along with
+// the number of times the asset was previously resumed. Chunks must have their
+// expected size to be counted.
+func (w *DefaultChunkUtils) writtenChunks(
+	ctx context.Context,
+	chunkDir string,
+	info *types.ChunksInfo,
+) (written uint64, resumes uint64, err error) {
+	existing, err := w.readInfo(ctx, chunkDir)
+	if errors.Is(err, rsstorage.ErrNoChunkMetadata) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	// Only resume incomplete writes with an identical layout
+	if existing.Complete ||
+		existing.ChunkSize != info.ChunkSize ||
+		existing.FileSize != info.FileSize ||
+		existing.NumChunks != info.NumChunks {
+		return 0, 0, nil
+	}
+
+	for i := uint64(1); i <= info.NumChunks; i++ {
+		ok, _, sz, _, err := w.Server.Check(ctx, chunkDir, fmt.Sprintf("%08d", i))
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok || uint64(sz) != info.ExpectedChunkSize(i) {
+			break
+		}
+		written = i
+	}
+
+	return written, existing.Resumes, nil
+}
+
+// storedChecksums computes the checksums of the chunks that are already
+// stored, starting with the first chunk.
+func (w *DefaultChunkUtils) storedChecksums(ctx context.Context, chunkDir string, checksums []string) error {
+	for i := range checksums {
+		chunkFile := fmt.Sprintf("%08d", i+1)
+		chunk, _, _, _, ok, err := w.Server.Get(ctx, chunkDir, chunkFile)
+		if err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("chunk %s at %s is missing", chunkFile, chunkDir)
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, chunk)
+		chunk.Close()
+		if err != nil {
+			return err
+		}
+		checksums[i] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return nil
+}
+
+// chunkResult reports a chunk that was written.
+type chunkResult struct {
+	size     uint64
+	checksum string
+}
+
+// writeRemaining resolves the item and writes all chunks after the first
+// `resumed` chunks.
+func (w *DefaultChunkUtils) writeRemaining(
+	ctx context.Context,
+	address string,
+	chunkDir string,
+	info *types.ChunksInfo,
+	resumed uint64,
+	resolve types.Resolver,
+) error {
+	numChunks := info.NumChunks
+	sz := info.FileSize
+
+	// Pipe results from resolver (pW) to chunk writer (pR)
+	pR, pW := io.Pipe()
+
 	// Write all chunks
-	results := make(chan uint64)
+	results := make(chan chunkResult)
 	errs := make(chan error, 1)
-	go w.writeChunks(ctx, numChunks, chunkDir, pR, results, errs)
+	go w.writeChunks(ctx, resumed+1, numChunks, chunkDir, pR, results, errs)
+
+	// When resuming, the resolver either skips the bytes that are already
+	// stored or they are discarded as they are written.
+	var writer io.Writer = pW
+	offset := resumed * w.ChunkSize
+	if resumed > 0 {
+		writer = &resumeWriter{w: pW, offset: offset, discard: offset}
+	}
 
 	// Resolve/get the data we need
 	resolverErrs := make(chan error)
@@ -93,70 +260,66 @@ func (w *DefaultChunkUtils) WriteChunked(
 				resolverErrs <- closeErr
 			}
 		}(pW)
-		_, _, resolveErr := resolve(pW)
+		_, _, resolveErr := resolve(writer)
 		if resolveErr != nil {
 			resolverErrs <- resolveErr
 		}
 	}()
 
+	// Wait for the resolver to return, so that it is done with its source
+	// when the write returns. Closing the reader unblocks a resolver that is
+	// still writing.
+	defer func() {
+		_ = pR.Close()
+		for range resolverErrs {
+		}
+	}()
+
 	// tally up the number of bytes written so it can be compared to
 	// the file's size to ensure all the file's content are written
-	totalBytesWritten := uint64(0)
-	chunkCount := uint64(0)
+	totalBytesWritten := offset
+	chunkCount := resumed
 
 	// Wait for all results to complete
-	err = func() error {
-		for {
-			select {
-			case err = <-resolverErrs:
-				if err != nil {
-					return err
-				}
-			case err = <-errs:
+	for {
+		select {
+		case err := <-resolverErrs:
+			if err != nil {
 				return err
-			case bytesWritten := <-results:
-				chunkCount++
-				// tally up the bytes written so it can be checked later
-				totalBytesWritten += bytesWritten
-				err = w.Notifier.Notify(ctx, &types.ChunkNotification{
-					Address: address,
-					Chunk:   chunkCount,
-				})
-				if err != nil {
-					slog.Error("unable to notify of chunk completion", "address", address, "chunk", chunkCount, "error", err)
-				}
-				if chunkCount == numChunks {
-					// if the total number of bytes written doesn't equal the size of the file, then something
-					// went wrong
-					if totalBytesWritten != sz {
-						return fmt.Errorf("expected to write '%d' bytes but only wrote '%d' bytes", sz, totalBytesWritten)
-					}
-					return nil
+			}
+		case err := <-errs:
+			return err
+		case result := <-results:
+			info.Checksums[chunkCount] = result.checksum
+			chunkCount++
+			// tally up the bytes written so it can be checked later
+			totalBytesWritten += result.size
+			err := w.Notifier.Notify(ctx, &types.ChunkNotification{
+				Address: address,
+				Chunk:   chunkCount,
+			})
+			if err != nil {
+				slog.Error("unable to notify of chunk completion", "address", address, "chunk", chunkCount, "error", err)
+			}
+			if chunkCount == numChunks {
+				// if the total number of bytes written doesn't equal the size of the file, then something
+				// went wrong
+				if totalBytesWritten != sz {
+					return fmt.Errorf("expected to write '%d' bytes but only wrote '%d' bytes", sz, totalBytesWritten)
 				}
+				return nil
 			}
 		}
-	}()
-	if err != nil {
-		return
 	}
-
-	// Update `info.json` when complete
-	info.Complete = true
-	info.ModTime = time.Now()
-	_, _, err = w.Server.Put(ctx, infoResolver, chunkDir, "info.json")
-	if err != nil {
-		return
-	}
-
-	return
 }
 
 func (w *DefaultChunkUtils) writeChunks(
 	ctx context.Context,
+	first uint64,
 	numChunks uint64,
 	chunkDir string,
 	r *io.PipeReader,
-	results chan uint64,
+	results chan chunkResult,
 	errs chan error,
 ) {
 	defer func(r *io.PipeReader) {
@@ -167,11 +330,19 @@ func (w *DefaultChunkUtils) writeChunks(
 		close(results)
 		close(errs)
 	}(r)
-	for i := uint64(1); i <= numChunks; i++ {
+	if w.Concurrency > 1 {
+		err := w.writeChunksParallel(ctx, first, numChunks, chunkDir, r, results)
+		if err != nil {
+			errs <- err
+		}
+		return
+	}
+	for i := first; i <= numChunks; i++ {
 		err := func() error {
 			var copiedBytes uint64
+			hash := sha256.New()
 			resolve := func(writer io.Writer) (dir, address string, err error) {
-				written, err := io.CopyN(writer, r, int64(w.ChunkSize))
+				written, err := io.CopyN(io.MultiWriter(writer, hash), r, int64(w.ChunkSize))
 				if err != nil {
 					// an End of File error should be considered a critical error if it is
 					// returned before the last chunk
@@ -194,7 +365,10 @@ func (w *DefaultChunkUtils) writeChunks(
 
 			// if no error was encountered, report the number of bytes copied so it can be
 			// computed to ensure the download was successful
-			results <- copiedBytes
+			results <- chunkResult{
+				size:     copiedBytes,
+				checksum: hex.EncodeToString(hash.Sum(nil)),
+			}
 			return nil
 		}()
 		if err != nil {
@@ -204,6 +378,89 @@ func (w *DefaultChunkUtils) writeChunks(
 	}
 }
 
+// writeChunksParallel reads each chunk into memory and uploads up to
+// `Concurrency` chunks at once. The size and checksum of each chunk are sent
+// to `results` in chunk order once the chunk and all earlier chunks are
+// uploaded.
+func (w *DefaultChunkUtils) writeChunksParallel(
+	ctx context.Context,
+	first uint64,
+	numChunks uint64,
+	chunkDir string,
+	r io.Reader,
+	results chan chunkResult,
+) error {
+	type upload struct {
+		result chunkResult
+		done   chan error
+	}
+	pending := make([]upload, 0, w.Concurrency)
+
+	// Wait for the oldest upload to complete and report its result
+	next := func() error {
+		u := pending[0]
+		pending = pending[1:]
+		err := <-u.done
+		if err != nil {
+			return err
+		}
+		results <- u.result
+		return nil
+	}
+
+	// Wait for the remaining uploads so that none outlive a failed write
+	fail := func(err error) error {
+		for _, u := range pending {
+			<-u.done
+		}
+		return err
+	}
+
+	for i := first; i <= numChunks; i++ {
+		buf := bytes.NewBuffer(make([]byte, 0, w.ChunkSize))
+		written, err := io.CopyN(buf, r, int64(w.ChunkSize))
+		// an End of File error should be considered a critical error if it is
+		// returned before the last chunk
+		if err != nil && !(errors.Is(err, io.EOF) && i == numChunks) {
+			return fail(err)
+		}
+
+		sum := sha256.Sum256(buf.Bytes())
+		u := upload{
+			result: chunkResult{
+				size:     uint64(written),
+				checksum: hex.EncodeToString(sum[:]),
+			},
+			done: make(chan error, 1),
+		}
+		go func(chunkFile string, data []byte) {
+			resolve := func(writer io.Writer) (dir, address string, err error) {
+				_, err = writer.Write(data)
+				return
+			}
+			_, _, err := w.Server.Put(ctx, resolve, chunkDir, chunkFile)
+			u.done <- err
+		}(fmt.Sprintf("%08d", i), buf.Bytes())
+		pending = append(pending, u)
+
+		if len(pending) >= w.Concurrency {
+			err = next()
+			if err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		err := next()
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	return nil
+}
+
 func (w *DefaultChunkUtils) ReadChunked(
 	ctx context.Context,
 	dir string,
@@ -211,11 +468,61 @@ func (w *DefaultChunkUtils) ReadChunked(
 ) (rc io.ReadCloser, chunksInfo *types.ChunksInfo, size int64, modTime time.Time, err error) {
 	chunkDir := filepath.Join(dir, address)
 
-	infoFile, _, _, _, ok, err := w.Server.Get(ctx, chunkDir, "info.json")
+	info, err := w.readInfo(ctx, chunkDir)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, err
+	}
+
+	pR, pW := io.Pipe()
+	go func() {
+		readErr := w.readChunks(ctx, address, chunkDir, info.NumChunks, info.Complete, pW)
+		if readErr != nil {
+			slog.Error("unable to read chunked file", "error", readErr)
+		}
+	}()
+
+	return pR, info, int64(info.FileSize), info.ModTime, nil
+}
+
+// ReadChunkedRange reads `length` bytes of a chunked asset starting at
+// `offset`. Only the chunks covering the range are opened. A negative `length`
+// reads to the end of the asset.
+func (w *DefaultChunkUtils) ReadChunkedRange(
+	ctx context.Context,
+	dir string,
+	address string,
+	offset int64,
+	length int64,
+) (rc io.ReadCloser, chunksInfo *types.ChunksInfo, size int64, modTime time.Time, err error) {
+	chunkDir := filepath.Join(dir, address)
+
+	info, err := w.readInfo(ctx, chunkDir)
 	if err != nil {
 		return nil, nil, 0, time.Time{}, err
+	}
+
+	n, err := ClampRange(int64(info.FileSize), offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, err
+	}
+
+	pR, pW := io.Pipe()
+	go func() {
+		readErr := w.readChunkRange(ctx, address, chunkDir, info, offset, n, pW)
+		if readErr != nil {
+			slog.Error("unable to read chunked file range", "error", readErr)
+		}
+	}()
+
+	return pR, info, int64(info.FileSize), info.ModTime, nil
+}
+
+func (w *DefaultChunkUtils) readInfo(ctx context.Context, chunkDir string) (chunksInfo *types.ChunksInfo, err error) {
+	infoFile, _, _, _, ok, err := w.Server.Get(ctx, chunkDir, "info.json")
+	if err != nil {
+		return nil, err
 	} else if !ok {
-		return nil, nil, 0, time.Time{}, rsstorage.ErrNoChunkMetadata
+		return nil, rsstorage.ErrNoChunkMetadata
 	}
 	defer func(infoFile io.ReadCloser) {
 		closeErr := infoFile.Close()
@@ -228,18 +535,18 @@ func (w *DefaultChunkUtils) ReadChunked(
 	dec := json.NewDecoder(infoFile)
 	err = dec.Decode(&info)
 	if err != nil {
-		return nil, nil, 0, time.Time{}, err
+		return nil, err
 	}
 
-	pR, pW := io.Pipe()
-	go func() {
-		readErr := w.readChunks(ctx, address, chunkDir, info.NumChunks, info.Complete, pW)
-		if readErr != nil {
-			slog.Error("unable to read chunked file", "error", readErr)
-		}
-	}()
+	return &info, nil
+}
 
-	return pR, &info, int64(info.FileSize), info.ModTime, nil
+// chunkRead describes the portion of a chunk to read. A negative length reads
+// the entire chunk.
+type chunkRead struct {
+	index  uint64
+	offset int64
+	length int64
 }
 
 func (w *DefaultChunkUtils) readChunks(
@@ -249,6 +556,64 @@ func (w *DefaultChunkUtils) readChunks(
 	numChunks uint64,
 	complete bool,
 	writer *io.PipeWriter,
+) error {
+	reads := make([]chunkRead, 0, numChunks)
+	for i := uint64(1); i <= numChunks; i++ {
+		reads = append(reads, chunkRead{index: i, offset: 0, length: -1})
+	}
+
+	return w.copyChunks(ctx, address, chunkDir, complete, reads, writer)
+}
+
+func (w *DefaultChunkUtils) readChunkRange(
+	ctx context.Context,
+	address string,
+	chunkDir string,
+	info *types.ChunksInfo,
+	offset int64,
+	length int64,
+	writer *io.PipeWriter,
+) error {
+	if length == 0 {
+		return writer.Close()
+	}
+
+	// Determine the first and last chunks that cover the range
+	chunkSize := int64(info.ChunkSize)
+	fileSize := int64(info.FileSize)
+	end := offset + length
+	first := uint64(offset/chunkSize) + 1
+	last := uint64((end-1)/chunkSize) + 1
+
+	reads := make([]chunkRead, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		chunkStart := int64(i-1) * chunkSize
+		chunkEnd := min(chunkStart+chunkSize, fileSize)
+
+		// Determine the portion of this chunk to read. Chunks that are fully
+		// covered by the range are read normally.
+		start := max(offset, chunkStart) - chunkStart
+		stop := min(end, chunkEnd) - chunkStart
+		read := chunkRead{index: i, offset: 0, length: -1}
+		if start != 0 || stop != chunkEnd-chunkStart {
+			read.offset, read.length = start, stop-start
+		}
+		reads = append(reads, read)
+	}
+
+	return w.copyChunks(ctx, address, chunkDir, info.Complete, reads, writer)
+}
+
+// copyChunks reads the requested chunks in order and writes them to `writer`.
+// The writer is closed when done, or closed with an error if a chunk cannot
+// be read.
+func (w *DefaultChunkUtils) copyChunks(
+	ctx context.Context,
+	address string,
+	chunkDir string,
+	complete bool,
+	reads []chunkRead,
+	writer *io.PipeWriter,
 ) (err error) {
 	defer func(writer *io.PipeWriter) {
 		closeErr := writer.Close()
@@ -257,33 +622,97 @@ func (w *DefaultChunkUtils) readChunks(
 		}
 	}(writer)
 
-	for i := uint64(1); i <= numChunks; i++ {
-		err = w.retryingChunkRead(ctx, i, address, chunkDir, complete, writer)
-		if err != nil {
-			closeErr := writer.CloseWithError(err)
-			if closeErr != nil {
-				return errors.Join(err, closeErr)
+	if w.ReadAhead > 1 {
+		err = w.prefetchChunks(ctx, address, chunkDir, complete, reads, writer)
+	} else {
+		for _, read := range reads {
+			err = w.retryingChunkRead(ctx, read.index, address, chunkDir, complete, read.offset, read.length, writer)
+			if err != nil {
+				break
 			}
-			return err
 		}
 	}
+	if err != nil {
+		closeErr := writer.CloseWithError(err)
+		if closeErr != nil {
+			return errors.Join(err, closeErr)
+		}
+		return err
+	}
 
 	return
 }
 
+// prefetchChunks reads up to `ReadAhead` chunks at once into memory and
+// writes them to `writer` in order.
+func (w *DefaultChunkUtils) prefetchChunks(
+	ctx context.Context,
+	address string,
+	chunkDir string,
+	complete bool,
+	reads []chunkRead,
+	writer io.Writer,
+) error {
+	// Stop any outstanding prefetches when done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type prefetch struct {
+		buf  *bytes.Buffer
+		done chan error
+	}
+	pending := make([]prefetch, 0, w.ReadAhead)
+	next := 0
+	fetch := func() {
+		read := reads[next]
+		next++
+		p := prefetch{
+			buf:  &bytes.Buffer{},
+			done: make(chan error, 1),
+		}
+		go func() {
+			p.done <- w.retryingChunkRead(ctx, read.index, address, chunkDir, complete, read.offset, read.length, p.buf)
+		}()
+		pending = append(pending, p)
+	}
+
+	for next < len(reads) && len(pending) < w.ReadAhead {
+		fetch()
+	}
+	for len(pending) > 0 {
+		p := pending[0]
+		pending = pending[1:]
+		err := <-p.done
+		if err != nil {
+			return err
+		}
+		_, err = p.buf.WriteTo(writer)
+		if err != nil {
+			return err
+		}
+		if next < len(reads) {
+			fetch()
+		}
+	}
+
+	return nil
+}
+
 func (w *DefaultChunkUtils) retryingChunkRead(
 	ctx context.Context,
 	chunkIndex uint64,
 	address string,
 	chunkDir string,
 	complete bool,
-	writer *io.PipeWriter,
+	offset int64,
+	length int64,
+	writer io.Writer,
 ) (err error) {
 	attempts := 0
 	for {
 		attempts += 1
 		var done bool
-		done, err = w.tryChunkRead(ctx, attempts, chunkIndex, address, chunkDir, complete, writer)
+		done, err = w.tryChunkRead(ctx, attempts, chunkIndex, address, chunkDir, complete, offset, length, writer)
 		if err != nil || done {
 			return
 		}
@@ -297,14 +726,23 @@ func (w *DefaultChunkUtils) tryChunkRead(
 	address string,
 	chunkDir string,
 	complete bool,
-	writer *io.PipeWriter,
+	offset int64,
+	length int64,
+	writer io.Writer,
 ) (found bool, err error) {
 	chunkFile := fmt.Sprintf("%08d", chunkIndex)
 
-	// Open the chunks sequentially
-	chunk, _, _, _, ok, err := w.Server.Get(ctx, chunkDir, chunkFile)
+	// Open the chunks sequentially. Only open a range of the chunk when
+	// a partial chunk is requested.
+	var chunk io.ReadCloser
+	var ok bool
+	if offset == 0 && length < 0 {
+		chunk, _, _, _, ok, err = w.Server.Get(ctx, chunkDir, chunkFile)
+	} else {
+		chunk, _, _, _, ok, err = rsstorage.GetRange(ctx, w.Server, chunkDir, chunkFile, offset, length)
+	}
 	if err != nil {
-		return false, fmt.Errorf("error opening chunk file at %s: %s", chunkDir, err)
+		return false, fmt.Errorf("error opening chunk file at %s: %w", chunkDir, err)
 	} else if !ok {
 		if !complete {
 			// If we've waited 5 minutes for this chunk to appear, err to avoid
@@ -340,6 +778,48 @@ func (w *DefaultChunkUtils) tryChunkRead(
 	return true, nil
 }
 
+// resumeWriter is the ResumableWriter passed to a resolver when resuming a
+// chunked write. It discards the bytes that are already stored unless the
+// resolver skips them itself.
+type resumeWriter struct {
+	w       io.Writer
+	offset  uint64
+	discard uint64
+	written bool
+}
+
+func (r *resumeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 {
+		r.written = true
+	}
+	if r.discard > 0 {
+		skip := min(r.discard, uint64(len(p)))
+		r.discard -= skip
+		p = p[skip:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	_, err := r.w.Write(p)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *resumeWriter) Offset() uint64 {
+	return r.offset
+}
+
+func (r *resumeWriter) Skip() error {
+	if r.written {
+		return errors.New("cannot skip stored bytes after writing")
+	}
+	r.discard = 0
+	return nil
+}
+
 func FilterChunks(input []types.StoredItem) []types.StoredItem {
 	output := make([]types.StoredItem, 0)
 	chunkDirs := make(map[string]bool)
@@ -0,0 +1,52 @@
+package internal
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type ChunksSuite struct{}
+
+var _ = check.Suite(&ChunksSuite{})
+
+func (s *ChunksSuite) TestWriteChunkedWaitsForResolver(c *check.C) {
+	ctx := context.Background()
+	defer leaktest.Check(c)
+
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	cw := &DefaultChunkUtils{
+		ChunkSize: 5,
+		Server:    &rsstorage.DummyStorageServer{},
+		Waiter:    wn,
+		Notifier:  wn,
+	}
+
+	// The resolver is still running after the last chunk is written
+	var done bool
+	resolve := func(writer io.Writer) (string, string, error) {
+		_, err := io.WriteString(writer, "0123456789")
+		time.Sleep(50 * time.Millisecond)
+		done = true
+		return "", "", err
+	}
+	c.Assert(cw.WriteChunked(ctx, "dir", "address", 10, resolve), check.IsNil)
+	c.Check(done, check.Equals, true)
+
+	// Resolvers that write too much are not left blocked
+	resolve = func(writer io.Writer) (string, string, error) {
+		_, err := io.WriteString(writer, "0123456789abcde")
+		return "", "", err
+	}
+	c.Assert(cw.WriteChunked(ctx, "dir", "address", 10, resolve), check.IsNil)
+}
@@ -0,0 +1,90 @@
+package internal
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"path"
+	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// ChunkGrouper turns a stream of stored files into stored items, like
+// `FilterChunks` does for a list. The files of each chunk directory must be
+// adjacent in the stream, which is true for a listing in lexical order since
+// chunk directories have no subdirectories. Chunks are held until the
+// `info.json` of their directory is found, or until another file shows that
+// the directory is not chunked.
+type ChunkGrouper struct {
+	dir     string
+	chunked bool
+	pending []types.StoredItem
+	size    int64
+}
+
+// Add adds the next file in the stream, and yields the items that are
+// complete. Returns false if `yield` returned false.
+func (g *ChunkGrouper) Add(file types.StoredItem, yield func(types.StoredItem) bool) bool {
+	if file.Dir != g.dir {
+		if !g.Flush(yield) {
+			return false
+		}
+		g.dir = file.Dir
+		g.chunked = false
+		g.size = 0
+	}
+
+	if g.chunked {
+		// Chunk data after the `info.json`
+		return true
+	} else if file.Dir != "" && file.Address == "info.json" {
+		g.chunked = true
+		g.pending = nil
+		d, f := path.Split(file.Dir)
+		return yield(types.StoredItem{
+			Dir:     strings.TrimSuffix(d, "/"),
+			Address: f,
+			Chunked: true,
+			Size:    g.size,
+			ModTime: file.ModTime,
+			Token:   file.Token,
+		})
+	} else if file.Dir != "" && isChunkName(file.Address) {
+		g.pending = append(g.pending, file)
+		g.size += file.Size
+		return true
+	}
+
+	return g.Flush(yield) && yield(file)
+}
+
+// Flush yields any held files as items. Call it at the end of the stream.
+func (g *ChunkGrouper) Flush(yield func(types.StoredItem) bool) bool {
+	pending := g.pending
+	g.pending = nil
+	for _, item := range pending {
+		if !yield(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// isChunkName returns true for the names of chunk files, e.g., `00000001`.
+func isChunkName(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesPrefix returns true if the path of an item starts with the prefix
+// set in the options.
+func MatchesPrefix(item types.StoredItem, opts types.EnumerateOptions) bool {
+	return strings.HasPrefix(item.Path(), opts.Prefix)
+}
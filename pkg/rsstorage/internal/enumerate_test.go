@@ -0,0 +1,62 @@
+package internal
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type EnumerateSuite struct{}
+
+var _ = check.Suite(&EnumerateSuite{})
+
+func (s *EnumerateSuite) TestChunkGrouper(c *check.C) {
+	files := []types.StoredItem{
+		{Address: "PACKAGES", Size: 1, Token: "PACKAGES"},
+		{Dir: "dir", Address: "00000001", Size: 2, Token: "dir/00000001"},
+		{Dir: "dir", Address: "data.json", Size: 3, Token: "dir/data.json"},
+		{Dir: "dir/chunked", Address: "00000001", Size: 4, Token: "dir/chunked/00000001"},
+		{Dir: "dir/chunked", Address: "00000002", Size: 5, Token: "dir/chunked/00000002"},
+		{Dir: "dir/chunked", Address: "info.json", Size: 6, Token: "dir/chunked/info.json"},
+		{Dir: "dir/chunked", Address: "00000003", Size: 7, Token: "dir/chunked/00000003"},
+		{Dir: "incomplete", Address: "00000001", Size: 8, Token: "incomplete/00000001"},
+	}
+
+	var items []types.StoredItem
+	yield := func(item types.StoredItem) bool {
+		items = append(items, item)
+		return true
+	}
+	g := &ChunkGrouper{}
+	for _, file := range files {
+		c.Assert(g.Add(file, yield), check.Equals, true)
+	}
+	c.Assert(g.Flush(yield), check.Equals, true)
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		files[0],
+		files[1],
+		files[2],
+		{Dir: "dir", Address: "chunked", Chunked: true, Size: 9, Token: "dir/chunked/info.json"},
+		files[7],
+	})
+
+	// Stopped by the consumer
+	n := 0
+	stop := func(item types.StoredItem) bool {
+		n++
+		return false
+	}
+	g = &ChunkGrouper{}
+	c.Check(g.Add(files[1], stop), check.Equals, true)
+	c.Check(g.Add(files[2], stop), check.Equals, false)
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *EnumerateSuite) TestMatchesPrefix(c *check.C) {
+	item := types.StoredItem{Dir: "dir", Address: "address"}
+	c.Check(MatchesPrefix(item, types.EnumerateOptions{}), check.Equals, true)
+	c.Check(MatchesPrefix(item, types.EnumerateOptions{Prefix: "dir/add"}), check.Equals, true)
+	c.Check(MatchesPrefix(item, types.EnumerateOptions{Prefix: "dir/b"}), check.Equals, false)
+}
@@ -8,10 +8,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,6 +29,7 @@ import (
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/postgres"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/s3server"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
@@ -114,6 +118,12 @@ func (s *ChunksIntegrationSuite) NewServerSet(c *check.C, class, prefix string)
 		CacheTimeout: time.Minute,
 		WalkTimeout:  time.Minute,
 	})
+	memoryServer := memory.NewStorageServer(memory.StorageServerArgs{
+		ChunkSize: 100 * 1024,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     class,
+	})
 
 	return map[string]rsstorage.StorageServer{
 		"file": rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
@@ -131,16 +141,21 @@ func (s *ChunksIntegrationSuite) NewServerSet(c *check.C, class, prefix string)
 			Server: pgServer,
 			Store:  cstore,
 		}),
+		"memory": rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
+			Name:   "memory",
+			Server: memoryServer,
+			Store:  cstore,
+		}),
 	}
 }
 
-// This test will only validate File storage when used without Postgres and MinIO. To test
+// This test will only validate File and memory storage when used without Postgres and MinIO. To test
 // all services, use the `make test-integration` target. To run these tests only, use:
 // `MODULE=pkg/rsstorage/internal/integration_test just test-integration -v github.com/rstudio/platform-lib/pkg/rsstorage/internal/integration_test -check.f=ChunksIntegrationSuite`
 func (s *ChunksIntegrationSuite) TestWriteChunked(c *check.C) {
 	serverSet := s.NewServerSet(c, "chunks", "")
 	for key, server := range serverSet {
-		if testing.Short() && key != "file" {
+		if testing.Short() && key != "file" && key != "memory" {
 			slog.Info("skipping chunks integration tests because -short was provided", "server", key)
 		} else {
 			slog.Info("testing chunks integration tests", "server", key)
@@ -205,6 +220,9 @@ func (s *ChunksIntegrationSuite) check(c *check.C, chunkServer rsstorage.Storage
 	c.Assert(err, check.IsNil)
 	c.Check(time.Now().Sub(info.ModTime).Minutes() < 2, check.Equals, true)
 	info.ModTime = time.Time{}
+
+	checkChecksums(c, &info, servertest.TestDESC)
+	info.Checksums = nil
 	c.Assert(info, check.DeepEquals, types.ChunksInfo{
 		ChunkSize: 5,
 		NumChunks: 391,
@@ -231,6 +249,230 @@ func (s *ChunksIntegrationSuite) check(c *check.C, chunkServer rsstorage.Storage
 	c.Assert(err, check.IsNil)
 	assembledChecksum := hex.EncodeToString(sha.Sum(nil))
 	c.Assert(assembledChecksum, check.Equals, "b62eee53e57da53802a706f1ede904e0051675c981f9df5974b913bd76b1e8f8")
+
+	// Read a range spanning several partial chunks
+	rr, _, size, _, err := cw.ReadChunkedRange(ctx, "0a", "test-chunk", 13, 21)
+	c.Assert(err, check.IsNil)
+	c.Assert(size, check.Equals, int64(1953))
+	defer rr.Close()
+	rb, err := io.ReadAll(rr)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(rb), check.Equals, servertest.TestDESC[13:34])
+}
+
+// This test will only validate File and memory storage when used without Postgres and MinIO.
+func (s *ChunksIntegrationSuite) TestResumeChunked(c *check.C) {
+	serverSet := s.NewServerSet(c, "resume", "")
+	for key, server := range serverSet {
+		if testing.Short() && key != "file" && key != "memory" {
+			slog.Info("skipping chunks integration tests because -short was provided", "server", key)
+		} else {
+			slog.Info("testing resumed chunks integration tests", "server", key)
+			s.checkResume(c, server)
+		}
+	}
+}
+
+// checkChecksums verifies that the checksum of each chunk of `data` was
+// recorded.
+func checkChecksums(c *check.C, info *types.ChunksInfo, data string) {
+	c.Assert(info.Checksums, check.HasLen, int(info.NumChunks))
+	for i := uint64(1); i <= info.NumChunks; i++ {
+		start := (i - 1) * info.ChunkSize
+		sum := sha256.Sum256([]byte(data[start : start+info.ExpectedChunkSize(i)]))
+		c.Check(info.Checksum(i), check.Equals, hex.EncodeToString(sum[:]), check.Commentf("chunk %d", i))
+	}
+}
+
+func (s *ChunksIntegrationSuite) checkResume(c *check.C, chunkServer rsstorage.StorageServer) {
+	ctx := context.Background()
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+
+	cw := &internal.DefaultChunkUtils{
+		ChunkSize: 100,
+		Server:    chunkServer,
+		Waiter:    wn,
+		Notifier:  wn,
+		Resume:    true,
+	}
+	sz := uint64(len(servertest.TestDESC))
+
+	// Fail part way through the 8th chunk. The chunks that were already
+	// written are kept.
+	resolveFail := func(writer io.Writer) (dir, address string, err error) {
+		_, err = io.Copy(writer, strings.NewReader(servertest.TestDESC[:750]))
+		if err == nil {
+			err = errors.New("interrupted")
+		}
+		return
+	}
+	for _, address := range []string{"skipped", "discarded"} {
+		err := cw.WriteChunked(ctx, "0b", address, sz, resolveFail)
+		c.Assert(err, check.ErrorMatches, "interrupted")
+		ok, chunked, _, _, err := chunkServer.Check(ctx, "0b", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(chunked.Complete, check.Equals, false)
+	}
+
+	// Resume with a resolver that skips the stored bytes itself
+	var offset uint64
+	resolveSkip := func(writer io.Writer) (dir, address string, err error) {
+		rw, ok := writer.(types.ResumableWriter)
+		c.Assert(ok, check.Equals, true)
+		offset = rw.Offset()
+		err = rw.Skip()
+		if err != nil {
+			return
+		}
+		_, err = io.Copy(writer, strings.NewReader(servertest.TestDESC[offset:]))
+		return
+	}
+	err := cw.WriteChunked(ctx, "0b", "skipped", sz, resolveSkip)
+	c.Assert(err, check.IsNil)
+	c.Check(offset, check.Equals, uint64(700))
+
+	// Resume with a resolver that writes everything from the start
+	resolve := func(writer io.Writer) (dir, address string, err error) {
+		_, err = io.Copy(writer, strings.NewReader(servertest.TestDESC))
+		return
+	}
+	err = cw.WriteChunked(ctx, "0b", "discarded", sz, resolve)
+	c.Assert(err, check.IsNil)
+
+	for _, address := range []string{"skipped", "discarded"} {
+		r, info, size, _, err := cw.ReadChunked(ctx, "0b", address)
+		c.Assert(err, check.IsNil)
+		c.Check(size, check.Equals, int64(sz))
+		c.Check(info.Complete, check.Equals, true)
+		c.Check(info.Resumes, check.Equals, uint64(1))
+		checkChecksums(c, info, servertest.TestDESC)
+		b, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Assert(r.Close(), check.IsNil)
+		c.Check(string(b), check.Equals, servertest.TestDESC)
+	}
+
+	// Writing a complete item again starts over
+	err = cw.WriteChunked(ctx, "0b", "skipped", sz, resolve)
+	c.Assert(err, check.IsNil)
+	_, info, _, _, err := cw.ReadChunked(ctx, "0b", "skipped")
+	c.Assert(err, check.IsNil)
+	c.Check(info.Resumes, check.Equals, uint64(0))
+}
+
+// slowServer delays writes and reads of chunk files so that parallel
+// transfers complete out of order.
+type slowServer struct {
+	rsstorage.StorageServer
+}
+
+func (s *slowServer) delay(address string) {
+	if n, err := strconv.Atoi(address); err == nil {
+		time.Sleep(time.Duration(5-n%5) * time.Millisecond)
+	}
+}
+
+func (s *slowServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	s.delay(address)
+	return s.StorageServer.Put(ctx, resolve, dir, address)
+}
+
+func (s *slowServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	s.delay(address)
+	return s.StorageServer.Get(ctx, dir, address)
+}
+
+// recordingNotifier records the chunks that were notified.
+type recordingNotifier struct {
+	servertest.DummyWaiterNotifier
+	mutex  sync.Mutex
+	chunks []uint64
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, c *types.ChunkNotification) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.chunks = append(n.chunks, c.Chunk)
+	return n.DummyWaiterNotifier.Notify(ctx, c)
+}
+
+// This test will only validate File and memory storage when used without Postgres and MinIO.
+func (s *ChunksIntegrationSuite) TestParallelChunks(c *check.C) {
+	serverSet := s.NewServerSet(c, "parallel", "")
+	for key, server := range serverSet {
+		if testing.Short() && key != "file" && key != "memory" {
+			slog.Info("skipping chunks integration tests because -short was provided", "server", key)
+		} else {
+			slog.Info("testing parallel chunks integration tests", "server", key)
+			s.checkParallel(c, &slowServer{StorageServer: server})
+		}
+	}
+}
+
+func (s *ChunksIntegrationSuite) checkParallel(c *check.C, chunkServer rsstorage.StorageServer) {
+	ctx := context.Background()
+	wn := &recordingNotifier{
+		DummyWaiterNotifier: servertest.DummyWaiterNotifier{
+			Ch: make(chan bool, 1),
+		},
+	}
+
+	cw := &internal.DefaultChunkUtils{
+		ChunkSize:   100,
+		Server:      chunkServer,
+		Waiter:      wn,
+		Notifier:    wn,
+		Concurrency: 4,
+		ReadAhead:   3,
+	}
+	sz := uint64(len(servertest.TestDESC))
+
+	resolve := func(writer io.Writer) (dir, address string, err error) {
+		_, err = io.Copy(writer, strings.NewReader(servertest.TestDESC))
+		return
+	}
+	err := cw.WriteChunked(ctx, "0c", "parallel", sz, resolve)
+	c.Assert(err, check.IsNil)
+
+	// Notifications are sent in chunk order
+	expected := make([]uint64, 0, 20)
+	for i := uint64(1); i <= 20; i++ {
+		expected = append(expected, i)
+	}
+	c.Check(wn.chunks, check.DeepEquals, expected)
+
+	// Read chunks in order
+	r, info, size, _, err := cw.ReadChunked(ctx, "0c", "parallel")
+	c.Assert(err, check.IsNil)
+	c.Check(info.NumChunks, check.Equals, uint64(20))
+	c.Check(size, check.Equals, int64(sz))
+	checkChecksums(c, info, servertest.TestDESC)
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Close(), check.IsNil)
+	c.Check(string(b), check.Equals, servertest.TestDESC)
+
+	// Read a range in order
+	r, _, _, _, err = cw.ReadChunkedRange(ctx, "0c", "parallel", 150, 1000)
+	c.Assert(err, check.IsNil)
+	b, err = io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Close(), check.IsNil)
+	c.Check(string(b), check.Equals, servertest.TestDESC[150:1150])
+
+	// A short resolver fails the write
+	resolveShort := func(writer io.Writer) (dir, address string, err error) {
+		_, err = io.Copy(writer, strings.NewReader(servertest.TestDESC[:1000]))
+		return
+	}
+	err = cw.WriteChunked(ctx, "0c", "short", sz, resolveShort)
+	c.Assert(err, check.ErrorMatches, "EOF")
+	ok, _, _, _, err := chunkServer.Check(ctx, "0c", "short")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
 }
 
 type ChunksPartialReadSuite struct {
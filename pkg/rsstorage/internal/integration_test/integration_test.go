@@ -31,6 +31,7 @@ import (
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/postgres"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/s3server"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
@@ -117,6 +118,12 @@ func EphemeralPostgresPool(dbname string) (pool *pgxpool.Pool, err error) {
 		"	address TEXT UNIQUE NOT NULL " +
 		");"
 	_, err = pool.Exec(context.Background(), sql)
+	if err != nil {
+		return
+	}
+
+	// Bring the table up to date
+	err = postgres.Migrate(context.Background(), pool)
 
 	return
 }
@@ -204,6 +211,12 @@ func (s *StorageIntegrationSuite) NewServerSet(c *check.C, class, prefix string)
 		CacheTimeout: time.Minute,
 		WalkTimeout:  time.Minute,
 	})
+	memoryServer := memory.NewStorageServer(memory.StorageServerArgs{
+		ChunkSize: 100 * 1024,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     class,
+	})
 
 	return map[string]rsstorage.StorageServer{
 		"file": rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
@@ -221,6 +234,11 @@ func (s *StorageIntegrationSuite) NewServerSet(c *check.C, class, prefix string)
 			Server: pgServer,
 			Store:  cstore,
 		}),
+		"memory": rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
+			Name:   "memory",
+			Server: memoryServer,
+			Store:  cstore,
+		}),
 	}
 }
 
@@ -0,0 +1,31 @@
+package memtest
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
+)
+
+// NewServer returns a memory storage server of `class` that splits chunked
+// items into chunks of `chunkSize` bytes.
+func NewServer(class string, chunkSize uint64) rsstorage.StorageServer {
+	return New(memory.StorageServerArgs{
+		ChunkSize: chunkSize,
+		Class:     class,
+	})
+}
+
+// New returns a memory storage server. A `servertest.DummyWaiterNotifier` is
+// used unless a waiter and notifier are set.
+func New(args memory.StorageServerArgs) rsstorage.StorageServer {
+	if args.Waiter == nil && args.Notifier == nil {
+		wn := &servertest.DummyWaiterNotifier{
+			Ch: make(chan bool, 1),
+		}
+		args.Waiter = wn
+		args.Notifier = wn
+	}
+	return memory.NewStorageServer(args)
+}
@@ -3,14 +3,17 @@ package servertest
 // Copyright (C) 2022 by RStudio, PBC
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
 )
 
@@ -27,10 +30,18 @@ func (f *DummyChunkUtils) WriteChunked(ctx context.Context, dir, address string,
 	return f.WriteErr
 }
 
+func (f *DummyChunkUtils) WriteChunkedWithMetadata(ctx context.Context, dir, address string, sz uint64, meta types.Metadata, resolve types.Resolver) error {
+	return f.WriteErr
+}
+
 func (f *DummyChunkUtils) ReadChunked(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, error) {
 	return f.Read, f.ReadCh, f.ReadSz, f.ReadMod, f.ReadErr
 }
 
+func (f *DummyChunkUtils) ReadChunkedRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, error) {
+	return f.Read, f.ReadCh, f.ReadSz, f.ReadMod, f.ReadErr
+}
+
 type DummyWaiterNotifier struct {
 	Ch chan bool
 }
@@ -52,6 +63,66 @@ func (d *DummyWaiterNotifier) Notify(ctx context.Context, c *types.ChunkNotifica
 	return nil
 }
 
+// FakeCacheStore is a `rsstorage.CacheStore` that records nothing.
+type FakeCacheStore struct{}
+
+func (f *FakeCacheStore) CacheObjectEnsureExists(cacheName, key string) error {
+	return nil
+}
+
+func (f *FakeCacheStore) CacheObjectMarkUse(cacheName, key string, accessTime time.Time) error {
+	return nil
+}
+
+// StringResolver returns a resolver that writes `data`.
+func StringResolver(data string) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewBufferString(data))
+		return "", "", err
+	}
+}
+
+// ReadAll reads and closes `r`.
+func ReadAll(c *check.C, r io.ReadCloser) string {
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	return string(b)
+}
+
+// ReadItem gets and reads an item that must exist.
+func ReadItem(c *check.C, server rsstorage.StorageServer, dir, address string) string {
+	r, _, _, _, ok, err := server.Get(context.Background(), dir, address)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	return ReadAll(c, r)
+}
+
+// Exists checks whether an item exists.
+func Exists(c *check.C, server rsstorage.StorageServer, dir, address string) bool {
+	ok, _, _, _, err := server.Check(context.Background(), dir, address)
+	c.Assert(err, check.IsNil)
+	return ok
+}
+
+// TestData returns `n` bytes of test data.
+func TestData(n int) string {
+	return strings.Repeat(TestDESC, n/len(TestDESC)+1)[:n]
+}
+
+// CheckCopy checks that a wrapping `server` fails to copy a missing item
+// with `missingErr`, and that an item it stores with `ctx` can be copied to
+// `dest` and read back.
+func CheckCopy(c *check.C, ctx context.Context, server, dest rsstorage.StorageServer, missingErr string) {
+	err := server.Copy(ctx, "dir", "missing", dest)
+	c.Check(err, check.ErrorMatches, missingErr)
+
+	_, _, err = server.Put(ctx, StringResolver("some data"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Copy(ctx, "dir", "a", dest), check.IsNil)
+	c.Check(ReadItem(c, dest, "dir", "a"), check.Equals, "some data")
+}
+
 type timeEquals struct {
 	*check.CheckerInfo
 }
@@ -3,10 +3,18 @@ package internal
 // Copyright (C) 2022 by RStudio, PBC
 
 import (
+	"errors"
+	"io"
 	"math/rand"
 	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
 )
 
+// ErrNotResolved closes the pipe to a secondary write when the resolver
+// returns before the item is complete.
+var ErrNotResolved = errors.New("item was not resolved")
+
 func MinInt64(a, b int64) int64 {
 	if a < b {
 		return a
@@ -48,3 +56,46 @@ func NotEmptyJoin(a []string, sep string) string {
 	}
 	return strings.Join(b, sep)
 }
+
+// ClampRange validates a byte range against the size of an item and returns
+// the number of bytes that can be read from `offset`. A negative `length`
+// reads to the end of the item.
+func ClampRange(size, offset, length int64) (int64, error) {
+	if offset < 0 || offset > size {
+		return 0, rsstorage.ErrInvalidRange
+	}
+	remaining := size - offset
+	if length < 0 || length > remaining {
+		return remaining, nil
+	}
+	return length, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// LimitReadCloser returns a ReadCloser that reads at most `n` bytes from `rc`
+// and closes `rc` when closed.
+func LimitReadCloser(rc io.ReadCloser, n int64) io.ReadCloser {
+	return &limitedReadCloser{
+		Reader: io.LimitReader(rc, n),
+		Closer: rc,
+	}
+}
+
+// BestEffortWriter writes to `W` until the first error. It then stops writing
+// and ignores the error, which is kept in `Err`, so that a write to other
+// destinations continues.
+type BestEffortWriter struct {
+	W   io.Writer
+	Err error
+}
+
+func (b *BestEffortWriter) Write(p []byte) (int, error) {
+	if b.Err == nil {
+		_, b.Err = b.W.Write(p)
+	}
+	return len(p), nil
+}
@@ -3,10 +3,15 @@ package internal
 // Copyright (C) 2022 by RStudio, PBC
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"regexp"
 	"testing"
 
 	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
 )
 
 func TestPackage(t *testing.T) { check.TestingT(t) }
@@ -48,3 +53,69 @@ func (s *UtilSuite) TestNotEmptyJoin(c *check.C) {
 	outputB := NotEmptyJoin(caseB, "/")
 	c.Assert(outputB, check.Equals, outputA)
 }
+
+func (s *UtilSuite) TestClampRange(c *check.C) {
+	n, err := ClampRange(100, 0, -1)
+	c.Check(err, check.IsNil)
+	c.Check(n, check.Equals, int64(100))
+
+	n, err = ClampRange(100, 10, 20)
+	c.Check(err, check.IsNil)
+	c.Check(n, check.Equals, int64(20))
+
+	n, err = ClampRange(100, 90, 20)
+	c.Check(err, check.IsNil)
+	c.Check(n, check.Equals, int64(10))
+
+	n, err = ClampRange(100, 100, -1)
+	c.Check(err, check.IsNil)
+	c.Check(n, check.Equals, int64(0))
+
+	_, err = ClampRange(100, 101, -1)
+	c.Check(err, check.Equals, rsstorage.ErrInvalidRange)
+
+	_, err = ClampRange(100, -1, 10)
+	c.Check(err, check.Equals, rsstorage.ErrInvalidRange)
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (s *UtilSuite) TestLimitReadCloser(c *check.C) {
+	rc := &closeRecorder{Reader: bytes.NewBufferString("some test data")}
+	limited := LimitReadCloser(rc, 4)
+	b, err := io.ReadAll(limited)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "some")
+	c.Assert(limited.Close(), check.IsNil)
+	c.Check(rc.closed, check.Equals, true)
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("write error")
+}
+
+func (s *UtilSuite) TestBestEffortWriter(c *check.C) {
+	fw := &failingWriter{}
+	buf := &bytes.Buffer{}
+	w := io.MultiWriter(buf, &BestEffortWriter{W: fw})
+	for range 2 {
+		n, err := w.Write([]byte("data"))
+		c.Assert(err, check.IsNil)
+		c.Check(n, check.Equals, 4)
+	}
+	c.Check(buf.String(), check.Equals, "datadata")
+	c.Check(fw.writes, check.Equals, 1)
+}
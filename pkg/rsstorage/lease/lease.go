@@ -0,0 +1,98 @@
+package lease
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLeaseHeld = errors.New("the lease is held by another owner")
+	ErrLeaseLost = errors.New("the lease expired or was released")
+)
+
+// Leaser grants leases on addresses, so that only one owner at a time writes
+// an address.
+type Leaser interface {
+	// Acquire acquires the lease on an address for `ttl`. It returns
+	// `ErrLeaseHeld` when another owner holds an unexpired lease on the
+	// address.
+	Acquire(ctx context.Context, address string, ttl time.Duration) (Lease, error)
+}
+
+// Lease is a lease on an address held until it expires or is released.
+type Lease interface {
+	Address() string
+
+	// Renew extends the lease to `ttl` from now. It returns `ErrLeaseLost`
+	// when the lease already expired or was released.
+	Renew(ctx context.Context, ttl time.Duration) error
+
+	// Release releases the lease. Releasing a lease that expired or was
+	// already released is not an error.
+	Release(ctx context.Context) error
+}
+
+// Wait acquires the lease on an address, retrying every `interval` while
+// another owner holds it, until the context is done.
+func Wait(ctx context.Context, leaser Leaser, address string, ttl, interval time.Duration) (Lease, error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		l, err := leaser.Acquire(ctx, address, ttl)
+		if !errors.Is(err, ErrLeaseHeld) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ErrLeaseHeld, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// Keep renews a lease every half `ttl` until the returned stop function is
+// called. The returned context is cancelled with `ErrLeaseLost` as its cause
+// when the lease cannot be renewed, so work done under the lease stops. The
+// stop function returns the error that failed a renewal, if any, and does
+// not release the lease.
+func Keep(ctx context.Context, l Lease, ttl time.Duration) (context.Context, func() error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var renewErr error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(ttl / 2)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := l.Renew(ctx, ttl); err != nil {
+					if !errors.Is(err, ErrLeaseLost) {
+						err = errors.Join(ErrLeaseLost, err)
+					}
+					renewErr = err
+					cancel(err)
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() error {
+		close(done)
+		wg.Wait()
+		cancel(nil)
+		return renewErr
+	}
+}
@@ -0,0 +1,109 @@
+package lease
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type LeaseSuite struct{}
+
+var _ = check.Suite(&LeaseSuite{})
+
+type fakeLease struct {
+	mutex    sync.Mutex
+	renewed  int
+	renewErr error
+}
+
+func (f *fakeLease) Address() string {
+	return "address"
+}
+
+func (f *fakeLease) Renew(ctx context.Context, ttl time.Duration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.renewed++
+	return f.renewErr
+}
+
+func (f *fakeLease) Release(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeLease) renewals() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.renewed
+}
+
+// fakeLeaser grants a lease after `held` attempts.
+type fakeLeaser struct {
+	held     int
+	attempts int
+	err      error
+}
+
+func (f *fakeLeaser) Acquire(ctx context.Context, address string, ttl time.Duration) (Lease, error) {
+	f.attempts++
+	if f.err != nil {
+		return nil, f.err
+	} else if f.attempts <= f.held {
+		return nil, ErrLeaseHeld
+	}
+	return &fakeLease{}, nil
+}
+
+func (s *LeaseSuite) TestWait(c *check.C) {
+	leaser := &fakeLeaser{held: 2}
+	l, err := Wait(context.Background(), leaser, "address", time.Minute, time.Millisecond)
+	c.Assert(err, check.IsNil)
+	c.Check(l, check.NotNil)
+	c.Check(leaser.attempts, check.Equals, 3)
+
+	// Other errors are returned immediately
+	leaser = &fakeLeaser{err: errors.New("acquire error")}
+	_, err = Wait(context.Background(), leaser, "address", time.Minute, time.Millisecond)
+	c.Check(err, check.ErrorMatches, "acquire error")
+	c.Check(leaser.attempts, check.Equals, 1)
+
+	// Waits until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leaser = &fakeLeaser{held: 1 << 30}
+	_, err = Wait(ctx, leaser, "address", time.Minute, time.Millisecond)
+	c.Check(errors.Is(err, ErrLeaseHeld), check.Equals, true)
+	c.Check(errors.Is(err, context.DeadlineExceeded), check.Equals, true)
+}
+
+func (s *LeaseSuite) TestKeep(c *check.C) {
+	l := &fakeLease{}
+	ctx, stop := Keep(context.Background(), l, 10*time.Millisecond)
+	for l.renewals() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Check(stop(), check.IsNil)
+	c.Check(ctx.Err(), check.Equals, context.Canceled)
+	c.Check(context.Cause(ctx), check.Equals, context.Canceled)
+
+	// The context is cancelled when the lease is lost
+	l = &fakeLease{renewErr: errors.New("renew error")}
+	ctx, stop = Keep(context.Background(), l, 10*time.Millisecond)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for the lease to be lost")
+	}
+	c.Check(errors.Is(context.Cause(ctx), ErrLeaseLost), check.Equals, true)
+	err := stop()
+	c.Check(errors.Is(err, ErrLeaseLost), check.Equals, true)
+	c.Check(err, check.ErrorMatches, "(?s).*renew error")
+}
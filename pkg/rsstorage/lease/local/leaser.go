@@ -0,0 +1,85 @@
+package local
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+)
+
+// Leaser grants leases within a single process. Use it when only one node
+// writes to storage.
+type Leaser struct {
+	mutex  sync.Mutex
+	leases map[string]*entry
+	next   uint64
+	now    func() time.Time
+}
+
+type entry struct {
+	id      uint64
+	expires time.Time
+}
+
+func NewLeaser() *Leaser {
+	return &Leaser{
+		leases: make(map[string]*entry),
+		now:    time.Now,
+	}
+}
+
+func (l *Leaser) Acquire(ctx context.Context, address string, ttl time.Duration) (lease.Lease, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if e, ok := l.leases[address]; ok && now.Before(e.expires) {
+		return nil, lease.ErrLeaseHeld
+	}
+	l.next++
+	l.leases[address] = &entry{
+		id:      l.next,
+		expires: now.Add(ttl),
+	}
+	return &localLease{
+		leaser:  l,
+		address: address,
+		id:      l.next,
+	}, nil
+}
+
+type localLease struct {
+	leaser  *Leaser
+	address string
+	id      uint64
+}
+
+func (l *localLease) Address() string {
+	return l.address
+}
+
+func (l *localLease) Renew(ctx context.Context, ttl time.Duration) error {
+	l.leaser.mutex.Lock()
+	defer l.leaser.mutex.Unlock()
+
+	now := l.leaser.now()
+	e, ok := l.leaser.leases[l.address]
+	if !ok || e.id != l.id || !now.Before(e.expires) {
+		return lease.ErrLeaseLost
+	}
+	e.expires = now.Add(ttl)
+	return nil
+}
+
+func (l *localLease) Release(ctx context.Context) error {
+	l.leaser.mutex.Lock()
+	defer l.leaser.mutex.Unlock()
+
+	if e, ok := l.leaser.leases[l.address]; ok && e.id == l.id {
+		delete(l.leaser.leases, l.address)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package local
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type LocalLeaserSuite struct{}
+
+var _ = check.Suite(&LocalLeaserSuite{})
+
+// newLeaser returns a leaser with a clock that is advanced by `clock`.
+func newLeaser() (*Leaser, *time.Time) {
+	leaser := NewLeaser()
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaser.now = func() time.Time {
+		return clock
+	}
+	return leaser, &clock
+}
+
+func (s *LocalLeaserSuite) TestAcquire(c *check.C) {
+	ctx := context.Background()
+	leaser, clock := newLeaser()
+
+	l, err := leaser.Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(l.Address(), check.Equals, "a")
+
+	// Held until it expires
+	_, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+	_, err = leaser.Acquire(ctx, "b", time.Minute)
+	c.Check(err, check.IsNil)
+
+	*clock = clock.Add(time.Minute)
+	other, err := leaser.Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+
+	// The expired lease cannot be renewed, and releasing it does not release
+	// the new lease
+	c.Check(l.Renew(ctx, time.Minute), check.Equals, lease.ErrLeaseLost)
+	c.Check(l.Release(ctx), check.IsNil)
+	_, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+
+	// Released leases can be acquired again
+	c.Check(other.Release(ctx), check.IsNil)
+	c.Check(other.Release(ctx), check.IsNil)
+	c.Check(other.Renew(ctx, time.Minute), check.Equals, lease.ErrLeaseLost)
+	_, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Check(err, check.IsNil)
+}
+
+func (s *LocalLeaserSuite) TestRenew(c *check.C) {
+	ctx := context.Background()
+	leaser, clock := newLeaser()
+
+	l, err := leaser.Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+	*clock = clock.Add(50 * time.Second)
+	c.Assert(l.Renew(ctx, time.Minute), check.IsNil)
+
+	// Still held past the original expiry
+	*clock = clock.Add(50 * time.Second)
+	_, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+
+	*clock = clock.Add(10 * time.Second)
+	c.Check(l.Renew(ctx, time.Minute), check.Equals, lease.ErrLeaseLost)
+}
@@ -0,0 +1,141 @@
+package pgxlease
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+)
+
+// Leaser grants leases with Postgres session-level advisory locks, so leases
+// are shared by every node using the same database. Each held lease keeps a
+// connection from the pool until it is released or expires, so the pool size
+// limits the number of leases held at once. Leases held by a node that exits
+// are released when its connections close.
+type Leaser struct {
+	pool      *pgxpool.Pool
+	namespace string
+}
+
+type LeaserArgs struct {
+	Pool *pgxpool.Pool
+
+	// Namespace is included in the lock keys, so that applications sharing a
+	// database do not hold leases on each other's addresses.
+	Namespace string
+}
+
+func NewLeaser(args LeaserArgs) *Leaser {
+	return &Leaser{
+		pool:      args.Pool,
+		namespace: args.Namespace,
+	}
+}
+
+func (l *Leaser) Acquire(ctx context.Context, address string, ttl time.Duration) (lease.Lease, error) {
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := l.key(address)
+	var ok bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	} else if !ok {
+		conn.Release()
+		return nil, lease.ErrLeaseHeld
+	}
+
+	pl := &pgxLease{
+		conn:    conn,
+		key:     key,
+		address: address,
+	}
+	pl.timer = time.AfterFunc(ttl, pl.expire)
+	return pl, nil
+}
+
+// key returns the advisory lock key for an address.
+func (l *Leaser) key(address string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(l.namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(address))
+	return int64(h.Sum64())
+}
+
+type pgxLease struct {
+	mutex   sync.Mutex
+	conn    *pgxpool.Conn
+	timer   *time.Timer
+	key     int64
+	address string
+}
+
+func (l *pgxLease) Address() string {
+	return l.address
+}
+
+func (l *pgxLease) Renew(ctx context.Context, ttl time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// When the timer already fired, the lease is expiring
+	if l.conn == nil || !l.timer.Stop() {
+		return lease.ErrLeaseLost
+	}
+
+	// The lock is gone if the session ended
+	if err := l.conn.Ping(ctx); err != nil {
+		l.unlock()
+		return errors.Join(lease.ErrLeaseLost, err)
+	}
+	l.timer.Reset(ttl)
+	return nil
+}
+
+func (l *pgxLease) Release(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	l.timer.Stop()
+	l.unlock()
+	return nil
+}
+
+func (l *pgxLease) expire() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.conn != nil {
+		l.unlock()
+	}
+}
+
+// unlock releases the advisory lock and returns the connection to the pool.
+// If the lock cannot be released, the connection is closed instead, which
+// also releases the lock.
+func (l *pgxLease) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if err != nil {
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+	l.conn = nil
+}
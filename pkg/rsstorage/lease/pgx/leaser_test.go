@@ -0,0 +1,101 @@
+package pgxlease
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listeners/postgrespgx"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type PgxLeaserSuite struct {
+	pool *pgxpool.Pool
+}
+
+var _ = check.Suite(&PgxLeaserSuite{})
+
+func (s *PgxLeaserSuite) SetUpSuite(c *check.C) {
+	if testing.Short() {
+		c.Skip("PgxLeaserSuite only runs on Postgres")
+	}
+
+	var err error
+	s.pool, err = postgrespgx.EphemeralPgxPool("postgres")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *PgxLeaserSuite) TearDownSuite(c *check.C) {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+}
+
+func (s *PgxLeaserSuite) TestKey(c *check.C) {
+	a := NewLeaser(LeaserArgs{Namespace: "a"})
+	b := NewLeaser(LeaserArgs{Namespace: "b"})
+	c.Check(a.key("address"), check.Equals, a.key("address"))
+	c.Check(a.key("address"), check.Not(check.Equals), a.key("other"))
+	c.Check(a.key("address"), check.Not(check.Equals), b.key("address"))
+}
+
+func (s *PgxLeaserSuite) TestAcquire(c *check.C) {
+	ctx := context.Background()
+	leaser := NewLeaser(LeaserArgs{Pool: s.pool, Namespace: "acquire"})
+
+	l, err := leaser.Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(l.Address(), check.Equals, "a")
+
+	// Held on other connections
+	_, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+	b, err := leaser.Acquire(ctx, "b", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(b.Release(ctx), check.IsNil)
+
+	// Not held in other namespaces
+	other, err := NewLeaser(LeaserArgs{Pool: s.pool, Namespace: "other"}).Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(other.Release(ctx), check.IsNil)
+
+	c.Assert(l.Renew(ctx, time.Minute), check.IsNil)
+	c.Check(l.Release(ctx), check.IsNil)
+	c.Check(l.Release(ctx), check.IsNil)
+	c.Check(l.Renew(ctx, time.Minute), check.Equals, lease.ErrLeaseLost)
+
+	l, err = leaser.Acquire(ctx, "a", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(l.Release(ctx), check.IsNil)
+}
+
+func (s *PgxLeaserSuite) TestExpire(c *check.C) {
+	ctx := context.Background()
+	leaser := NewLeaser(LeaserArgs{Pool: s.pool, Namespace: "expire"})
+
+	l, err := leaser.Acquire(ctx, "a", 10*time.Millisecond)
+	c.Assert(err, check.IsNil)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		other, err := leaser.Acquire(ctx, "a", time.Minute)
+		if err == nil {
+			c.Check(other.Release(ctx), check.IsNil)
+			break
+		}
+		c.Assert(err, check.Equals, lease.ErrLeaseHeld)
+		select {
+		case <-timeout:
+			c.Fatal("timed out waiting for the lease to expire")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	c.Check(l.Renew(ctx, time.Minute), check.Equals, lease.ErrLeaseLost)
+}
@@ -0,0 +1,58 @@
+package migrate
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checkpoint records the progress of a migration so it can be resumed. The
+// token is that of the last item that was migrated, such that all the items
+// before it were also migrated. See `types.EnumerateOptions`.
+type Checkpoint interface {
+	// Load returns the saved token, or an empty string if there is none.
+	Load() (string, error)
+	Save(token string) error
+}
+
+// FileCheckpoint saves the token in a file.
+type FileCheckpoint struct {
+	Path string
+}
+
+func (c *FileCheckpoint) Load() (string, error) {
+	b, err := os.ReadFile(c.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("error loading checkpoint %s: %w", c.Path, err)
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// Save writes the token to a temporary file that replaces the checkpoint, so
+// an interrupted save never leaves a partial token.
+func (c *FileCheckpoint) Save(token string) error {
+	f, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("error saving checkpoint %s: %w", c.Path, err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(token + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving checkpoint %s: %w", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package migrate
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+)
+
+// OpenFunc returns the storage server for a location given on the command
+// line. Applications decide what a location is, e.g., a storage class in
+// their configuration, or a URL.
+type OpenFunc func(ctx context.Context, location string) (rsstorage.StorageServer, error)
+
+type CommandArgs struct {
+	// Use defaults to "migrate".
+	Use  string
+	Open OpenFunc
+}
+
+// NewCommand returns a cobra command that runs a `Migrator`. Applications add
+// it to their root command.
+func NewCommand(args CommandArgs) *cobra.Command {
+	var (
+		source            string
+		destination       string
+		prefix            string
+		concurrency       int
+		checkpoint        string
+		verify            string
+		dryRun            bool
+		deleteAfterVerify bool
+	)
+
+	use := args.Use
+	if use == "" {
+		use = "migrate"
+	}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Migrate stored items from one storage location to another",
+		Example: fmt.Sprintf(`  %[1]s --source=<location> --destination=<location> --checkpoint=migrate.checkpoint
+  %[1]s --source=<location> --destination=<location> --dry-run
+  %[1]s --source=<location> --destination=<location> --verify=checksum --delete-after-verify
+`, use),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if source == "" || destination == "" {
+				return errors.New("both --source and --destination are required")
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			src, err := args.Open(ctx, source)
+			if err != nil {
+				return fmt.Errorf("error opening source %s: %w", source, err)
+			}
+			dest, err := args.Open(ctx, destination)
+			if err != nil {
+				return fmt.Errorf("error opening destination %s: %w", destination, err)
+			}
+
+			migratorArgs := MigratorArgs{
+				Source:            src,
+				Destination:       dest,
+				Prefix:            prefix,
+				Concurrency:       concurrency,
+				Verify:            Verification(verify),
+				DryRun:            dryRun,
+				DeleteAfterVerify: deleteAfterVerify,
+			}
+			if checkpoint != "" {
+				migratorArgs.Checkpoint = &FileCheckpoint{Path: checkpoint}
+			}
+			result, err := NewMigrator(migratorArgs).Migrate(ctx)
+
+			action := "Copied"
+			if dryRun {
+				action = "Would copy"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Checked %d items. %s %d, skipped %d, deleted %d, failed %d.\n",
+				result.Checked, action, result.Copied, result.Skipped, result.Deleted, result.Failed)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "The storage location to migrate from.")
+	cmd.Flags().StringVar(&destination, "destination", "", "The storage location to migrate to.")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Only migrate items with paths that start with this prefix.")
+	cmd.Flags().IntVar(&concurrency, "concurrency", DefaultConcurrency, "The number of items to copy at once.")
+	cmd.Flags().StringVar(&checkpoint, "checkpoint", "", "A file that records progress, so an interrupted migration resumes where it stopped.")
+	cmd.Flags().StringVar(&verify, "verify", string(VerifySize), "How to verify items at the destination. Either 'size' or 'checksum'.")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report the items that would be copied.")
+	cmd.Flags().BoolVar(&deleteAfterVerify, "delete-after-verify", false, "Remove items from the source once they are verified at the destination.")
+	return cmd
+}
@@ -0,0 +1,72 @@
+package migrate
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type CommandSuite struct{}
+
+var _ = check.Suite(&CommandSuite{})
+
+func (s *CommandSuite) TestCommand(c *check.C) {
+	servers := map[string]rsstorage.StorageServer{
+		"source": memtest.NewServer("source", 4096),
+		"dest":   newFileServer(c),
+	}
+	populate(c, servers["source"])
+	open := func(ctx context.Context, location string) (rsstorage.StorageServer, error) {
+		server, ok := servers[location]
+		if !ok {
+			return nil, errors.New("unknown location")
+		}
+		return server, nil
+	}
+	run := func(args ...string) (string, error) {
+		cmd := NewCommand(CommandArgs{Open: open})
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	cmd := NewCommand(CommandArgs{Open: open})
+	c.Check(cmd.Use, check.Equals, "migrate")
+
+	_, err := run("--source=source")
+	c.Check(err, check.ErrorMatches, "both --source and --destination are required")
+	_, err = run("--source=missing", "--destination=dest")
+	c.Check(err, check.ErrorMatches, "error opening source missing: unknown location")
+	_, err = run("--source=source", "--destination=missing")
+	c.Check(err, check.ErrorMatches, "error opening destination missing: unknown location")
+
+	out, err := run("--source=source", "--destination=dest", "--dry-run")
+	c.Assert(err, check.IsNil)
+	c.Check(out, check.Equals, "Checked 3 items. Would copy 3, skipped 0, deleted 0, failed 0.\n")
+
+	checkpoint := filepath.Join(c.MkDir(), "checkpoint")
+	out, err = run("--source=source", "--destination=dest", "--checkpoint="+checkpoint, "--verify=checksum", "--concurrency=2")
+	c.Assert(err, check.IsNil)
+	c.Check(out, check.Equals, "Checked 3 items. Copied 3, skipped 0, deleted 0, failed 0.\n")
+	token, err := (&FileCheckpoint{Path: checkpoint}).Load()
+	c.Assert(err, check.IsNil)
+	c.Check(token, check.Equals, "c/two")
+	c.Check(servertest.ReadItem(c, servers["dest"], "b", "chunked"), check.Equals, servertest.ReadItem(c, servers["source"], "b", "chunked"))
+
+	out, err = run("--source=source", "--destination=dest", "--prefix=a/", "--delete-after-verify")
+	c.Assert(err, check.IsNil)
+	c.Check(out, check.Equals, "Checked 1 items. Copied 0, skipped 1, deleted 1, failed 0.\n")
+	c.Check(servertest.Exists(c, servers["source"], "a", "one"), check.Equals, false)
+}
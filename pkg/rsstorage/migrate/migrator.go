@@ -0,0 +1,347 @@
+package migrate
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const (
+	DefaultConcurrency = 4
+
+	// The checkpoint is saved after this many items are migrated, and when
+	// the migration ends.
+	checkpointInterval = 100
+)
+
+// Verification is how items are verified at the destination.
+type Verification string
+
+const (
+	// VerifySize compares the sizes of the source and destination items.
+	VerifySize Verification = "size"
+
+	// VerifyChecksum compares the SHA-256 checksums of the source and
+	// destination items. Both items are read in full.
+	VerifyChecksum Verification = "checksum"
+)
+
+// Migrator copies the items in one storage server to another. Any two
+// storage servers can be used, e.g., from file storage to S3. Items are
+// copied with the source server's `Copy`, so chunked items stay chunked.
+type Migrator struct {
+	source            rsstorage.StorageServer
+	destination       rsstorage.StorageServer
+	prefix            string
+	concurrency       int
+	checkpoint        Checkpoint
+	verify            Verification
+	dryRun            bool
+	deleteAfterVerify bool
+}
+
+type MigratorArgs struct {
+	Source      rsstorage.StorageServer
+	Destination rsstorage.StorageServer
+
+	// Prefix limits the migration to the items with paths that start with
+	// it. See `types.EnumerateOptions`.
+	Prefix string
+
+	// Concurrency is the number of items copied at once. Defaults to
+	// `DefaultConcurrency`.
+	Concurrency int
+
+	// Checkpoint is optional. When set, the migration resumes after the
+	// saved item, and saves its progress.
+	Checkpoint Checkpoint
+
+	// Verify defaults to `VerifySize`.
+	Verify Verification
+
+	// DryRun only reports the items that would be copied.
+	DryRun bool
+
+	// DeleteAfterVerify removes each item from the source once it is
+	// verified at the destination.
+	DeleteAfterVerify bool
+}
+
+func NewMigrator(args MigratorArgs) *Migrator {
+	m := &Migrator{
+		source:            args.Source,
+		destination:       args.Destination,
+		prefix:            args.Prefix,
+		concurrency:       args.Concurrency,
+		checkpoint:        args.Checkpoint,
+		verify:            args.Verify,
+		dryRun:            args.DryRun,
+		deleteAfterVerify: args.DeleteAfterVerify,
+	}
+	if m.concurrency <= 0 {
+		m.concurrency = DefaultConcurrency
+	}
+	if m.verify == "" {
+		m.verify = VerifySize
+	}
+	return m
+}
+
+// MigrateResult summarizes a migration.
+type MigrateResult struct {
+	// Checked is the number of items found in the source.
+	Checked int
+
+	// Copied is the number of items copied. In a dry run, it is the number
+	// of items that would be copied.
+	Copied int
+
+	// Skipped is the number of items that were already at the destination,
+	// or that were removed from the source before they were copied.
+	Skipped int
+
+	// Deleted is the number of items removed from the source after they
+	// were verified.
+	Deleted int
+
+	// Failed is the number of items that could not be migrated. The errors
+	// are returned by `Migrate`.
+	Failed int
+}
+
+type itemOutcome struct {
+	copied  bool
+	skipped bool
+	deleted bool
+}
+
+type job struct {
+	index int
+	item  types.StoredItem
+}
+
+type jobResult struct {
+	job
+	outcome itemOutcome
+	err     error
+}
+
+// Migrate copies the items from the source to the destination, and verifies
+// them. Items that are already at the destination are verified, but not
+// copied again, so an interrupted migration can be run again. Errors for
+// individual items do not stop the migration; they are joined and returned
+// once the other items are migrated. The checkpoint never advances past an
+// item that failed.
+func (m *Migrator) Migrate(ctx context.Context) (MigrateResult, error) {
+	if m.verify != VerifySize && m.verify != VerifyChecksum {
+		return MigrateResult{}, fmt.Errorf("invalid verification %q", m.verify)
+	}
+
+	var after string
+	if m.checkpoint != nil {
+		var err error
+		if after, err = m.checkpoint.Load(); err != nil {
+			return MigrateResult{}, err
+		}
+	}
+
+	jobs := make(chan job)
+	results := make(chan jobResult)
+	var enumErr error
+	go func() {
+		defer close(jobs)
+		index := 0
+		opts := types.EnumerateOptions{Prefix: m.prefix, After: after}
+		for item, err := range rsstorage.EnumerateItems(ctx, m.source, opts) {
+			if err != nil {
+				enumErr = fmt.Errorf("error enumerating source: %w", err)
+				return
+			}
+			select {
+			case jobs <- job{index: index, item: item}:
+				index++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := make(chan struct{})
+	for range m.concurrency {
+		go func() {
+			defer func() { workers <- struct{}{} }()
+			for j := range jobs {
+				outcome, err := m.migrateItem(ctx, j.item)
+				results <- jobResult{job: j, outcome: outcome, err: err}
+			}
+		}()
+	}
+	go func() {
+		for range m.concurrency {
+			<-workers
+		}
+		close(results)
+	}()
+
+	var result MigrateResult
+	var errs []error
+	progress := &progress{done: make(map[int]string), last: after}
+	for r := range results {
+		result.Checked++
+		if r.err != nil {
+			result.Failed++
+			errs = append(errs, fmt.Errorf("error migrating dir=%s and address=%s: %w", r.item.Dir, r.item.Address, r.err))
+			continue
+		}
+		if r.outcome.copied {
+			result.Copied++
+		}
+		if r.outcome.skipped {
+			result.Skipped++
+		}
+		if r.outcome.deleted {
+			result.Deleted++
+		}
+		if progress.complete(r.index, r.item.Token) >= checkpointInterval {
+			errs = append(errs, m.save(progress))
+		}
+	}
+	if enumErr != nil {
+		errs = append(errs, enumErr)
+	} else if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if progress.unsaved > 0 {
+		errs = append(errs, m.save(progress))
+	}
+	return result, errors.Join(errs...)
+}
+
+// progress tracks the last item such that it and all the items before it
+// were migrated.
+type progress struct {
+	done    map[int]string
+	next    int
+	last    string
+	unsaved int
+}
+
+// complete records that an item was migrated, and returns the number of
+// items the checkpoint has advanced by since it was last saved.
+func (p *progress) complete(index int, token string) int {
+	p.done[index] = token
+	for {
+		token, ok := p.done[p.next]
+		if !ok {
+			break
+		}
+		delete(p.done, p.next)
+		p.next++
+		p.last = token
+		p.unsaved++
+	}
+	return p.unsaved
+}
+
+func (m *Migrator) save(p *progress) error {
+	if m.checkpoint == nil || m.dryRun {
+		return nil
+	}
+	p.unsaved = 0
+	return m.checkpoint.Save(p.last)
+}
+
+func (m *Migrator) migrateItem(ctx context.Context, item types.StoredItem) (itemOutcome, error) {
+	ok, _, sz, _, err := m.source.Check(ctx, item.Dir, item.Address)
+	if err != nil {
+		return itemOutcome{}, err
+	} else if !ok {
+		// Removed since it was enumerated
+		return itemOutcome{skipped: true}, nil
+	}
+
+	var outcome itemOutcome
+	if err = m.verifyItem(ctx, item, sz); errors.Is(err, errNotVerified) {
+		if m.dryRun {
+			slog.Debug("Would migrate item", "dir", item.Dir, "address", item.Address)
+			return itemOutcome{copied: true}, nil
+		}
+		start := time.Now()
+		if err = m.source.Copy(ctx, item.Dir, item.Address, m.destination); err != nil {
+			return itemOutcome{}, err
+		}
+		if err = m.verifyItem(ctx, item, sz); err != nil {
+			return itemOutcome{}, err
+		}
+		slog.Debug("Migrated item", "dir", item.Dir, "address", item.Address, "duration", time.Since(start))
+		outcome.copied = true
+	} else if err != nil {
+		return itemOutcome{}, err
+	} else {
+		outcome.skipped = true
+	}
+
+	if m.deleteAfterVerify && !m.dryRun {
+		if err = m.source.Remove(ctx, item.Dir, item.Address); err != nil {
+			return itemOutcome{}, fmt.Errorf("error removing verified item: %w", err)
+		}
+		outcome.deleted = true
+	}
+	return outcome, nil
+}
+
+var errNotVerified = errors.New("item does not match at the destination")
+
+// verifyItem returns `errNotVerified` if the item is missing or different at
+// the destination.
+func (m *Migrator) verifyItem(ctx context.Context, item types.StoredItem, sz int64) error {
+	ok, chunked, destSz, _, err := m.destination.Check(ctx, item.Dir, item.Address)
+	if err != nil {
+		return err
+	} else if !ok || chunked != nil && !chunked.Complete {
+		return errNotVerified
+	} else if destSz != sz {
+		return fmt.Errorf("%w: source size %d and destination size %d", errNotVerified, sz, destSz)
+	}
+
+	if m.verify == VerifyChecksum {
+		sum, err := checksum(ctx, m.source, item)
+		if err != nil {
+			return err
+		}
+		destSum, err := checksum(ctx, m.destination, item)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sum, destSum) {
+			return fmt.Errorf("%w: checksums differ", errNotVerified)
+		}
+	}
+	return nil
+}
+
+func checksum(ctx context.Context, server rsstorage.StorageServer, item types.StoredItem) ([]byte, error) {
+	r, _, _, _, ok, err := server.Get(ctx, item.Dir, item.Address)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errNotVerified
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
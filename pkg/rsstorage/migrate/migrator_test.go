@@ -0,0 +1,247 @@
+package migrate
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type MigratorSuite struct{}
+
+var _ = check.Suite(&MigratorSuite{})
+
+func newFileServer(c *check.C) rsstorage.StorageServer {
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	return file.NewStorageServer(file.StorageServerArgs{
+		Dir:       c.MkDir(),
+		ChunkSize: 1024,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     "file",
+	})
+}
+
+// populate stores a chunked item and two other items.
+func populate(c *check.C, server rsstorage.StorageServer) {
+	ctx := context.Background()
+	data := servertest.TestDESC
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "a", "one")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "b", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("more data"), "c", "two")
+	c.Assert(err, check.IsNil)
+}
+
+// truncatingServer stores the first byte of each item.
+type truncatingServer struct {
+	rsstorage.StorageServer
+}
+
+func (s *truncatingServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.StorageServer.Put(ctx, func(w io.Writer) (string, string, error) {
+		buf := &bytes.Buffer{}
+		d, a, err := resolve(buf)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = w.Write(buf.Bytes()[:1])
+		return d, a, err
+	}, dir, address)
+}
+
+func (s *truncatingServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+type memoryCheckpoint struct {
+	token string
+	saved int
+	err   error
+}
+
+func (c *memoryCheckpoint) Load() (string, error) {
+	return c.token, c.err
+}
+
+func (c *memoryCheckpoint) Save(token string) error {
+	c.token = token
+	c.saved++
+	return nil
+}
+
+func (s *MigratorSuite) TestMigrate(c *check.C) {
+	ctx := context.Background()
+	source := newFileServer(c)
+	dest := memtest.NewServer("dest", 4096)
+	populate(c, source)
+	checkpoint := &memoryCheckpoint{}
+
+	m := NewMigrator(MigratorArgs{
+		Source:      source,
+		Destination: dest,
+		Concurrency: 2,
+		Checkpoint:  checkpoint,
+	})
+	c.Check(m.concurrency, check.Equals, 2)
+	c.Check(m.verify, check.Equals, VerifySize)
+	result, err := m.Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 3, Copied: 3})
+	c.Check(servertest.ReadItem(c, dest, "a", "one"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, dest, "b", "chunked"), check.Equals, servertest.TestDESC)
+	c.Check(servertest.ReadItem(c, dest, "c", "two"), check.Equals, "more data")
+	ok, chunked, _, _, err := dest.Check(ctx, "b", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+	c.Check(checkpoint.token, check.Equals, "c/two")
+	c.Check(checkpoint.saved, check.Equals, 1)
+
+	// Items at the destination are skipped
+	checkpoint.token = ""
+	result, err = m.Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 3, Skipped: 3})
+
+	// Resumed after the checkpoint
+	_, _, err = source.Put(ctx, servertest.StringResolver("new data"), "d", "three")
+	c.Assert(err, check.IsNil)
+	result, err = m.Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 1, Copied: 1})
+	c.Check(servertest.ReadItem(c, dest, "d", "three"), check.Equals, "new data")
+	c.Check(checkpoint.token, check.Equals, "d/three")
+
+	// Limited to a prefix
+	dest = memtest.NewServer("dest", 4096)
+	result, err = NewMigrator(MigratorArgs{Source: source, Destination: dest, Prefix: "b/"}).Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 1, Copied: 1})
+	c.Check(servertest.Exists(c, dest, "a", "one"), check.Equals, false)
+
+	// Checkpoint errors
+	checkpoint.err = errors.New("load error")
+	_, err = m.Migrate(ctx)
+	c.Check(err, check.ErrorMatches, "load error")
+}
+
+func (s *MigratorSuite) TestDryRun(c *check.C) {
+	ctx := context.Background()
+	source := memtest.NewServer("source", 4096)
+	dest := newFileServer(c)
+	populate(c, source)
+	_, _, err := dest.Put(ctx, servertest.StringResolver("some data"), "a", "one")
+	c.Assert(err, check.IsNil)
+	checkpoint := &memoryCheckpoint{}
+
+	result, err := NewMigrator(MigratorArgs{
+		Source:            source,
+		Destination:       dest,
+		Checkpoint:        checkpoint,
+		DryRun:            true,
+		DeleteAfterVerify: true,
+	}).Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 3, Copied: 2, Skipped: 1})
+	c.Check(servertest.Exists(c, dest, "b", "chunked"), check.Equals, false)
+	c.Check(servertest.Exists(c, source, "a", "one"), check.Equals, true)
+	c.Check(checkpoint.saved, check.Equals, 0)
+}
+
+func (s *MigratorSuite) TestDeleteAfterVerify(c *check.C) {
+	ctx := context.Background()
+	source := memtest.NewServer("source", 4096)
+	dest := newFileServer(c)
+	populate(c, source)
+
+	// A different item with the same size is replaced
+	_, _, err := dest.Put(ctx, servertest.StringResolver("SOME DATA"), "a", "one")
+	c.Assert(err, check.IsNil)
+
+	result, err := NewMigrator(MigratorArgs{
+		Source:            source,
+		Destination:       dest,
+		Verify:            VerifyChecksum,
+		DeleteAfterVerify: true,
+	}).Migrate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 3, Copied: 3, Deleted: 3})
+	c.Check(servertest.ReadItem(c, dest, "a", "one"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, dest, "b", "chunked"), check.Equals, servertest.TestDESC)
+	items, err := source.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.HasLen, 0)
+}
+
+func (s *MigratorSuite) TestFailed(c *check.C) {
+	ctx := context.Background()
+	source := memtest.NewServer("source", 4096)
+	dest := &truncatingServer{StorageServer: memtest.NewServer("dest", 4096)}
+	populate(c, source)
+	checkpoint := &memoryCheckpoint{}
+
+	result, err := NewMigrator(MigratorArgs{
+		Source:            source,
+		Destination:       dest,
+		Concurrency:       1,
+		Checkpoint:        checkpoint,
+		DeleteAfterVerify: true,
+	}).Migrate(ctx)
+	c.Check(err, check.ErrorMatches, "(?s)error migrating dir=a and address=one: item does not match at the destination: "+
+		"source size 9 and destination size 1\n"+
+		"error migrating dir=c and address=two: .*")
+	c.Check(result, check.DeepEquals, MigrateResult{Checked: 3, Copied: 1, Deleted: 1, Failed: 2})
+
+	// Failed items are kept, and are not passed by the checkpoint
+	c.Check(servertest.Exists(c, source, "a", "one"), check.Equals, true)
+	c.Check(servertest.Exists(c, source, "b", "chunked"), check.Equals, false)
+	c.Check(checkpoint.saved, check.Equals, 0)
+
+	_, err = NewMigrator(MigratorArgs{Source: source, Destination: dest, Verify: "bad"}).Migrate(ctx)
+	c.Check(err, check.ErrorMatches, `invalid verification "bad"`)
+}
+
+func (s *MigratorSuite) TestProgress(c *check.C) {
+	p := &progress{done: make(map[int]string)}
+	c.Check(p.complete(1, "b"), check.Equals, 0)
+	c.Check(p.last, check.Equals, "")
+	c.Check(p.complete(0, "a"), check.Equals, 2)
+	c.Check(p.last, check.Equals, "b")
+	c.Check(p.complete(3, "d"), check.Equals, 2)
+	c.Check(p.last, check.Equals, "b")
+	c.Check(p.done, check.HasLen, 1)
+}
+
+func (s *MigratorSuite) TestFileCheckpoint(c *check.C) {
+	checkpoint := &FileCheckpoint{Path: filepath.Join(c.MkDir(), "checkpoint")}
+	token, err := checkpoint.Load()
+	c.Assert(err, check.IsNil)
+	c.Check(token, check.Equals, "")
+
+	c.Assert(checkpoint.Save("dir/address"), check.IsNil)
+	c.Assert(checkpoint.Save("dir/address2"), check.IsNil)
+	token, err = checkpoint.Load()
+	c.Assert(err, check.IsNil)
+	c.Check(token, check.Equals, "dir/address2")
+
+	checkpoint.Path = filepath.Join(c.MkDir(), "missing", "checkpoint")
+	c.Check(checkpoint.Save("dir/address"), check.ErrorMatches, "error saving checkpoint .*")
+}
@@ -0,0 +1,131 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// ErrMetadataNotSupported is returned when writing metadata to a server that
+// cannot store it. Servers return it before calling the resolver, unless they
+// wrap a server and must transform the whole item before writing it.
+var ErrMetadataNotSupported = errors.New("the storage server does not support object metadata")
+
+// ObjectMetadataServer is implemented by storage servers that store user
+// metadata with objects. Metadata is replaced whenever an object is written,
+// and is preserved when an object is moved or copied. Objects written with
+// `Put` or `PutChunked` have no metadata.
+type ObjectMetadataServer interface {
+	// PutWithMetadata writes an item like `Put`, and stores the metadata
+	// with it.
+	PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error)
+
+	// PutChunkedWithMetadata writes an item like `PutChunked`, and stores
+	// the metadata in its `info.json`.
+	PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error)
+
+	// CheckWithMetadata checks for an item like `Check`, and also returns
+	// its metadata.
+	CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error)
+
+	// GetWithMetadata gets an item like `Get`, and also returns its
+	// metadata.
+	GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error)
+}
+
+// PutWithMetadata writes an item with metadata. Servers that do not
+// implement `ObjectMetadataServer` can only write items without metadata,
+// and return `ErrMetadataNotSupported` otherwise.
+func PutWithMetadata(ctx context.Context, server StorageServer, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	if m, ok := server.(ObjectMetadataServer); ok {
+		return m.PutWithMetadata(ctx, resolve, dir, address, meta)
+	} else if len(meta) > 0 {
+		return "", "", ErrMetadataNotSupported
+	}
+	return server.Put(ctx, resolve, dir, address)
+}
+
+// PutChunkedWithMetadata writes a chunked item with metadata. Servers that do
+// not implement `ObjectMetadataServer` can only write items without
+// metadata, and return `ErrMetadataNotSupported` otherwise.
+func PutChunkedWithMetadata(ctx context.Context, server StorageServer, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if m, ok := server.(ObjectMetadataServer); ok {
+		return m.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, meta)
+	} else if len(meta) > 0 {
+		return "", "", ErrMetadataNotSupported
+	}
+	return server.PutChunked(ctx, resolve, dir, address, sz)
+}
+
+// PutCopy writes an item copied from another server, with the metadata of
+// the original. Unlike `PutWithMetadata` and `PutChunkedWithMetadata`, the
+// metadata is dropped when the server cannot store it, so that items can be
+// copied to any server. The copy fails instead if the server rejected the
+// metadata after calling the resolver, since the item cannot be read again.
+func PutCopy(ctx context.Context, server StorageServer, resolve types.Resolver, dir, address string, chunked bool, sz uint64, meta types.Metadata) (err error) {
+	resolved := false
+	withMeta := func(w io.Writer) (string, string, error) {
+		resolved = true
+		return resolve(w)
+	}
+	if chunked {
+		_, _, err = PutChunkedWithMetadata(ctx, server, withMeta, dir, address, sz, meta)
+	} else {
+		_, _, err = PutWithMetadata(ctx, server, withMeta, dir, address, meta)
+	}
+	if resolved || !errors.Is(err, ErrMetadataNotSupported) {
+		return
+	}
+
+	slog.Debug("Dropping metadata of copied item", "dir", dir, "address", address, "server", server.Dir())
+	if chunked {
+		_, _, err = server.PutChunked(ctx, resolve, dir, address, sz)
+	} else {
+		_, _, err = server.Put(ctx, resolve, dir, address)
+	}
+	return
+}
+
+// ChunkMetadataWriter is implemented by chunk utilities that can store
+// metadata in the `info.json` of a chunked asset.
+type ChunkMetadataWriter interface {
+	WriteChunkedWithMetadata(ctx context.Context, dir, address string, sz uint64, meta types.Metadata, resolve types.Resolver) error
+}
+
+// WriteChunkedWithMetadata writes a chunked asset with metadata. Chunk
+// utilities that do not implement `ChunkMetadataWriter` can only write
+// assets without metadata, and return `ErrMetadataNotSupported` otherwise.
+func WriteChunkedWithMetadata(ctx context.Context, chunker ChunkUtils, dir, address string, sz uint64, meta types.Metadata, resolve types.Resolver) error {
+	if w, ok := chunker.(ChunkMetadataWriter); ok {
+		return w.WriteChunkedWithMetadata(ctx, dir, address, sz, meta, resolve)
+	} else if len(meta) > 0 {
+		return ErrMetadataNotSupported
+	}
+	return chunker.WriteChunked(ctx, dir, address, sz, resolve)
+}
+
+// CheckWithMetadata checks for an item and returns its metadata. Items in
+// servers that do not implement `ObjectMetadataServer` have no metadata.
+func CheckWithMetadata(ctx context.Context, server StorageServer, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	if m, ok := server.(ObjectMetadataServer); ok {
+		return m.CheckWithMetadata(ctx, dir, address)
+	}
+	found, chunked, sz, mod, err := server.Check(ctx, dir, address)
+	return found, chunked, sz, mod, nil, err
+}
+
+// GetWithMetadata gets an item and its metadata. Items in servers that do not
+// implement `ObjectMetadataServer` have no metadata.
+func GetWithMetadata(ctx context.Context, server StorageServer, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	if m, ok := server.(ObjectMetadataServer); ok {
+		return m.GetWithMetadata(ctx, dir, address)
+	}
+	f, chunked, sz, mod, found, err := server.Get(ctx, dir, address)
+	return f, chunked, sz, mod, nil, found, err
+}
@@ -0,0 +1,93 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type ObjectMetadataSuite struct{}
+
+var _ = check.Suite(&ObjectMetadataSuite{})
+
+func (s *ObjectMetadataSuite) TestWriteChunkedWithMetadata(c *check.C) {
+	ctx := context.Background()
+
+	// Chunk utilities without metadata support can write assets without
+	// metadata
+	chunker := &dummyChunker{}
+	err := WriteChunkedWithMetadata(ctx, chunker, "dir", "address", 10, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(chunker.written, check.Equals, true)
+
+	chunker = &dummyChunker{}
+	err = WriteChunkedWithMetadata(ctx, chunker, "dir", "address", 10, types.Metadata{"key": "value"}, nil)
+	c.Check(err, check.Equals, ErrMetadataNotSupported)
+	c.Check(chunker.written, check.Equals, false)
+}
+
+func (s *ObjectMetadataSuite) TestPutCopy(c *check.C) {
+	ctx := context.Background()
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("some data"))
+		return "", "", err
+	}
+
+	// Metadata is dropped when the server cannot store it
+	server := &DummyStorageServer{}
+	err := PutCopy(ctx, server, resolve, "dir", "address", false, 9, types.Metadata{"key": "value"})
+	c.Assert(err, check.IsNil)
+	c.Check(server.Placed, check.DeepEquals, []string{"dir-some data"})
+	c.Check(server.PutChunks, check.Equals, false)
+
+	server = &DummyStorageServer{}
+	err = PutCopy(ctx, server, resolve, "dir", "address", true, 9, types.Metadata{"key": "value"})
+	c.Assert(err, check.IsNil)
+	c.Check(server.Placed, check.DeepEquals, []string{"dir-some data"})
+	c.Check(server.PutChunks, check.Equals, true)
+
+	// Not retried once the item is read
+	rejecting := &rejectingServer{DummyStorageServer: &DummyStorageServer{}}
+	err = PutCopy(ctx, rejecting, resolve, "dir", "address", false, 9, types.Metadata{"key": "value"})
+	c.Check(err, check.Equals, ErrMetadataNotSupported)
+	c.Check(rejecting.PutCalled, check.Equals, 0)
+
+	// Other errors are returned
+	server = &DummyStorageServer{PutErr: errors.New("put error")}
+	err = PutCopy(ctx, server, resolve, "dir", "address", false, 9, nil)
+	c.Check(err, check.ErrorMatches, "put error")
+	c.Check(server.PutCalled, check.Equals, 1)
+}
+
+// rejectingServer reads the item before rejecting its metadata, like a
+// server that transforms items before writing them.
+type rejectingServer struct {
+	*DummyStorageServer
+}
+
+func (r *rejectingServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	_, _, err := resolve(io.Discard)
+	if err != nil {
+		return "", "", err
+	}
+	return "", "", ErrMetadataNotSupported
+}
+
+func (r *rejectingServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	return r.PutWithMetadata(ctx, resolve, dir, address, meta)
+}
+
+func (r *rejectingServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	return false, nil, 0, time.Time{}, nil, nil
+}
+
+func (r *rejectingServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	return nil, nil, 0, time.Time{}, nil, false, nil
+}
@@ -0,0 +1,104 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// RangeReader is implemented by storage servers that can read a byte range
+// of an item without reading the bytes before it.
+type RangeReader interface {
+	// GetRange gets a byte range of an item if it exists. For chunked
+	// items, only the chunks covering the range are read.
+	// Accepts:
+	//  * dir     The prefix or directory in which to look
+	//  * address The address of the item
+	//  * offset  The offset of the first byte to read
+	//  * length  The number of bytes to read. A negative length reads
+	//            to the end of the item
+	// Returns:
+	//  * io.ReadCloser the requested range of the file
+	//  * int64 The full size of the file
+	//  * time.Time The last modification time
+	//  * bool `true` if found
+	//  * error `ErrInvalidRange` if the offset is beyond the end of the file
+	GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error)
+}
+
+// ChunkRangeReader is implemented by chunk utilities that can read a byte
+// range of a chunked asset from only the chunks covering it.
+type ChunkRangeReader interface {
+	ReadChunkedRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, error)
+}
+
+// GetRange gets a byte range of an item. Servers that do not implement
+// `RangeReader` are read with `Get`, and the bytes before the range are
+// discarded.
+func GetRange(ctx context.Context, server StorageServer, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	if r, ok := server.(RangeReader); ok {
+		return r.GetRange(ctx, dir, address, offset, length)
+	}
+	return getRange(ctx, server, dir, address, offset, length)
+}
+
+// ReadChunkedRange reads a byte range of a chunked asset. Chunk utilities
+// that do not implement `ChunkRangeReader` read the whole asset with
+// `ReadChunked`, and the bytes before the range are discarded.
+func ReadChunkedRange(ctx context.Context, chunker ChunkUtils, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, error) {
+	if r, ok := chunker.(ChunkRangeReader); ok {
+		return r.ReadChunkedRange(ctx, dir, address, offset, length)
+	}
+	rc, chunked, sz, mod, err := chunker.ReadChunked(ctx, dir, address)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, err
+	}
+	rc, err = sliceReader(rc, sz, offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, err
+	}
+	return rc, chunked, sz, mod, nil
+}
+
+func getRange(ctx context.Context, server StorageServer, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	rc, chunked, sz, mod, ok, err := server.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, ok, err
+	}
+	rc, err = sliceReader(rc, sz, offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	return rc, chunked, sz, mod, true, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// sliceReader discards the bytes of `rc` before `offset`, and limits it to
+// the requested range of an item of `size` bytes. `rc` is closed on error.
+func sliceReader(rc io.ReadCloser, size, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || offset > size {
+		return nil, errors.Join(ErrInvalidRange, rc.Close())
+	}
+	n := size - offset
+	if length >= 0 && length < n {
+		n = length
+	}
+
+	_, err := io.CopyN(io.Discard, rc, offset)
+	if err != nil {
+		return nil, errors.Join(err, rc.Close())
+	}
+	return &limitedReadCloser{
+		Reader: io.LimitReader(rc, n),
+		Closer: rc,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package rsstorage
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type RangeSuite struct{}
+
+var _ = check.Suite(&RangeSuite{})
+
+func (s *RangeSuite) TestGetRange(c *check.C) {
+	ctx := context.Background()
+	dummy := &DummyStorageServer{}
+	read := func(server StorageServer, offset, length int64) string {
+		dummy.GetReader = io.NopCloser(strings.NewReader("0123456789"))
+		dummy.GetSize = 10
+		dummy.GetOk = true
+		r, _, sz, _, ok, err := GetRange(ctx, server, "dir", "address", offset, length)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(sz, check.Equals, int64(10))
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		return string(b)
+	}
+
+	// The dummy server implements `RangeReader`, and the embedded interface
+	// hides it so that the `Get` fallback is used.
+	fallback := struct{ StorageServer }{dummy}
+	for _, server := range []StorageServer{dummy, fallback} {
+		c.Check(read(server, 0, -1), check.Equals, "0123456789")
+		c.Check(read(server, 2, 3), check.Equals, "234")
+		c.Check(read(server, 8, 5), check.Equals, "89")
+		c.Check(read(server, 10, -1), check.Equals, "")
+
+		dummy.GetReader = io.NopCloser(strings.NewReader("0123456789"))
+		_, _, _, _, _, err := GetRange(ctx, server, "dir", "address", 11, 1)
+		c.Check(errors.Is(err, ErrInvalidRange), check.Equals, true)
+
+		dummy.GetOk = false
+		dummy.GetReader = nil
+		r, _, _, _, ok, err := GetRange(ctx, server, "dir", "address", 0, 1)
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, false)
+		c.Check(r, check.IsNil)
+	}
+}
+
+type dummyChunker struct {
+	data    string
+	written bool
+}
+
+func (d *dummyChunker) WriteChunked(ctx context.Context, dir, address string, sz uint64, resolve types.Resolver) error {
+	d.written = true
+	return nil
+}
+
+func (d *dummyChunker) ReadChunked(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, error) {
+	return io.NopCloser(strings.NewReader(d.data)), &types.ChunksInfo{}, int64(len(d.data)), time.Time{}, nil
+}
+
+func (s *RangeSuite) TestReadChunkedRange(c *check.C) {
+	ctx := context.Background()
+	chunker := &dummyChunker{data: "0123456789"}
+
+	r, _, sz, _, err := ReadChunkedRange(ctx, chunker, "dir", "address", 4, 3)
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, int64(10))
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "456")
+
+	_, _, _, _, err = ReadChunkedRange(ctx, chunker, "dir", "address", 11, -1)
+	c.Check(errors.Is(err, ErrInvalidRange), check.Equals, true)
+}
@@ -0,0 +1,844 @@
+package rsstoragetest
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Factory returns a new, empty storage server for a test. Each call must
+// return a server with its own storage, so that items can be copied and
+// moved between two servers. Use `t.TempDir` and `t.Cleanup` to remove the
+// storage when the test ends.
+type Factory func(t *testing.T) rsstorage.StorageServer
+
+// RunConformance tests that the servers returned by `factory` behave like the
+// storage servers in this module. Each behavior is run as a subtest of `t`,
+// so a failing behavior can be run on its own with `-run`.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, factory Factory)
+	}{
+		{"Describe", testDescribe},
+		{"NotFound", testNotFound},
+		{"Put", testPut},
+		{"PutResolvedAddress", testPutResolvedAddress},
+		{"PutResolverError", testPutResolverError},
+		{"PutChunked", testPutChunked},
+		{"PutChunkedResolverError", testPutChunkedResolverError},
+		{"GetRange", testGetRange},
+		{"Remove", testRemove},
+		{"Enumerate", testEnumerate},
+		{"CopyMove", testCopyMove},
+		{"CalculateUsage", testCalculateUsage},
+		{"ConcurrentPut", testConcurrentPut},
+		{"ConcurrentReadWrite", testConcurrentReadWrite},
+		{"Cancellation", testCancellation},
+		{"Metadata", testMetadata},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, factory)
+		})
+	}
+}
+
+// The size of the chunked items written by the tests. It is not a multiple
+// of common chunk sizes, so the last chunk is partial.
+const chunkedSize = 3*4096 + 100
+
+// testData returns `n` bytes that differ from one offset to the next, so a
+// read from the wrong offset is detected.
+func testData(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i%251) + seed
+	}
+	return b
+}
+
+func resolver(data []byte, dir, address string) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewReader(data))
+		return dir, address, err
+	}
+}
+
+func put(t *testing.T, server rsstorage.StorageServer, dir, address string, data []byte) {
+	t.Helper()
+	_, _, err := server.Put(context.Background(), resolver(data, "", ""), dir, address)
+	if err != nil {
+		t.Fatalf("Put(%q, %q) failed: %v", dir, address, err)
+	}
+}
+
+func putChunked(t *testing.T, server rsstorage.StorageServer, dir, address string, data []byte) {
+	t.Helper()
+	_, _, err := server.PutChunked(context.Background(), resolver(data, "", ""), dir, address, uint64(len(data)))
+	if err != nil {
+		t.Fatalf("PutChunked(%q, %q) failed: %v", dir, address, err)
+	}
+}
+
+// get reads an item, and fails the test if it does not exist.
+func get(t *testing.T, server rsstorage.StorageServer, dir, address string) ([]byte, *types.ChunksInfo, int64) {
+	t.Helper()
+	r, chunked, sz, _, ok, err := server.Get(context.Background(), dir, address)
+	if err != nil {
+		t.Fatalf("Get(%q, %q) failed: %v", dir, address, err)
+	} else if !ok {
+		t.Fatalf("Get(%q, %q) did not find the item", dir, address)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading Get(%q, %q) failed: %v", dir, address, err)
+	}
+	return b, chunked, sz
+}
+
+// assertItem fails the test unless an item exists with the data.
+func assertItem(t *testing.T, server rsstorage.StorageServer, dir, address string, data []byte, chunked bool) {
+	t.Helper()
+	ok, info, sz, _, err := server.Check(context.Background(), dir, address)
+	if err != nil {
+		t.Fatalf("Check(%q, %q) failed: %v", dir, address, err)
+	} else if !ok {
+		t.Fatalf("Check(%q, %q) did not find the item", dir, address)
+	}
+	if sz != int64(len(data)) {
+		t.Errorf("Check(%q, %q) returned size %d, want %d", dir, address, sz, len(data))
+	}
+	if chunked != (info != nil) {
+		t.Errorf("Check(%q, %q) returned chunks %+v, want chunked=%t", dir, address, info, chunked)
+	}
+
+	b, info, sz := get(t, server, dir, address)
+	if !bytes.Equal(b, data) {
+		t.Errorf("Get(%q, %q) returned %d bytes that differ from the %d bytes written", dir, address, len(b), len(data))
+	}
+	if sz != int64(len(data)) {
+		t.Errorf("Get(%q, %q) returned size %d, want %d", dir, address, sz, len(data))
+	}
+	if chunked != (info != nil) {
+		t.Errorf("Get(%q, %q) returned chunks %+v, want chunked=%t", dir, address, info, chunked)
+	}
+}
+
+// assertMissing fails the test if an item exists.
+func assertMissing(t *testing.T, server rsstorage.StorageServer, dir, address string) {
+	t.Helper()
+	ok, _, _, _, err := server.Check(context.Background(), dir, address)
+	if err != nil {
+		t.Fatalf("Check(%q, %q) failed: %v", dir, address, err)
+	} else if ok {
+		t.Errorf("Check(%q, %q) found an item that should not exist", dir, address)
+	}
+}
+
+// assertNotComplete fails the test if a complete item exists. Servers may
+// either remove an item when a write fails, or leave it incomplete.
+func assertNotComplete(t *testing.T, server rsstorage.StorageServer, dir, address string) {
+	t.Helper()
+	ok, chunked, _, _, err := server.Check(context.Background(), dir, address)
+	if err != nil {
+		t.Fatalf("Check(%q, %q) failed: %v", dir, address, err)
+	} else if ok && (chunked == nil || chunked.Complete) {
+		t.Errorf("Check(%q, %q) found a complete item after the write failed", dir, address)
+	}
+}
+
+func testDescribe(t *testing.T, factory Factory) {
+	server := factory(t)
+	if server.Type() == "" {
+		t.Error("Type() is empty")
+	}
+	if server.Locate("dir", "address") == "" {
+		t.Error("Locate() is empty")
+	}
+	if server.Base() == nil {
+		t.Error("Base() is nil")
+	}
+}
+
+func testNotFound(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+
+	ok, chunked, _, _, err := server.Check(ctx, "dir", "missing")
+	if err != nil || ok || chunked != nil {
+		t.Errorf("Check() of a missing item returned ok=%t, chunks=%+v, err=%v", ok, chunked, err)
+	}
+	_, _, _, _, ok, err = server.Get(ctx, "dir", "missing")
+	if err != nil || ok {
+		t.Errorf("Get() of a missing item returned ok=%t, err=%v", ok, err)
+	}
+	_, _, _, _, ok, err = rsstorage.GetRange(ctx, server, "dir", "missing", 0, 1)
+	if err != nil || ok {
+		t.Errorf("GetRange() of a missing item returned ok=%t, err=%v", ok, err)
+	}
+	if err = server.Remove(ctx, "dir", "missing"); err != nil {
+		t.Errorf("Remove() of a missing item failed: %v", err)
+	}
+	if err = server.Copy(ctx, "dir", "missing", factory(t)); err == nil {
+		t.Error("Copy() of a missing item did not fail")
+	}
+	if err = server.Move(ctx, "dir", "missing", factory(t)); err == nil {
+		t.Error("Move() of a missing item did not fail")
+	}
+	server.Flush(ctx, "dir", "missing")
+}
+
+func testPut(t *testing.T, factory Factory) {
+	server := factory(t)
+	data := testData(1000, 0)
+
+	dir, address, err := server.Put(context.Background(), resolver(data, "", ""), "dir", "address")
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if dir != "dir" || address != "address" {
+		t.Errorf("Put() returned dir=%q and address=%q, want the dir and address given", dir, address)
+	}
+	assertItem(t, server, "dir", "address", data, false)
+
+	// Items without a dir
+	put(t, server, "", "top", data)
+	assertItem(t, server, "", "top", data, false)
+
+	// Empty items
+	put(t, server, "dir", "empty", nil)
+	assertItem(t, server, "dir", "empty", nil, false)
+
+	// Writing again replaces the item
+	replaced := testData(10, 1)
+	put(t, server, "dir", "address", replaced)
+	assertItem(t, server, "dir", "address", replaced, false)
+}
+
+func testPutResolvedAddress(t *testing.T, factory Factory) {
+	server := factory(t)
+	data := testData(1000, 0)
+
+	// When no dir and address are given, the resolver chooses them
+	dir, address, err := server.Put(context.Background(), resolver(data, "resolved", "address"), "", "")
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if dir != "resolved" || address != "address" {
+		t.Errorf("Put() returned dir=%q and address=%q, want the dir and address from the resolver", dir, address)
+	}
+	assertItem(t, server, "resolved", "address", data, false)
+
+	// Otherwise, the resolver's dir and address are ignored
+	dir, address, err = server.Put(context.Background(), resolver(data, "ignored", "ignored"), "dir", "address")
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if dir != "dir" || address != "address" {
+		t.Errorf("Put() returned dir=%q and address=%q, want the dir and address given", dir, address)
+	}
+	assertItem(t, server, "dir", "address", data, false)
+	assertMissing(t, server, "ignored", "ignored")
+}
+
+func testPutResolverError(t *testing.T, factory Factory) {
+	server := factory(t)
+	resolveErr := errors.New("resolve failed")
+	_, _, err := server.Put(context.Background(), func(w io.Writer) (string, string, error) {
+		if _, err := w.Write(testData(100, 0)); err != nil {
+			return "", "", err
+		}
+		return "", "", resolveErr
+	}, "dir", "address")
+	if err == nil {
+		t.Fatal("Put() did not fail when the resolver failed")
+	}
+	assertMissing(t, server, "dir", "address")
+}
+
+func testPutChunked(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+	data := testData(chunkedSize, 0)
+
+	_, _, err := server.PutChunked(ctx, resolver(data, "", ""), "dir", "", uint64(len(data)))
+	if err == nil {
+		t.Error("PutChunked() without an address did not fail")
+	}
+
+	dir, address, err := server.PutChunked(ctx, resolver(data, "", ""), "dir", "chunked", uint64(len(data)))
+	if err != nil {
+		t.Fatalf("PutChunked() failed: %v", err)
+	}
+	if dir != "dir" || address != "chunked" {
+		t.Errorf("PutChunked() returned dir=%q and address=%q, want the dir and address given", dir, address)
+	}
+	assertItem(t, server, "dir", "chunked", data, true)
+
+	_, chunked, _, _, err := server.Check(ctx, "dir", "chunked")
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	if !chunked.Complete {
+		t.Error("the chunked item is not complete")
+	}
+	if chunked.FileSize != uint64(len(data)) {
+		t.Errorf("the chunked item has size %d, want %d", chunked.FileSize, len(data))
+	}
+	if chunked.ChunkSize == 0 {
+		t.Fatal("the chunked item has a chunk size of zero")
+	}
+	if want := (chunked.FileSize + chunked.ChunkSize - 1) / chunked.ChunkSize; chunked.NumChunks != want {
+		t.Errorf("the chunked item has %d chunks, want %d", chunked.NumChunks, want)
+	}
+
+	// Chunked items are not listed as their chunks
+	items, err := server.Enumerate(ctx)
+	if err != nil {
+		t.Fatalf("Enumerate() failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Dir != "dir" || items[0].Address != "chunked" || !items[0].Chunked {
+		t.Errorf("Enumerate() returned %+v, want the chunked item", items)
+	}
+}
+
+func testPutChunkedResolverError(t *testing.T, factory Factory) {
+	server := factory(t)
+	data := testData(chunkedSize, 0)
+	_, _, err := server.PutChunked(context.Background(), func(w io.Writer) (string, string, error) {
+		if _, err := w.Write(data[:100]); err != nil {
+			return "", "", err
+		}
+		return "", "", errors.New("resolve failed")
+	}, "dir", "chunked", uint64(len(data)))
+	if err == nil {
+		t.Fatal("PutChunked() did not fail when the resolver failed")
+	}
+	assertNotComplete(t, server, "dir", "chunked")
+}
+
+func testGetRange(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+	data := testData(chunkedSize, 0)
+	put(t, server, "dir", "address", data)
+	putChunked(t, server, "dir", "chunked", data)
+
+	_, chunked, _, _, err := server.Check(ctx, "dir", "chunked")
+	if err != nil || chunked == nil {
+		t.Fatalf("Check() of the chunked item returned chunks=%+v, err=%v", chunked, err)
+	}
+	size := int64(len(data))
+	boundary := int64(chunked.ChunkSize)
+
+	ranges := []struct {
+		offset, length int64
+	}{
+		{0, -1},
+		{0, size},
+		{10, 20},
+		{size - 5, 100},
+		{size, -1},
+		{boundary - 5, 10},
+		{boundary, 1},
+	}
+	for _, address := range []string{"address", "chunked"} {
+		for _, rng := range ranges {
+			if rng.offset > size {
+				continue
+			}
+			r, _, sz, _, ok, err := rsstorage.GetRange(ctx, server, "dir", address, rng.offset, rng.length)
+			if err != nil || !ok {
+				t.Errorf("GetRange(%q, %d, %d) returned ok=%t, err=%v", address, rng.offset, rng.length, ok, err)
+				continue
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Errorf("reading GetRange(%q, %d, %d) failed: %v", address, rng.offset, rng.length, err)
+				continue
+			}
+			end := size
+			if rng.length >= 0 {
+				end = min(rng.offset+rng.length, size)
+			}
+			if !bytes.Equal(b, data[rng.offset:end]) {
+				t.Errorf("GetRange(%q, %d, %d) returned %d bytes that differ from bytes %d to %d", address, rng.offset, rng.length, len(b), rng.offset, end)
+			}
+			if sz != size {
+				t.Errorf("GetRange(%q, %d, %d) returned size %d, want the full size %d", address, rng.offset, rng.length, sz, size)
+			}
+		}
+
+		_, _, _, _, _, err = rsstorage.GetRange(ctx, server, "dir", address, size+1, 1)
+		if !errors.Is(err, rsstorage.ErrInvalidRange) {
+			t.Errorf("GetRange(%q) beyond the end returned %v, want ErrInvalidRange", address, err)
+		}
+	}
+}
+
+func testRemove(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+	data := testData(chunkedSize, 0)
+	put(t, server, "dir", "address", data)
+	putChunked(t, server, "dir", "chunked", data)
+	put(t, server, "dir", "kept", data)
+
+	if err := server.Remove(ctx, "dir", "address"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if err := server.Remove(ctx, "dir", "chunked"); err != nil {
+		t.Fatalf("Remove() of the chunked item failed: %v", err)
+	}
+	assertMissing(t, server, "dir", "address")
+	assertMissing(t, server, "dir", "chunked")
+	assertItem(t, server, "dir", "kept", data, false)
+
+	items, err := server.Enumerate(ctx)
+	if err != nil {
+		t.Fatalf("Enumerate() failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Address != "kept" {
+		t.Errorf("Enumerate() returned %+v after removing items, want only the kept item", items)
+	}
+}
+
+func testEnumerate(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+	data := testData(100, 0)
+	put(t, server, "a", "1", data)
+	put(t, server, "a", "2", data)
+	put(t, server, "b", "3", data)
+	putChunked(t, server, "c", "4", testData(chunkedSize, 0))
+
+	want := []string{"a/1", "a/2", "b/3", "c/4 (chunked)"}
+	describe := func(item types.StoredItem) string {
+		if item.Chunked {
+			return item.Path() + " (chunked)"
+		}
+		return item.Path()
+	}
+
+	items, err := server.Enumerate(ctx)
+	if err != nil {
+		t.Fatalf("Enumerate() failed: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, describe(item))
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("Enumerate() returned %v, want %v", got, want)
+	}
+
+	enumerate := func(opts types.EnumerateOptions) []types.StoredItem {
+		var result []types.StoredItem
+		for item, err := range rsstorage.EnumerateItems(ctx, server, opts) {
+			if err != nil {
+				t.Fatalf("EnumerateItems(%+v) failed: %v", opts, err)
+			}
+			result = append(result, item)
+		}
+		return result
+	}
+
+	streamed := enumerate(types.EnumerateOptions{})
+	got = nil
+	for _, item := range streamed {
+		got = append(got, describe(item))
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("EnumerateItems() returned %v, want %v", got, want)
+	}
+
+	got = nil
+	for _, item := range enumerate(types.EnumerateOptions{Prefix: "a/"}) {
+		got = append(got, describe(item))
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want[:2]) {
+		t.Errorf("EnumerateItems() with a prefix returned %v, want %v", got, want[:2])
+	}
+
+	// Resuming after an item lists the items after it
+	if len(streamed) == len(want) {
+		resumed := enumerate(types.EnumerateOptions{After: streamed[1].Token})
+		if len(resumed) != len(streamed)-2 {
+			t.Fatalf("EnumerateItems() after the second item returned %d items, want %d", len(resumed), len(streamed)-2)
+		}
+		for i, item := range resumed {
+			if item.Path() != streamed[i+2].Path() {
+				t.Errorf("EnumerateItems() after the second item returned %s at %d, want %s", item.Path(), i, streamed[i+2].Path())
+			}
+		}
+	}
+}
+
+func testCopyMove(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	data := testData(1000, 0)
+	chunkedData := testData(chunkedSize, 1)
+
+	other := func(t *testing.T) rsstorage.StorageServer {
+		wn := &waiterNotifier{}
+		return memory.NewStorageServer(memory.StorageServerArgs{
+			ChunkSize: 4096,
+			Waiter:    wn,
+			Notifier:  wn,
+			Class:     "conformance",
+		})
+	}
+
+	// Copy and move to a server of the same type, and to and from a server
+	// of another type
+	pairs := []struct {
+		name        string
+		source      Factory
+		destination Factory
+	}{
+		{"SameType", factory, factory},
+		{"ToOtherType", factory, other},
+		{"FromOtherType", other, factory},
+	}
+	for _, pair := range pairs {
+		t.Run(pair.name, func(t *testing.T) {
+			source := pair.source(t)
+			destination := pair.destination(t)
+			put(t, source, "dir", "address", data)
+			putChunked(t, source, "dir", "chunked", chunkedData)
+
+			for _, address := range []string{"address", "chunked"} {
+				if err := source.Copy(ctx, "dir", address, destination); err != nil {
+					t.Fatalf("Copy(%q) failed: %v", address, err)
+				}
+			}
+			assertItem(t, source, "dir", "address", data, false)
+			assertItem(t, source, "dir", "chunked", chunkedData, true)
+			assertItem(t, destination, "dir", "address", data, false)
+			assertItem(t, destination, "dir", "chunked", chunkedData, true)
+
+			// Copying replaces items at the destination
+			replaced := testData(10, 2)
+			put(t, source, "dir", "address", replaced)
+			if err := source.Copy(ctx, "dir", "address", destination); err != nil {
+				t.Fatalf("Copy() of a replaced item failed: %v", err)
+			}
+			assertItem(t, destination, "dir", "address", replaced, false)
+
+			moved := pair.destination(t)
+			for _, address := range []string{"address", "chunked"} {
+				if err := source.Move(ctx, "dir", address, moved); err != nil {
+					t.Fatalf("Move(%q) failed: %v", address, err)
+				}
+			}
+			assertMissing(t, source, "dir", "address")
+			assertMissing(t, source, "dir", "chunked")
+			assertItem(t, moved, "dir", "address", replaced, false)
+			assertItem(t, moved, "dir", "chunked", chunkedData, true)
+		})
+	}
+}
+
+func testCalculateUsage(t *testing.T, factory Factory) {
+	server := factory(t)
+	put(t, server, "dir", "address", testData(1000, 0))
+	usage, err := server.CalculateUsage()
+	if err != nil {
+		t.Fatalf("CalculateUsage() failed: %v", err)
+	}
+	if usage.SizeBytes > 0 && usage.UsedBytes > usage.SizeBytes {
+		t.Errorf("CalculateUsage() reported %d bytes used of %d", usage.UsedBytes, usage.SizeBytes)
+	}
+}
+
+func testConcurrentPut(t *testing.T, factory Factory) {
+	server := factory(t)
+	const writers = 8
+
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			address := fmt.Sprintf("%d", i)
+			if _, _, err := server.Put(ctx, resolver(testData(1000, byte(i)), "", ""), "dir", address); err != nil {
+				t.Errorf("Put(%q) failed: %v", address, err)
+			}
+			chunked := fmt.Sprintf("chunked-%d", i)
+			data := testData(chunkedSize, byte(i))
+			if _, _, err := server.PutChunked(ctx, resolver(data, "", ""), "dir", chunked, uint64(len(data))); err != nil {
+				t.Errorf("PutChunked(%q) failed: %v", chunked, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range writers {
+		assertItem(t, server, "dir", fmt.Sprintf("%d", i), testData(1000, byte(i)), false)
+		assertItem(t, server, "dir", fmt.Sprintf("chunked-%d", i), testData(chunkedSize, byte(i)), true)
+	}
+}
+
+// testConcurrentReadWrite writes an item repeatedly while it is read. Readers
+// must only ever see one of the versions written, never part of one.
+func testConcurrentReadWrite(t *testing.T, factory Factory) {
+	server := factory(t)
+	const versions = 8
+	data := make([][]byte, versions)
+	for i := range data {
+		data[i] = testData(10000, byte(i))
+	}
+	put(t, server, "dir", "address", data[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for ctx.Err() == nil {
+				r, _, _, _, ok, err := server.Get(context.Background(), "dir", "address")
+				if err != nil {
+					t.Errorf("Get() while writing failed: %v", err)
+					return
+				} else if !ok {
+					t.Error("Get() while writing did not find the item")
+					return
+				}
+				b, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					t.Errorf("reading Get() while writing failed: %v", err)
+					return
+				}
+				if !slices.ContainsFunc(data, func(d []byte) bool { return bytes.Equal(b, d) }) {
+					t.Errorf("Get() while writing returned %d bytes that match no version written", len(b))
+					return
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i := 1; i < versions; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			if _, _, err := server.Put(context.Background(), resolver(data[i], "", ""), "dir", "address"); err != nil {
+				t.Errorf("Put() of version %d failed: %v", i, err)
+			}
+		}()
+	}
+	writers.Wait()
+	cancel()
+	readers.Wait()
+
+	b, _, _ := get(t, server, "dir", "address")
+	if !slices.ContainsFunc(data[1:], func(d []byte) bool { return bytes.Equal(b, d) }) {
+		t.Errorf("Get() after writing returned %d bytes that match no version written", len(b))
+	}
+}
+
+// testCancellation cancels writes while the resolver is writing. Resolvers
+// see the cancellation through their own context, e.g., the context of a
+// request being proxied, so servers must not store the partial data.
+func testCancellation(t *testing.T, factory Factory) {
+	server := factory(t)
+	data := testData(chunkedSize, 0)
+
+	cancelled := func(ctx context.Context, cancel context.CancelFunc) types.Resolver {
+		return func(w io.Writer) (string, string, error) {
+			if _, err := w.Write(data[:100]); err != nil {
+				return "", "", err
+			}
+			cancel()
+			<-ctx.Done()
+			return "", "", ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, err := server.Put(ctx, cancelled(ctx, cancel), "dir", "address")
+	if err == nil {
+		t.Error("Put() did not fail when cancelled")
+	}
+	assertMissing(t, server, "dir", "address")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	_, _, err = server.PutChunked(ctx, cancelled(ctx, cancel), "dir", "chunked", uint64(len(data)))
+	if err == nil {
+		t.Error("PutChunked() did not fail when cancelled")
+	}
+	assertNotComplete(t, server, "dir", "chunked")
+
+	// Enumerating with a cancelled context either fails or lists nothing
+	put(t, server, "dir", "kept", data)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	for item, err := range rsstorage.EnumerateItems(ctx, server, types.EnumerateOptions{}) {
+		if err == nil {
+			t.Errorf("EnumerateItems() with a cancelled context listed %s", item.Path())
+		}
+	}
+}
+
+// waiterNotifier wakes chunk readers when chunks are written to a memory
+// server.
+type waiterNotifier struct {
+	mutex sync.Mutex
+	ch    chan struct{}
+}
+
+// testMetadata tests servers that implement `rsstorage.ObjectMetadataServer`,
+// and is skipped for other servers.
+func testMetadata(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	server := factory(t)
+	if _, ok := server.(rsstorage.ObjectMetadataServer); !ok {
+		t.Skip("the server does not implement rsstorage.ObjectMetadataServer")
+	}
+	data := testData(1000, 0)
+	chunkedData := testData(chunkedSize, 1)
+	meta := types.Metadata{
+		"Content-Type":     "text/plain",
+		"content-encoding": "gzip",
+		"tag":              "value",
+	}
+	want := meta.Normalize()
+
+	assertMetadata := func(t *testing.T, server rsstorage.StorageServer, address string, want types.Metadata) {
+		t.Helper()
+		ok, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, server, "dir", address)
+		if err != nil || !ok {
+			t.Fatalf("CheckWithMetadata(%q) returned found=%t, err=%v", address, ok, err)
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("CheckWithMetadata(%q) returned metadata %v, want %v", address, got, want)
+		}
+		r, _, _, _, got, ok, err := rsstorage.GetWithMetadata(ctx, server, "dir", address)
+		if err != nil || !ok {
+			t.Fatalf("GetWithMetadata(%q) returned found=%t, err=%v", address, ok, err)
+		}
+		r.Close()
+		if !maps.Equal(got, want) {
+			t.Errorf("GetWithMetadata(%q) returned metadata %v, want %v", address, got, want)
+		}
+	}
+
+	_, _, err := rsstorage.PutWithMetadata(ctx, server, resolver(data, "", ""), "dir", "address", meta)
+	if err != nil {
+		t.Fatalf("PutWithMetadata() failed: %v", err)
+	}
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, resolver(chunkedData, "", ""), "dir", "chunked", uint64(len(chunkedData)), meta)
+	if err != nil {
+		t.Fatalf("PutChunkedWithMetadata() failed: %v", err)
+	}
+	assertItem(t, server, "dir", "address", data, false)
+	assertItem(t, server, "dir", "chunked", chunkedData, true)
+	assertMetadata(t, server, "address", want)
+	assertMetadata(t, server, "chunked", want)
+
+	// Metadata is not enumerated as an item
+	items, err := server.Enumerate(ctx)
+	if err != nil {
+		t.Fatalf("Enumerate() failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Enumerate() returned %d items, want 2: %+v", len(items), items)
+	}
+
+	// Copying and moving preserve metadata
+	for _, destination := range []rsstorage.StorageServer{factory(t), memory.NewStorageServer(memory.StorageServerArgs{
+		ChunkSize: 4096,
+		Waiter:    &waiterNotifier{},
+		Notifier:  &waiterNotifier{},
+		Class:     "conformance",
+	})} {
+		for _, address := range []string{"address", "chunked"} {
+			if err = server.Copy(ctx, "dir", address, destination); err != nil {
+				t.Fatalf("Copy(%q) failed: %v", address, err)
+			}
+			assertMetadata(t, destination, address, want)
+		}
+	}
+
+	// Copying to a server that cannot store metadata drops it
+	plain := plainServer{StorageServer: factory(t)}
+	for _, address := range []string{"address", "chunked"} {
+		if err = server.Copy(ctx, "dir", address, plain); err != nil {
+			t.Fatalf("Copy(%q) to a server without metadata failed: %v", address, err)
+		}
+		assertMetadata(t, plain, address, nil)
+	}
+	assertItem(t, plain, "dir", "address", data, false)
+	assertItem(t, plain, "dir", "chunked", chunkedData, true)
+
+	moved := factory(t)
+	for _, address := range []string{"address", "chunked"} {
+		if err = server.Move(ctx, "dir", address, moved); err != nil {
+			t.Fatalf("Move(%q) failed: %v", address, err)
+		}
+		assertMetadata(t, moved, address, want)
+	}
+
+	// Writing an item replaces its metadata
+	put(t, moved, "dir", "address", data)
+	putChunked(t, moved, "dir", "chunked", chunkedData)
+	assertMetadata(t, moved, "address", nil)
+	assertMetadata(t, moved, "chunked", nil)
+}
+
+// plainServer hides the metadata methods of a server, like a server that
+// cannot store metadata.
+type plainServer struct {
+	rsstorage.StorageServer
+}
+
+func (p plainServer) Base() rsstorage.StorageServer {
+	return p
+}
+
+func (w *waiterNotifier) channel() chan struct{} {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.ch == nil {
+		w.ch = make(chan struct{})
+	}
+	return w.ch
+}
+
+func (w *waiterNotifier) WaitForChunk(ctx context.Context, c *types.ChunkNotification) {
+	select {
+	case <-w.channel():
+	case <-ctx.Done():
+	}
+}
+
+func (w *waiterNotifier) Notify(ctx context.Context, c *types.ChunkNotification) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.ch != nil {
+		close(w.ch)
+	}
+	w.ch = make(chan struct{})
+	return nil
+}
@@ -0,0 +1,54 @@
+package rsstoragetest
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/httphandler"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/file"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/httpclient"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
+)
+
+func newMemoryServer(t *testing.T) rsstorage.StorageServer {
+	wn := &waiterNotifier{}
+	return memory.NewStorageServer(memory.StorageServerArgs{
+		ChunkSize: 4096,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     "test",
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	RunConformance(t, newMemoryServer)
+}
+
+func TestFileConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) rsstorage.StorageServer {
+		wn := &waiterNotifier{}
+		return file.NewStorageServer(file.StorageServerArgs{
+			Dir:          t.TempDir(),
+			ChunkSize:    4096,
+			Waiter:       wn,
+			Notifier:     wn,
+			Class:        "test",
+			CacheTimeout: time.Minute,
+			WalkTimeout:  time.Minute,
+		})
+	})
+}
+
+func TestHttpClientConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) rsstorage.StorageServer {
+		s := httptest.NewServer(httphandler.NewHandler(httphandler.HandlerArgs{
+			Server: newMemoryServer(t),
+		}))
+		t.Cleanup(s.Close)
+		return httpclient.NewStorageServer(httpclient.StorageServerArgs{URL: s.URL})
+	})
+}
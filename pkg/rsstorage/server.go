@@ -12,8 +12,10 @@ import (
 
 const (
 	StorageTypeFile     = types.StorageType("file")
+	StorageTypeMemory   = types.StorageType("memory")
 	StorageTypePostgres = types.StorageType("postgres")
 	StorageTypeS3       = types.StorageType("s3")
+	StorageTypeHTTP     = types.StorageType("http")
 )
 
 // The StorageServer provides an interface to the file system
@@ -109,11 +111,32 @@ type CacheStore interface {
 	CacheObjectMarkUse(cacheName, key string, accessTime time.Time) error
 }
 
+// CacheObject is a cached object recorded in a `CacheStore`. The key is
+// `dir + "/" + address`, as recorded by `MetadataStorageServer`.
+type CacheObject struct {
+	Key        string
+	AccessTime time.Time
+}
+
+// EvictionStore extends `CacheStore` with the queries needed to evict least
+// recently used objects.
+type EvictionStore interface {
+	CacheStore
+
+	// CacheObjectsLeastRecentlyUsed lists up to `limit` objects for a cache,
+	// least recently used first, after skipping `offset` objects.
+	CacheObjectsLeastRecentlyUsed(cacheName string, offset, limit int) ([]CacheObject, error)
+
+	// CacheObjectRemove removes the record of an object.
+	CacheObjectRemove(cacheName, key string) error
+}
+
 type Config struct {
 	CacheTimeout   time.Duration
 	ChunkSizeBytes uint64
 	S3             *ConfigS3
 	File           *ConfigFile
+	Postgres       *ConfigPostgres
 }
 
 type ConfigFile struct {
@@ -133,6 +156,11 @@ type ConfigS3 struct {
 	EnableSharedConfig bool   // overrides the AWS_SKD_LOAD_CONFIG env var and enables shared config functionality
 }
 
+type ConfigPostgres struct {
+	URL            string // PostgreSQL connection URL, not needed when a pool is provided
+	SkipValidation bool   // skip connecting to the database to validate the configuration
+}
+
 type CopyPart struct {
 	Dir     string
 	Address string
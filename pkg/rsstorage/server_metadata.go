@@ -5,6 +5,7 @@ package rsstorage
 import (
 	"context"
 	"io"
+	"iter"
 	"time"
 
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
@@ -44,6 +45,18 @@ func (s *MetadataStorageServer) Get(ctx context.Context, dir, address string) (i
 	return r, c, sz, ts, ok, err
 }
 
+func (s *MetadataStorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, c, sz, ts, ok, err := GetRange(ctx, s.StorageServer, dir, address, offset, length)
+	if ok && err == nil {
+		// Record access of cached object
+		err = s.store.CacheObjectMarkUse(s.name, dir+"/"+address, time.Now())
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+	}
+	return r, c, sz, ts, ok, err
+}
+
 func (s *MetadataStorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
 	dirOut, addrOut, err := s.StorageServer.PutChunked(ctx, resolve, dir, address, sz)
 	if err == nil {
@@ -68,6 +81,53 @@ func (s *MetadataStorageServer) Put(ctx context.Context, resolve types.Resolver,
 	return dirOut, addrOut, err
 }
 
+func (s *MetadataStorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	r, c, sz, ts, meta, ok, err := GetWithMetadata(ctx, s.StorageServer, dir, address)
+	if ok && err == nil {
+		// Record access of cached object
+		err = s.store.CacheObjectMarkUse(s.name, dir+"/"+address, time.Now())
+		if err != nil {
+			return nil, nil, 0, time.Time{}, nil, false, err
+		}
+	}
+	return r, c, sz, ts, meta, ok, err
+}
+
+// CheckWithMetadata checks the wrapped server. Like `Check`, it does not
+// record access.
+func (s *MetadataStorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	return CheckWithMetadata(ctx, s.StorageServer, dir, address)
+}
+
+func (s *MetadataStorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	dirOut, addrOut, err := PutChunkedWithMetadata(ctx, s.StorageServer, resolve, dir, address, sz, meta)
+	if err == nil {
+		// Record cached object
+		err = s.store.CacheObjectEnsureExists(s.name, dirOut+"/"+addrOut)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return dirOut, addrOut, err
+}
+
+func (s *MetadataStorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	dirOut, addrOut, err := PutWithMetadata(ctx, s.StorageServer, resolve, dir, address, meta)
+	if err == nil {
+		// Record cached object
+		err = s.store.CacheObjectEnsureExists(s.name, dirOut+"/"+addrOut)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return dirOut, addrOut, err
+}
+
+// EnumerateItems streams the items of the wrapped server.
+func (s *MetadataStorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return EnumerateItems(ctx, s.StorageServer, opts)
+}
+
 func (s *MetadataStorageServer) Base() StorageServer {
 	return s.StorageServer.Base()
 }
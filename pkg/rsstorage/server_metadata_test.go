@@ -185,3 +185,31 @@ func (s *MetadataServerSuite) TestPutOk(c *check.C) {
 	c.Check(err, check.IsNil)
 	c.Check(cstore.got, check.Equals, "adir/storageaddress")
 }
+
+func (s *MetadataServerSuite) TestGetRangeOk(c *check.C) {
+	f := &FakeFileIOFile{contents: bytes.NewBufferString("0123456789abcdefghij")}
+	parentServer := &DummyStorageServer{
+		GetOk:     true,
+		GetReader: f,
+		GetSize:   20,
+	}
+	cstore := &cacheStore{}
+	server := &MetadataStorageServer{
+		StorageServer: parentServer,
+		store:         cstore,
+		name:          "test",
+	}
+	r, _, _, _, ok, err := server.GetRange(context.Background(), "somedir", "storageaddress", 5, 10)
+	c.Check(ok, check.Equals, true)
+	c.Assert(err, check.IsNil)
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "56789abcde")
+	c.Check(cstore.used, check.Equals, "somedir/storageaddress")
+
+	cstore.useErr = errors.New("store get error")
+	f.contents = bytes.NewBufferString("0123456789abcdefghij")
+	_, _, _, _, ok, err = server.GetRange(context.Background(), "somedir", "storageaddress", 5, 10)
+	c.Check(ok, check.Equals, false)
+	c.Check(err, check.ErrorMatches, "store get error")
+}
@@ -0,0 +1,55 @@
+package changefeed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listener"
+)
+
+const (
+	// DefaultNotifyType is the notification type used for change notifications
+	// when none is configured.
+	DefaultNotifyType = uint8(5)
+
+	// DefaultChannel is the channel on which change notifications are sent
+	// when none is configured.
+	DefaultChannel = "messages"
+)
+
+// Op is the kind of change to an item.
+type Op string
+
+const (
+	OpPut    Op = "put"
+	OpRemove Op = "remove"
+)
+
+// ChangeNotification is sent when an item is written or removed.
+type ChangeNotification struct {
+	listener.GenericNotification
+	Op      Op
+	Dir     string
+	Address string
+}
+
+// NewChangeNotification returns a notification of the given type announcing
+// a change to an item.
+func NewChangeNotification(notifyType uint8, op Op, dir, address string) *ChangeNotification {
+	return &ChangeNotification{
+		GenericNotification: listener.GenericNotification{
+			NotifyGuid: uuid.New().String(),
+			NotifyType: notifyType,
+		},
+		Op:      op,
+		Dir:     dir,
+		Address: address,
+	}
+}
+
+// Register registers the `ChangeNotification` type with a matcher so
+// listeners can decode change notifications.
+func Register(matcher listener.TypeMatcher, notifyType uint8) {
+	matcher.Register(notifyType, &ChangeNotification{})
+}
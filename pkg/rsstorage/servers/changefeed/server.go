@@ -0,0 +1,194 @@
+package changefeed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"io"
+	"iter"
+	"log/slog"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Sender sends notifications. It is implemented by `notifier.Notifier`.
+type Sender interface {
+	Notify(ctx context.Context, channelName string, notification interface{}) error
+}
+
+// StorageServer is a storage server that publishes a `ChangeNotification`
+// whenever an item is written or removed, so that other nodes can invalidate
+// copies of the item they hold in memory. Notifications are sent after the
+// underlying server succeeds. Errors sending notifications are logged and do
+// not fail the write or removal.
+type StorageServer struct {
+	server     rsstorage.StorageServer
+	sender     Sender
+	channel    string
+	notifyType uint8
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	Sender Sender
+
+	// Channel defaults to `DefaultChannel`.
+	Channel string
+
+	// NotifyType defaults to `DefaultNotifyType`.
+	NotifyType uint8
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	if args.Channel == "" {
+		args.Channel = DefaultChannel
+	}
+	if args.NotifyType == 0 {
+		args.NotifyType = DefaultNotifyType
+	}
+	return &StorageServer{
+		server:     args.Server,
+		sender:     args.Sender,
+		channel:    args.Channel,
+		notifyType: args.NotifyType,
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	return s.server.Check(ctx, dir, address)
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return s.server.Get(ctx, dir, address)
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	wdir, waddress, err := s.server.Put(ctx, resolve, dir, address)
+	if err != nil {
+		return "", "", err
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+	}
+	s.publish(ctx, OpPut, dir, address)
+	return wdir, waddress, nil
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	wdir, waddress, err := s.server.PutChunked(ctx, resolve, dir, address, sz)
+	if err != nil {
+		return "", "", err
+	}
+	s.publish(ctx, OpPut, dir, address)
+	return wdir, waddress, nil
+}
+
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	wdir, waddress, err := rsstorage.PutWithMetadata(ctx, s.server, resolve, dir, address, meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+	}
+	s.publish(ctx, OpPut, dir, address)
+	return wdir, waddress, nil
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	wdir, waddress, err := rsstorage.PutChunkedWithMetadata(ctx, s.server, resolve, dir, address, sz, meta)
+	if err != nil {
+		return "", "", err
+	}
+	s.publish(ctx, OpPut, dir, address)
+	return wdir, waddress, nil
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	return rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	return rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	err := s.server.Remove(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	s.publish(ctx, OpRemove, dir, address)
+	return nil
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	return s.server.Enumerate(ctx)
+}
+
+// Move moves an item to another server, and publishes its removal from this
+// server. Writing the item to the other server publishes a notification only
+// when the other server is also a change feed server.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return rsstorage.EnumerateItems(ctx, s.server, opts)
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.server.Move(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	s.publish(ctx, OpRemove, dir, address)
+	return nil
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	return s.server.Copy(ctx, dir, address, server)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, so that items copied into it are published.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+func (s *StorageServer) publish(ctx context.Context, op Op, dir, address string) {
+	err := s.sender.Notify(ctx, s.channel, NewChangeNotification(s.notifyType, op, dir, address))
+	if err != nil {
+		slog.Error("unable to publish storage change", "op", op, "dir", dir, "address", address, "error", err)
+	}
+}
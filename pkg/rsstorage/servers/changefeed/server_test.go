@@ -0,0 +1,174 @@
+package changefeed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ChangeFeedStorageServerSuite struct{}
+
+var _ = check.Suite(&ChangeFeedStorageServerSuite{})
+
+type fakeSender struct {
+	channel string
+	sent    []*ChangeNotification
+	err     error
+}
+
+func (f *fakeSender) Notify(ctx context.Context, channelName string, n interface{}) error {
+	f.channel = channelName
+	f.sent = append(f.sent, n.(*ChangeNotification))
+	return f.err
+}
+
+// changes returns the changes sent, without the notification GUIDs.
+func (f *fakeSender) changes() []ChangeNotification {
+	changes := make([]ChangeNotification, 0, len(f.sent))
+	for _, n := range f.sent {
+		change := *n
+		change.NotifyGuid = ""
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func change(op Op, dir, address string) ChangeNotification {
+	n := NewChangeNotification(DefaultNotifyType, op, dir, address)
+	n.NotifyGuid = ""
+	return *n
+}
+
+func (s *ChangeFeedStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("changefeed", 4096)
+	sender := &fakeSender{}
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Sender: sender})
+	c.Check(server, check.DeepEquals, &StorageServer{
+		server:     underlying,
+		sender:     sender,
+		channel:    DefaultChannel,
+		notifyType: DefaultNotifyType,
+	})
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+}
+
+func (s *ChangeFeedStorageServerSuite) TestPublish(c *check.C) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	server := NewStorageServer(StorageServerArgs{
+		Server:     memtest.NewServer("changefeed", 4096),
+		Sender:     sender,
+		Channel:    "changes",
+		NotifyType: DefaultNotifyType,
+	})
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, "two")
+		return "dir", "resolved", err
+	}, "", "")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver("three"), "dir", "chunked", 5)
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+
+	// Moves publish the removal, and copies publish nothing from the source
+	other := memtest.NewServer("other", 4096)
+	c.Assert(server.Copy(ctx, "dir", "resolved", other), check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "resolved", other), check.IsNil)
+
+	// Items copied into the server are published
+	c.Assert(other.Copy(ctx, "dir", "resolved", server), check.IsNil)
+
+	c.Check(sender.channel, check.Equals, "changes")
+	c.Check(sender.changes(), check.DeepEquals, []ChangeNotification{
+		change(OpPut, "dir", "address"),
+		change(OpPut, "dir", "resolved"),
+		change(OpPut, "dir", "chunked"),
+		change(OpRemove, "dir", "address"),
+		change(OpRemove, "dir", "resolved"),
+		change(OpPut, "dir", "resolved"),
+	})
+}
+
+func (s *ChangeFeedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("changefeed", 4096),
+		Sender: sender,
+	})
+	meta := types.Metadata{"key": "value"}
+
+	_, _, err := rsstorage.PutWithMetadata(ctx, server, servertest.StringResolver("one"), "dir", "address", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutWithMetadata(ctx, server, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, "two")
+		return "dir", "resolved", err
+	}, "", "", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, servertest.StringResolver("three"), "dir", "chunked", 5, meta)
+	c.Assert(err, check.IsNil)
+	c.Check(sender.changes(), check.DeepEquals, []ChangeNotification{
+		change(OpPut, "dir", "address"),
+		change(OpPut, "dir", "resolved"),
+		change(OpPut, "dir", "chunked"),
+	})
+
+	ok, _, sz, _, got, err := rsstorage.CheckWithMetadata(ctx, server, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(3))
+	c.Check(got, check.DeepEquals, meta)
+	r, chunked, _, _, got, ok, err := rsstorage.GetWithMetadata(ctx, server, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "three")
+
+	var items []types.StoredItem
+	for item, err := range rsstorage.EnumerateItems(ctx, server, types.EnumerateOptions{Prefix: "dir"}) {
+		c.Assert(err, check.IsNil)
+		items = append(items, item)
+	}
+	c.Check(items, check.HasLen, 3)
+}
+
+func (s *ChangeFeedStorageServerSuite) TestPublishErrors(c *check.C) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("changefeed", 4096),
+		Sender: sender,
+	})
+
+	// Failed writes are not published
+	_, _, err := server.Put(ctx, func(w io.Writer) (string, string, error) {
+		return "", "", errors.New("resolver error")
+	}, "dir", "address")
+	c.Check(err, check.ErrorMatches, "resolver error")
+	c.Check(sender.sent, check.HasLen, 0)
+
+	// Errors sending notifications do not fail writes
+	sender.err = errors.New("notify error")
+	_, _, err = server.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Check(err, check.IsNil)
+	c.Check(server.Remove(ctx, "dir", "address"), check.IsNil)
+	c.Check(sender.sent, check.HasLen, 2)
+}
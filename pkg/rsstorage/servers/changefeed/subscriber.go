@@ -0,0 +1,63 @@
+package changefeed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/broadcaster"
+)
+
+// Invalidator removes items from a cache by address. It is implemented by
+// `rscache.MemoryCache`.
+type Invalidator interface {
+	Uncache(address string)
+}
+
+type SubscriberArgs struct {
+	// Cache is invalidated for every item written or removed.
+	Cache Invalidator
+
+	// NotifyType defaults to `DefaultNotifyType`.
+	NotifyType uint8
+}
+
+// Subscriber invalidates cached copies of items when it receives a
+// `ChangeNotification` for them. Run a subscriber on every node that keeps
+// an `rscache.MemoryCache` in front of storage wrapped by a change feed
+// `StorageServer`.
+type Subscriber struct {
+	cache      Invalidator
+	notifyType uint8
+}
+
+func NewSubscriber(args SubscriberArgs) *Subscriber {
+	if args.NotifyType == 0 {
+		args.NotifyType = DefaultNotifyType
+	}
+	return &Subscriber{
+		cache:      args.Cache,
+		notifyType: args.NotifyType,
+	}
+}
+
+// Run invalidates cached items until the context is done or the broadcaster
+// stops.
+func (s *Subscriber) Run(ctx context.Context, b broadcaster.Broadcaster) {
+	sub := b.Subscribe(s.notifyType)
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.Unsubscribe(sub)
+			return
+		case n, more := <-sub:
+			if !more {
+				return
+			}
+			if cn, ok := n.(*ChangeNotification); ok {
+				s.cache.Uncache(cn.Address)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package changefeed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rscache"
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/broadcaster"
+	"github.com/rstudio/platform-lib/v4/pkg/rsnotify/listener"
+)
+
+type SubscriberSuite struct{}
+
+var _ = check.Suite(&SubscriberSuite{})
+
+var _ Invalidator = rscache.MemoryCache(nil)
+
+type fakeListener struct {
+	items chan listener.Notification
+	errs  chan error
+}
+
+func (f *fakeListener) Listen() (chan listener.Notification, chan error, error) {
+	return f.items, f.errs, nil
+}
+
+func (f *fakeListener) Stop() {}
+
+func (f *fakeListener) IP() string {
+	return ""
+}
+
+// fakeInvalidator records invalidated addresses.
+type fakeInvalidator struct {
+	addresses chan string
+}
+
+func (f *fakeInvalidator) Uncache(address string) {
+	f.addresses <- address
+}
+
+func (s *SubscriberSuite) TestRun(c *check.C) {
+	l := &fakeListener{
+		items: make(chan listener.Notification, listener.MaxChannelSize),
+		errs:  make(chan error),
+	}
+	b, err := broadcaster.NewNotificationBroadcaster(l, make(chan bool))
+	c.Assert(err, check.IsNil)
+
+	cache := &fakeInvalidator{addresses: make(chan string, 10)}
+	sub := NewSubscriber(SubscriberArgs{Cache: cache})
+	c.Check(sub.notifyType, check.Equals, DefaultNotifyType)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sub.Run(ctx, b)
+	}()
+
+	// Keep notifying since the subscriber may not have subscribed yet
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		l.items <- NewChangeNotification(DefaultNotifyType, OpPut, "dir", "address")
+		select {
+		case address := <-cache.addresses:
+			c.Check(address, check.Equals, "address")
+			received = true
+		case <-timeout:
+			c.Fatal("timed out waiting for the subscriber")
+		case <-tick.C:
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func (s *SubscriberSuite) TestRunInvalidatesMemoryCache(c *check.C) {
+	rc, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 100,
+		MaxCost:     1000,
+		BufferItems: 64,
+	})
+	c.Assert(err, check.IsNil)
+	mc := rscache.NewMemoryCache(rscache.MemoryCacheConfig{Ristretto: rc, TTL: time.Minute})
+	c.Assert(mc.Put("address", &rscache.CacheReturn{Value: "one"}), check.IsNil)
+	rc.Wait()
+	c.Assert(mc.Get("address").IsNull(), check.Equals, false)
+
+	l := &fakeListener{
+		items: make(chan listener.Notification, listener.MaxChannelSize),
+		errs:  make(chan error),
+	}
+	b, err := broadcaster.NewNotificationBroadcaster(l, make(chan bool))
+	c.Assert(err, check.IsNil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewSubscriber(SubscriberArgs{Cache: mc}).Run(context.Background(), b)
+	}()
+
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+	for !mc.Get("address").IsNull() {
+		l.items <- NewChangeNotification(DefaultNotifyType, OpRemove, "dir", "address")
+		select {
+		case <-timeout:
+			c.Fatal("timed out waiting for the subscriber")
+		case <-tick.C:
+		}
+	}
+
+	// Returns when the broadcaster stops
+	close(l.items)
+	<-done
+}
@@ -0,0 +1,139 @@
+package checksum
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+)
+
+// ScrubResult summarizes a scrub.
+type ScrubResult struct {
+	// Checked is the number of items checked.
+	Checked int
+
+	// Verified is the number of items that matched their checksums.
+	Verified int
+
+	// Unverified is the number of items without recorded checksums, e.g.,
+	// items written before checksums were recorded.
+	Unverified int
+
+	// Skipped is the number of items that were skipped because a chunked
+	// write is still in progress.
+	Skipped int
+
+	// Quarantined is the number of corrupt items moved to the quarantine
+	// server.
+	Quarantined int
+
+	// Corrupt lists the items that did not match their checksums.
+	Corrupt []*ChecksumError
+}
+
+// Scrub reads all items and verifies them against their recorded checksums.
+// Corrupt items are reported in the result and in the returned error. When a
+// `Quarantine` server is configured, corrupt items are moved to it.
+func (s *StorageServer) Scrub(ctx context.Context) (ScrubResult, error) {
+	result := ScrubResult{}
+
+	items, err := s.Enumerate(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var errs error
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return result, errors.Join(errs, err)
+		}
+		result.Checked++
+		err = s.scrubItem(ctx, item.Dir, item.Address, &result)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error scrubbing dir=%s and address=%s: %w", item.Dir, item.Address, err))
+		}
+	}
+	return result, errs
+}
+
+func (s *StorageServer) scrubItem(ctx context.Context, dir, address string, result *ScrubResult) error {
+	ok, chunked, _, _, err := s.server.Check(ctx, dir, address)
+	if err != nil {
+		return err
+	} else if !ok {
+		// Removed since it was enumerated
+		return nil
+	} else if chunked != nil && !chunked.Complete {
+		result.Skipped++
+		return nil
+	}
+
+	r, chunked, _, _, ok, err := s.server.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return err
+	}
+	v, err := s.verifier(ctx, dir, address, r, chunked)
+	if err != nil {
+		r.Close()
+		return err
+	} else if v == nil {
+		r.Close()
+		result.Unverified++
+		return nil
+	}
+	_, err = io.Copy(io.Discard, v)
+	v.Close()
+
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) {
+		if err == nil {
+			result.Verified++
+		}
+		return err
+	}
+	result.Corrupt = append(result.Corrupt, cerr)
+	slog.Warn("found corrupt item", "dir", dir, "address", address, "error", cerr)
+
+	if s.quarantine != nil {
+		err = s.quarantineItem(ctx, dir, address, chunked == nil)
+		if err != nil {
+			return errors.Join(cerr, fmt.Errorf("error quarantining item: %w", err))
+		}
+		result.Quarantined++
+	}
+	return cerr
+}
+
+// quarantineItem moves a corrupt item, and its checksum, to the quarantine
+// server unchanged.
+func (s *StorageServer) quarantineItem(ctx context.Context, dir, address string, sidecar bool) error {
+	err := s.server.Move(ctx, dir, address, s.quarantine)
+	if err != nil {
+		return err
+	}
+	if sidecar {
+		return s.server.Move(ctx, dir, address+ChecksumSuffix, s.quarantine)
+	}
+	return nil
+}
+
+// NewScrubTask returns a scheduled task that scrubs `server`.
+func NewScrubTask(name string, server *StorageServer, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := server.Scrub(ctx)
+		slog.Debug("scrubbed items",
+			"task", name,
+			"checked", result.Checked,
+			"verified", result.Verified,
+			"unverified", result.Unverified,
+			"skipped", result.Skipped,
+			"corrupt", len(result.Corrupt),
+			"quarantined", result.Quarantined)
+		return err
+	})
+}
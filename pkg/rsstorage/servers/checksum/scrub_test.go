@@ -0,0 +1,109 @@
+package checksum
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type ScrubSuite struct{}
+
+var _ = check.Suite(&ScrubSuite{})
+
+func (s *ScrubSuite) TestScrub(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	quarantine := memtest.NewServer("quarantine", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying})
+
+	data := servertest.TestData(2*chunkSize + 100)
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("other data"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("legacy"), "dir", "legacy")
+	c.Assert(err, check.IsNil)
+
+	result, err := server.Scrub(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, ScrubResult{
+		Checked:    4,
+		Verified:   3,
+		Unverified: 1,
+	})
+
+	// Corrupt some items
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("some dada"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(data[:100]), "dir/chunked", "00000003")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(servertest.TestData(100)), "dir/chunked", "00000001")
+	c.Assert(err, check.IsNil)
+
+	// Reported
+	result, err = server.Scrub(ctx)
+	c.Check(err, check.ErrorMatches, "(?s).*error scrubbing dir=dir and address=a: checksum mismatch for dir=dir and address=a: .*")
+	c.Check(err, check.ErrorMatches, "(?s).*error scrubbing dir=dir and address=chunked: checksum mismatch for dir=dir and address=chunked chunk 1: .*")
+	c.Check(result.Checked, check.Equals, 4)
+	c.Check(result.Verified, check.Equals, 1)
+	c.Check(result.Unverified, check.Equals, 1)
+	c.Assert(result.Corrupt, check.HasLen, 2)
+	corrupt := map[string]uint64{}
+	for _, cerr := range result.Corrupt {
+		corrupt[cerr.Address] = cerr.Chunk
+	}
+	c.Check(corrupt, check.DeepEquals, map[string]uint64{"a": 0, "chunked": 1})
+	c.Check(servertest.Exists(c, server, "dir", "a"), check.Equals, true)
+
+	// Quarantined
+	server.quarantine = quarantine
+	result, err = server.Scrub(ctx)
+	c.Check(err, check.NotNil)
+	c.Check(result.Quarantined, check.Equals, 2)
+	for _, address := range []string{"a", "a" + ChecksumSuffix, "chunked"} {
+		c.Check(servertest.Exists(c, underlying, "dir", address), check.Equals, false)
+		c.Check(servertest.Exists(c, quarantine, "dir", address), check.Equals, true)
+	}
+	c.Check(servertest.ReadItem(c, quarantine, "dir", "a"), check.Equals, "some dada")
+
+	result, err = server.Scrub(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, ScrubResult{
+		Checked:    2,
+		Verified:   1,
+		Unverified: 1,
+	})
+}
+
+func (s *ScrubSuite) TestScrubTask(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	quarantine := memtest.NewServer("quarantine", chunkSize)
+	server := NewStorageServer(StorageServerArgs{
+		Server:     underlying,
+		Quarantine: quarantine,
+	})
+
+	ticker := make(chan time.Time)
+	task := NewScrubTask("scrub", server, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "scrub")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("some dada"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	task.Run(ctx, nil)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"), check.Equals, false)
+	c.Check(servertest.Exists(c, quarantine, "dir", "address"), check.Equals, true)
+}
@@ -0,0 +1,411 @@
+package checksum
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// ChecksumSuffix is appended to the address of an item to store its checksum.
+const ChecksumSuffix = ".sha256"
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError reports an item whose content does not match its recorded
+// checksum.
+type ChecksumError struct {
+	Dir     string
+	Address string
+
+	// Chunk is the one-based index of the corrupt chunk of a chunked item,
+	// or zero for items that are not chunked.
+	Chunk uint64
+
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Chunk > 0 {
+		return fmt.Sprintf("%s for dir=%s and address=%s chunk %d: expected %s, got %s", ErrChecksumMismatch, e.Dir, e.Address, e.Chunk, e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("%s for dir=%s and address=%s: expected %s, got %s", ErrChecksumMismatch, e.Dir, e.Address, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// StorageServer is a storage server that records a SHA-256 checksum of each
+// item written to another storage server, so that silent corruption can be
+// detected. Chunked items record the checksum of each chunk in their
+// `ChunksInfo`. Other items store their checksum next to the item, with
+// `ChecksumSuffix` appended to the address.
+//
+// Checksums are verified as items are read with `Get` when `Verify` is set,
+// and by `Scrub`. Ranges read with `GetRange` are not verified. Items
+// without a recorded checksum are read without verification.
+type StorageServer struct {
+	server     rsstorage.StorageServer
+	verify     bool
+	quarantine rsstorage.StorageServer
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	// Verify enables verifying checksums as items are read with `Get`. A
+	// reader returns an error wrapping `ErrChecksumMismatch` when corruption
+	// is detected. Corrupt data may already have been read by then.
+	Verify bool
+
+	// Quarantine is an optional storage server. When set, `Scrub` moves
+	// corrupt items to it.
+	Quarantine rsstorage.StorageServer
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server:     args.Server,
+		verify:     args.Verify,
+		quarantine: args.Quarantine,
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	return s.server.Check(ctx, dir, address)
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	return rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok || !s.verify {
+		return r, chunked, sz, mod, meta, ok, err
+	}
+	v, err := s.verifier(ctx, dir, address, r, chunked)
+	if err != nil {
+		r.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	} else if v != nil {
+		r = v
+	}
+	return r, chunked, sz, mod, meta, true, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	err := s.checkMetadata(meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Remove the checksum of any earlier item first, so that an interrupted
+	// write does not leave a stale checksum behind
+	if address != "" {
+		err = s.server.Remove(ctx, dir, address+ChecksumSuffix)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	h := sha256.New()
+	wdir, waddress, err := rsstorage.PutWithMetadata(ctx, s.server, func(w io.Writer) (string, string, error) {
+		return resolve(io.MultiWriter(w, h))
+	}, dir, address, meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	_, _, err = s.server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, sum)
+		return "", "", err
+	}, dir, address+ChecksumSuffix)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, address, nil
+}
+
+// PutChunked stores the item in chunks. The checksum of each chunk is
+// recorded in the item's `ChunksInfo`.
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+	err := s.checkMetadata(meta)
+	if err != nil {
+		return "", "", err
+	}
+	// Remove any checksum left by an earlier item that was not chunked
+	err = s.server.Remove(ctx, dir, address+ChecksumSuffix)
+	if err != nil {
+		return "", "", err
+	}
+	return rsstorage.PutChunkedWithMetadata(ctx, s.server, resolve, dir, address, sz, meta)
+}
+
+// checkMetadata rejects metadata that the underlying storage server cannot
+// store before the checksum of an earlier item is removed.
+func (s *StorageServer) checkMetadata(meta types.Metadata) error {
+	if _, ok := s.server.(rsstorage.ObjectMetadataServer); !ok && len(meta) > 0 {
+		return rsstorage.ErrMetadataNotSupported
+	}
+	return nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	err := s.server.Remove(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	return s.server.Remove(ctx, dir, address+ChecksumSuffix)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+// Enumerate lists the items. Checksums are not included.
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.StoredItem, 0, len(items))
+	for _, item := range items {
+		if !item.Chunked && strings.HasSuffix(item.Address, ChecksumSuffix) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+// Copy copies an item to another server. The item is verified while it is
+// copied when `Verify` is set.
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, so that items copied into it record checksums.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// checksums returns the recorded checksums of an item, or nil if no checksums
+// were recorded.
+func (s *StorageServer) checksums(ctx context.Context, dir, address string, chunked *types.ChunksInfo) ([]string, error) {
+	if chunked != nil {
+		if !chunked.Complete || uint64(len(chunked.Checksums)) != chunked.NumChunks {
+			return nil, nil
+		}
+		return chunked.Checksums, nil
+	}
+
+	r, _, _, _, ok, err := s.server.Get(ctx, dir, address+ChecksumSuffix)
+	if err != nil || !ok {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(bytes.TrimSpace(b))}, nil
+}
+
+// verifier returns a reader that verifies `r`, or nil if the item has no
+// recorded checksums.
+func (s *StorageServer) verifier(ctx context.Context, dir, address string, r io.ReadCloser, chunked *types.ChunksInfo) (*verifier, error) {
+	sums, err := s.checksums(ctx, dir, address, chunked)
+	if err != nil || sums == nil {
+		return nil, err
+	}
+	v := &verifier{
+		r:       r,
+		dir:     dir,
+		address: address,
+		sums:    sums,
+		hash:    sha256.New(),
+	}
+	if chunked != nil {
+		v.chunkSize = int64(chunked.ChunkSize)
+	}
+	return v, nil
+}
+
+// verifier computes checksums as an item is read, and returns a
+// `ChecksumError` if they do not match the expected checksums.
+type verifier struct {
+	r       io.ReadCloser
+	dir     string
+	address string
+
+	// The expected checksums. Chunked items have one per chunk.
+	sums      []string
+	chunkSize int64
+
+	// The current chunk and the number of bytes read from it
+	chunk int
+	n     int64
+	hash  hash.Hash
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	b := p[:n]
+	for len(b) > 0 {
+		take := int64(len(b))
+		if v.chunkSize > 0 {
+			take = min(take, v.chunkSize-v.n)
+		}
+		v.hash.Write(b[:take])
+		v.n += take
+		b = b[take:]
+		if v.chunkSize > 0 && v.n == v.chunkSize {
+			if verr := v.finish(); verr != nil {
+				return n, verr
+			}
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		if verr := v.end(); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// end verifies the final chunk, and that no chunks are missing.
+func (v *verifier) end() error {
+	if v.n > 0 || v.chunk == 0 {
+		err := v.finish()
+		if err != nil {
+			return err
+		}
+	}
+	if v.chunk < len(v.sums) {
+		cerr := &ChecksumError{
+			Dir:      v.dir,
+			Address:  v.address,
+			Expected: v.sums[v.chunk],
+			Actual:   "missing data",
+		}
+		if v.chunkSize > 0 {
+			cerr.Chunk = uint64(v.chunk + 1)
+		}
+		return cerr
+	}
+	return nil
+}
+
+// finish verifies the checksum of the current chunk.
+func (v *verifier) finish() error {
+	cerr := &ChecksumError{
+		Dir:     v.dir,
+		Address: v.address,
+		Actual:  hex.EncodeToString(v.hash.Sum(nil)),
+	}
+	if v.chunkSize > 0 {
+		cerr.Chunk = uint64(v.chunk + 1)
+	}
+	if v.chunk >= len(v.sums) {
+		cerr.Expected = "end of item"
+		return cerr
+	}
+	cerr.Expected = v.sums[v.chunk]
+	v.chunk++
+	v.n = 0
+	v.hash.Reset()
+	if cerr.Actual != cerr.Expected {
+		return cerr
+	}
+	return nil
+}
+
+func (v *verifier) Close() error {
+	return v.r.Close()
+}
@@ -0,0 +1,316 @@
+package checksum
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ChecksumStorageServerSuite struct{}
+
+var _ = check.Suite(&ChecksumStorageServerSuite{})
+
+const chunkSize = 4096
+
+func read(server rsstorage.StorageServer, dir, address string) (string, error) {
+	r, _, _, _, ok, err := server.Get(context.Background(), dir, address)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", errors.New("not found")
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	return string(b), err
+}
+
+func sum(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func (s *ChecksumStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("checksum", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying})
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://checksum/dir/address")
+}
+
+func (s *ChecksumStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Verify: true})
+
+	for _, data := range []string{"", "some data", servertest.TestData(3*chunkSize + 5)} {
+		_, _, err := server.Put(ctx, servertest.StringResolver(data), "dir", "address")
+		c.Assert(err, check.IsNil)
+		c.Check(servertest.ReadItem(c, server, "dir", "address") == data, check.Equals, true)
+		c.Check(servertest.ReadItem(c, underlying, "dir", "address"+ChecksumSuffix), check.Equals, sum(data))
+	}
+
+	// Deferred address
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, d, a), check.Equals, "deferred")
+	c.Check(servertest.ReadItem(c, underlying, d, a+ChecksumSuffix), check.Equals, sum("deferred"))
+
+	// Ranges are not verified
+	r, _, _, _, ok, err := server.GetRange(ctx, "dir", "address", 5, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, servertest.TestData(15)[5:])
+
+	// Items without a checksum are not verified
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("legacy"), "dir", "legacy")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "legacy"), check.Equals, "legacy")
+
+	// Missing
+	r, _, _, _, ok, err = server.Get(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+
+	// Checksums are not listed
+	items, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	addresses := make([]string, 0)
+	for _, item := range items {
+		addresses = append(addresses, item.Address)
+	}
+	c.Check(addresses, check.DeepEquals, []string{"deferred-address", "address", "legacy"})
+
+	// Removed with the item
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"), check.Equals, false)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"+ChecksumSuffix), check.Equals, false)
+}
+
+func (s *ChecksumStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Verify: true})
+	data := servertest.TestData(2*chunkSize + 100)
+	sz := uint64(len(data))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(data), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", 0)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-sized chunked put commands")
+
+	// Replaces an item that was not chunked
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(sum("stale")), "dir", "address"+ChecksumSuffix)
+	c.Assert(err, check.IsNil)
+
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", sz)
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"+ChecksumSuffix), check.Equals, false)
+
+	ok, chunked, _, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(chunked.Checksums, check.DeepEquals, []string{
+		sum(data[:chunkSize]),
+		sum(data[chunkSize : 2*chunkSize]),
+		sum(data[2*chunkSize:]),
+	})
+	c.Check(servertest.ReadItem(c, server, "dir", "address") == data, check.Equals, true)
+}
+
+func (s *ChecksumStorageServerSuite) TestCorrupt(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Verify: true})
+
+	// Modified
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("some dada"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, err = read(server, "dir", "address")
+	var cerr *ChecksumError
+	c.Assert(errors.As(err, &cerr), check.Equals, true)
+	c.Check(errors.Is(err, ErrChecksumMismatch), check.Equals, true)
+	c.Check(cerr, check.DeepEquals, &ChecksumError{
+		Dir:      "dir",
+		Address:  "address",
+		Expected: sum("some data"),
+		Actual:   sum("some dada"),
+	})
+	c.Check(err, check.ErrorMatches, "checksum mismatch for dir=dir and address=address: expected .*, got .*")
+
+	// Not verified unless enabled
+	unverified := NewStorageServer(StorageServerArgs{Server: underlying})
+	c.Check(servertest.ReadItem(c, unverified, "dir", "address"), check.Equals, "some dada")
+
+	// A modified chunk
+	data := servertest.TestData(3*chunkSize + 100)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	modified := []byte(data[chunkSize : 2*chunkSize])
+	modified[10] ^= 1
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(string(modified)), "dir/chunked", "00000002")
+	c.Assert(err, check.IsNil)
+	_, err = read(server, "dir", "chunked")
+	c.Assert(errors.As(err, &cerr), check.Equals, true)
+	c.Check(cerr.Chunk, check.Equals, uint64(2))
+	c.Check(cerr.Expected, check.Equals, sum(data[chunkSize:2*chunkSize]))
+	c.Check(cerr.Actual, check.Equals, sum(string(modified)))
+	c.Check(err, check.ErrorMatches, "checksum mismatch for dir=dir and address=chunked chunk 2: expected .*, got .*")
+}
+
+func (s *ChecksumStorageServerSuite) TestVerifier(c *check.C) {
+	data := servertest.TestData(2*chunkSize + 100)
+	sums := []string{
+		sum(data[:chunkSize]),
+		sum(data[chunkSize : 2*chunkSize]),
+		sum(data[2*chunkSize:]),
+	}
+	verify := func(data string, sums []string, chunkSize int64) error {
+		v := &verifier{
+			r:         io.NopCloser(strings.NewReader(data)),
+			dir:       "dir",
+			address:   "address",
+			sums:      sums,
+			chunkSize: chunkSize,
+			hash:      sha256.New(),
+		}
+		_, err := io.Copy(io.Discard, v)
+		return err
+	}
+
+	c.Check(verify(data, sums, chunkSize), check.IsNil)
+	c.Check(verify(data, []string{sum(data)}, 0), check.IsNil)
+	c.Check(verify("", []string{sum("")}, 0), check.IsNil)
+
+	// Truncated at a chunk boundary
+	err := verify(data[:2*chunkSize], sums, chunkSize)
+	c.Check(err, check.DeepEquals, &ChecksumError{
+		Dir:      "dir",
+		Address:  "address",
+		Chunk:    3,
+		Expected: sums[2],
+		Actual:   "missing data",
+	})
+
+	// Truncated within a chunk
+	err = verify(data[:chunkSize+10], sums, chunkSize)
+	c.Check(err, check.DeepEquals, &ChecksumError{
+		Dir:      "dir",
+		Address:  "address",
+		Chunk:    2,
+		Expected: sums[1],
+		Actual:   sum(data[chunkSize : chunkSize+10]),
+	})
+
+	// Extra data
+	err = verify(data+servertest.TestData(chunkSize), sums, chunkSize)
+	c.Check(err, check.DeepEquals, &ChecksumError{
+		Dir:      "dir",
+		Address:  "address",
+		Chunk:    3,
+		Expected: sums[2],
+		Actual:   sum((data + servertest.TestData(chunkSize))[2*chunkSize : 3*chunkSize]),
+	})
+	err = verify(data+"x", []string{sum(data)}, 0)
+	c.Check(err, check.DeepEquals, &ChecksumError{
+		Dir:      "dir",
+		Address:  "address",
+		Expected: sum(data),
+		Actual:   sum(data + "x"),
+	})
+}
+
+func (s *ChecksumStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	server := NewStorageServer(StorageServerArgs{Server: memtest.NewServer("checksum", chunkSize), Verify: true})
+	dest := NewStorageServer(StorageServerArgs{Server: memtest.NewServer("dest", chunkSize), Verify: true})
+
+	servertest.CheckCopy(c, ctx, server, dest, "the object with dir=dir and address=missing to copy does not exist")
+	c.Check(servertest.ReadItem(c, dest.server, "dir", "a"+ChecksumSuffix), check.Equals, sum("some data"))
+
+	data := servertest.TestData(chunkSize + 100)
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "chunked", dest), check.IsNil)
+	c.Check(servertest.ReadItem(c, dest, "dir", "chunked") == data, check.Equals, true)
+	c.Check(servertest.Exists(c, server, "dir", "chunked"), check.Equals, false)
+}
+
+func (s *ChecksumStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("checksum", chunkSize)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Verify: true})
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	data := servertest.TestData(chunkSize + 100)
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, underlying, "dir", "a"+ChecksumSuffix), check.Equals, sum("some data"))
+
+	for _, address := range []string{"a", "chunked"} {
+		ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+
+		// Items are verified as they are read
+		r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+		_, isVerifier := r.(*verifier)
+		c.Check(isVerifier, check.Equals, true)
+		_, err = io.Copy(io.Discard, r)
+		c.Check(err, check.IsNil)
+		r.Close()
+	}
+
+	// Metadata is copied
+	dest := NewStorageServer(StorageServerArgs{Server: memtest.NewServer("dest", chunkSize)})
+	c.Assert(server.Copy(ctx, "dir", "chunked", dest), check.IsNil)
+	_, _, _, _, got, err := dest.CheckWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// Metadata is rejected before anything is removed when the underlying
+	// server cannot store it
+	dummy := &rsstorage.DummyStorageServer{}
+	server = NewStorageServer(StorageServerArgs{Server: dummy})
+	_, _, err = server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", 9, meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	c.Check(dummy.RemoveCount, check.Equals, 0)
+	c.Check(dummy.PutCalled, check.Equals, 0)
+}
@@ -0,0 +1,684 @@
+package compressed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Codec identifies how an item is compressed. The codec is recorded in a
+// small header with each item, so items written with different codecs, or
+// before compression was enabled, can be read by the same server.
+type Codec byte
+
+const (
+	CodecNone Codec = iota
+	CodecGzip
+	CodecZstd
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecGzip:
+		return "gzip"
+	case CodecZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(c))
+	}
+}
+
+var ErrUnknownCodec = errors.New("unknown compression codec")
+
+const (
+	// marker identifies items written by this server.
+	marker = "\x00RSZ"
+
+	// Each item starts with a header containing the marker, the codec, and
+	// the logical size, if it was known when the item was written.
+	headerLen = len(marker) + 1 + 8
+
+	// Each item ends with a trailer containing the logical size and the
+	// marker.
+	trailerLen = 8 + len(marker)
+
+	// unknownSize is recorded in the header when the logical size was not
+	// known when the item was written.
+	unknownSize = math.MaxUint64
+
+	// sniffLen is the number of bytes used to detect the content type.
+	sniffLen = 512
+)
+
+// incompressible lists the content types that are stored uncompressed by
+// default, since they are already compressed.
+var incompressible = []string{
+	"application/x-gzip",
+	"application/zip",
+	"application/x-rar-compressed",
+	"application/wasm",
+	"application/vnd.ms-fontobject",
+	"font/woff",
+	"font/woff2",
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"audio/",
+	"video/",
+}
+
+// magic lists the signatures of compressed formats that are not recognized
+// by `http.DetectContentType`.
+var magic = [][]byte{
+	{0x28, 0xb5, 0x2f, 0xfd},           // zstd
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},   // xz
+	{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, // 7z
+	{'B', 'Z', 'h'},                    // bzip2
+	{0x04, 0x22, 0x4d, 0x18},           // lz4
+}
+
+// StorageServer is a storage server that compresses items as they are
+// written to another storage server, and decompresses them as they are read.
+// Content that is already compressed is detected and stored raw. Items that
+// were written to the underlying storage server without this wrapper are
+// read as they are.
+//
+// Sizes reported by `Check`, `Get`, and `GetRange` are the logical,
+// uncompressed sizes. Chunk information describes the stored layout.
+type StorageServer struct {
+	server  rsstorage.StorageServer
+	codec   Codec
+	level   int
+	tempDir string
+	skip    []string
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	// Codec is used to compress new items. Use `CodecNone` to stop
+	// compressing new items while still reading compressed items.
+	Codec Codec
+
+	// Level is the compression level. For gzip, this is a `compress/gzip`
+	// level. For zstd, this is a `zstd.EncoderLevel`. Defaults to the
+	// codec's default level.
+	Level int
+
+	// TempDir is the directory used to spool chunked items while they are
+	// compressed. Defaults to the system temporary directory.
+	TempDir string
+
+	// SkipContentTypes lists additional content types, or content type
+	// prefixes like "image/", to store uncompressed.
+	SkipContentTypes []string
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server:  args.Server,
+		codec:   args.Codec,
+		level:   args.Level,
+		tempDir: args.TempDir,
+		skip:    append(append([]string{}, incompressible...), args.SkipContentTypes...),
+	}
+}
+
+// header describes how a stored item is encoded.
+type header struct {
+	// Whether the item has a header and trailer. Items written without this
+	// wrapper do not.
+	marked bool
+	codec  Codec
+	size   int64
+}
+
+// bodyOffset returns the offset of the encoded content in the stored item.
+func (h header) bodyOffset() int64 {
+	if h.marked {
+		return int64(headerLen)
+	}
+	return 0
+}
+
+// bodyLength returns the length of the encoded content in a stored item of
+// `physical` bytes.
+func (h header) bodyLength(physical int64) int64 {
+	if h.marked {
+		return physical - int64(headerLen+trailerLen)
+	}
+	return physical
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+// CheckWithMetadata checks for an item like `Check`, and also returns the
+// metadata stored with it by the underlying storage server.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return ok, chunked, sz, mod, meta, err
+	}
+	// Don't wait for incomplete chunked items to be written
+	if chunked != nil && !chunked.Complete {
+		return ok, chunked, sz, mod, meta, nil
+	}
+	h, err := s.header(ctx, dir, address, sz)
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	return true, chunked, h.size, mod, meta, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+// CalculateUsage reports the bytes used by the underlying storage server.
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+// Get returns the uncompressed item. The header is read from the stored
+// item as it is streamed, so the size is only read separately for items
+// whose size was not known when they were written.
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+// GetWithMetadata gets an item like `Get`, and also returns the metadata
+// stored with it by the underlying storage server.
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	r, chunked, physical, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	if physical < int64(headerLen+trailerLen) {
+		return r, chunked, physical, mod, meta, true, nil
+	}
+
+	b := make([]byte, headerLen)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		r.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	h, err := parseHeader(b, physical, dir, address)
+	if err != nil {
+		r.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	if !h.marked {
+		return &readCloser{Reader: io.MultiReader(bytes.NewReader(b), r), Closer: r}, chunked, physical, mod, meta, true, nil
+	}
+	if h.size < 0 {
+		h.size, err = s.trailer(ctx, dir, address, physical)
+		if err != nil {
+			r.Close()
+			return nil, nil, 0, time.Time{}, nil, false, err
+		}
+	}
+
+	body := internal.LimitReadCloser(r, h.bodyLength(physical))
+	if h.codec == CodecNone {
+		return body, chunked, h.size, mod, meta, true, nil
+	}
+	dr, err := h.codec.newReader(body)
+	if err != nil {
+		body.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	return dr, chunked, h.size, mod, meta, true, nil
+}
+
+// GetRange returns a range of the uncompressed item. Compressed items are
+// decompressed from the start, and the bytes before `offset` are discarded.
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	ok, chunked, physical, mod, err := s.server.Check(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	h, err := s.header(ctx, dir, address, physical)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+
+	n, err := internal.ClampRange(h.size, offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+
+	// Raw items can be read directly
+	if h.codec == CodecNone {
+		r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, h.bodyOffset()+offset, n)
+		if err != nil || !ok {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+		return r, chunked, h.size, mod, true, nil
+	}
+
+	r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, h.bodyOffset(), h.bodyLength(physical))
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	dr, err := h.codec.newReader(r)
+	if err != nil {
+		r.Close()
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	if offset > 0 {
+		_, err = io.CopyN(io.Discard, dr, offset)
+		if err != nil {
+			dr.Close()
+			return nil, nil, 0, time.Time{}, false, err
+		}
+	}
+	return internal.LimitReadCloser(dr, n), chunked, h.size, mod, true, nil
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata compresses an item like `Put`, and stores the metadata
+// with it in the underlying storage server.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	return rsstorage.PutWithMetadata(ctx, s.server, func(w io.Writer) (string, string, error) {
+		return s.encode(w, resolve, unknownSize)
+	}, dir, address, meta)
+}
+
+// PutChunked compresses the item to a temporary file before storing it in
+// chunks, since the underlying storage server needs to know the compressed
+// size in advance. The item cannot be read until it is completely written.
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+// PutChunkedWithMetadata compresses a chunked item like `PutChunked`, and
+// stores the metadata with it in the underlying storage server.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+	// Fail before the item is read
+	if _, ok := s.server.(rsstorage.ObjectMetadataServer); !ok && len(meta) > 0 {
+		return "", "", rsstorage.ErrMetadataNotSupported
+	}
+
+	f, err := os.CreateTemp(s.tempDir, "compressed-")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	_, _, err = s.encode(f, resolve, sz)
+	if err != nil {
+		return "", "", err
+	}
+	physical, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", "", err
+	}
+
+	return rsstorage.PutChunkedWithMetadata(ctx, s.server, func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, f)
+		return "", "", err
+	}, dir, address, uint64(physical), meta)
+}
+
+// encode writes the header, the encoded output of the resolver, and the
+// trailer to `w`.
+func (s *StorageServer) encode(w io.Writer, resolve types.Resolver, sz uint64) (string, string, error) {
+	enc := &encoder{
+		server: s,
+		w:      w,
+		size:   sz,
+	}
+	// Release the compressor if the item is not completely written
+	defer enc.abort()
+
+	dir, address, err := resolve(enc)
+	if err != nil {
+		return dir, address, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return "", "", err
+	}
+	if sz != unknownSize && enc.written != sz {
+		return "", "", fmt.Errorf("expected %d bytes, but %d were written", sz, enc.written)
+	}
+	return dir, address, nil
+}
+
+// choose selects the codec for an item given its first bytes.
+func (s *StorageServer) choose(sample []byte) Codec {
+	if s.codec == CodecNone {
+		return CodecNone
+	}
+	for _, m := range magic {
+		if bytes.HasPrefix(sample, m) {
+			return CodecNone
+		}
+	}
+	contentType := http.DetectContentType(sample)
+	for _, skip := range s.skip {
+		if strings.HasPrefix(contentType, skip) {
+			return CodecNone
+		}
+	}
+	return s.codec
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	return s.server.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	return s.server.Enumerate(ctx)
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the compressed object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, since items in the underlying storage server
+// cannot be used without it.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// header reads the header of a stored item of `physical` bytes. Items
+// without a header are treated as raw items.
+func (s *StorageServer) header(ctx context.Context, dir, address string, physical int64) (header, error) {
+	if physical < int64(headerLen+trailerLen) {
+		return header{codec: CodecNone, size: physical}, nil
+	}
+
+	b, err := s.read(ctx, dir, address, 0, int64(headerLen))
+	if err != nil {
+		return header{}, err
+	}
+	h, err := parseHeader(b, physical, dir, address)
+	if err != nil {
+		return header{}, err
+	}
+	if h.size < 0 {
+		h.size, err = s.trailer(ctx, dir, address, physical)
+		if err != nil {
+			return header{}, err
+		}
+	}
+	return h, nil
+}
+
+// parseHeader parses the first `headerLen` bytes of a stored item of
+// `physical` bytes. Items without a header are treated as raw items. The
+// size is negative when it is only recorded in the trailer.
+func parseHeader(b []byte, physical int64, dir, address string) (header, error) {
+	if !bytes.HasPrefix(b, []byte(marker)) {
+		return header{codec: CodecNone, size: physical}, nil
+	}
+	h := header{
+		marked: true,
+		codec:  Codec(b[len(marker)]),
+		size:   -1,
+	}
+	if h.codec > CodecZstd {
+		return header{}, fmt.Errorf("%w %d for dir=%s and address=%s", ErrUnknownCodec, h.codec, dir, address)
+	}
+	size := binary.BigEndian.Uint64(b[len(marker)+1:])
+	if size != unknownSize {
+		h.size = int64(size)
+	}
+	return h, nil
+}
+
+// trailer reads the logical size from the trailer of a stored item of
+// `physical` bytes.
+func (s *StorageServer) trailer(ctx context.Context, dir, address string, physical int64) (int64, error) {
+	b, err := s.read(ctx, dir, address, physical-int64(trailerLen), int64(trailerLen))
+	if err != nil {
+		return 0, err
+	}
+	if string(b[8:]) != marker {
+		return 0, fmt.Errorf("compressed item with dir=%s and address=%s has an invalid trailer", dir, address)
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (s *StorageServer) read(ctx context.Context, dir, address string, offset, length int64) ([]byte, error) {
+	r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("the compressed object with dir=%s and address=%s does not exist", dir, address)
+	}
+	defer r.Close()
+	b := make([]byte, length)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// encoder compresses the bytes written to it. The first bytes are buffered
+// until the content type can be detected.
+type encoder struct {
+	server *StorageServer
+	w      io.Writer
+
+	// The logical size, if known in advance
+	size uint64
+
+	sniff   []byte
+	started bool
+	body    io.Writer
+	zw      io.WriteCloser
+	written uint64
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	if !e.started {
+		e.sniff = append(e.sniff, p...)
+		if len(e.sniff) < sniffLen {
+			return len(p), nil
+		}
+		err := e.start()
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	n, err := e.body.Write(p)
+	e.written += uint64(n)
+	return n, err
+}
+
+// start chooses the codec, writes the header and any buffered bytes.
+func (e *encoder) start() error {
+	e.started = true
+	codec := e.server.choose(e.sniff)
+
+	h := make([]byte, headerLen)
+	copy(h, marker)
+	h[len(marker)] = byte(codec)
+	binary.BigEndian.PutUint64(h[len(marker)+1:], e.size)
+	_, err := e.w.Write(h)
+	if err != nil {
+		return err
+	}
+
+	e.body = e.w
+	if codec != CodecNone {
+		e.zw, err = codec.newWriter(e.w, e.server.level)
+		if err != nil {
+			return err
+		}
+		e.body = e.zw
+	}
+
+	sniff := e.sniff
+	e.sniff = nil
+	n, err := e.body.Write(sniff)
+	e.written += uint64(n)
+	return err
+}
+
+// Close flushes the compressed stream and writes the trailer.
+func (e *encoder) Close() error {
+	if !e.started {
+		// The whole item is buffered, so its size is known
+		e.size = uint64(len(e.sniff))
+		err := e.start()
+		if err != nil {
+			return err
+		}
+	}
+	if e.zw != nil {
+		zw := e.zw
+		e.zw = nil
+		err := zw.Close()
+		if err != nil {
+			return err
+		}
+	}
+	t := make([]byte, trailerLen)
+	binary.BigEndian.PutUint64(t, e.written)
+	copy(t[8:], marker)
+	_, err := e.w.Write(t)
+	return err
+}
+
+// abort releases the compressor if it was not closed.
+func (e *encoder) abort() {
+	if e.zw != nil {
+		_ = e.zw.Close()
+		e.zw = nil
+	}
+}
+
+func (c Codec) newWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	switch c {
+	case CodecGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CodecZstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevel(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownCodec, c)
+	}
+}
+
+// newReader returns a reader that decompresses `r`. Closing the reader also
+// closes `r`.
+func (c Codec) newReader(r io.ReadCloser) (io.ReadCloser, error) {
+	switch c {
+	case CodecGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decoder{Reader: gr, close: func() { _ = gr.Close() }, r: r}, nil
+	case CodecZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &decoder{Reader: zr, close: zr.Close, r: r}, nil
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownCodec, c)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type decoder struct {
+	io.Reader
+	close func()
+	r     io.ReadCloser
+}
+
+func (d *decoder) Close() error {
+	d.close()
+	return d.r.Close()
+}
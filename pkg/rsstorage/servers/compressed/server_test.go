@@ -0,0 +1,346 @@
+package compressed
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type CompressedStorageServerSuite struct {
+	tempdirhelper servertest.TempDirHelper
+}
+
+var _ = check.Suite(&CompressedStorageServerSuite{})
+
+func (s *CompressedStorageServerSuite) SetUpTest(c *check.C) {
+	c.Assert(s.tempdirhelper.SetUp(), check.IsNil)
+}
+
+func (s *CompressedStorageServerSuite) TearDownTest(c *check.C) {
+	c.Assert(s.tempdirhelper.TearDown(), check.IsNil)
+}
+
+func (s *CompressedStorageServerSuite) newServer(underlying rsstorage.StorageServer, codec Codec) *StorageServer {
+	return NewStorageServer(StorageServerArgs{
+		Server:  underlying,
+		Codec:   codec,
+		TempDir: s.tempdirhelper.Dir(),
+	})
+}
+
+// storedCodec returns the codec recorded in the header of a stored item.
+func storedCodec(c *check.C, server rsstorage.StorageServer, dir, address string) Codec {
+	stored := servertest.ReadItem(c, server, dir, address)
+	c.Assert(strings.HasPrefix(stored, marker), check.Equals, true)
+	return Codec(stored[len(marker)])
+}
+
+func (s *CompressedStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("compressed", 352)
+	server := s.newServer(underlying, CodecZstd)
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://compressed/dir/address")
+	c.Check(CodecGzip.String(), check.Equals, "gzip")
+	c.Check(Codec(9).String(), check.Equals, "unknown(9)")
+}
+
+func (s *CompressedStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	data := strings.Repeat(servertest.TestDESC, 10)
+
+	for _, codec := range []Codec{CodecGzip, CodecZstd, CodecNone} {
+		underlying := memtest.NewServer("compressed", 352)
+		server := s.newServer(underlying, codec)
+
+		_, _, err := server.Put(ctx, servertest.StringResolver(data), "dir", "address")
+		c.Assert(err, check.IsNil)
+		c.Check(storedCodec(c, underlying, "dir", "address"), check.Equals, codec)
+
+		ok, chunked, sz, mod, err := server.Check(ctx, "dir", "address")
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(chunked, check.IsNil)
+		c.Check(sz, check.Equals, int64(len(data)))
+		c.Check(mod.IsZero(), check.Equals, false)
+		c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, data)
+
+		_, _, physical, _, err := underlying.Check(ctx, "dir", "address")
+		c.Assert(err, check.IsNil)
+		if codec == CodecNone {
+			c.Check(physical, check.Equals, int64(len(data)+headerLen+trailerLen))
+		} else {
+			c.Check(physical < int64(len(data)/2), check.Equals, true)
+		}
+
+		r, _, sz, _, ok, err := server.GetRange(ctx, "dir", "address", 1000, 12)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(sz, check.Equals, int64(len(data)))
+		b, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Check(string(b), check.Equals, data[1000:1012])
+		c.Assert(r.Close(), check.IsNil)
+
+		_, _, _, _, _, err = server.GetRange(ctx, "dir", "address", int64(len(data)+1), -1)
+		c.Check(err, check.Equals, rsstorage.ErrInvalidRange)
+	}
+}
+
+func (s *CompressedStorageServerSuite) TestPutEmpty(c *check.C) {
+	ctx := context.Background()
+	server := s.newServer(memtest.NewServer("compressed", 352), CodecZstd)
+
+	_, _, err := server.Put(ctx, servertest.StringResolver(""), "dir", "empty")
+	c.Assert(err, check.IsNil)
+	ok, _, sz, _, err := server.Check(ctx, "dir", "empty")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(0))
+	c.Check(servertest.ReadItem(c, server, "dir", "empty"), check.Equals, "")
+}
+
+// countingServer counts the requests made to a storage server.
+type countingServer struct {
+	rsstorage.StorageServer
+	requests int
+}
+
+func (s *countingServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	s.requests++
+	return s.StorageServer.Check(ctx, dir, address)
+}
+
+func (s *countingServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	s.requests++
+	return s.StorageServer.Get(ctx, dir, address)
+}
+
+func (s *countingServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	s.requests++
+	return rsstorage.GetRange(ctx, s.StorageServer, dir, address, offset, length)
+}
+
+func (s *CompressedStorageServerSuite) TestGetRequests(c *check.C) {
+	ctx := context.Background()
+	underlying := &countingServer{StorageServer: memtest.NewServer("compressed", 352)}
+	server := s.newServer(underlying, CodecZstd)
+	data := strings.Repeat(servertest.TestDESC, 10)
+
+	// The size of small items is known when they are written
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "small")
+	c.Assert(err, check.IsNil)
+	underlying.requests = 0
+	c.Check(servertest.ReadItem(c, server, "dir", "small"), check.Equals, "some data")
+	c.Check(underlying.requests, check.Equals, 1)
+
+	// Otherwise, the size is read from the trailer
+	_, _, err = server.Put(ctx, servertest.StringResolver(data), "dir", "large")
+	c.Assert(err, check.IsNil)
+	underlying.requests = 0
+	c.Check(servertest.ReadItem(c, server, "dir", "large"), check.Equals, data)
+	c.Check(underlying.requests, check.Equals, 2)
+}
+
+func (s *CompressedStorageServerSuite) TestPutDeferred(c *check.C) {
+	ctx := context.Background()
+	server := s.newServer(memtest.NewServer("compressed", 352), CodecGzip)
+
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, d, a), check.Equals, "deferred")
+
+	failing := func(w io.Writer) (string, string, error) {
+		return "", "", errors.New("resolve error")
+	}
+	_, _, err = server.Put(ctx, failing, "dir", "failed")
+	c.Check(err, check.ErrorMatches, "resolve error")
+	ok, _, _, _, err := server.Check(ctx, "dir", "failed")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *CompressedStorageServerSuite) TestIncompressible(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("compressed", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Server:           underlying,
+		Codec:            CodecZstd,
+		SkipContentTypes: []string{"text/html"},
+	})
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	_, err := gw.Write([]byte(servertest.TestDESC))
+	c.Assert(err, check.IsNil)
+	c.Assert(gw.Close(), check.IsNil)
+	gzipped := buf.String()
+
+	_, _, err = server.Put(ctx, servertest.StringResolver(gzipped), "dir", "gzipped")
+	c.Assert(err, check.IsNil)
+	c.Check(storedCodec(c, underlying, "dir", "gzipped"), check.Equals, CodecNone)
+	c.Check(servertest.ReadItem(c, server, "dir", "gzipped"), check.Equals, gzipped)
+
+	// Configured content types
+	_, _, err = server.Put(ctx, servertest.StringResolver("<html><body>page</body></html>"), "dir", "html")
+	c.Assert(err, check.IsNil)
+	c.Check(storedCodec(c, underlying, "dir", "html"), check.Equals, CodecNone)
+
+	_, _, err = server.Put(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "text")
+	c.Assert(err, check.IsNil)
+	c.Check(storedCodec(c, underlying, "dir", "text"), check.Equals, CodecZstd)
+}
+
+func (s *CompressedStorageServerSuite) TestMixed(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("compressed", 352)
+
+	// Written before compression was enabled
+	_, _, err := underlying.Put(ctx, servertest.StringResolver("legacy data that is longer than a header"), "dir", "legacy")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("tiny"), "dir", "tiny")
+	c.Assert(err, check.IsNil)
+
+	_, _, err = s.newServer(underlying, CodecGzip).Put(ctx, servertest.StringResolver("gzip data"), "dir", "gzip")
+	c.Assert(err, check.IsNil)
+
+	server := s.newServer(underlying, CodecZstd)
+	_, _, err = server.Put(ctx, servertest.StringResolver("zstd data"), "dir", "zstd")
+	c.Assert(err, check.IsNil)
+
+	for address, data := range map[string]string{
+		"legacy": "legacy data that is longer than a header",
+		"tiny":   "tiny",
+		"gzip":   "gzip data",
+		"zstd":   "zstd data",
+	} {
+		_, _, sz, _, err := server.Check(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(sz, check.Equals, int64(len(data)))
+		c.Check(servertest.ReadItem(c, server, "dir", address), check.Equals, data)
+	}
+
+	r, _, _, _, _, err := server.GetRange(ctx, "dir", "legacy", 7, 4)
+	c.Assert(err, check.IsNil)
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "data")
+
+	// Unknown codecs are reported
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(marker+"\x09\xff\xff\xff\xff\xff\xff\xff\xff"+"00000000"+marker), "dir", "unknown")
+	c.Assert(err, check.IsNil)
+	_, _, _, _, err = server.Check(ctx, "dir", "unknown")
+	c.Check(errors.Is(err, ErrUnknownCodec), check.Equals, true)
+}
+
+func (s *CompressedStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("compressed", 352)
+	server := s.newServer(underlying, CodecZstd)
+	data := strings.Repeat(servertest.TestDESC, 10)
+	sz := uint64(len(data))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(data), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", 0)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-sized chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", sz+1)
+	c.Check(err, check.ErrorMatches, "expected 19531 bytes, but 19530 were written")
+
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", sz)
+	c.Assert(err, check.IsNil)
+
+	ok, chunked, physical, _, err := underlying.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(physical < int64(sz), check.Equals, true)
+
+	ok, chunked, size, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+	c.Check(size, check.Equals, int64(sz))
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, data)
+}
+
+func (s *CompressedStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	server := s.newServer(memtest.NewServer("compressed", 352), CodecGzip)
+	dest := memtest.NewServer("dest", 352)
+
+	servertest.CheckCopy(c, ctx, server, dest, "the compressed object with dir=dir and address=missing to copy does not exist")
+
+	// Move into another compressed server
+	other := s.newServer(memtest.NewServer("other", 352), CodecZstd)
+	c.Assert(server.Move(ctx, "dir", "a", other), check.IsNil)
+	c.Check(servertest.ReadItem(c, other, "dir", "a"), check.Equals, "some data")
+	ok, _, _, _, err := server.Check(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *CompressedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	server := s.newServer(memtest.NewServer("compressed", 352), CodecGzip)
+	meta := types.Metadata{"content-type": "text/plain"}
+	data := servertest.TestDESC
+
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver(data), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	for _, address := range []string{"a", "chunked"} {
+		ok, _, sz, _, got, err := server.CheckWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(sz, check.Equals, int64(len(data)))
+		c.Check(got, check.DeepEquals, meta)
+		r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+		c.Check(servertest.ReadAll(c, r) == data, check.Equals, true)
+	}
+
+	// Copies keep the metadata
+	other := s.newServer(memtest.NewServer("other", 352), CodecZstd)
+	c.Assert(server.Copy(ctx, "dir", "chunked", other), check.IsNil)
+	_, _, _, _, got, err := other.CheckWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// Without metadata support in the underlying server, writes with
+	// metadata fail, and copies drop the metadata
+	plain := s.newServer(&rsstorage.DummyStorageServer{}, CodecGzip)
+	_, _, err = plain.PutWithMetadata(ctx, servertest.StringResolver(data), "dir", "a", meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	_, _, err = plain.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	c.Assert(server.Copy(ctx, "dir", "a", plain), check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "chunked", plain), check.IsNil)
+	c.Check(plain.server.(*rsstorage.DummyStorageServer).Placed, check.HasLen, 2)
+}
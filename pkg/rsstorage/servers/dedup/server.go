@@ -0,0 +1,495 @@
+package dedup
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const (
+	// BlobDir is the directory in the underlying storage server that holds
+	// the deduplicated blobs.
+	BlobDir = ".blobs"
+
+	refsSuffix = ".refs"
+)
+
+var ErrNotReference = errors.New("item is not a deduplicated reference")
+
+// StorageServer is a content-addressable storage server that stores each
+// distinct item only once. Items are hashed while they are written, and
+// stored in the underlying storage server as blobs named by their SHA-256
+// digest. A small reference record is stored at each `dir` and `address`,
+// and each blob keeps a count of its references. A blob is removed when its
+// last reference is removed.
+//
+// Blobs are uploaded under a name of their own, and reference counts are
+// updated under a lock that is local to this process. The lock is not held
+// while blobs upload. When several processes share the underlying storage,
+// writes to the same content should be coordinated by the caller.
+type StorageServer struct {
+	server  rsstorage.StorageServer
+	tempDir string
+
+	// Guards reference counts
+	mutex sync.Mutex
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	// TempDir is the directory used to spool items while they are hashed.
+	// Defaults to the system temporary directory.
+	TempDir string
+}
+
+// reference is the record stored at each `dir` and `address`. Object
+// metadata is kept with the reference, since items with different metadata
+// can share a blob.
+type reference struct {
+	Digest   string         `json:"digest"`
+	Blob     string         `json:"blob,omitempty"`
+	Size     int64          `json:"size"`
+	Chunked  bool           `json:"chunked"`
+	ModTime  time.Time      `json:"mod_time"`
+	Metadata types.Metadata `json:"metadata,omitempty"`
+}
+
+// refCount is the reference count stored next to each blob.
+type refCount struct {
+	Count int64  `json:"count"`
+	Size  int64  `json:"size"`
+	Blob  string `json:"blob,omitempty"`
+}
+
+// blob returns the address of the blob in `blobDir(digest)`. Blobs that were
+// stored without a name of their own are named by their digest.
+func (c refCount) blob(digest string) string {
+	if c.Blob == "" {
+		return digest
+	}
+	return c.Blob
+}
+
+func (r reference) blob() string {
+	return refCount{Blob: r.Blob}.blob(r.Digest)
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server:  args.Server,
+		tempDir: args.TempDir,
+	}
+}
+
+func blobDir(digest string) string {
+	return path.Join(BlobDir, digest[:2])
+}
+
+func isBlobDir(dir string) bool {
+	return dir == BlobDir || strings.HasPrefix(dir, BlobDir+"/")
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, ts, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, ts, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	ref, ok, err := s.reference(ctx, dir, address)
+	if err != nil || !ok {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	ok, chunked, _, _, err := s.server.Check(ctx, blobDir(ref.Digest), ref.blob())
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	} else if !ok {
+		return false, nil, 0, time.Time{}, nil, fmt.Errorf("blob %s for dir=%s and address=%s is missing", ref.Digest, dir, address)
+	}
+	return true, chunked, ref.Size, ref.ModTime, ref.Metadata, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+// CalculateUsage reports the bytes used by the underlying storage server as
+// `UsedBytes`, and the size of all referenced items as `LogicalBytes`.
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	start := time.Now()
+	usage, err := s.server.CalculateUsage()
+	if err != nil {
+		return types.Usage{}, err
+	}
+
+	ctx := context.Background()
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return types.Usage{}, err
+	}
+	var logical int64
+	for _, item := range items {
+		if !isBlobDir(item.Dir) || !strings.HasSuffix(item.Address, refsSuffix) {
+			continue
+		}
+		count := refCount{}
+		ok, err := s.readJSON(ctx, item.Dir, item.Address, &count)
+		if err != nil {
+			return types.Usage{}, err
+		} else if ok {
+			logical += count.Count * count.Size
+		}
+	}
+
+	usage.LogicalBytes = datasize.ByteSize(logical)
+	usage.CalculationTime = time.Since(start)
+	return usage, nil
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, ts, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, ts, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	ref, ok, err := s.reference(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	r, chunked, sz, _, ok, err := s.server.Get(ctx, blobDir(ref.Digest), ref.blob())
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	} else if !ok {
+		return nil, nil, 0, time.Time{}, nil, false, fmt.Errorf("blob %s for dir=%s and address=%s is missing", ref.Digest, dir, address)
+	}
+	return r, chunked, sz, ref.ModTime, ref.Metadata, true, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	ref, ok, err := s.reference(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	r, chunked, sz, _, ok, err := rsstorage.GetRange(ctx, s.server, blobDir(ref.Digest), ref.blob(), offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, err
+	} else if !ok {
+		return nil, nil, 0, time.Time{}, false, fmt.Errorf("blob %s for dir=%s and address=%s is missing", ref.Digest, dir, address)
+	}
+	return r, chunked, sz, ref.ModTime, true, nil
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata writes an item like `Put`, and stores the metadata with
+// its reference. The underlying storage server does not need to support
+// metadata.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	return s.put(ctx, resolve, dir, address, false, 0, meta)
+}
+
+// PutChunked stores the blob in chunks in the underlying storage server. The
+// item cannot be read until it is completely written, and the write fails if
+// the resolver does not write exactly `sz` bytes.
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+// PutChunkedWithMetadata writes an item like `PutChunked`, and stores the
+// metadata with its reference.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+	return s.put(ctx, resolve, dir, address, true, sz, meta)
+}
+
+func (s *StorageServer) put(ctx context.Context, resolve types.Resolver, dir, address string, chunked bool, declared uint64, meta types.Metadata) (string, string, error) {
+	// Spool the item to a temporary file while hashing it
+	f, err := os.CreateTemp(s.tempDir, "dedup-")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	hash := sha256.New()
+	wdir, waddress, err := resolve(io.MultiWriter(f, hash))
+	if err != nil {
+		return "", "", err
+	}
+	sz, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", "", err
+	}
+	if chunked && uint64(sz) != declared {
+		return "", "", fmt.Errorf("expected to write '%d' bytes but wrote '%d' bytes", declared, sz)
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+	}
+
+	ref := reference{
+		Digest:   hex.EncodeToString(hash.Sum(nil)),
+		Size:     sz,
+		Chunked:  chunked,
+		ModTime:  time.Now(),
+		Metadata: meta,
+	}
+
+	// Reference the blob if it is already stored
+	linked, err := s.link(ctx, dir, address, &ref, "")
+	if err != nil || linked {
+		return dir, address, err
+	}
+
+	// Otherwise, upload the blob under a name of its own so that the lock is
+	// not held while it uploads
+	blob := ref.Digest + "-" + internal.RandomString(12)
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", "", err
+	}
+	install := func(writer io.Writer) (string, string, error) {
+		_, err := io.Copy(writer, f)
+		return "", "", err
+	}
+	bdir := blobDir(ref.Digest)
+	if chunked {
+		_, _, err = s.server.PutChunked(ctx, install, bdir, blob, uint64(sz))
+	} else {
+		_, _, err = s.server.Put(ctx, install, bdir, blob)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// Remove the upload if it fails to link, or if the same content was
+	// stored while it uploaded
+	_, err = s.link(ctx, dir, address, &ref, blob)
+	if err != nil || ref.Blob != blob {
+		err = errors.Join(err, s.server.Remove(ctx, bdir, blob))
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return dir, address, nil
+}
+
+// link adds a reference at `dir` and `address` to the stored blob with the
+// digest of `ref`, and releases the blob that was referenced there before.
+// When no blob with that digest is stored, the `uploaded` blob is used if
+// given, and otherwise nothing is linked. `ref.Blob` is set to the linked
+// blob.
+func (s *StorageServer) link(ctx context.Context, dir, address string, ref *reference, uploaded string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bdir := blobDir(ref.Digest)
+	count := refCount{}
+	_, err := s.readJSON(ctx, bdir, ref.Digest+refsSuffix, &count)
+	if err != nil {
+		return false, err
+	}
+	if count.Count > 0 {
+		ref.Blob = count.blob(ref.Digest)
+	} else if uploaded != "" {
+		ref.Blob = uploaded
+		count.Blob = uploaded
+	} else {
+		return false, nil
+	}
+
+	// Replace any existing reference
+	old, hadOld, err := s.reference(ctx, dir, address)
+	if errors.Is(err, ErrNotReference) {
+		hadOld = false
+	} else if err != nil {
+		return false, err
+	}
+
+	count.Count++
+	count.Size = ref.Size
+	err = s.writeJSON(ctx, bdir, ref.Digest+refsSuffix, &count)
+	if err != nil {
+		return false, err
+	}
+	err = s.writeJSON(ctx, dir, address, ref)
+	if err != nil {
+		return false, errors.Join(err, s.release(ctx, ref.Digest))
+	}
+	if hadOld {
+		err = s.release(ctx, old.Digest)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ref, ok, err := s.reference(ctx, dir, address)
+	if errors.Is(err, ErrNotReference) {
+		return s.server.Remove(ctx, dir, address)
+	} else if err != nil || !ok {
+		return err
+	}
+
+	err = s.server.Remove(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	return s.release(ctx, ref.Digest)
+}
+
+// release removes a reference to a blob, and removes the blob when it has
+// no more references.
+func (s *StorageServer) release(ctx context.Context, digest string) error {
+	bdir := blobDir(digest)
+	count := refCount{}
+	_, err := s.readJSON(ctx, bdir, digest+refsSuffix, &count)
+	if err != nil {
+		return err
+	}
+	if count.Count > 1 {
+		count.Count--
+		return s.writeJSON(ctx, bdir, digest+refsSuffix, &count)
+	}
+
+	err = s.server.Remove(ctx, bdir, count.blob(digest))
+	if err != nil {
+		return err
+	}
+	return s.server.Remove(ctx, bdir, digest+refsSuffix)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+// Enumerate lists the references. Blobs are not included.
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]types.StoredItem, 0, len(items))
+	for _, item := range items {
+		if !isBlobDir(item.Dir) {
+			refs = append(refs, item)
+		}
+	}
+	return refs, nil
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the deduplicated object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, since items in the underlying storage server
+// cannot be used without it.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// reference reads the reference record at `dir` and `address`.
+func (s *StorageServer) reference(ctx context.Context, dir, address string) (reference, bool, error) {
+	r, _, _, _, ok, err := s.server.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return reference{}, false, err
+	}
+	defer r.Close()
+
+	ref := reference{}
+	err = json.NewDecoder(r).Decode(&ref)
+	if err != nil || len(ref.Digest) != sha256.Size*2 {
+		return reference{}, false, fmt.Errorf("%w at dir=%s and address=%s", ErrNotReference, dir, address)
+	}
+	return ref, true, nil
+}
+
+func (s *StorageServer) readJSON(ctx context.Context, dir, address string, v any) (bool, error) {
+	r, _, _, _, ok, err := s.server.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer r.Close()
+	return true, json.NewDecoder(r).Decode(v)
+}
+
+func (s *StorageServer) writeJSON(ctx context.Context, dir, address string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewReader(b))
+		return "", "", err
+	}, dir, address)
+	return err
+}
@@ -0,0 +1,331 @@
+package dedup
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/servers/memory"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type DedupStorageServerSuite struct {
+	tempdirhelper servertest.TempDirHelper
+}
+
+var _ = check.Suite(&DedupStorageServerSuite{})
+
+func (s *DedupStorageServerSuite) SetUpTest(c *check.C) {
+	c.Assert(s.tempdirhelper.SetUp(), check.IsNil)
+}
+
+func (s *DedupStorageServerSuite) TearDownTest(c *check.C) {
+	c.Assert(s.tempdirhelper.TearDown(), check.IsNil)
+}
+
+func newMemoryServer(class string) rsstorage.StorageServer {
+	return memtest.New(memory.StorageServerArgs{
+		ChunkSize: 352,
+		Class:     class,
+		MaxBytes:  datasize.MB,
+	})
+}
+
+func (s *DedupStorageServerSuite) newServer() (*StorageServer, rsstorage.StorageServer) {
+	underlying := newMemoryServer("dedup")
+	return NewStorageServer(StorageServerArgs{
+		Server:  underlying,
+		TempDir: s.tempdirhelper.Dir(),
+	}), underlying
+}
+
+func digest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// storedBlob returns the address of the blob stored for `data`.
+func storedBlob(c *check.C, underlying rsstorage.StorageServer, data string) (string, string) {
+	blob := digest(data)
+	dir := BlobDir + "/" + blob[:2]
+	count := refCount{}
+	err := json.Unmarshal([]byte(servertest.ReadItem(c, underlying, dir, blob+refsSuffix)), &count)
+	c.Assert(err, check.IsNil)
+	return dir, count.blob(blob)
+}
+
+func (s *DedupStorageServerSuite) TestNew(c *check.C) {
+	server, underlying := s.newServer()
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://dedup/dir/address")
+}
+
+func (s *DedupStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+
+	ok, _, _, _, err := server.Check(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	// Two addresses with the same content share a blob
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "other", "b")
+	c.Assert(err, check.IsNil)
+	bdir, blob := storedBlob(c, underlying, "some data")
+	c.Check(servertest.Exists(c, underlying, bdir, blob), check.Equals, true)
+
+	ok, chunked, sz, mod, err := server.Check(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.IsNil)
+	c.Check(sz, check.Equals, int64(9))
+	c.Check(mod.IsZero(), check.Equals, false)
+	c.Check(servertest.ReadItem(c, server, "dir", "a"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, server, "other", "b"), check.Equals, "some data")
+
+	r, _, sz, _, ok, err := server.GetRange(ctx, "other", "b", 5, -1)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(9))
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "data")
+
+	// Only references are enumerated
+	items, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Dir: "dir", Address: "a"},
+		{Dir: "other", Address: "b"},
+	})
+
+	// Deferred addresses
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, d, a), check.Equals, "deferred")
+
+	// Resolver errors
+	failing := func(w io.Writer) (string, string, error) {
+		return "", "", errors.New("resolve error")
+	}
+	_, _, err = server.Put(ctx, failing, "dir", "failed")
+	c.Check(err, check.ErrorMatches, "resolve error")
+	c.Check(servertest.Exists(c, server, "dir", "failed"), check.Equals, false)
+}
+
+func (s *DedupStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "a", sz)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "b")
+	c.Assert(err, check.IsNil)
+
+	// The declared size must match
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "short", sz+1)
+	c.Check(err, check.ErrorMatches, "expected to write '.*' bytes but wrote '.*' bytes")
+	c.Check(servertest.Exists(c, server, "dir", "short"), check.Equals, false)
+
+	bdir, blob := storedBlob(c, underlying, servertest.TestDESC)
+	ok, chunked, _, _, err := underlying.Check(ctx, bdir, blob)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+
+	ok, chunked, size, _, err := server.Check(ctx, "dir", "b")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(size, check.Equals, int64(sz))
+	c.Check(servertest.ReadItem(c, server, "dir", "b"), check.Equals, servertest.TestDESC)
+}
+
+func (s *DedupStorageServerSuite) TestRemove(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+
+	c.Assert(server.Remove(ctx, "dir", "missing"), check.IsNil)
+
+	for _, address := range []string{"a", "b", "c"} {
+		_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", address)
+		c.Assert(err, check.IsNil)
+	}
+	blobDir, blob := storedBlob(c, underlying, "some data")
+
+	// Overwriting with different content releases the blob
+	_, _, err := server.Put(ctx, servertest.StringResolver("other data"), "dir", "c")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "c"), check.Equals, "other data")
+
+	// Overwriting with the same content keeps a single reference
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "b")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(server.Remove(ctx, "dir", "a"), check.IsNil)
+	c.Check(servertest.Exists(c, server, "dir", "a"), check.Equals, false)
+	c.Check(servertest.Exists(c, underlying, blobDir, blob), check.Equals, true)
+	c.Check(servertest.ReadItem(c, server, "dir", "b"), check.Equals, "some data")
+
+	// Removing the last reference removes the blob
+	c.Assert(server.Remove(ctx, "dir", "b"), check.IsNil)
+	c.Check(servertest.Exists(c, underlying, blobDir, blob), check.Equals, false)
+	c.Check(servertest.Exists(c, underlying, blobDir, digest("some data")+refsSuffix), check.Equals, false)
+
+	c.Assert(server.Remove(ctx, "dir", "c"), check.IsNil)
+	items, err := underlying.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.HasLen, 0)
+
+	// Items that are not references are removed normally
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("raw"), "dir", "raw")
+	c.Assert(err, check.IsNil)
+	_, _, _, _, err = server.Check(ctx, "dir", "raw")
+	c.Check(errors.Is(err, ErrNotReference), check.Equals, true)
+	c.Assert(server.Remove(ctx, "dir", "raw"), check.IsNil)
+	c.Check(servertest.Exists(c, underlying, "dir", "raw"), check.Equals, false)
+}
+
+func (s *DedupStorageServerSuite) TestPutConcurrent(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+
+	// Concurrent writes of the same content share a single blob
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", fmt.Sprintf("%d", i))
+			c.Check(err, check.IsNil)
+		}()
+	}
+	wg.Wait()
+
+	items, err := underlying.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	blobs := 0
+	for _, item := range items {
+		if isBlobDir(item.Dir) && !strings.HasSuffix(item.Address, refsSuffix) {
+			blobs++
+		}
+	}
+	c.Check(blobs, check.Equals, 1)
+	for i := range 8 {
+		c.Check(servertest.ReadItem(c, server, "dir", fmt.Sprintf("%d", i)), check.Equals, "some data")
+	}
+}
+
+func (s *DedupStorageServerSuite) TestUsage(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+
+	for _, address := range []string{"a", "b", "c"} {
+		_, _, err := server.Put(ctx, servertest.StringResolver("0123456789"), "dir", address)
+		c.Assert(err, check.IsNil)
+	}
+	_, _, err := server.Put(ctx, servertest.StringResolver("01234"), "dir", "d")
+	c.Assert(err, check.IsNil)
+
+	physical, err := underlying.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	usage, err := server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.LogicalBytes, check.Equals, datasize.ByteSize(35))
+	c.Check(usage.UsedBytes, check.Equals, physical.UsedBytes)
+	c.Check(usage.SizeBytes, check.Equals, datasize.MB)
+}
+
+func (s *DedupStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	server, underlying := s.newServer()
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	// Items that share a blob keep their own metadata
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	bdir, blob := storedBlob(c, underlying, "some data")
+	ok, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, underlying, bdir, blob)
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.IsNil)
+
+	ok, _, sz, _, got, err := server.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(9))
+	c.Check(got, check.DeepEquals, meta)
+	r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", "b")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	r.Close()
+	c.Check(got, check.IsNil)
+
+	data := servertest.TestData(1000)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	ok, chunked, _, _, got, err := server.CheckWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// Metadata is copied when the destination can store it, and dropped
+	// otherwise
+	other, _ := s.newServer()
+	c.Assert(server.Copy(ctx, "dir", "chunked", other), check.IsNil)
+	_, _, _, _, got, err = other.CheckWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+	dummy := &rsstorage.DummyStorageServer{}
+	c.Assert(server.Move(ctx, "dir", "a", dummy), check.IsNil)
+	c.Check(dummy.Placed, check.DeepEquals, []string{"dir-some data"})
+	c.Check(servertest.Exists(c, server, "dir", "a"), check.Equals, false)
+}
+
+func (s *DedupStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	server, _ := s.newServer()
+	dest := newMemoryServer("dest")
+
+	servertest.CheckCopy(c, ctx, server, dest, "the deduplicated object with dir=dir and address=missing to copy does not exist")
+
+	// Copy into another deduplicated server
+	other, _ := s.newServer()
+	c.Assert(server.Move(ctx, "dir", "a", other), check.IsNil)
+	c.Check(servertest.ReadItem(c, other, "dir", "a"), check.Equals, "some data")
+	c.Check(servertest.Exists(c, server, "dir", "a"), check.Equals, false)
+}
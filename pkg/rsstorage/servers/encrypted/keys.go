@@ -0,0 +1,214 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownKey = errors.New("unknown key-encryption key")
+
+// KeyProvider wraps and unwraps the per-object data keys with a
+// key-encryption key. Implementations may keep keys locally, or delegate to
+// an external key management service.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key used to wrap new data keys.
+	CurrentKeyID(ctx context.Context) (string, error)
+
+	// Wrap encrypts a data key with the key `keyID`.
+	Wrap(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key that was wrapped with the key `keyID`.
+	// Returns an error wrapping `ErrUnknownKey` if the key does not exist.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyFileProvider is a `KeyProvider` that keeps its keys in a local JSON file.
+// Old keys are kept in the file after rotation so that existing items can
+// still be read until they are re-keyed.
+type KeyFileProvider struct {
+	path string
+
+	mutex sync.RWMutex
+	file  keyFile
+}
+
+type keyFile struct {
+	Current string    `json:"current"`
+	Keys    []fileKey `json:"keys"`
+}
+
+type fileKey struct {
+	ID      string    `json:"id"`
+	Key     []byte    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+// NewKeyFileProvider loads the keys from the file at `path`. If the file
+// does not exist, it is created with a new key.
+func NewKeyFileProvider(path string) (*KeyFileProvider, error) {
+	p := &KeyFileProvider{
+		path: path,
+	}
+	err := p.Reload()
+	if errors.Is(err, os.ErrNotExist) {
+		_, err = p.Rotate()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the keys from the file again, e.g., after the keys were
+// rotated by another process.
+func (p *KeyFileProvider) Reload() error {
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	file := keyFile{}
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		return fmt.Errorf("error reading key file %s: %w", p.path, err)
+	}
+	if _, ok := file.key(file.Current); !ok {
+		return fmt.Errorf("key file %s has no current key", p.path)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.file = file
+	return nil
+}
+
+// Rotate adds a new key to the file and makes it the current key. Returns
+// the ID of the new key.
+func (p *KeyFileProvider) Rotate() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := make([]byte, dataKeyLen)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	file := keyFile{
+		Current: uuid.New().String(),
+		Keys: append(append([]fileKey{}, p.file.Keys...), fileKey{
+			Key:     key,
+			Created: time.Now(),
+		}),
+	}
+	file.Keys[len(file.Keys)-1].ID = file.Current
+
+	err = p.write(file)
+	if err != nil {
+		return "", err
+	}
+	p.file = file
+	return file.Current, nil
+}
+
+// write replaces the key file.
+func (p *KeyFileProvider) write(file keyFile) error {
+	b, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p.path)
+}
+
+func (p *KeyFileProvider) CurrentKeyID(ctx context.Context) (string, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.file.Current, nil
+}
+
+func (p *KeyFileProvider) Wrap(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, err := p.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (p *KeyFileProvider) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, err := p.aead(keyID)
+	if errors.Is(err, ErrUnknownKey) {
+		// The key may have been added by another process
+		if p.Reload() == nil {
+			aead, err = p.aead(keyID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dataKey, nil
+}
+
+func (p *KeyFileProvider) aead(keyID string) (cipher.AEAD, error) {
+	p.mutex.RLock()
+	key, ok := p.file.key(keyID)
+	p.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s in key file %s", ErrUnknownKey, keyID, p.path)
+	}
+	return newAEAD(key)
+}
+
+func (f keyFile) key(id string) ([]byte, bool) {
+	for _, k := range f.Keys {
+		if k.ID == id {
+			return k.Key, true
+		}
+	}
+	return nil, false
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
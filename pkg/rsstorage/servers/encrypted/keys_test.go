@@ -0,0 +1,102 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type KeyFileProviderSuite struct {
+	tempdirhelper servertest.TempDirHelper
+}
+
+var _ = check.Suite(&KeyFileProviderSuite{})
+
+func (s *KeyFileProviderSuite) SetUpTest(c *check.C) {
+	c.Assert(s.tempdirhelper.SetUp(), check.IsNil)
+}
+
+func (s *KeyFileProviderSuite) TearDownTest(c *check.C) {
+	c.Assert(s.tempdirhelper.TearDown(), check.IsNil)
+}
+
+func (s *KeyFileProviderSuite) TestNew(c *check.C) {
+	ctx := context.Background()
+	path := filepath.Join(s.tempdirhelper.Dir(), "keys.json")
+
+	// Created with a new key
+	keys, err := NewKeyFileProvider(path)
+	c.Assert(err, check.IsNil)
+	current, err := keys.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(current, check.Not(check.Equals), "")
+	info, err := os.Stat(path)
+	c.Assert(err, check.IsNil)
+	c.Check(info.Mode().Perm(), check.Equals, os.FileMode(0600))
+
+	// Loaded
+	loaded, err := NewKeyFileProvider(path)
+	c.Assert(err, check.IsNil)
+	id, err := loaded.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, current)
+
+	// Invalid
+	c.Assert(os.WriteFile(path, []byte("{}"), 0600), check.IsNil)
+	_, err = NewKeyFileProvider(path)
+	c.Check(err, check.ErrorMatches, "key file .* has no current key")
+	c.Assert(os.WriteFile(path, []byte("bad"), 0600), check.IsNil)
+	_, err = NewKeyFileProvider(path)
+	c.Check(err, check.ErrorMatches, "error reading key file .*")
+}
+
+func (s *KeyFileProviderSuite) TestWrapRotate(c *check.C) {
+	ctx := context.Background()
+	path := filepath.Join(s.tempdirhelper.Dir(), "keys.json")
+	keys, err := NewKeyFileProvider(path)
+	c.Assert(err, check.IsNil)
+	old, err := keys.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := keys.Wrap(ctx, old, dataKey)
+	c.Assert(err, check.IsNil)
+	unwrapped, err := keys.Unwrap(ctx, old, wrapped)
+	c.Assert(err, check.IsNil)
+	c.Check(unwrapped, check.DeepEquals, dataKey)
+
+	// Another process rotates the keys
+	other, err := NewKeyFileProvider(path)
+	c.Assert(err, check.IsNil)
+	current, err := other.Rotate()
+	c.Assert(err, check.IsNil)
+	c.Check(current, check.Not(check.Equals), old)
+	rewrapped, err := other.Wrap(ctx, current, dataKey)
+	c.Assert(err, check.IsNil)
+
+	// Old keys can still be unwrapped, and new keys are found by reloading
+	unwrapped, err = other.Unwrap(ctx, old, wrapped)
+	c.Assert(err, check.IsNil)
+	c.Check(unwrapped, check.DeepEquals, dataKey)
+	unwrapped, err = keys.Unwrap(ctx, current, rewrapped)
+	c.Assert(err, check.IsNil)
+	c.Check(unwrapped, check.DeepEquals, dataKey)
+	id, err := keys.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, current)
+
+	// Errors
+	_, err = keys.Unwrap(ctx, "missing", wrapped)
+	c.Check(errors.Is(err, ErrUnknownKey), check.Equals, true)
+	_, err = keys.Wrap(ctx, "missing", dataKey)
+	c.Check(errors.Is(err, ErrUnknownKey), check.Equals, true)
+	_, err = keys.Unwrap(ctx, current, wrapped)
+	c.Check(err, check.Equals, ErrDecrypt)
+}
@@ -0,0 +1,265 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// RekeyResult summarizes a re-key.
+type RekeyResult struct {
+	// Checked is the number of items checked.
+	Checked int
+
+	// Rekeyed is the number of items whose data keys were wrapped again with
+	// the current key.
+	Rekeyed int
+
+	// Encrypted is the number of plaintext items that were encrypted.
+	Encrypted int
+
+	// Skipped is the number of items that were skipped because a chunked
+	// write is still in progress.
+	Skipped int
+}
+
+// Rekey walks all items and wraps their data keys with the current key of
+// the `KeyProvider`. The content of the items is not decrypted, since only
+// the header changes. When `AllowPlaintext` is set, plaintext items are
+// encrypted. Once all items are re-keyed, old keys can be retired.
+//
+// Items are rewritten in place, so they should not be written by others
+// while they are re-keyed. Since a chunked write removes the existing chunks
+// first, re-keyed chunked items are first written to a staging address with
+// the `.rekey` suffix. If replacing the item fails, the staging copy is kept
+// and the item is restored from it by the next re-key.
+func (s *StorageServer) Rekey(ctx context.Context) (RekeyResult, error) {
+	result := RekeyResult{}
+
+	current, err := s.keys.CurrentKeyID(ctx)
+	if err != nil {
+		return result, err
+	}
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var errs error
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return result, errors.Join(errs, err)
+		}
+		result.Checked++
+		err = s.rekeyItem(ctx, item.Dir, item.Address, current, &result)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error re-keying dir=%s and address=%s: %w", item.Dir, item.Address, err))
+		}
+	}
+	return result, errs
+}
+
+// rekeySuffix is appended to the address of a re-keyed chunked item while it
+// replaces the original.
+const rekeySuffix = ".rekey"
+
+func (s *StorageServer) rekeyItem(ctx context.Context, dir, address, current string, result *RekeyResult) error {
+	if original, ok := strings.CutSuffix(address, rekeySuffix); ok {
+		return s.restoreRekeyed(ctx, dir, original, address)
+	}
+
+	ok, chunked, _, _, err := s.server.Check(ctx, dir, address)
+	if err != nil {
+		return err
+	} else if !ok {
+		// Removed since it was enumerated
+		return nil
+	} else if chunked != nil && !chunked.Complete {
+		result.Skipped++
+		return nil
+	}
+
+	it, ok, err := s.item(ctx, dir, address)
+	if err != nil || !ok {
+		return err
+	} else if it.encrypted && it.header.keyID == current {
+		return nil
+	}
+
+	// Spool the item, since it is rewritten in place
+	f, err := os.CreateTemp(s.tempDir, "encrypted-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	r, _, _, _, ok, err := s.server.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return err
+	}
+	if it.encrypted {
+		err = s.rewrap(ctx, f, r, it.header, current)
+	} else {
+		err = s.encryptPlaintext(ctx, f, r)
+	}
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	sz, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	if it.chunked != nil {
+		err = s.replaceChunked(ctx, f, dir, address, uint64(sz), it.meta)
+	} else {
+		_, _, err = rsstorage.PutWithMetadata(ctx, s.server, copyResolver(f), dir, address, it.meta)
+	}
+	if err != nil {
+		return err
+	}
+
+	if it.encrypted {
+		slog.Debug("re-keyed item", "dir", dir, "address", address, "from", it.header.keyID, "to", current)
+		result.Rekeyed++
+	} else {
+		slog.Debug("encrypted plaintext item", "dir", dir, "address", address, "key", current)
+		result.Encrypted++
+	}
+	return nil
+}
+
+// replaceChunked replaces a chunked item with the spooled re-keyed item `f`
+// of `sz` bytes, keeping its metadata. The item is written to a staging
+// address first, so that it is not lost if the replacement fails.
+func (s *StorageServer) replaceChunked(ctx context.Context, f *os.File, dir, address string, sz uint64, meta types.Metadata) error {
+	staging := address + rekeySuffix
+	_, _, err := rsstorage.PutChunkedWithMetadata(ctx, s.server, copyResolver(f), dir, staging, sz, meta)
+	if err != nil {
+		return errors.Join(err, s.server.Remove(ctx, dir, staging))
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, s.server, copyResolver(f), dir, address, sz, meta)
+	if err != nil {
+		return fmt.Errorf("the re-keyed item is kept at address=%s until the next re-key: %w", staging, err)
+	}
+	return s.server.Remove(ctx, dir, staging)
+}
+
+// restoreRekeyed finishes replacing a chunked item with the re-keyed copy
+// at `staging`, which is left behind when a re-key fails.
+func (s *StorageServer) restoreRekeyed(ctx context.Context, dir, address, staging string) error {
+	ok, chunked, sz, _, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, staging)
+	if err != nil || !ok {
+		return err
+	} else if chunked == nil || !chunked.Complete {
+		// The copy is incomplete, so the original was not replaced
+		return s.server.Remove(ctx, dir, staging)
+	}
+
+	ok, chunked, _, _, err = s.server.Check(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	if !ok || (chunked != nil && !chunked.Complete) {
+		r, _, _, _, ok, err := s.server.Get(ctx, dir, staging)
+		if err != nil || !ok {
+			return err
+		}
+		_, _, err = rsstorage.PutChunkedWithMetadata(ctx, s.server, copyResolver(r), dir, address, uint64(sz), meta)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		slog.Debug("restored re-keyed item", "dir", dir, "address", address)
+	}
+	return s.server.Remove(ctx, dir, staging)
+}
+
+// copyResolver returns a resolver that copies `r`.
+func copyResolver(r io.Reader) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, r)
+		return "", "", err
+	}
+}
+
+// rewrap writes a new header with the data key wrapped by the key `keyID`,
+// followed by the unchanged encrypted content from `r`.
+func (s *StorageServer) rewrap(ctx context.Context, w io.Writer, r io.Reader, h header, keyID string) error {
+	dataKey, err := s.keys.Unwrap(ctx, h.keyID, h.wrapped)
+	if err != nil {
+		return err
+	}
+	wrapped, err := s.keys.Wrap(ctx, keyID, dataKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(io.Discard, r, h.len())
+	if err != nil {
+		return err
+	}
+	b, err := header{
+		segmentSize: h.segmentSize,
+		keyID:       keyID,
+		wrapped:     wrapped,
+	}.marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (s *StorageServer) encryptPlaintext(ctx context.Context, w io.Writer, r io.Reader) error {
+	h, aead, err := s.newHeader(ctx)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.encrypt(w, h, aead, func(writer io.Writer) (string, string, error) {
+		_, err := io.Copy(writer, r)
+		return "", "", err
+	})
+	return err
+}
+
+// NewRekeyTask returns a scheduled task that re-keys `server`.
+func NewRekeyTask(name string, server *StorageServer, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := server.Rekey(ctx)
+		slog.Debug("re-keyed items",
+			"task", name,
+			"checked", result.Checked,
+			"rekeyed", result.Rekeyed,
+			"encrypted", result.Encrypted,
+			"skipped", result.Skipped)
+		return err
+	})
+}
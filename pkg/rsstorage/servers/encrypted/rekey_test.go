@@ -0,0 +1,192 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+type RekeySuite struct {
+	tempdirhelper servertest.TempDirHelper
+}
+
+var _ = check.Suite(&RekeySuite{})
+
+func (s *RekeySuite) SetUpTest(c *check.C) {
+	c.Assert(s.tempdirhelper.SetUp(), check.IsNil)
+}
+
+func (s *RekeySuite) TearDownTest(c *check.C) {
+	c.Assert(s.tempdirhelper.TearDown(), check.IsNil)
+}
+
+func storedKeyID(c *check.C, server rsstorage.StorageServer, dir, address string) string {
+	h, err := parseHeader([]byte(servertest.ReadItem(c, server, dir, address)))
+	c.Assert(err, check.IsNil)
+	return h.keyID
+}
+
+func (s *RekeySuite) TestRekey(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, keys := newServer(c, s.tempdirhelper.Dir(), underlying)
+	server.allowPlaintext = true
+	old, err := keys.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+
+	data := servertest.TestData(segmentSize + 100)
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("plaintext"), "dir", "plaintext")
+	c.Assert(err, check.IsNil)
+
+	current, err := keys.Rotate()
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("new data"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	c.Check(storedKeyID(c, underlying, "dir", "a"), check.Equals, old)
+	c.Check(storedKeyID(c, underlying, "dir", "b"), check.Equals, current)
+
+	result, err := server.Rekey(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, RekeyResult{
+		Checked:   4,
+		Rekeyed:   2,
+		Encrypted: 1,
+	})
+	for _, address := range []string{"a", "b", "chunked", "plaintext"} {
+		c.Check(storedKeyID(c, underlying, "dir", address), check.Equals, current)
+	}
+	c.Check(servertest.ReadItem(c, server, "dir", "a"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, server, "dir", "b"), check.Equals, "new data")
+	c.Check(servertest.ReadItem(c, server, "dir", "chunked") == data, check.Equals, true)
+	c.Check(servertest.ReadItem(c, server, "dir", "plaintext"), check.Equals, "plaintext")
+	ok, chunked, _, _, err := server.Check(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+
+	// Nothing left to re-key
+	result, err = server.Rekey(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, RekeyResult{Checked: 4})
+
+	// Plaintext items are reported when not allowed
+	server.allowPlaintext = false
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("plaintext"), "dir", "plaintext")
+	c.Assert(err, check.IsNil)
+	_, err = server.Rekey(ctx)
+	c.Check(err, check.ErrorMatches, "error re-keying dir=dir and address=plaintext: error reading dir=dir and address=plaintext: item is not encrypted")
+}
+
+// failingServer fails chunked writes to `address` after removing the
+// existing chunks.
+type failingServer struct {
+	rsstorage.StorageServer
+	address string
+}
+
+func (f *failingServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	if address == f.address {
+		resolve = func(w io.Writer) (string, string, error) {
+			return "", "", errors.New("write error")
+		}
+	}
+	return f.StorageServer.PutChunked(ctx, resolve, dir, address, sz)
+}
+
+func (s *RekeySuite) TestRekeyFailure(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	failing := &failingServer{StorageServer: underlying}
+	server, keys := newServer(c, s.tempdirhelper.Dir(), failing)
+	old, err := keys.CurrentKeyID(ctx)
+	c.Assert(err, check.IsNil)
+
+	data := servertest.TestData(segmentSize + 100)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	current, err := keys.Rotate()
+	c.Assert(err, check.IsNil)
+
+	// Failing to write the staging copy leaves the item unchanged
+	failing.address = "chunked" + rekeySuffix
+	_, err = server.Rekey(ctx)
+	c.Check(err, check.ErrorMatches, "error re-keying dir=dir and address=chunked: write error")
+	c.Check(storedKeyID(c, underlying, "dir", "chunked"), check.Equals, old)
+	c.Check(servertest.ReadItem(c, server, "dir", "chunked") == data, check.Equals, true)
+	c.Check(servertest.Exists(c, underlying, "dir", "chunked"+rekeySuffix), check.Equals, false)
+
+	// Failing to replace the item keeps the staging copy
+	failing.address = "chunked"
+	_, err = server.Rekey(ctx)
+	c.Check(err, check.ErrorMatches, "error re-keying dir=dir and address=chunked: the re-keyed item is kept at address=chunked.rekey until the next re-key: write error")
+	c.Check(servertest.Exists(c, underlying, "dir", "chunked"), check.Equals, false)
+	c.Check(servertest.Exists(c, underlying, "dir", "chunked"+rekeySuffix), check.Equals, true)
+
+	// The next re-key restores the item
+	failing.address = ""
+	_, err = server.Rekey(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(storedKeyID(c, underlying, "dir", "chunked"), check.Equals, current)
+	c.Check(servertest.ReadItem(c, server, "dir", "chunked") == data, check.Equals, true)
+	c.Check(servertest.Exists(c, underlying, "dir", "chunked"+rekeySuffix), check.Equals, false)
+}
+
+func (s *RekeySuite) TestRekeyMetadata(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, keys := newServer(c, s.tempdirhelper.Dir(), underlying)
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	data := servertest.TestData(segmentSize + 100)
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	current, err := keys.Rotate()
+	c.Assert(err, check.IsNil)
+
+	// Re-keyed items keep their metadata
+	result, err := server.Rekey(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result.Rekeyed, check.Equals, 2)
+	for _, address := range []string{"a", "chunked"} {
+		c.Check(storedKeyID(c, underlying, "dir", address), check.Equals, current)
+		_, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(got, check.DeepEquals, meta)
+	}
+}
+
+func (s *RekeySuite) TestRekeyTask(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, keys := newServer(c, s.tempdirhelper.Dir(), underlying)
+
+	ticker := make(chan time.Time)
+	task := NewRekeyTask("rekey", server, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "rekey")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	current, err := keys.Rotate()
+	c.Assert(err, check.IsNil)
+	task.Run(ctx, nil)
+	c.Check(storedKeyID(c, underlying, "dir", "address"), check.Equals, current)
+}
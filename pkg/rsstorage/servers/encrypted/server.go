@@ -0,0 +1,373 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// StorageServer is a storage server that encrypts items at rest in another
+// storage server using envelope encryption. Each item is encrypted with
+// AES-GCM using its own random data key. The data key is wrapped by a
+// `KeyProvider` and stored in a header with the item, so key-encryption keys
+// can be rotated without re-encrypting the content of existing items. See
+// `Rekey`.
+//
+// Sizes reported by `Check`, `Get`, and `GetRange` are plaintext sizes.
+// Chunk information describes the stored layout. Object metadata is stored
+// by the underlying storage server, and is not encrypted.
+type StorageServer struct {
+	server         rsstorage.StorageServer
+	keys           KeyProvider
+	allowPlaintext bool
+	tempDir        string
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	// Keys wraps and unwraps the data keys.
+	Keys KeyProvider
+
+	// AllowPlaintext allows items that were written to the underlying storage
+	// server without encryption to be read as they are. Otherwise, reading
+	// them returns an error wrapping `ErrNotEncrypted`. `Rekey` encrypts
+	// these items when it is allowed.
+	AllowPlaintext bool
+
+	// TempDir is the directory used to spool items while they are re-keyed.
+	// Defaults to the system temporary directory.
+	TempDir string
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server:         args.Server,
+		keys:           args.Keys,
+		allowPlaintext: args.AllowPlaintext,
+		tempDir:        args.TempDir,
+	}
+}
+
+// item describes a stored item.
+type item struct {
+	// Whether the item is encrypted. Plaintext items are only read when
+	// `AllowPlaintext` is set.
+	encrypted bool
+	header    header
+	chunked   *types.ChunksInfo
+	physical  int64
+	size      int64
+	modTime   time.Time
+	meta      types.Metadata
+}
+
+func (i item) body() int64 {
+	return i.physical - i.header.len()
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+// CheckWithMetadata checks for an item like `Check`, and also returns the
+// metadata stored with it by the underlying storage server.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return ok, chunked, sz, mod, meta, err
+	}
+	// Don't wait for incomplete chunked items to be written
+	if chunked != nil && !chunked.Complete {
+		return ok, chunked, sz, mod, meta, nil
+	}
+	it, ok, err := s.item(ctx, dir, address)
+	if err != nil || !ok {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	return true, it.chunked, it.size, it.modTime, it.meta, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return s.GetRange(ctx, dir, address, 0, -1)
+}
+
+// GetWithMetadata gets an item like `Get`, and also returns the metadata
+// stored with it by the underlying storage server.
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	it, ok, err := s.item(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	r, ok, err := s.read(ctx, dir, address, it, 0, -1)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	return r, it.chunked, it.size, it.modTime, it.meta, true, nil
+}
+
+// GetRange returns a range of the decrypted item. Only the segments that
+// contain the range are read and decrypted.
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	it, ok, err := s.item(ctx, dir, address)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	r, ok, err := s.read(ctx, dir, address, it, offset, length)
+	if err != nil || !ok {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	return r, it.chunked, it.size, it.modTime, true, nil
+}
+
+// read returns a range of the decrypted item `it`. It is not found if the
+// item was removed since its header was read.
+func (s *StorageServer) read(ctx context.Context, dir, address string, it item, offset, length int64) (io.ReadCloser, bool, error) {
+	n, err := internal.ClampRange(it.size, offset, length)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !it.encrypted {
+		r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, offset, n)
+		return r, ok, err
+	}
+
+	aead, err := s.dataKey(ctx, it.header)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Read the segments that contain the range
+	seg := it.header.segmentSize
+	final := it.header.segments(it.body()) - 1
+	first := min(offset/seg, final)
+	last := final
+	if n > 0 {
+		last = min(final, (offset+n-1)/seg)
+	}
+	start := it.header.len() + first*(seg+tagLen)
+	end := min(it.physical, it.header.len()+(last+1)*(seg+tagLen))
+	r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, start, end-start)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	d := newDecrypter(r, aead, seg, first, last, final)
+	if skip := offset - first*seg; skip > 0 {
+		_, err = io.CopyN(io.Discard, d, skip)
+		if err != nil {
+			d.Close()
+			return nil, false, err
+		}
+	}
+	return internal.LimitReadCloser(d, n), true, nil
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata encrypts an item like `Put`, and stores the metadata with
+// it in the underlying storage server.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	h, aead, err := s.newHeader(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return rsstorage.PutWithMetadata(ctx, s.server, func(w io.Writer) (string, string, error) {
+		return s.encrypt(w, h, aead, resolve)
+	}, dir, address, meta)
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+// PutChunkedWithMetadata encrypts a chunked item like `PutChunked`, and
+// stores the metadata with it in the underlying storage server.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+	h, aead, err := s.newHeader(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	physical := h.len() + h.encryptedSize(int64(sz))
+	return rsstorage.PutChunkedWithMetadata(ctx, s.server, func(w io.Writer) (string, string, error) {
+		return s.encrypt(w, h, aead, resolve)
+	}, dir, address, uint64(physical), meta)
+}
+
+// newHeader generates a data key, and wraps it with the current key.
+func (s *StorageServer) newHeader(ctx context.Context) (header, cipher.AEAD, error) {
+	keyID, err := s.keys.CurrentKeyID(ctx)
+	if err != nil {
+		return header{}, nil, err
+	}
+	dataKey := make([]byte, dataKeyLen)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return header{}, nil, err
+	}
+	wrapped, err := s.keys.Wrap(ctx, keyID, dataKey)
+	if err != nil {
+		return header{}, nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return header{}, nil, err
+	}
+	return header{
+		segmentSize: segmentSize,
+		keyID:       keyID,
+		wrapped:     wrapped,
+	}, aead, nil
+}
+
+// encrypt writes the header and the encrypted output of the resolver to `w`.
+func (s *StorageServer) encrypt(w io.Writer, h header, aead cipher.AEAD, resolve types.Resolver) (string, string, error) {
+	b, err := h.marshal()
+	if err != nil {
+		return "", "", err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return "", "", err
+	}
+	enc := newEncrypter(w, aead, int(h.segmentSize))
+	dir, address, err := resolve(enc)
+	if err != nil {
+		return dir, address, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return "", "", err
+	}
+	return dir, address, nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	return s.server.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	return s.server.Enumerate(ctx)
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the encrypted object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, since items in the underlying storage server
+// cannot be used without it.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// item reads the header of a stored item.
+func (s *StorageServer) item(ctx context.Context, dir, address string) (item, bool, error) {
+	ok, chunked, physical, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return item{}, false, err
+	}
+	it := item{
+		encrypted: true,
+		chunked:   chunked,
+		physical:  physical,
+		modTime:   mod,
+		meta:      meta,
+	}
+
+	r, _, _, _, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, 0, min(physical, int64(maxHeaderLen)))
+	if err != nil || !ok {
+		return item{}, false, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return item{}, false, err
+	}
+
+	it.header, err = parseHeader(b)
+	if errors.Is(err, ErrNotEncrypted) && s.allowPlaintext {
+		it.encrypted = false
+		it.size = physical
+		return it, true, nil
+	} else if err != nil {
+		return item{}, false, fmt.Errorf("error reading dir=%s and address=%s: %w", dir, address, err)
+	}
+	if it.body() < tagLen {
+		return item{}, false, fmt.Errorf("error reading dir=%s and address=%s: %w", dir, address, ErrDecrypt)
+	}
+	it.size = it.header.plaintextSize(it.body())
+	return it, true, nil
+}
+
+func (s *StorageServer) dataKey(ctx context.Context, h header) (cipher.AEAD, error) {
+	dataKey, err := s.keys.Unwrap(ctx, h.keyID, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(dataKey)
+}
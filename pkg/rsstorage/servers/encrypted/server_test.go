@@ -0,0 +1,226 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type EncryptedStorageServerSuite struct {
+	tempdirhelper servertest.TempDirHelper
+}
+
+var _ = check.Suite(&EncryptedStorageServerSuite{})
+
+func (s *EncryptedStorageServerSuite) SetUpTest(c *check.C) {
+	c.Assert(s.tempdirhelper.SetUp(), check.IsNil)
+}
+
+func (s *EncryptedStorageServerSuite) TearDownTest(c *check.C) {
+	c.Assert(s.tempdirhelper.TearDown(), check.IsNil)
+}
+
+func newServer(c *check.C, dir string, underlying rsstorage.StorageServer) (*StorageServer, *KeyFileProvider) {
+	keys, err := NewKeyFileProvider(filepath.Join(dir, "keys.json"))
+	c.Assert(err, check.IsNil)
+	return NewStorageServer(StorageServerArgs{
+		Server:  underlying,
+		Keys:    keys,
+		TempDir: dir,
+	}), keys
+}
+
+func readRange(c *check.C, server rsstorage.StorageServer, dir, address string, offset, length int64) string {
+	r, _, _, _, ok, err := rsstorage.GetRange(context.Background(), server, dir, address, offset, length)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	return string(b)
+}
+
+func (s *EncryptedStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, _ := newServer(c, s.tempdirhelper.Dir(), underlying)
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://encrypted/dir/address")
+}
+
+func (s *EncryptedStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, _ := newServer(c, s.tempdirhelper.Dir(), underlying)
+
+	for _, n := range []int{0, 10, segmentSize, segmentSize + 1, 3*segmentSize - 7} {
+		data := servertest.TestData(n)
+		_, _, err := server.Put(ctx, servertest.StringResolver(data), "dir", "address")
+		c.Assert(err, check.IsNil)
+
+		ok, chunked, sz, mod, err := server.Check(ctx, "dir", "address")
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(chunked, check.IsNil)
+		c.Check(sz, check.Equals, int64(n))
+		c.Check(mod.IsZero(), check.Equals, false)
+		c.Check(servertest.ReadItem(c, server, "dir", "address") == data, check.Equals, true)
+
+		// The stored item is encrypted
+		stored := servertest.ReadItem(c, underlying, "dir", "address")
+		c.Check(strings.HasPrefix(stored, marker), check.Equals, true)
+		if n > 0 {
+			c.Check(strings.Contains(stored, data[:min(n, 100)]), check.Equals, false)
+		}
+	}
+
+	// Ranges within and across segments
+	data := servertest.TestData(3*segmentSize - 7)
+	for _, r := range [][2]int64{{0, 10}, {segmentSize - 5, 10}, {segmentSize, 10}, {2*segmentSize + 3, -1}, {100, 0}, {int64(len(data)), -1}} {
+		end := int64(len(data))
+		if r[1] >= 0 {
+			end = r[0] + r[1]
+		}
+		c.Check(readRange(c, server, "dir", "address", r[0], r[1]) == data[r[0]:end], check.Equals, true, check.Commentf("range %v", r))
+	}
+	_, _, _, _, _, err := server.GetRange(ctx, "dir", "address", int64(len(data)+1), -1)
+	c.Check(err, check.Equals, rsstorage.ErrInvalidRange)
+
+	// Deferred address
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, d, a), check.Equals, "deferred")
+}
+
+func (s *EncryptedStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, _ := newServer(c, s.tempdirhelper.Dir(), underlying)
+	data := servertest.TestData(segmentSize + 100)
+	sz := uint64(len(data))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(data), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", 0)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-sized chunked put commands")
+
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", sz)
+	c.Assert(err, check.IsNil)
+
+	ok, chunked, size, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(size, check.Equals, int64(sz))
+	c.Check(servertest.ReadItem(c, server, "dir", "address") == data, check.Equals, true)
+	c.Check(readRange(c, server, "dir", "address", segmentSize+90, -1), check.Equals, data[segmentSize+90:])
+}
+
+func (s *EncryptedStorageServerSuite) TestTampered(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("encrypted", 4096)
+	server, _ := newServer(c, s.tempdirhelper.Dir(), underlying)
+	data := servertest.TestData(2 * segmentSize)
+
+	_, _, err := server.Put(ctx, servertest.StringResolver(data), "dir", "address")
+	c.Assert(err, check.IsNil)
+	stored := []byte(servertest.ReadItem(c, underlying, "dir", "address"))
+
+	// Modified content
+	modified := append([]byte{}, stored...)
+	modified[len(modified)-100] ^= 1
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(string(modified)), "dir", "modified")
+	c.Assert(err, check.IsNil)
+	r, _, _, _, _, err := server.Get(ctx, "dir", "modified")
+	c.Assert(err, check.IsNil)
+	_, err = io.ReadAll(r)
+	c.Check(err, check.Equals, ErrDecrypt)
+
+	// Truncated to a segment boundary
+	h, err := parseHeader(stored)
+	c.Assert(err, check.IsNil)
+	truncated := stored[:h.len()+segmentSize+tagLen]
+	_, _, err = underlying.Put(ctx, servertest.StringResolver(string(truncated)), "dir", "truncated")
+	c.Assert(err, check.IsNil)
+	r, _, _, _, _, err = server.Get(ctx, "dir", "truncated")
+	c.Assert(err, check.IsNil)
+	_, err = io.ReadAll(r)
+	c.Check(err, check.Equals, ErrDecrypt)
+
+	// Plaintext
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("plaintext"), "dir", "plaintext")
+	c.Assert(err, check.IsNil)
+	_, _, _, _, err = server.Check(ctx, "dir", "plaintext")
+	c.Check(errors.Is(err, ErrNotEncrypted), check.Equals, true)
+
+	server.allowPlaintext = true
+	c.Check(servertest.ReadItem(c, server, "dir", "plaintext"), check.Equals, "plaintext")
+	c.Check(readRange(c, server, "dir", "plaintext", 5, -1), check.Equals, "text")
+}
+
+func (s *EncryptedStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	server, _ := newServer(c, s.tempdirhelper.Dir(), memtest.NewServer("encrypted", 4096))
+	dest := memtest.NewServer("dest", 4096)
+
+	servertest.CheckCopy(c, ctx, server, dest, "the encrypted object with dir=dir and address=missing to copy does not exist")
+
+	c.Assert(server.Move(ctx, "dir", "a", dest), check.IsNil)
+	ok, _, _, _, err := server.Check(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *EncryptedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	server, _ := newServer(c, s.tempdirhelper.Dir(), memtest.NewServer("encrypted", 4096))
+	meta := types.Metadata{"content-type": "text/plain"}
+	data := servertest.TestData(segmentSize + 100)
+
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+
+	ok, _, sz, _, got, err := server.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(9))
+	c.Check(got, check.DeepEquals, meta)
+	r, _, sz, _, got, ok, err := server.GetWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(len(data)))
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r) == data, check.Equals, true)
+	_, _, _, _, got, ok, err = server.GetWithMetadata(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(got, check.IsNil)
+
+	// Copies to servers that cannot store metadata drop it
+	other := &rsstorage.DummyStorageServer{}
+	c.Assert(server.Copy(ctx, "dir", "a", other), check.IsNil)
+	c.Check(other.Placed, check.DeepEquals, []string{"dir-some data"})
+}
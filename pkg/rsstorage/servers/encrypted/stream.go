@@ -0,0 +1,246 @@
+package encrypted
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Items are stored with a header followed by the encrypted content. The
+// content is split into segments of `segmentSize` bytes, and each segment is
+// sealed with AES-GCM using the item's data key. The nonce of each segment
+// is its index, plus a flag marking the final segment, so segments cannot be
+// reordered or truncated without detection. Since each segment can be
+// decrypted independently, ranges can be read without decrypting the whole
+// item.
+//
+// The header contains:
+//
+//	marker      4 bytes
+//	version     1 byte
+//	segment     4 bytes, the segment size
+//	key ID      1 byte length, followed by the key ID
+//	wrapped key 2 byte length, followed by the wrapped data key
+const (
+	marker  = "\x00RSE"
+	version = 1
+
+	// segmentSize is the size of the plaintext in each segment.
+	segmentSize = 64 * 1024
+
+	dataKeyLen = 32
+	tagLen     = 16
+	nonceLen   = 12
+
+	// maxHeaderLen is the largest possible header.
+	maxHeaderLen = len(marker) + 1 + 4 + 1 + 255 + 2 + 1024
+)
+
+var (
+	ErrDecrypt      = errors.New("message authentication failed")
+	ErrNotEncrypted = errors.New("item is not encrypted")
+)
+
+// header describes an encrypted item.
+type header struct {
+	segmentSize int64
+	keyID       string
+	wrapped     []byte
+}
+
+func (h header) len() int64 {
+	return int64(len(marker) + 1 + 4 + 1 + len(h.keyID) + 2 + len(h.wrapped))
+}
+
+func (h header) marshal() ([]byte, error) {
+	if len(h.keyID) > 255 {
+		return nil, fmt.Errorf("key ID %s is too long", h.keyID)
+	} else if len(h.wrapped) > 1024 {
+		return nil, fmt.Errorf("wrapped data key is too long")
+	}
+	b := make([]byte, 0, h.len())
+	b = append(b, marker...)
+	b = append(b, version)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.segmentSize))
+	b = append(b, byte(len(h.keyID)))
+	b = append(b, h.keyID...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.wrapped)))
+	b = append(b, h.wrapped...)
+	return b, nil
+}
+
+// parseHeader parses the header at the start of `b`. Returns
+// `ErrNotEncrypted` if `b` does not start with a header.
+func parseHeader(b []byte) (header, error) {
+	if !bytes.HasPrefix(b, []byte(marker)) {
+		return header{}, ErrNotEncrypted
+	}
+	invalid := errors.New("invalid encryption header")
+	b = b[len(marker):]
+	if len(b) < 6 {
+		return header{}, invalid
+	} else if b[0] != version {
+		return header{}, fmt.Errorf("unsupported encryption header version %d", b[0])
+	}
+	h := header{
+		segmentSize: int64(binary.BigEndian.Uint32(b[1:5])),
+	}
+	idLen := int(b[5])
+	b = b[6:]
+	if len(b) < idLen+2 || h.segmentSize == 0 {
+		return header{}, invalid
+	}
+	h.keyID = string(b[:idLen])
+	wrappedLen := int(binary.BigEndian.Uint16(b[idLen:]))
+	b = b[idLen+2:]
+	if len(b) < wrappedLen {
+		return header{}, invalid
+	}
+	h.wrapped = append([]byte{}, b[:wrappedLen]...)
+	return h, nil
+}
+
+// segments returns the number of segments in an item with `body` encrypted
+// bytes. Every item has at least one segment.
+func (h header) segments(body int64) int64 {
+	return max(1, (body+h.segmentSize+tagLen-1)/(h.segmentSize+tagLen))
+}
+
+// plaintextSize returns the size of the plaintext of an item with `body`
+// encrypted bytes.
+func (h header) plaintextSize(body int64) int64 {
+	return body - h.segments(body)*tagLen
+}
+
+// encryptedSize returns the number of encrypted bytes for `sz` bytes of
+// plaintext.
+func (h header) encryptedSize(sz int64) int64 {
+	segments := max(1, (sz+h.segmentSize-1)/h.segmentSize)
+	return sz + segments*tagLen
+}
+
+func segmentNonce(index int64, final bool) []byte {
+	nonce := make([]byte, nonceLen)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if final {
+		nonce[nonceLen-1] = 1
+	}
+	return nonce
+}
+
+// encrypter encrypts the bytes written to it in segments. A segment is only
+// sealed once more data arrives, so that the final segment can be marked
+// when the encrypter is closed.
+type encrypter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	segmentSize int
+	buf         []byte
+	index       int64
+}
+
+func newEncrypter(w io.Writer, aead cipher.AEAD, segmentSize int) *encrypter {
+	return &encrypter{
+		w:           w,
+		aead:        aead,
+		segmentSize: segmentSize,
+		buf:         make([]byte, 0, segmentSize),
+	}
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if len(e.buf) == e.segmentSize {
+			err := e.seal(false)
+			if err != nil {
+				return n, err
+			}
+		}
+		c := min(len(p), e.segmentSize-len(e.buf))
+		e.buf = append(e.buf, p[:c]...)
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close seals the final segment.
+func (e *encrypter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encrypter) seal(final bool) error {
+	out := e.aead.Seal(nil, segmentNonce(e.index, final), e.buf, nil)
+	_, err := e.w.Write(out)
+	if err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decrypter decrypts the segments `index` through `last` read from `r`.
+// `final` is the index of the final segment of the item.
+type decrypter struct {
+	r       io.ReadCloser
+	aead    cipher.AEAD
+	index   int64
+	last    int64
+	final   int64
+	segment []byte
+	plain   []byte
+}
+
+func newDecrypter(r io.ReadCloser, aead cipher.AEAD, segmentSize, index, last, final int64) *decrypter {
+	return &decrypter{
+		r:       r,
+		aead:    aead,
+		index:   index,
+		last:    last,
+		final:   final,
+		segment: make([]byte, segmentSize+tagLen),
+	}
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.index > d.last {
+			return 0, io.EOF
+		}
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decrypter) open() error {
+	n, err := io.ReadFull(d.r, d.segment)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		if d.index != d.final {
+			return ErrDecrypt
+		}
+	} else if err != nil {
+		return err
+	}
+	d.plain, err = d.aead.Open(d.segment[:0], segmentNonce(d.index, d.index == d.final), d.segment[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.index++
+	return nil
+}
+
+func (d *decrypter) Close() error {
+	return d.r.Close()
+}
@@ -0,0 +1,332 @@
+package expiring
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// ExpirySuffix is appended to the address of an item to store its expiry.
+const ExpirySuffix = ".expires"
+
+type expiryKey struct{}
+
+// WithExpiry returns a context that sets the expiry of items written with it.
+func WithExpiry(ctx context.Context, expiry time.Time) context.Context {
+	return context.WithValue(ctx, expiryKey{}, expiry)
+}
+
+// WithTTL returns a context that sets items written with it to expire after
+// `ttl`.
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return WithExpiry(ctx, time.Now().Add(ttl))
+}
+
+// Expiry returns the expiry set on a context with `WithExpiry` or `WithTTL`.
+func Expiry(ctx context.Context) (time.Time, bool) {
+	expiry, ok := ctx.Value(expiryKey{}).(time.Time)
+	return expiry, ok
+}
+
+// StorageServer is a storage server that lets items expire. The expiry of an
+// item is stored next to the item, with `ExpirySuffix` appended to the
+// address. Expired items are reported as missing by `Check`, `Get`, and
+// `GetRange`, so caches resolve them again. `Sweep` removes expired items.
+//
+// The expiry of an item is set from the context used to write it, see
+// `WithExpiry` and `WithTTL`, or from the default `TTL`. Items without an
+// expiry never expire.
+type StorageServer struct {
+	server rsstorage.StorageServer
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	// TTL is the default time to live of items written without an expiry
+	// set on the context. Items written without an expiry never expire when
+	// zero.
+	TTL time.Duration
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server: args.Server,
+		ttl:    args.TTL,
+		now:    time.Now,
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return ok, chunked, sz, mod, meta, err
+	}
+	expired, err := s.expired(ctx, dir, address)
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	} else if expired {
+		return false, nil, 0, time.Time{}, nil, nil
+	}
+	return ok, chunked, sz, mod, meta, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+	if err != nil || !ok {
+		return r, chunked, sz, mod, meta, ok, err
+	}
+	expired, err := s.expired(ctx, dir, address)
+	if err != nil || expired {
+		r.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	return r, chunked, sz, mod, meta, true, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+	if err != nil || !ok {
+		return r, chunked, sz, mod, ok, err
+	}
+	expired, err := s.expired(ctx, dir, address)
+	if err != nil || expired {
+		r.Close()
+		return nil, nil, 0, time.Time{}, false, err
+	}
+	return r, chunked, sz, mod, true, nil
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	err := s.checkMetadata(meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Remove the expiry of any earlier item first, so the new item does not
+	// appear expired
+	if address != "" {
+		err = s.server.Remove(ctx, dir, address+ExpirySuffix)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	wdir, waddress, err := rsstorage.PutWithMetadata(ctx, s.server, resolve, dir, address, meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+		err = s.server.Remove(ctx, dir, address+ExpirySuffix)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	err = s.setExpiry(ctx, dir, address)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, address, nil
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+
+	err := s.checkMetadata(meta)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.server.Remove(ctx, dir, address+ExpirySuffix)
+	if err != nil {
+		return "", "", err
+	}
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, s.server, resolve, dir, address, sz, meta)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.setExpiry(ctx, dir, address)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, address, nil
+}
+
+// checkMetadata rejects metadata that the underlying storage server cannot
+// store before the expiry of an earlier item is removed.
+func (s *StorageServer) checkMetadata(meta types.Metadata) error {
+	if _, ok := s.server.(rsstorage.ObjectMetadataServer); !ok && len(meta) > 0 {
+		return rsstorage.ErrMetadataNotSupported
+	}
+	return nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	err := s.server.Remove(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	return s.server.Remove(ctx, dir, address+ExpirySuffix)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+// Enumerate lists the items. Expiries are not included. Expired items are
+// listed until they are removed by `Sweep`.
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.StoredItem, 0, len(items))
+	for _, item := range items {
+		if !item.Chunked && strings.HasSuffix(item.Address, ExpirySuffix) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+// Copy copies an item to another server. The expiry of the item is set on
+// the context used to write the copy, so it is kept when the other server
+// is also an expiring server.
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	expiry, ok, err := s.expiry(ctx, dir, address)
+	if err != nil {
+		return err
+	} else if ok {
+		ctx = WithExpiry(ctx, expiry)
+	}
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, so that items copied into it get an expiry.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// setExpiry records the expiry for an item written with `ctx`, if any.
+func (s *StorageServer) setExpiry(ctx context.Context, dir, address string) error {
+	expiry, ok := Expiry(ctx)
+	if !ok {
+		if s.ttl == 0 {
+			return nil
+		}
+		expiry = s.now().Add(s.ttl)
+	}
+
+	_, _, err := s.server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, expiry.UTC().Format(time.RFC3339Nano))
+		return "", "", err
+	}, dir, address+ExpirySuffix)
+	return err
+}
+
+// expiry returns the recorded expiry of an item, if any.
+func (s *StorageServer) expiry(ctx context.Context, dir, address string) (time.Time, bool, error) {
+	r, _, _, _, ok, err := s.server.Get(ctx, dir, address+ExpirySuffix)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	expiry, err := time.Parse(time.RFC3339Nano, string(bytes.TrimSpace(b)))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid expiry for dir=%s and address=%s: %w", dir, address, err)
+	}
+	return expiry, true, nil
+}
+
+func (s *StorageServer) expired(ctx context.Context, dir, address string) (bool, error) {
+	expiry, ok, err := s.expiry(ctx, dir, address)
+	if err != nil || !ok {
+		return false, err
+	}
+	return !s.now().Before(expiry), nil
+}
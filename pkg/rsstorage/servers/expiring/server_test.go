@@ -0,0 +1,251 @@
+package expiring
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ExpiringStorageServerSuite struct{}
+
+var _ = check.Suite(&ExpiringStorageServerSuite{})
+
+// newServer returns a server with a clock that is advanced by `clock`.
+func newServer(underlying rsstorage.StorageServer, ttl time.Duration) (*StorageServer, *time.Time) {
+	server := NewStorageServer(StorageServerArgs{
+		Server: underlying,
+		TTL:    ttl,
+	})
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.now = func() time.Time {
+		return clock
+	}
+	return server, &clock
+}
+
+func (s *ExpiringStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("expiring", 4096)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, TTL: time.Hour})
+	c.Check(server.ttl, check.Equals, time.Hour)
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://expiring/dir/address")
+}
+
+func (s *ExpiringStorageServerSuite) TestContext(c *check.C) {
+	ctx := context.Background()
+	_, ok := Expiry(ctx)
+	c.Check(ok, check.Equals, false)
+
+	expiry := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got, ok := Expiry(WithExpiry(ctx, expiry))
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.Equals, expiry)
+
+	got, ok = Expiry(WithTTL(ctx, time.Hour))
+	c.Check(ok, check.Equals, true)
+	c.Check(got.After(time.Now().Add(59*time.Minute)), check.Equals, true)
+}
+
+func (s *ExpiringStorageServerSuite) TestExpiry(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, 0)
+
+	// No expiry
+	_, _, err := server.Put(ctx, servertest.StringResolver("forever"), "dir", "forever")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, underlying, "dir", "forever"+ExpirySuffix), check.Equals, false)
+
+	// Expiry from the context
+	_, _, err = server.Put(WithExpiry(ctx, clock.Add(time.Hour)), servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, underlying, "dir", "address"+ExpirySuffix), check.Equals, "2026-01-01T01:00:00Z")
+	c.Check(servertest.Exists(c, server, "dir", "address"), check.Equals, true)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "some data")
+
+	*clock = clock.Add(time.Hour)
+	c.Check(servertest.Exists(c, server, "dir", "address"), check.Equals, false)
+	r, chunked, sz, mod, ok, err := server.Get(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+	c.Check(chunked, check.IsNil)
+	c.Check(sz, check.Equals, int64(0))
+	c.Check(mod.IsZero(), check.Equals, true)
+	_, _, _, _, ok, err = server.GetRange(ctx, "dir", "address", 0, 4)
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir", "forever"), check.Equals, true)
+
+	// Still stored until swept
+	c.Check(servertest.Exists(c, underlying, "dir", "address"), check.Equals, true)
+
+	// Written again without an expiry
+	_, _, err = server.Put(ctx, servertest.StringResolver("new data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "new data")
+	c.Check(servertest.Exists(c, underlying, "dir", "address"+ExpirySuffix), check.Equals, false)
+
+	// Invalid expiry
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("bad"), "dir", "address"+ExpirySuffix)
+	c.Assert(err, check.IsNil)
+	_, _, _, _, err = server.Check(ctx, "dir", "address")
+	c.Check(err, check.ErrorMatches, "invalid expiry for dir=dir and address=address: .*")
+}
+
+func (s *ExpiringStorageServerSuite) TestDefaultTTL(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, time.Minute)
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "default")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(WithTTL(ctx, 24*time.Hour), servertest.StringResolver("some data"), "dir", "context")
+	c.Assert(err, check.IsNil)
+
+	// Deferred address
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, d, a), check.Equals, "deferred")
+
+	*clock = clock.Add(time.Minute)
+	c.Check(servertest.Exists(c, server, "dir", "default"), check.Equals, false)
+	c.Check(servertest.Exists(c, server, d, a), check.Equals, false)
+	c.Check(servertest.Exists(c, server, "dir", "context"), check.Equals, true)
+}
+
+func (s *ExpiringStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, 0)
+	data := servertest.TestDESC
+	sz := uint64(len(data))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(data), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "address", 0)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-sized chunked put commands")
+
+	_, _, err = server.PutChunked(WithExpiry(ctx, clock.Add(time.Hour)), servertest.StringResolver(data), "dir", "address", sz)
+	c.Assert(err, check.IsNil)
+	ok, chunked, size, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(size, check.Equals, int64(sz))
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, data)
+
+	*clock = clock.Add(2 * time.Hour)
+	c.Check(servertest.Exists(c, server, "dir", "address"), check.Equals, false)
+
+	// Expiries are not listed
+	items, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.DeepEquals, []types.StoredItem{{Dir: "dir", Address: "address", Chunked: true}})
+
+	// Removed with the item
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"), check.Equals, false)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"+ExpirySuffix), check.Equals, false)
+}
+
+func (s *ExpiringStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	server, clock := newServer(memtest.NewServer("expiring", 4096), 0)
+	destUnderlying := memtest.NewServer("dest", 4096)
+	dest, destClock := newServer(destUnderlying, 0)
+	plain := memtest.NewServer("plain", 4096)
+
+	// The expiry is kept
+	servertest.CheckCopy(c, WithExpiry(ctx, clock.Add(time.Hour)), server, dest, "the object with dir=dir and address=missing to copy does not exist")
+	c.Check(servertest.ReadItem(c, destUnderlying, "dir", "a"+ExpirySuffix), check.Equals, "2026-01-01T01:00:00Z")
+	*destClock = destClock.Add(time.Hour)
+	c.Check(servertest.Exists(c, dest, "dir", "a"), check.Equals, false)
+
+	c.Assert(server.Move(ctx, "dir", "a", plain), check.IsNil)
+	c.Check(servertest.ReadItem(c, plain, "dir", "a"), check.Equals, "some data")
+	c.Check(servertest.Exists(c, server, "dir", "a"), check.Equals, false)
+
+	// Expired items are not copied
+	_, _, err := server.Put(WithExpiry(ctx, *clock), servertest.StringResolver("some data"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	err = server.Copy(ctx, "dir", "b", plain)
+	c.Check(err, check.ErrorMatches, "the object with dir=dir and address=b to copy does not exist")
+}
+
+func (s *ExpiringStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, 0)
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	data := servertest.TestData(5000)
+	ctx = WithExpiry(ctx, clock.Add(time.Hour))
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, underlying, "dir", "chunked"+ExpirySuffix), check.Equals, "2026-01-01T01:00:00Z")
+
+	for _, address := range []string{"a", "chunked"} {
+		ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+		r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+		r.Close()
+	}
+
+	// Metadata is copied with the expiry
+	dest, _ := newServer(memtest.NewServer("dest", 4096), 0)
+	c.Assert(server.Copy(ctx, "dir", "a", dest), check.IsNil)
+	_, _, _, _, got, err := dest.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// Expired items have no metadata
+	*clock = clock.Add(time.Hour)
+	ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(got, check.IsNil)
+	r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+	c.Check(got, check.IsNil)
+
+	// Metadata is rejected before anything is removed when the underlying
+	// server cannot store it
+	dummy := &rsstorage.DummyStorageServer{}
+	server, _ = newServer(dummy, time.Hour)
+	_, _, err = server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", 9, meta)
+	c.Check(err, check.Equals, rsstorage.ErrMetadataNotSupported)
+	c.Check(dummy.RemoveCount, check.Equals, 0)
+	c.Check(dummy.PutCalled, check.Equals, 0)
+}
@@ -0,0 +1,73 @@
+package expiring
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+)
+
+// SweepResult summarizes a sweep.
+type SweepResult struct {
+	// Checked is the number of items with an expiry that were checked.
+	Checked int
+
+	// Removed is the number of expired items that were removed.
+	Removed int
+}
+
+// Sweep removes all expired items. Only items with an expiry are read, so a
+// sweep costs one listing of the underlying server plus one read per item
+// with an expiry.
+func (s *StorageServer) Sweep(ctx context.Context) (SweepResult, error) {
+	result := SweepResult{}
+
+	items, err := s.server.Enumerate(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var errs error
+	for _, item := range items {
+		if item.Chunked || !strings.HasSuffix(item.Address, ExpirySuffix) {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return result, errors.Join(errs, err)
+		}
+		result.Checked++
+		address := strings.TrimSuffix(item.Address, ExpirySuffix)
+		expired, err := s.expired(ctx, item.Dir, address)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error sweeping dir=%s and address=%s: %w", item.Dir, address, err))
+			continue
+		} else if !expired {
+			continue
+		}
+		err = s.Remove(ctx, item.Dir, address)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error sweeping dir=%s and address=%s: %w", item.Dir, address, err))
+			continue
+		}
+		slog.Debug("removed expired item", "dir", item.Dir, "address", address)
+		result.Removed++
+	}
+	return result, errs
+}
+
+// NewSweepTask returns a scheduled task that removes expired items.
+func NewSweepTask(name string, server *StorageServer, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := server.Sweep(ctx)
+		slog.Debug("swept expired items",
+			"task", name,
+			"checked", result.Checked,
+			"removed", result.Removed)
+		return err
+	})
+}
@@ -0,0 +1,79 @@
+package expiring
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type SweepSuite struct{}
+
+var _ = check.Suite(&SweepSuite{})
+
+func (s *SweepSuite) TestSweep(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, time.Hour)
+	data := servertest.TestDESC
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(WithExpiry(ctx, clock.Add(2*time.Hour)), servertest.StringResolver("some data"), "dir", "later")
+	c.Assert(err, check.IsNil)
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("forever"), "dir", "forever")
+	c.Assert(err, check.IsNil)
+
+	result, err := server.Sweep(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, SweepResult{Checked: 3})
+
+	*clock = clock.Add(time.Hour)
+	result, err = server.Sweep(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, SweepResult{Checked: 3, Removed: 2})
+	for address, ok := range map[string]bool{
+		"a":                      false,
+		"a" + ExpirySuffix:       false,
+		"chunked":                false,
+		"chunked" + ExpirySuffix: false,
+		"later":                  true,
+		"later" + ExpirySuffix:   true,
+		"forever":                true,
+	} {
+		c.Check(servertest.Exists(c, underlying, "dir", address), check.Equals, ok, check.Commentf("address %s", address))
+	}
+
+	// Invalid expiries are reported
+	_, _, err = underlying.Put(ctx, servertest.StringResolver("bad"), "dir", "later"+ExpirySuffix)
+	c.Assert(err, check.IsNil)
+	result, err = server.Sweep(ctx)
+	c.Check(err, check.ErrorMatches, "error sweeping dir=dir and address=later: invalid expiry .*")
+	c.Check(result, check.DeepEquals, SweepResult{Checked: 1})
+}
+
+func (s *SweepSuite) TestSweepTask(c *check.C) {
+	ctx := context.Background()
+	underlying := memtest.NewServer("expiring", 4096)
+	server, clock := newServer(underlying, time.Hour)
+
+	ticker := make(chan time.Time)
+	task := NewSweepTask("sweep", server, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "sweep")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	*clock = clock.Add(time.Hour)
+	task.Run(ctx, nil)
+	c.Check(servertest.Exists(c, underlying, "dir", "address"), check.Equals, false)
+}
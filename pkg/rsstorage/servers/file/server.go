@@ -9,9 +9,13 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"iter"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/c2h5oh/datasize"
@@ -24,6 +28,16 @@ import (
 const (
 	walkCheckTime      = 250 * time.Millisecond
 	defaultWalkTimeout = 5 * time.Minute
+
+	// StagingPrefix starts the names of the files that data is written to
+	// before it is moved into place. Staging files are created in the
+	// storage directory, and are not enumerated.
+	StagingPrefix = ".staging-"
+
+	// MetadataPrefix starts the names of the sidecar files that hold the
+	// metadata of an item. A sidecar is stored next to its item, and is not
+	// enumerated.
+	MetadataPrefix = ".metadata-"
 )
 
 var (
@@ -48,6 +62,10 @@ type StorageServerArgs struct {
 	Class        string
 	CacheTimeout time.Duration
 	WalkTimeout  time.Duration
+
+	// ResumeChunked resumes interrupted chunked writes instead of starting
+	// over. See `internal.DefaultChunkUtils.Resume`.
+	ResumeChunked bool
 }
 
 func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
@@ -70,16 +88,23 @@ func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
 			Notifier:    args.Notifier,
 			PollTimeout: rsstorage.DefaultChunkPollTimeout,
 			MaxAttempts: rsstorage.DefaultMaxChunkAttempts,
+			Resume:      args.ResumeChunked,
 		},
 		class: args.Class,
 	}
 }
 
-func (s *StorageServer) Check(ctx context.Context, dir, address string) (
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	found, chunkInfo, fileSize, modTime, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return found, chunkInfo, fileSize, modTime, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (
 	found bool,
 	chunkInfo *types.ChunksInfo,
 	fileSize int64,
 	modTime time.Time,
+	meta types.Metadata,
 	err error,
 ) {
 	// Determine the location for this file
@@ -119,6 +144,12 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (
 		chunkInfo = &info
 		fileSize = int64(info.FileSize)
 		modTime = info.ModTime
+		meta = info.Metadata
+		return
+	}
+
+	meta, err = s.readMetadata(dir, address)
+	if err != nil {
 		return
 	}
 
@@ -244,6 +275,46 @@ func diskUsage(duPath string, cacheTimeout, walkTimeout time.Duration) (size dat
 }
 
 func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, c, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, c, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	// Determine the location for this file
+	filePath := filepath.Join(s.dir, dir, address)
+
+	// Open the file
+	f, err := s.fileIO.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil, 0, time.Time{}, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+
+	if stat.IsDir() {
+		r, c, sz, mod, err := s.chunker.ReadChunked(ctx, dir, address)
+		if err != nil {
+			return nil, nil, 0, time.Time{}, nil, false, fmt.Errorf("error reading chunked directory files for %s: %s", address, err)
+		}
+
+		return r, c, sz, mod, c.Metadata, true, nil
+	}
+
+	meta, err := s.readMetadata(dir, address)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, errors.Join(err, f.Close())
+	}
+
+	return f, nil, stat.Size(), stat.ModTime(), meta, true, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
 	// Determine the location for this file
 	filePath := filepath.Join(s.dir, dir, address)
 
@@ -258,19 +329,35 @@ func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCl
 
 	stat, err := f.Stat()
 	if err != nil {
-		return nil, nil, 0, time.Time{}, false, err
+		return nil, nil, 0, time.Time{}, false, errors.Join(err, f.Close())
 	}
 
 	if stat.IsDir() {
-		r, c, sz, mod, err := s.chunker.ReadChunked(ctx, dir, address)
+		err = f.Close()
 		if err != nil {
-			return nil, nil, 0, time.Time{}, false, fmt.Errorf("error reading chunked directory files for %s: %s", address, err)
+			return nil, nil, 0, time.Time{}, false, err
+		}
+
+		r, c, sz, mod, err := rsstorage.ReadChunkedRange(ctx, s.chunker, dir, address, offset, length)
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
 		}
 
 		return r, c, sz, mod, true, nil
 	}
 
-	return f, nil, stat.Size(), stat.ModTime(), true, nil
+	n, err := internal.ClampRange(stat.Size(), offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, errors.Join(err, f.Close())
+	}
+
+	// Seek to the start of the range
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, errors.Join(err, f.Close())
+	}
+
+	return internal.LimitReadCloser(f, n), nil, stat.Size(), stat.ModTime(), true, nil
 }
 
 func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
@@ -285,6 +372,12 @@ func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
 }
 
 func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata writes an item, then replaces the sidecar file that holds
+// its metadata. The sidecar is removed when there is no metadata.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
 
 	// Store the data
 	wdir, waddress, staging, err := s.write(resolve)
@@ -316,17 +409,26 @@ func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, ad
 		return "", "", err
 	}
 
+	err = s.writeMetadata(dir, address, meta)
+	if err != nil {
+		return "", "", err
+	}
+
 	return dir, address, nil
 }
 
 func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
 	if address == "" {
 		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
 	}
 	if sz == 0 {
 		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
 	}
-	err := s.chunker.WriteChunked(ctx, dir, address, sz, resolve)
+	err := rsstorage.WriteChunkedWithMetadata(ctx, s.chunker, dir, address, sz, meta, resolve)
 	if err != nil {
 		return "", "", err
 	}
@@ -336,7 +438,7 @@ func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver,
 
 func (s *StorageServer) write(resolve types.Resolver) (dir, address, staging string, err error) {
 	// Open the file where we will stage the data
-	stagingFile, err := s.fileIO.OpenStaging(s.dir, "")
+	stagingFile, err := s.fileIO.OpenStaging(s.dir, StagingPrefix)
 	if err != nil {
 		return
 	}
@@ -369,6 +471,57 @@ func (s *StorageServer) cleanup(staging string) {
 	}
 }
 
+// metadataPath returns the location of the sidecar file for an item.
+func (s *StorageServer) metadataPath(dir, address string) string {
+	filePath := filepath.Join(s.dir, dir, address)
+	return filepath.Join(filepath.Dir(filePath), MetadataPrefix+filepath.Base(filePath))
+}
+
+// readMetadata reads the sidecar file for an item. Items without a sidecar
+// have no metadata.
+func (s *StorageServer) readMetadata(dir, address string) (meta types.Metadata, err error) {
+	f, err := s.fileIO.Open(s.metadataPath(dir, address))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func(f fileIOFile) {
+		closeErr := f.Close()
+		if closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}(f)
+
+	err = json.NewDecoder(f).Decode(&meta)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding metadata for %s: %w", address, err)
+	}
+	return meta, nil
+}
+
+// writeMetadata replaces the sidecar file for an item, or removes it when
+// there is no metadata.
+func (s *StorageServer) writeMetadata(dir, address string, meta types.Metadata) error {
+	metaPath := s.metadataPath(dir, address)
+	if len(meta) == 0 {
+		err := s.fileIO.Remove(metaPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	_, _, staging, err := s.write(func(w io.Writer) (string, string, error) {
+		return "", "", json.NewEncoder(w).Encode(meta.Normalize())
+	})
+	defer s.cleanup(staging)
+	if err != nil {
+		return err
+	}
+	return s.fileIO.Move(staging, metaPath)
+}
+
 func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
 	// Determine the location for this file
 	ok, chunked, _, _, err := s.Check(ctx, dir, address)
@@ -384,7 +537,11 @@ func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
 		return s.fileIO.RemoveAll(filePath)
 	}
 
-	return s.fileIO.Remove(filePath)
+	err = s.fileIO.Remove(filePath)
+	if err != nil {
+		return err
+	}
+	return s.writeMetadata(dir, address, nil)
 }
 
 func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
@@ -398,6 +555,144 @@ func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, erro
 	return internal.FilterChunks(items), nil
 }
 
+// EnumerateItems streams the items in the order they are walked, which is
+// lexical order within each directory. Directories that cannot hold items
+// after `opts.After` or with the prefix `opts.Prefix` are not walked.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		filtered := func(item types.StoredItem) bool {
+			if !internal.MatchesPrefix(item, opts) {
+				return true
+			}
+			return yield(item, nil)
+		}
+
+		stopped := false
+		grouper := &internal.ChunkGrouper{}
+		err := filepath.WalkDir(s.dir, func(path string, info fs.DirEntry, err error) error {
+			if err != nil {
+				slog.Error("Error enumerating storage for directory", "dir", s.dir, "error", err)
+				return nil
+			}
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(s.dir, path)
+			if err != nil {
+				return err
+			}
+			relPath = filepath.ToSlash(relPath)
+			if relPath == "." {
+				return nil
+			}
+
+			if info.IsDir() {
+				if opts.After != "" && comparePaths(relPath, opts.After) < 0 && !strings.HasPrefix(opts.After, relPath+"/") {
+					return fs.SkipDir
+				}
+				if !strings.HasPrefix(relPath+"/", opts.Prefix) && !strings.HasPrefix(opts.Prefix, relPath+"/") {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if opts.After != "" && comparePaths(relPath, opts.After) <= 0 || !strings.HasPrefix(relPath, opts.Prefix) || isStaging(relPath) || isMetadata(relPath) {
+				return nil
+			}
+
+			stat, err := info.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed since it was listed
+				return nil
+			} else if err != nil {
+				return err
+			}
+			dir := filepath.ToSlash(filepath.Dir(relPath))
+			if dir == "." {
+				dir = ""
+			}
+			file := types.StoredItem{
+				Dir:     dir,
+				Address: stat.Name(),
+				Size:    stat.Size(),
+				ModTime: stat.ModTime(),
+				Token:   relPath,
+			}
+			if !grouper.Add(file, filtered) {
+				stopped = true
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			yield(types.StoredItem{}, err)
+			return
+		}
+		if !stopped {
+			grouper.Flush(filtered)
+		}
+	}
+}
+
+// isStaging returns true for the path of a staging file relative to the
+// storage directory.
+func isStaging(relPath string) bool {
+	return strings.HasPrefix(relPath, StagingPrefix) && !strings.Contains(relPath, "/")
+}
+
+// RemoveStaging removes the staging files that were last modified before
+// `before`. These are left behind when a process stops while writing.
+// Returns the number of files removed and their total size.
+func (s *StorageServer) RemoveStaging(ctx context.Context, before time.Time) (removed int, reclaimed int64, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if entry.IsDir() || !isStaging(entry.Name()) {
+			continue
+		}
+		info, infoErr := entry.Info()
+		if errors.Is(infoErr, fs.ErrNotExist) {
+			// Moved into place since it was listed
+			continue
+		} else if infoErr != nil {
+			errs = append(errs, infoErr)
+			continue
+		}
+		if !info.ModTime().Before(before) {
+			continue
+		}
+		removeErr := s.fileIO.Remove(filepath.Join(s.dir, entry.Name()))
+		if errors.Is(removeErr, fs.ErrNotExist) {
+			continue
+		} else if removeErr != nil {
+			errs = append(errs, removeErr)
+			continue
+		}
+		slog.Debug("Removed staging file", "file", entry.Name(), "modified", info.ModTime())
+		removed++
+		reclaimed += info.Size()
+	}
+	err = errors.Join(append(errs, err)...)
+	return
+}
+
+// isMetadata returns true for the path of a metadata sidecar file.
+func isMetadata(relPath string) bool {
+	return strings.HasPrefix(path.Base(relPath), MetadataPrefix)
+}
+
+// comparePaths compares slash-separated paths in the order they are walked.
+func comparePaths(a, b string) int {
+	return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+}
+
 func enumerate(dir string, walkTimeout time.Duration) ([]types.StoredItem, error) {
 	stop := make(chan struct{})
 	defer close(stop)
@@ -421,6 +716,9 @@ func enumerate(dir string, walkTimeout time.Duration) ([]types.StoredItem, error
 				if err != nil {
 					return err
 				}
+				if isStaging(relPath) || isMetadata(filepath.ToSlash(relPath)) {
+					return nil
+				}
 
 				dir := filepath.Dir(relPath)
 				if dir == "." {
@@ -489,6 +787,22 @@ func (s *StorageServer) move(dir, address string, server rsstorage.StorageServer
 		return err
 	}
 
+	// Move the metadata sidecar with the item, if any
+	if destServer, ok := server.(*StorageServer); ok {
+		destMeta := destServer.metadataPath(dir, address)
+		err = os.Rename(s.metadataPath(dir, address), destMeta)
+		if os.IsNotExist(err) {
+			err = os.Remove(destMeta)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			slog.Debug("Error moving metadata with os.Rename", "error", err)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -524,13 +838,14 @@ func (s *StorageServer) Move(ctx context.Context, dir, address string, server rs
 
 func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
 	// Open the file
-	f, chunked, sz, _, ok, err := s.Get(ctx, dir, address)
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return fmt.Errorf("the file at %s to copy does not exist", filepath.Join(dir, address))
 	}
+	defer f.Close()
 
 	install := func(file io.ReadCloser) types.Resolver {
 		return func(writer io.Writer) (string, string, error) {
@@ -540,11 +855,7 @@ func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rs
 	}
 
 	// Use the server Base() in case the server is wrapped, e.g., `Metadatarsstorage.StorageServer`
-	if chunked != nil {
-		_, _, err = server.Base().PutChunked(ctx, install(f), dir, address, uint64(sz))
-	} else {
-		_, _, err = server.Base().Put(ctx, install(f), dir, address)
-	}
+	err = rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
 	return err
 }
 
@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -104,6 +105,14 @@ type fakeFileIO struct {
 	mkdir          error
 	stat           os.FileInfo
 	statErr        error
+
+	// Sidecar metadata files are opened and removed separately
+	metadata        fileIOFile
+	metadataRemoved int
+}
+
+func isMetadataPath(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), MetadataPrefix)
 }
 
 func (f *fakeFileIO) Stat(name string) (os.FileInfo, error) {
@@ -115,6 +124,12 @@ func (f *fakeFileIO) MkdirAll(name string, perm os.FileMode) error {
 }
 
 func (f *fakeFileIO) Open(name string) (fileIOFile, error) {
+	if isMetadataPath(name) {
+		if f.metadata == nil {
+			return nil, os.ErrNotExist
+		}
+		return f.metadata, nil
+	}
 	return f.open, f.openErr
 }
 
@@ -131,6 +146,10 @@ func (f *fakeFileIO) Move(stagedAt, permanent string) error {
 }
 
 func (f *fakeFileIO) Remove(location string) error {
+	if isMetadataPath(location) {
+		f.metadataRemoved++
+		return nil
+	}
 	f.removed++
 	return f.remove
 }
@@ -380,6 +399,61 @@ func (s *FileStorageServerSuite) TestGetChunked(c *check.C) {
 	c.Check(mod, check.DeepEquals, now)
 }
 
+func (s *FileStorageServerSuite) TestGetRange(c *check.C) {
+	ctx := context.Background()
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	server := NewStorageServer(StorageServerArgs{
+		Dir:       s.tempDirHelper.Dir(),
+		ChunkSize: 352,
+		Waiter:    wn,
+		Notifier:  wn,
+	})
+
+	resolve := func(w io.Writer) (string, string, error) {
+		buf := bytes.NewBufferString(servertest.TestDESC)
+		_, err := io.Copy(w, buf)
+		return "", "", err
+	}
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err := server.Put(ctx, resolve, "dir", "file")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, resolve, "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+
+	// Missing
+	r, _, _, _, ok, err := rsstorage.GetRange(ctx, server, "dir", "missing", 0, 10)
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+
+	for _, address := range []string{"file", "chunked"} {
+		// A range within a single chunk, a range spanning several chunks,
+		// and a range through the end of the item
+		for _, rng := range [][]int64{{10, 20}, {300, 800}, {1900, -1}, {0, -1}, {1953, -1}} {
+			r, ch, size, _, ok, err := rsstorage.GetRange(ctx, server, "dir", address, rng[0], rng[1])
+			c.Assert(err, check.IsNil)
+			c.Assert(ok, check.Equals, true)
+			c.Check(size, check.Equals, int64(sz))
+			c.Check(ch == nil, check.Equals, address == "file")
+			b, err := io.ReadAll(r)
+			c.Assert(err, check.IsNil)
+			c.Assert(r.Close(), check.IsNil)
+			end := int64(sz)
+			if rng[1] >= 0 {
+				end = rng[0] + rng[1]
+			}
+			c.Check(string(b), check.Equals, servertest.TestDESC[rng[0]:end])
+		}
+
+		// Invalid range
+		_, _, _, _, ok, err = rsstorage.GetRange(ctx, server, "dir", address, 2000, 10)
+		c.Check(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+		c.Check(ok, check.Equals, false)
+	}
+}
+
 func (s *FileStorageServerSuite) TestFlushFails(c *check.C) {
 	server := &StorageServer{
 		fileIO: &fakeFileIO{
@@ -904,6 +978,113 @@ func (s *FileEnumerationSuite) TestEnumerate(c *check.C) {
 	})
 }
 
+func (s *FileEnumerationSuite) TestEnumerateItems(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		dir:    s.tempDirHelper.Dir(),
+		fileIO: &defaultFileIO{},
+	}
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	server.chunker = &internal.DefaultChunkUtils{
+		ChunkSize: 352,
+		Server:    server,
+		Waiter:    wn,
+		Notifier:  wn,
+	}
+
+	createTempFile(server.dir, "PACKAGES", "some data", c)
+	createTempFile(filepath.Join(server.dir, "af"), "data.json", "{\"val\":\"test\"}", c)
+	createTempFile(filepath.Join(server.dir, "af/test"), "data2.json", "{\"val\":\"test2\"}", c)
+	createTempFile(filepath.Join(server.dir, "af.b"), "data.json", "{}", c)
+
+	resolve := func(w io.Writer) (string, string, error) {
+		buf := bytes.NewBufferString(servertest.TestDESC)
+		_, err := io.Copy(w, buf)
+		return "", "", err
+	}
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err := server.PutChunked(ctx, resolve, "dir", "DESCRIPTION", sz)
+	c.Assert(err, check.IsNil)
+
+	list := func(opts types.EnumerateOptions) []types.StoredItem {
+		items := make([]types.StoredItem, 0)
+		for item, err := range server.EnumerateItems(ctx, opts) {
+			c.Assert(err, check.IsNil)
+			c.Check(item.ModTime.IsZero(), check.Equals, false)
+			item.ModTime = time.Time{}
+			items = append(items, item)
+		}
+		return items
+	}
+
+	items := list(types.EnumerateOptions{})
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Address: "PACKAGES", Size: 9, Token: "PACKAGES"},
+		{Dir: "af", Address: "data.json", Size: 14, Token: "af/data.json"},
+		{Dir: "af/test", Address: "data2.json", Size: 15, Token: "af/test/data2.json"},
+		{Dir: "af.b", Address: "data.json", Size: 2, Token: "af.b/data.json"},
+		{Dir: "dir", Address: "DESCRIPTION", Chunked: true, Size: int64(sz), Token: "dir/DESCRIPTION/info.json"},
+	})
+
+	// Resumed after each item
+	for i, item := range items {
+		c.Check(list(types.EnumerateOptions{After: item.Token}), check.DeepEquals, items[i+1:])
+	}
+
+	c.Check(list(types.EnumerateOptions{Prefix: "af/"}), check.DeepEquals, items[1:3])
+	c.Check(list(types.EnumerateOptions{Prefix: "af"}), check.DeepEquals, items[1:4])
+	c.Check(list(types.EnumerateOptions{Prefix: "dir/DESC"}), check.DeepEquals, items[4:])
+	c.Check(list(types.EnumerateOptions{Prefix: "af/", After: "af/data.json"}), check.DeepEquals, items[2:3])
+
+	// Canceled
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, err := range server.EnumerateItems(canceled, types.EnumerateOptions{}) {
+		c.Check(err, check.Equals, context.Canceled)
+	}
+}
+
+func (s *FileEnumerationSuite) TestRemoveStaging(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		dir:    s.tempDirHelper.Dir(),
+		fileIO: &defaultFileIO{},
+	}
+	old := time.Now().Add(-time.Hour)
+
+	createTempFile(server.dir, "PACKAGES", "some data", c)
+	createTempFile(server.dir, StagingPrefix+"1", "abandoned", c)
+	c.Assert(os.Chtimes(filepath.Join(server.dir, StagingPrefix+"1"), old, old), check.IsNil)
+	createTempFile(server.dir, StagingPrefix+"2", "writing", c)
+	createTempFile(filepath.Join(server.dir, "dir"), StagingPrefix+"3", "not staging", c)
+	c.Assert(os.Chtimes(filepath.Join(server.dir, "dir", StagingPrefix+"3"), old, old), check.IsNil)
+
+	// Staging files are not enumerated
+	en, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(en, check.DeepEquals, []types.StoredItem{
+		{Address: "PACKAGES"},
+		{Dir: "dir", Address: StagingPrefix + "3"},
+	})
+	var addresses []string
+	for item, err := range server.EnumerateItems(ctx, types.EnumerateOptions{}) {
+		c.Assert(err, check.IsNil)
+		addresses = append(addresses, item.Address)
+	}
+	c.Check(addresses, check.DeepEquals, []string{"PACKAGES", StagingPrefix + "3"})
+
+	removed, reclaimed, err := server.RemoveStaging(ctx, time.Now().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 1)
+	c.Check(reclaimed, check.Equals, int64(len("abandoned")))
+	_, err = os.Stat(filepath.Join(server.dir, StagingPrefix+"1"))
+	c.Check(os.IsNotExist(err), check.Equals, true)
+	_, err = os.Stat(filepath.Join(server.dir, StagingPrefix+"2"))
+	c.Check(err, check.IsNil)
+}
+
 func (s *FileEnumerationSuite) TestEnumerateWalkTimeout(c *check.C) {
 	testFiles := 10
 	testStr := []byte("hello world")
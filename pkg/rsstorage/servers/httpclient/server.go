@@ -0,0 +1,470 @@
+package httpclient
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/httphandler"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// The longest error response body included in an error.
+const maxErrorBody = 4096
+
+// StorageServer is a storage server that stores items in a storage server
+// served by `httphandler.NewHandler` on another host.
+type StorageServer struct {
+	url          string
+	client       *http.Client
+	authenticate func(r *http.Request) error
+}
+
+type StorageServerArgs struct {
+	// URL is where the handler is served, e.g., `https://host/storage`.
+	URL string
+
+	// Client defaults to `http.DefaultClient`.
+	Client *http.Client
+
+	// Authenticate is optional. When set, it is called to add credentials
+	// to each request.
+	Authenticate func(r *http.Request) error
+}
+
+func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
+	s := &StorageServer{
+		url:          strings.TrimSuffix(args.URL, "/"),
+		client:       args.Client,
+		authenticate: args.Authenticate,
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	return s
+}
+
+func (s *StorageServer) newRequest(ctx context.Context, method, p string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := s.url + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if s.authenticate != nil {
+		if err = s.authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// do sends a request, and returns an error for responses that do not have
+// one of the `ok` statuses.
+func (s *StorageServer) do(req *http.Request, ok ...int) (*http.Response, error) {
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range ok {
+		if rsp.StatusCode == status {
+			return rsp, nil
+		}
+	}
+	defer rsp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(rsp.Body, maxErrorBody))
+	err = fmt.Errorf("unexpected response %s from %s %s: %s", rsp.Status, req.Method, req.URL.Path, strings.TrimSpace(string(b)))
+	if rsp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		err = fmt.Errorf("%w: %w", rsstorage.ErrInvalidRange, err)
+	} else if rsp.StatusCode == http.StatusNotImplemented {
+		err = fmt.Errorf("%w: %w", rsstorage.ErrMetadataNotSupported, err)
+	}
+	return nil, err
+}
+
+func objectQuery(dir, address string) url.Values {
+	return url.Values{
+		httphandler.ParamDir:     {dir},
+		httphandler.ParamAddress: {address},
+	}
+}
+
+// readHeaders reads the description of an item from response headers.
+func readHeaders(h http.Header) (*types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	var chunked *types.ChunksInfo
+	var sz int64
+	var mod time.Time
+	var meta types.Metadata
+	var err error
+	if v := h.Get(httphandler.HeaderSize); v != "" {
+		if sz, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, 0, time.Time{}, nil, fmt.Errorf("invalid size header: %w", err)
+		}
+	}
+	if v := h.Get(httphandler.HeaderModified); v != "" {
+		if mod, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, 0, time.Time{}, nil, fmt.Errorf("invalid modified header: %w", err)
+		}
+	}
+	if v := h.Get(httphandler.HeaderChunks); v != "" {
+		chunked = &types.ChunksInfo{}
+		if err = json.Unmarshal([]byte(v), chunked); err != nil {
+			return nil, 0, time.Time{}, nil, fmt.Errorf("invalid chunks header: %w", err)
+		}
+	}
+	if v := h.Get(httphandler.HeaderMetadata); v != "" {
+		if err = json.Unmarshal([]byte(v), &meta); err != nil {
+			return nil, 0, time.Time{}, nil, fmt.Errorf("invalid metadata header: %w", err)
+		}
+	}
+	return chunked, sz, mod, meta, nil
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, httphandler.PathObject, objectQuery(dir, address), nil)
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	rsp, err := s.do(req, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return false, nil, 0, time.Time{}, nil, nil
+	}
+	chunked, sz, mod, meta, err := readHeaders(rsp.Header)
+	if err != nil {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	return true, chunked, sz, mod, meta, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return "http:" + s.url
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return rsstorage.StorageTypeHTTP
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	req, err := s.newRequest(context.Background(), http.MethodGet, httphandler.PathUsage, nil, nil)
+	if err != nil {
+		return types.Usage{}, err
+	}
+	rsp, err := s.do(req, http.StatusOK)
+	if err != nil {
+		return types.Usage{}, err
+	}
+	defer rsp.Body.Close()
+	var usage types.Usage
+	if err = json.NewDecoder(rsp.Body).Decode(&usage); err != nil {
+		return types.Usage{}, fmt.Errorf("error decoding usage: %w", err)
+	}
+	return usage, nil
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	return s.get(ctx, objectQuery(dir, address))
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	query := objectQuery(dir, address)
+	query.Set(httphandler.ParamOffset, strconv.FormatInt(offset, 10))
+	query.Set(httphandler.ParamLength, strconv.FormatInt(length, 10))
+	r, chunked, sz, mod, _, ok, err := s.get(ctx, query)
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) get(ctx context.Context, query url.Values) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, httphandler.PathObject, query, nil)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	rsp, err := s.do(req, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	if rsp.StatusCode == http.StatusNotFound {
+		rsp.Body.Close()
+		return nil, nil, 0, time.Time{}, nil, false, nil
+	}
+	chunked, sz, mod, meta, err := readHeaders(rsp.Header)
+	if err != nil {
+		rsp.Body.Close()
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	return rsp.Body, chunked, sz, mod, meta, true, nil
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata writes an item like `Put`, and sends the metadata to the
+// remote server. `rsstorage.ErrMetadataNotSupported` is returned if the
+// remote server cannot store it.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	return s.put(ctx, resolve, objectQuery(dir, address), meta)
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	query := objectQuery(dir, address)
+	query.Set(httphandler.ParamChunked, "true")
+	query.Set(httphandler.ParamSize, strconv.FormatUint(sz, 10))
+	return s.put(ctx, resolve, query, meta)
+}
+
+// put streams the data written by `resolve` as the request body. The dir and
+// address returned by `resolve` are sent as trailers, since they are only
+// known once the data is written. `resolve` is only called once the
+// transport reads the body.
+//
+// Requests with metadata ask the remote server to accept the metadata with
+// `Expect: 100-continue` before the body is sent, so that `resolve` is not
+// called when it is rejected. Transports with no `ExpectContinueTimeout`
+// send the body right away.
+func (s *StorageServer) put(ctx context.Context, resolve types.Resolver, query url.Values, meta types.Metadata) (string, string, error) {
+	pr, pw := io.Pipe()
+	body := &startReader{PipeReader: pr}
+	req, err := s.newRequest(ctx, http.MethodPut, httphandler.PathObject, query, body)
+	if err != nil {
+		return "", "", err
+	}
+	req.Trailer = http.Header{
+		httphandler.HeaderDir:     nil,
+		httphandler.HeaderAddress: nil,
+	}
+	if len(meta) > 0 {
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return "", "", err
+		}
+		req.Header.Set(httphandler.HeaderMetadata, string(b))
+		req.Header.Set("Expect", "100-continue")
+	}
+
+	resolveErr := make(chan error, 1)
+	body.start = func() {
+		go func() {
+			// Trailers may be set, since the transport reads them at the end
+			// of the body
+			dir, address, err := resolve(pw)
+			if err == nil {
+				req.Trailer.Set(httphandler.HeaderDir, dir)
+				req.Trailer.Set(httphandler.HeaderAddress, address)
+			}
+			resolveErr <- err
+			pw.CloseWithError(err)
+		}()
+	}
+
+	rsp, err := s.do(req, http.StatusOK)
+	if !body.stop() {
+		// The body was not sent
+		pr.Close()
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		if err != nil {
+			// Stop `resolve` if the request failed before the body was sent
+			pr.CloseWithError(err)
+		}
+		if rErr := <-resolveErr; rErr != nil {
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+			return "", "", rErr
+		} else if err != nil {
+			return "", "", err
+		}
+	}
+	defer rsp.Body.Close()
+
+	var result httphandler.PutResult
+	if err = json.NewDecoder(rsp.Body).Decode(&result); err != nil {
+		return "", "", fmt.Errorf("error decoding put response: %w", err)
+	}
+	return result.Dir, result.Address, nil
+}
+
+// startReader calls `start` when it is first read, unless it was stopped.
+type startReader struct {
+	*io.PipeReader
+	start func()
+
+	mutex   sync.Mutex
+	started bool
+	stopped bool
+}
+
+func (r *startReader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	if !r.started && !r.stopped {
+		r.started = true
+		r.start()
+	}
+	r.mutex.Unlock()
+	return r.PipeReader.Read(p)
+}
+
+// stop keeps `start` from being called, and reports whether it was called.
+func (r *startReader) stop() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+	return r.started
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, httphandler.PathObject, objectQuery(dir, address), nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := s.do(req, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return rsp.Body.Close()
+}
+
+// Flush asks the remote server to flush an item. Errors are ignored, since
+// `Flush` does not return them.
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	req, err := s.newRequest(ctx, http.MethodPost, httphandler.PathFlush, objectQuery(dir, address), nil)
+	if err != nil {
+		return
+	}
+	rsp, err := s.do(req, http.StatusNoContent)
+	if err != nil {
+		return
+	}
+	rsp.Body.Close()
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	items := make([]types.StoredItem, 0)
+	for item, err := range s.EnumerateItems(ctx, types.EnumerateOptions{}) {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, types.StoredItem{
+			Dir:     item.Dir,
+			Address: item.Address,
+			Chunked: item.Chunked,
+		})
+	}
+	return items, nil
+}
+
+// EnumerateItems streams the items of the remote server as they are
+// enumerated there. Tokens are the remote server's tokens.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		query := url.Values{}
+		if opts.Prefix != "" {
+			query.Set(httphandler.ParamPrefix, opts.Prefix)
+		}
+		if opts.After != "" {
+			query.Set(httphandler.ParamAfter, opts.After)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, httphandler.PathItems, query, nil)
+		if err != nil {
+			yield(types.StoredItem{}, err)
+			return
+		}
+		rsp, err := s.do(req, http.StatusOK)
+		if err != nil {
+			yield(types.StoredItem{}, err)
+			return
+		}
+		defer rsp.Body.Close()
+
+		dec := json.NewDecoder(bufio.NewReader(rsp.Body))
+		for {
+			var line httphandler.EnumerateLine
+			err = dec.Decode(&line)
+			if errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				yield(types.StoredItem{}, fmt.Errorf("error decoding enumeration: %w", err))
+				return
+			} else if line.Error != "" {
+				yield(types.StoredItem{}, errors.New(line.Error))
+				return
+			}
+			if !yield(line.StoredItem, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err2 := io.Copy(writer, file)
+			return "", "", err2
+		}
+	}
+
+	return rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.url + httphandler.PathObject + "?" + objectQuery(dir, address).Encode()
+}
+
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
@@ -0,0 +1,285 @@
+package httpclient
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/httphandler"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type HttpClientStorageServerSuite struct {
+	remote rsstorage.StorageServer
+	http   *httptest.Server
+	server rsstorage.StorageServer
+}
+
+var _ = check.Suite(&HttpClientStorageServerSuite{})
+
+func (s *HttpClientStorageServerSuite) SetUpTest(c *check.C) {
+	s.remote = memtest.NewServer("remote", 4096)
+	s.http = httptest.NewServer(http.StripPrefix("/storage", httphandler.NewHandler(httphandler.HandlerArgs{
+		Server: s.remote,
+		Authorize: func(r *http.Request, op httphandler.Operation) error {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				return errors.New("not authorized")
+			}
+			return nil
+		},
+	})))
+	s.server = NewStorageServer(StorageServerArgs{
+		URL: s.http.URL + "/storage/",
+		Authenticate: func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer token")
+			return nil
+		},
+	})
+}
+
+func (s *HttpClientStorageServerSuite) TearDownTest(c *check.C) {
+	s.http.Close()
+}
+
+func stringResolver(dir, address, data string) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewBufferString(data))
+		return dir, address, err
+	}
+}
+
+func (s *HttpClientStorageServerSuite) TestNew(c *check.C) {
+	c.Check(s.server.Base(), check.Equals, s.server)
+	c.Check(s.server.Type(), check.Equals, rsstorage.StorageTypeHTTP)
+	c.Check(s.server.Dir(), check.Equals, "http:"+s.http.URL+"/storage")
+	c.Check(s.server.Locate("dir", "address"), check.Equals, s.http.URL+"/storage/object?address=address&dir=dir")
+}
+
+func (s *HttpClientStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	dir, address, err := s.server.Put(ctx, stringResolver("", "", "some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(dir, check.Equals, "dir")
+	c.Check(address, check.Equals, "address")
+
+	// The address can be chosen by the resolver
+	dir, address, err = s.server.Put(ctx, stringResolver("other", "resolved", "other data"), "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(dir, check.Equals, "other")
+	c.Check(address, check.Equals, "resolved")
+	ok, _, _, _, err := s.remote.Check(ctx, "other", "resolved")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+
+	ok, chunked, sz, mod, err := s.server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.IsNil)
+	c.Check(sz, check.Equals, int64(9))
+	_, _, _, remoteMod, err := s.remote.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(mod.Equal(remoteMod), check.Equals, true)
+
+	ok, _, _, _, err = s.server.Check(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	r, _, sz, _, ok, err := s.server.Get(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(9))
+	c.Check(servertest.ReadAll(c, r), check.Equals, "some data")
+
+	_, _, _, _, ok, err = s.server.Get(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	r, _, _, _, ok, err = rsstorage.GetRange(ctx, s.server, "dir", "address", 5, -1)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "data")
+
+	_, _, _, _, _, err = rsstorage.GetRange(ctx, s.server, "dir", "address", 20, 1)
+	c.Check(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+
+	c.Assert(s.server.Remove(ctx, "dir", "address"), check.IsNil)
+	ok, _, _, _, err = s.remote.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *HttpClientStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	data := servertest.TestDESC
+	_, _, err := s.server.PutChunked(ctx, stringResolver("", "", data), "dir", "", uint64(len(data)))
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+
+	dir, address, err := s.server.PutChunked(ctx, stringResolver("", "", data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	c.Check(dir, check.Equals, "dir")
+	c.Check(address, check.Equals, "chunked")
+
+	r, chunked, sz, _, ok, err := s.server.Get(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(chunked, check.NotNil)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(chunked.FileSize, check.Equals, uint64(len(data)))
+	c.Check(sz, check.Equals, int64(len(data)))
+	c.Check(servertest.ReadAll(c, r), check.Equals, data)
+}
+
+func (s *HttpClientStorageServerSuite) TestPutError(c *check.C) {
+	ctx := context.Background()
+	_, _, err := s.server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, "partial")
+		c.Assert(err, check.IsNil)
+		return "", "", errors.New("resolve error")
+	}, "dir", "address")
+	c.Check(err, check.ErrorMatches, "resolve error")
+	ok, _, _, _, err := s.remote.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *HttpClientStorageServerSuite) TestEnumerate(c *check.C) {
+	ctx := context.Background()
+	for _, address := range []string{"1", "2", "3"} {
+		_, _, err := s.server.Put(ctx, stringResolver("", "", "data "+address), "dir", address)
+		c.Assert(err, check.IsNil)
+	}
+	_, _, err := s.server.PutChunked(ctx, stringResolver("", "", "chunked"), "other", "chunked", 7)
+	c.Assert(err, check.IsNil)
+
+	items, err := s.server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Dir: "dir", Address: "1"},
+		{Dir: "dir", Address: "2"},
+		{Dir: "dir", Address: "3"},
+		{Dir: "other", Address: "chunked", Chunked: true},
+	})
+
+	var addresses []string
+	var token string
+	for item, err := range rsstorage.EnumerateItems(ctx, s.server, types.EnumerateOptions{Prefix: "dir/"}) {
+		c.Assert(err, check.IsNil)
+		c.Check(item.Size, check.Equals, int64(6))
+		addresses = append(addresses, item.Address)
+		if item.Address == "1" {
+			token = item.Token
+		}
+	}
+	c.Check(addresses, check.DeepEquals, []string{"1", "2", "3"})
+
+	addresses = nil
+	for item, err := range rsstorage.EnumerateItems(ctx, s.server, types.EnumerateOptions{After: token}) {
+		c.Assert(err, check.IsNil)
+		addresses = append(addresses, item.Address)
+	}
+	c.Check(addresses, check.DeepEquals, []string{"2", "3", "chunked"})
+}
+
+func (s *HttpClientStorageServerSuite) TestUsage(c *check.C) {
+	_, _, err := s.server.Put(context.Background(), stringResolver("", "", "some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	usage, err := s.server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	expected, err := s.remote.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, expected.UsedBytes)
+}
+
+func (s *HttpClientStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	local := memtest.NewServer("local", 4096)
+	_, _, err := s.server.Put(ctx, stringResolver("", "", "some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(s.server.Copy(ctx, "dir", "address", local), check.IsNil)
+	ok, _, _, _, err := local.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+
+	c.Assert(s.server.Move(ctx, "dir", "address", local), check.IsNil)
+	ok, _, _, _, err = s.remote.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	err = s.server.Copy(ctx, "dir", "missing", local)
+	c.Check(err, check.ErrorMatches, "the object with dir=dir and address=missing to copy does not exist")
+}
+
+func (s *HttpClientStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	server := s.server.(rsstorage.ObjectMetadataServer)
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	_, _, err := server.PutWithMetadata(ctx, stringResolver("", "", "some data"), "dir", "address", meta)
+	c.Assert(err, check.IsNil)
+	data := servertest.TestData(5000)
+	_, _, err = server.PutChunkedWithMetadata(ctx, stringResolver("", "", data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	for _, address := range []string{"address", "chunked"} {
+		_, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, s.remote, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(got, check.DeepEquals, meta)
+
+		ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Check(ok, check.Equals, true)
+		c.Check(got, check.DeepEquals, meta)
+		r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		r.Close()
+		c.Check(got, check.DeepEquals, meta)
+	}
+
+	// Metadata is copied
+	local := memtest.NewServer("local", 4096)
+	c.Assert(s.server.Copy(ctx, "dir", "chunked", local), check.IsNil)
+	_, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, local, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// A remote server that cannot store metadata rejects it before the item
+	// is resolved, so a copy is written again without it
+	dummy := &rsstorage.DummyStorageServer{}
+	h := httptest.NewServer(httphandler.NewHandler(httphandler.HandlerArgs{Server: dummy}))
+	defer h.Close()
+	plain := NewStorageServer(StorageServerArgs{URL: h.URL})
+	resolved := false
+	_, _, err = plain.(rsstorage.ObjectMetadataServer).PutWithMetadata(ctx, func(w io.Writer) (string, string, error) {
+		resolved = true
+		return "", "", nil
+	}, "dir", "address", meta)
+	c.Check(errors.Is(err, rsstorage.ErrMetadataNotSupported), check.Equals, true)
+	c.Check(resolved, check.Equals, false)
+	c.Assert(s.server.Copy(ctx, "dir", "address", plain), check.IsNil)
+	c.Check(dummy.Placed, check.DeepEquals, []string{"dir-some data"})
+}
+
+func (s *HttpClientStorageServerSuite) TestUnauthorized(c *check.C) {
+	server := NewStorageServer(StorageServerArgs{URL: s.http.URL + "/storage"})
+	_, _, _, _, err := server.Check(context.Background(), "dir", "address")
+	c.Check(err, check.ErrorMatches, "unexpected response 403 Forbidden from HEAD /storage/object: ")
+	_, _, err = server.Put(context.Background(), stringResolver("", "", "some data"), "dir", "address")
+	c.Check(err, check.ErrorMatches, "unexpected response 403 Forbidden from PUT /storage/object: not authorized")
+	for _, err = range rsstorage.EnumerateItems(context.Background(), server, types.EnumerateOptions{}) {
+		c.Check(err, check.ErrorMatches, "unexpected response 403 Forbidden from GET /storage/items: not authorized")
+	}
+}
@@ -0,0 +1,290 @@
+package instrumented
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Operation names a storage server operation in metrics.
+type Operation string
+
+const (
+	OperationCheck          Operation = "check"
+	OperationGet            Operation = "get"
+	OperationGetRange       Operation = "get_range"
+	OperationPut            Operation = "put"
+	OperationPutChunked     Operation = "put_chunked"
+	OperationRemove         Operation = "remove"
+	OperationEnumerate      Operation = "enumerate"
+	OperationMove           Operation = "move"
+	OperationCopy           Operation = "copy"
+	OperationCalculateUsage Operation = "calculate_usage"
+)
+
+// Metrics records the metrics of a storage server. Implement it to report
+// the metrics to a backend, e.g., as Prometheus histograms and counters.
+// Each metric is labeled with the type of the wrapped storage server.
+type Metrics interface {
+	// ObserveLatency records the duration of an operation. For `Get` and
+	// `GetRange`, this is the time to open the item, not to read it.
+	ObserveLatency(storageType types.StorageType, op Operation, d time.Duration)
+
+	// AddBytesRead records bytes read from an item by `Get` or `GetRange`.
+	AddBytesRead(storageType types.StorageType, op Operation, n int64)
+
+	// AddBytesWritten records bytes written to an item by `Put` or
+	// `PutChunked`.
+	AddBytesWritten(storageType types.StorageType, op Operation, n int64)
+
+	// Lookup records whether `Check` or `Get` found an item.
+	Lookup(storageType types.StorageType, op Operation, hit bool)
+
+	// Error records an operation that failed, including reads that fail
+	// after an item is opened.
+	Error(storageType types.StorageType, op Operation)
+}
+
+// StorageServer is a storage server that records metrics for each operation
+// on another storage server.
+type StorageServer struct {
+	server  rsstorage.StorageServer
+	metrics Metrics
+}
+
+type StorageServerArgs struct {
+	Server  rsstorage.StorageServer
+	Metrics Metrics
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		server:  args.Server,
+		metrics: args.Metrics,
+	}
+}
+
+// observe records the latency of an operation that started at `start`, and
+// whether it failed.
+func (s *StorageServer) observe(op Operation, start time.Time, err error) {
+	storageType := s.server.Type()
+	s.metrics.ObserveLatency(storageType, op, time.Since(start))
+	if err != nil {
+		s.metrics.Error(storageType, op)
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	start := time.Now()
+	ok, chunked, sz, mod, err := s.server.Check(ctx, dir, address)
+	s.observe(OperationCheck, start, err)
+	if err == nil {
+		s.metrics.Lookup(s.server.Type(), OperationCheck, ok)
+	}
+	return ok, chunked, sz, mod, err
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	start := time.Now()
+	usage, err := s.server.CalculateUsage()
+	s.observe(OperationCalculateUsage, start, err)
+	return usage, err
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	start := time.Now()
+	r, chunked, sz, mod, ok, err := s.server.Get(ctx, dir, address)
+	s.observe(OperationGet, start, err)
+	if err == nil {
+		s.metrics.Lookup(s.server.Type(), OperationGet, ok)
+	}
+	if ok && err == nil {
+		r = s.reader(r, OperationGet)
+	}
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	start := time.Now()
+	r, chunked, sz, mod, ok, err := rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+	s.observe(OperationGetRange, start, err)
+	if ok && err == nil {
+		r = s.reader(r, OperationGetRange)
+	}
+	return r, chunked, sz, mod, ok, err
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	start := time.Now()
+	d, a, err := s.server.Put(ctx, s.resolver(resolve, OperationPut), dir, address)
+	s.observe(OperationPut, start, err)
+	return d, a, err
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	start := time.Now()
+	d, a, err := s.server.PutChunked(ctx, s.resolver(resolve, OperationPutChunked), dir, address, sz)
+	s.observe(OperationPutChunked, start, err)
+	return d, a, err
+}
+
+// PutWithMetadata writes an item with metadata, and records it as a `Put`.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	start := time.Now()
+	d, a, err := rsstorage.PutWithMetadata(ctx, s.server, s.resolver(resolve, OperationPut), dir, address, meta)
+	s.observe(OperationPut, start, err)
+	return d, a, err
+}
+
+// PutChunkedWithMetadata writes a chunked item with metadata, and records it
+// as a `PutChunked`.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	start := time.Now()
+	d, a, err := rsstorage.PutChunkedWithMetadata(ctx, s.server, s.resolver(resolve, OperationPutChunked), dir, address, sz, meta)
+	s.observe(OperationPutChunked, start, err)
+	return d, a, err
+}
+
+// CheckWithMetadata checks for an item and returns its metadata, and records
+// it as a `Check`.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	start := time.Now()
+	ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+	s.observe(OperationCheck, start, err)
+	if err == nil {
+		s.metrics.Lookup(s.server.Type(), OperationCheck, ok)
+	}
+	return ok, chunked, sz, mod, meta, err
+}
+
+// GetWithMetadata gets an item and its metadata, and records it as a `Get`.
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	start := time.Now()
+	r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+	s.observe(OperationGet, start, err)
+	if err == nil {
+		s.metrics.Lookup(s.server.Type(), OperationGet, ok)
+	}
+	if ok && err == nil {
+		r = s.reader(r, OperationGet)
+	}
+	return r, chunked, sz, mod, meta, ok, err
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	start := time.Now()
+	err := s.server.Remove(ctx, dir, address)
+	s.observe(OperationRemove, start, err)
+	return err
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	start := time.Now()
+	items, err := s.server.Enumerate(ctx)
+	s.observe(OperationEnumerate, start, err)
+	return items, err
+}
+
+// EnumerateItems streams the items of the wrapped server. The latency is the
+// time until the enumeration ends.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		start := time.Now()
+		var err error
+		defer func() {
+			s.observe(OperationEnumerate, start, err)
+		}()
+		for item, itemErr := range rsstorage.EnumerateItems(ctx, s.server, opts) {
+			err = itemErr
+			if !yield(item, itemErr) {
+				return
+			}
+		}
+	}
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	start := time.Now()
+	err := s.server.Move(ctx, dir, address, server)
+	s.observe(OperationMove, start, err)
+	return err
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	start := time.Now()
+	err := s.server.Copy(ctx, dir, address, server)
+	s.observe(OperationCopy, start, err)
+	return err
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, so that items copied into it are recorded.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// resolver counts the bytes written by `resolve`.
+func (s *StorageServer) resolver(resolve types.Resolver, op Operation) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		cw := &countingWriter{w: w}
+		d, a, err := resolve(cw)
+		s.metrics.AddBytesWritten(s.server.Type(), op, cw.n)
+		return d, a, err
+	}
+}
+
+// reader counts the bytes read from `r`, and records read errors.
+func (s *StorageServer) reader(r io.ReadCloser, op Operation) io.ReadCloser {
+	return &countingReader{ReadCloser: r, server: s, op: op}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	io.ReadCloser
+	server *StorageServer
+	op     Operation
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	storageType := r.server.server.Type()
+	if n > 0 {
+		r.server.metrics.AddBytesRead(storageType, r.op, int64(n))
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.server.metrics.Error(storageType, r.op)
+	}
+	return n, err
+}
@@ -0,0 +1,260 @@
+package instrumented
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type InstrumentedStorageServerSuite struct{}
+
+var _ = check.Suite(&InstrumentedStorageServerSuite{})
+
+type fakeMetrics struct {
+	mutex   sync.Mutex
+	latency map[Operation]int
+	read    map[Operation]int64
+	written map[Operation]int64
+	hits    map[Operation]int
+	misses  map[Operation]int
+	errors  map[Operation]int
+	types   map[types.StorageType]bool
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{
+		latency: make(map[Operation]int),
+		read:    make(map[Operation]int64),
+		written: make(map[Operation]int64),
+		hits:    make(map[Operation]int),
+		misses:  make(map[Operation]int),
+		errors:  make(map[Operation]int),
+		types:   make(map[types.StorageType]bool),
+	}
+}
+
+func (m *fakeMetrics) ObserveLatency(storageType types.StorageType, op Operation, d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.types[storageType] = true
+	m.latency[op]++
+}
+
+func (m *fakeMetrics) AddBytesRead(storageType types.StorageType, op Operation, n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.read[op] += n
+}
+
+func (m *fakeMetrics) AddBytesWritten(storageType types.StorageType, op Operation, n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.written[op] += n
+}
+
+func (m *fakeMetrics) Lookup(storageType types.StorageType, op Operation, hit bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if hit {
+		m.hits[op]++
+	} else {
+		m.misses[op]++
+	}
+}
+
+func (m *fakeMetrics) Error(storageType types.StorageType, op Operation) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.errors[op]++
+}
+
+func (s *InstrumentedStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("instrumented", 4096)
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Metrics: newFakeMetrics()})
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://instrumented/dir/address")
+}
+
+func (s *InstrumentedStorageServerSuite) TestMetrics(c *check.C) {
+	ctx := context.Background()
+	metrics := newFakeMetrics()
+	server := NewStorageServer(StorageServerArgs{
+		Server:  memtest.NewServer("instrumented", 4096),
+		Metrics: metrics,
+	})
+	data := servertest.TestDESC
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)))
+	c.Assert(err, check.IsNil)
+	c.Check(metrics.written, check.DeepEquals, map[Operation]int64{
+		OperationPut:        9,
+		OperationPutChunked: int64(len(data)),
+	})
+
+	ok, _, _, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	_, _, _, _, err = server.Check(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+
+	r, _, _, _, ok, err := server.Get(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(servertest.ReadAll(c, r), check.Equals, data)
+	_, _, _, _, ok, err = server.Get(ctx, "dir", "missing")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	r, _, _, _, ok, err = server.GetRange(ctx, "dir", "address", 5, 4)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "data")
+
+	c.Check(metrics.read, check.DeepEquals, map[Operation]int64{
+		OperationGet:      int64(len(data)),
+		OperationGetRange: 4,
+	})
+	c.Check(metrics.hits, check.DeepEquals, map[Operation]int{OperationCheck: 1, OperationGet: 1})
+	c.Check(metrics.misses, check.DeepEquals, map[Operation]int{OperationCheck: 1, OperationGet: 1})
+
+	dest := memtest.NewServer("dest", 4096)
+	c.Assert(server.Copy(ctx, "dir", "address", dest), check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "chunked", dest), check.IsNil)
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	_, err = server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	for item, err := range server.EnumerateItems(ctx, types.EnumerateOptions{}) {
+		c.Assert(err, check.IsNil)
+		c.Check(item.Address, check.Equals, "address")
+	}
+	_, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+
+	c.Check(metrics.latency, check.DeepEquals, map[Operation]int{
+		OperationPut:            1,
+		OperationPutChunked:     1,
+		OperationCheck:          2,
+		OperationGet:            2,
+		OperationGetRange:       1,
+		OperationCopy:           1,
+		OperationMove:           1,
+		OperationRemove:         1,
+		OperationEnumerate:      2,
+		OperationCalculateUsage: 1,
+	})
+	c.Check(metrics.types, check.DeepEquals, map[types.StorageType]bool{rsstorage.StorageTypeMemory: true})
+	c.Check(metrics.errors, check.HasLen, 0)
+}
+
+func (s *InstrumentedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	metrics := newFakeMetrics()
+	server := NewStorageServer(StorageServerArgs{
+		Server:  memtest.NewServer("instrumented", 4096),
+		Metrics: metrics,
+	})
+	data := servertest.TestDESC
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	_, _, err := rsstorage.PutWithMetadata(ctx, server, servertest.StringResolver("some data"), "dir", "address", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+
+	ok, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, server, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	r, _, _, _, got, ok, err := rsstorage.GetWithMetadata(ctx, server, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, data)
+
+	c.Check(metrics.written, check.DeepEquals, map[Operation]int64{
+		OperationPut:        9,
+		OperationPutChunked: int64(len(data)),
+	})
+	c.Check(metrics.read, check.DeepEquals, map[Operation]int64{OperationGet: int64(len(data))})
+	c.Check(metrics.hits, check.DeepEquals, map[Operation]int{OperationCheck: 1, OperationGet: 1})
+	c.Check(metrics.latency, check.DeepEquals, map[Operation]int{
+		OperationPut:        1,
+		OperationPutChunked: 1,
+		OperationCheck:      1,
+		OperationGet:        1,
+	})
+}
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func (errReader) Close() error {
+	return nil
+}
+
+func (s *InstrumentedStorageServerSuite) TestErrors(c *check.C) {
+	ctx := context.Background()
+	metrics := newFakeMetrics()
+	underlying := &rsstorage.DummyStorageServer{
+		MockType:  rsstorage.StorageTypeFile,
+		GetErr:    errors.New("get error"),
+		PutErr:    errors.New("put error"),
+		RemoveErr: errors.New("remove error"),
+		EnumErr:   errors.New("enumerate error"),
+	}
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Metrics: metrics})
+
+	_, _, _, _, err := server.Check(ctx, "dir", "address")
+	c.Check(err, check.ErrorMatches, "get error")
+	_, _, _, _, _, err = server.Get(ctx, "dir", "address")
+	c.Check(err, check.ErrorMatches, "get error")
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Check(err, check.ErrorMatches, "put error")
+	c.Check(server.Remove(ctx, "dir", "address"), check.ErrorMatches, "remove error")
+	_, err = server.Enumerate(ctx)
+	c.Check(err, check.ErrorMatches, "enumerate error")
+	for _, err := range server.EnumerateItems(ctx, types.EnumerateOptions{}) {
+		c.Check(err, check.ErrorMatches, "enumerate error")
+	}
+
+	// Read errors
+	underlying.GetErr = nil
+	underlying.GetOk = true
+	underlying.GetReader = errReader{}
+	r, _, _, _, ok, err := server.Get(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	_, err = io.ReadAll(r)
+	c.Check(err, check.ErrorMatches, "read error")
+
+	c.Check(metrics.errors, check.DeepEquals, map[Operation]int{
+		OperationCheck:     1,
+		OperationGet:       2,
+		OperationPut:       1,
+		OperationRemove:    1,
+		OperationEnumerate: 2,
+	})
+	c.Check(metrics.types, check.DeepEquals, map[types.StorageType]bool{rsstorage.StorageTypeFile: true})
+	c.Check(metrics.hits, check.DeepEquals, map[Operation]int{OperationGet: 1})
+	c.Check(metrics.misses, check.HasLen, 0)
+}
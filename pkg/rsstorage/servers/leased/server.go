@@ -0,0 +1,200 @@
+package leased
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"path"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+const (
+	DefaultTTL          = time.Minute
+	DefaultPollInterval = time.Second
+)
+
+// StorageServer is a storage server that holds the lease on an address while
+// writing it, so that two producers never write the same address at once.
+// The lease is renewed while the write is in progress. Writes without an
+// address, which is chosen by the resolver, are not leased.
+type StorageServer struct {
+	server       rsstorage.StorageServer
+	leaser       lease.Leaser
+	ttl          time.Duration
+	pollInterval time.Duration
+	noWait       bool
+}
+
+type StorageServerArgs struct {
+	// Server is the underlying storage server.
+	Server rsstorage.StorageServer
+
+	Leaser lease.Leaser
+
+	// TTL is the time to live of leases, which are renewed every half TTL
+	// while writing. Defaults to `DefaultTTL`.
+	TTL time.Duration
+
+	// PollInterval is how often to try again to acquire a lease held by
+	// another producer. Defaults to `DefaultPollInterval`.
+	PollInterval time.Duration
+
+	// NoWait makes writes fail with `lease.ErrLeaseHeld` instead of waiting
+	// when another producer holds the lease.
+	NoWait bool
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	if args.TTL == 0 {
+		args.TTL = DefaultTTL
+	}
+	if args.PollInterval == 0 {
+		args.PollInterval = DefaultPollInterval
+	}
+	return &StorageServer{
+		server:       args.Server,
+		leaser:       args.Leaser,
+		ttl:          args.TTL,
+		pollInterval: args.PollInterval,
+		noWait:       args.NoWait,
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	return s.server.Check(ctx, dir, address)
+}
+
+func (s *StorageServer) Dir() string {
+	return s.server.Dir()
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return s.server.Type()
+}
+
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	return s.server.CalculateUsage()
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return s.server.Get(ctx, dir, address)
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return rsstorage.GetRange(ctx, s.server, dir, address, offset, length)
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (wdir string, waddress string, err error) {
+	if address == "" {
+		return s.server.Put(ctx, resolve, dir, address)
+	}
+	err = s.leased(ctx, dir, address, func(ctx context.Context) error {
+		wdir, waddress, err = s.server.Put(ctx, resolve, dir, address)
+		return err
+	})
+	return
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (wdir string, waddress string, err error) {
+	if address == "" {
+		return s.server.PutChunked(ctx, resolve, dir, address, sz)
+	}
+	err = s.leased(ctx, dir, address, func(ctx context.Context) error {
+		wdir, waddress, err = s.server.PutChunked(ctx, resolve, dir, address, sz)
+		return err
+	})
+	return
+}
+
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (wdir string, waddress string, err error) {
+	if address == "" {
+		return rsstorage.PutWithMetadata(ctx, s.server, resolve, dir, address, meta)
+	}
+	err = s.leased(ctx, dir, address, func(ctx context.Context) error {
+		wdir, waddress, err = rsstorage.PutWithMetadata(ctx, s.server, resolve, dir, address, meta)
+		return err
+	})
+	return
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (wdir string, waddress string, err error) {
+	if address == "" {
+		return rsstorage.PutChunkedWithMetadata(ctx, s.server, resolve, dir, address, sz, meta)
+	}
+	err = s.leased(ctx, dir, address, func(ctx context.Context) error {
+		wdir, waddress, err = rsstorage.PutChunkedWithMetadata(ctx, s.server, resolve, dir, address, sz, meta)
+		return err
+	})
+	return
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	return rsstorage.CheckWithMetadata(ctx, s.server, dir, address)
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	return rsstorage.GetWithMetadata(ctx, s.server, dir, address)
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	return s.server.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	s.server.Flush(ctx, dir, address)
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	return s.server.Enumerate(ctx)
+}
+
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return rsstorage.EnumerateItems(ctx, s.server, opts)
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	return s.server.Move(ctx, dir, address, server)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	return s.server.Copy(ctx, dir, address, server)
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return s.server.Locate(dir, address)
+}
+
+// Base returns this server, so that items copied into it are written under
+// a lease.
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
+
+// leased runs `write` while holding the lease on an address. The context
+// passed to `write` is cancelled if the lease is lost.
+func (s *StorageServer) leased(ctx context.Context, dir, address string, write func(ctx context.Context) error) error {
+	key := path.Join(dir, address)
+
+	var l lease.Lease
+	var err error
+	if s.noWait {
+		l, err = s.leaser.Acquire(ctx, key, s.ttl)
+	} else {
+		l, err = lease.Wait(ctx, s.leaser, key, s.ttl, s.pollInterval)
+	}
+	if err != nil {
+		return err
+	}
+	defer l.Release(context.Background())
+
+	leaseCtx, stop := lease.Keep(ctx, l, s.ttl)
+	err = write(leaseCtx)
+	return errors.Join(err, stop())
+}
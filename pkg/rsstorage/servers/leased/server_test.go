@@ -0,0 +1,251 @@
+package leased
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/lease/local"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type LeasedStorageServerSuite struct{}
+
+var _ = check.Suite(&LeasedStorageServerSuite{})
+
+// blockingResolver signals `started` and then waits for `proceed`.
+func blockingResolver(data string, started chan<- struct{}, proceed <-chan struct{}) types.Resolver {
+	return func(w io.Writer) (string, string, error) {
+		close(started)
+		<-proceed
+		_, err := io.Copy(w, bytes.NewBufferString(data))
+		return "", "", err
+	}
+}
+
+// lostLeaser grants leases that cannot be renewed.
+type lostLeaser struct{}
+
+func (l *lostLeaser) Acquire(ctx context.Context, address string, ttl time.Duration) (lease.Lease, error) {
+	return &lostLease{address: address}, nil
+}
+
+type lostLease struct {
+	address string
+}
+
+func (l *lostLease) Address() string {
+	return l.address
+}
+
+func (l *lostLease) Renew(ctx context.Context, ttl time.Duration) error {
+	return lease.ErrLeaseLost
+}
+
+func (l *lostLease) Release(ctx context.Context) error {
+	return nil
+}
+
+func (s *LeasedStorageServerSuite) TestNew(c *check.C) {
+	underlying := memtest.NewServer("leased", 4096)
+	leaser := local.NewLeaser()
+	server := NewStorageServer(StorageServerArgs{Server: underlying, Leaser: leaser})
+	c.Check(server, check.DeepEquals, &StorageServer{
+		server:       underlying,
+		leaser:       leaser,
+		ttl:          DefaultTTL,
+		pollInterval: DefaultPollInterval,
+	})
+	c.Check(server.Base(), check.Equals, server)
+	c.Check(server.Dir(), check.Equals, underlying.Dir())
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+}
+
+func (s *LeasedStorageServerSuite) TestPut(c *check.C) {
+	ctx := context.Background()
+	leaser := local.NewLeaser()
+	server := NewStorageServer(StorageServerArgs{
+		Server:       memtest.NewServer("leased", 4096),
+		Leaser:       leaser,
+		PollInterval: time.Millisecond,
+	})
+
+	dir, address, err := server.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(dir, check.Equals, "dir")
+	c.Check(address, check.Equals, "address")
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "one")
+
+	dir, address, err = server.PutChunked(ctx, servertest.StringResolver("two"), "dir", "chunked", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(dir, check.Equals, "dir")
+	c.Check(address, check.Equals, "chunked")
+	c.Check(servertest.ReadItem(c, server, "dir", "chunked"), check.Equals, "two")
+
+	// Leases are released after writing
+	for _, address := range []string{"dir/address", "dir/chunked"} {
+		l, err := leaser.Acquire(ctx, address, time.Minute)
+		c.Assert(err, check.IsNil)
+		c.Check(l.Release(ctx), check.IsNil)
+	}
+
+	// Writes without an address are not leased
+	_, err = leaser.Acquire(ctx, "dir", time.Minute)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, func(w io.Writer) (string, string, error) {
+		_, err := io.WriteString(w, "three")
+		return "dir", "resolved", err
+	}, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "resolved"), check.Equals, "three")
+}
+
+func (s *LeasedStorageServerSuite) TestPutWaits(c *check.C) {
+	ctx := context.Background()
+	server := NewStorageServer(StorageServerArgs{
+		Server:       memtest.NewServer("leased", 4096),
+		Leaser:       local.NewLeaser(),
+		PollInterval: time.Millisecond,
+	})
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		_, _, err := server.Put(ctx, blockingResolver("first", started, proceed), "dir", "address")
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error)
+	go func() {
+		_, _, err := server.Put(ctx, servertest.StringResolver("second"), "dir", "address")
+		second <- err
+	}()
+
+	// The second write waits for the first
+	select {
+	case <-second:
+		c.Fatal("the second write did not wait for the lease")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(proceed)
+	c.Assert(<-first, check.IsNil)
+	c.Assert(<-second, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "second")
+}
+
+func (s *LeasedStorageServerSuite) TestPutNoWait(c *check.C) {
+	ctx := context.Background()
+	leaser := local.NewLeaser()
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("leased", 4096),
+		Leaser: leaser,
+		NoWait: true,
+	})
+
+	l, err := leaser.Acquire(ctx, "dir/address", time.Minute)
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver("one"), "dir", "address", 3)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+
+	c.Assert(l.Release(ctx), check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Check(err, check.IsNil)
+}
+
+func (s *LeasedStorageServerSuite) TestPutLeaseLost(c *check.C) {
+	ctx := context.Background()
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("leased", 4096),
+		Leaser: &lostLeaser{},
+		TTL:    10 * time.Millisecond,
+	})
+
+	_, _, err := server.Put(ctx, func(w io.Writer) (string, string, error) {
+		time.Sleep(50 * time.Millisecond)
+		_, err := io.WriteString(w, "one")
+		return "", "", err
+	}, "dir", "address")
+	c.Check(errors.Is(err, lease.ErrLeaseLost), check.Equals, true)
+}
+
+func (s *LeasedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	leaser := local.NewLeaser()
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("leased", 4096),
+		Leaser: leaser,
+		NoWait: true,
+	})
+	meta := types.Metadata{"key": "value"}
+
+	_, _, err := rsstorage.PutWithMetadata(ctx, server, servertest.StringResolver("one"), "dir", "address", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, servertest.StringResolver("two"), "dir", "chunked", 3, meta)
+	c.Assert(err, check.IsNil)
+
+	ok, _, sz, _, got, err := rsstorage.CheckWithMetadata(ctx, server, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(3))
+	c.Check(got, check.DeepEquals, meta)
+	r, chunked, _, _, got, ok, err := rsstorage.GetWithMetadata(ctx, server, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(chunked, check.NotNil)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "two")
+
+	// Writes with metadata are leased
+	l, err := leaser.Acquire(ctx, "dir/address", time.Minute)
+	c.Assert(err, check.IsNil)
+	_, _, err = rsstorage.PutWithMetadata(ctx, server, servertest.StringResolver("three"), "dir", "address", meta)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+	_, _, err = rsstorage.PutChunkedWithMetadata(ctx, server, servertest.StringResolver("three"), "dir", "address", 5, meta)
+	c.Check(err, check.Equals, lease.ErrLeaseHeld)
+	c.Assert(l.Release(ctx), check.IsNil)
+
+	var items []types.StoredItem
+	for item, err := range rsstorage.EnumerateItems(ctx, server, types.EnumerateOptions{Prefix: "dir"}) {
+		c.Assert(err, check.IsNil)
+		items = append(items, item)
+	}
+	c.Check(items, check.HasLen, 2)
+}
+
+func (s *LeasedStorageServerSuite) TestCopy(c *check.C) {
+	ctx := context.Background()
+	leaser := local.NewLeaser()
+	server := NewStorageServer(StorageServerArgs{
+		Server: memtest.NewServer("leased", 4096),
+		Leaser: leaser,
+		NoWait: true,
+	})
+	source := memtest.NewServer("source", 4096)
+	_, _, err := source.Put(ctx, servertest.StringResolver("one"), "dir", "address")
+	c.Assert(err, check.IsNil)
+
+	// Copies into the server are leased
+	l, err := leaser.Acquire(ctx, "dir/address", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(source.Copy(ctx, "dir", "address", server), check.Equals, lease.ErrLeaseHeld)
+	c.Assert(l.Release(ctx), check.IsNil)
+	c.Assert(source.Copy(ctx, "dir", "address", server), check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "one")
+}
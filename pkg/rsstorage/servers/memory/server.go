@@ -0,0 +1,481 @@
+package memory
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// item is a single stored object. The data slice is never modified after
+// the item is stored, so it can be shared between readers and servers.
+type item struct {
+	data     []byte
+	modTime  time.Time
+	metadata types.Metadata
+}
+
+// store holds the items for a memory storage server. It is shared between
+// the server and the chunk utilities so chunks written through the chunker
+// are visible to the server.
+type store struct {
+	mutex sync.RWMutex
+	items map[string]*item
+}
+
+func newStore() *store {
+	return &store{
+		items: make(map[string]*item),
+	}
+}
+
+func (m *store) get(key string) (*item, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	i, ok := m.items[key]
+	return i, ok
+}
+
+func (m *store) set(key string, i *item) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items[key] = i
+}
+
+func (m *store) remove(keys ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+}
+
+func (m *store) keys() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	keys := make([]string, 0, len(m.items))
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *store) size() uint64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var sz uint64
+	for _, i := range m.items {
+		sz += uint64(len(i.data))
+	}
+	return sz
+}
+
+// StorageServer is a storage server that keeps all data in memory. Useful
+// for tests and for ephemeral nodes that do not need to persist data.
+type StorageServer struct {
+	store    *store
+	class    string
+	maxBytes datasize.ByteSize
+	chunker  rsstorage.ChunkUtils
+}
+
+type StorageServerArgs struct {
+	ChunkSize uint64
+	Waiter    rsstorage.ChunkWaiter
+	Notifier  rsstorage.ChunkNotifier
+	Class     string
+
+	// MaxBytes is the byte budget reported by CalculateUsage. It is not
+	// enforced when writing data.
+	MaxBytes datasize.ByteSize
+
+	// ResumeChunked resumes interrupted chunked writes instead of starting
+	// over. See `internal.DefaultChunkUtils.Resume`.
+	ResumeChunked bool
+}
+
+func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
+	s := newStore()
+	memoryBackedStorageServer := &StorageServer{
+		store:    s,
+		class:    args.Class,
+		maxBytes: args.MaxBytes,
+	}
+	return &StorageServer{
+		store:    s,
+		class:    args.Class,
+		maxBytes: args.MaxBytes,
+		chunker: &internal.DefaultChunkUtils{
+			ChunkSize:   args.ChunkSize,
+			Server:      memoryBackedStorageServer,
+			Waiter:      args.Waiter,
+			Notifier:    args.Notifier,
+			PollTimeout: rsstorage.DefaultChunkPollTimeout,
+			MaxAttempts: rsstorage.DefaultMaxChunkAttempts,
+			Resume:      args.ResumeChunked,
+		},
+	}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	found, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return found, chunked, sz, mod, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	key := path.Join(dir, address)
+	if i, ok := s.store.get(key); ok {
+		return true, nil, int64(len(i.data)), i.modTime, i.metadata, nil
+	}
+
+	// If the item was not found, check to see if it was chunked. If so, the original address
+	// will be a directory containing an `info.json` file.
+	info, ok, err := s.info(key)
+	if err != nil || !ok {
+		return false, nil, 0, time.Time{}, nil, err
+	}
+	return true, info, int64(info.FileSize), info.ModTime, info.Metadata, nil
+}
+
+func (s *StorageServer) info(key string) (*types.ChunksInfo, bool, error) {
+	i, ok := s.store.get(path.Join(key, "info.json"))
+	if !ok {
+		return nil, false, nil
+	}
+
+	info := types.ChunksInfo{}
+	err := json.Unmarshal(i.data, &info)
+	if err != nil {
+		return nil, false, fmt.Errorf("error decoding chunked directory 'info.json' for %s: %s", key, err)
+	}
+	return &info, true, nil
+}
+
+func (s *StorageServer) Dir() string {
+	return "memory:" + s.class
+}
+
+func (s *StorageServer) Type() types.StorageType {
+	return rsstorage.StorageTypeMemory
+}
+
+// CalculateUsage reports the number of bytes held in memory. The size and
+// free values are derived from the configured `MaxBytes` budget, and are
+// zero when no budget is configured.
+func (s *StorageServer) CalculateUsage() (types.Usage, error) {
+	start := time.Now()
+
+	used := datasize.ByteSize(s.store.size())
+	free := datasize.ByteSize(0)
+	if s.maxBytes > used {
+		free = s.maxBytes - used
+	}
+
+	return types.Usage{
+		SizeBytes:       s.maxBytes,
+		FreeBytes:       free,
+		UsedBytes:       used,
+		CalculationTime: time.Since(start),
+	}, nil
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, c, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, c, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	key := path.Join(dir, address)
+	if i, ok := s.store.get(key); ok {
+		return io.NopCloser(bytes.NewReader(i.data)), nil, int64(len(i.data)), i.modTime, i.metadata, true, nil
+	}
+
+	if _, ok := s.store.get(path.Join(key, "info.json")); !ok {
+		return nil, nil, 0, time.Time{}, nil, false, nil
+	}
+
+	r, c, sz, mod, err := s.chunker.ReadChunked(ctx, dir, address)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, nil, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
+	}
+	return r, c, sz, mod, c.Metadata, true, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	key := path.Join(dir, address)
+	if i, ok := s.store.get(key); ok {
+		n, err := internal.ClampRange(int64(len(i.data)), offset, length)
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+		r := bytes.NewReader(i.data[offset : offset+n])
+		return io.NopCloser(r), nil, int64(len(i.data)), i.modTime, true, nil
+	}
+
+	if _, ok := s.store.get(path.Join(key, "info.json")); !ok {
+		return nil, nil, 0, time.Time{}, false, nil
+	}
+
+	r, c, sz, mod, err := rsstorage.ReadChunkedRange(ctx, s.chunker, dir, address, offset, length)
+	if err != nil {
+		return nil, nil, 0, time.Time{}, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
+	}
+	return r, c, sz, mod, true, nil
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	// No-op
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	buf := &bytes.Buffer{}
+	wdir, waddress, err := resolve(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	// If no dir and address were provided, use the ones optionally returned
+	// from the resolver function
+	if dir == "" && address == "" {
+		dir = wdir
+		address = waddress
+	}
+
+	s.store.set(path.Join(dir, address), &item{
+		data:     buf.Bytes(),
+		modTime:  time.Now(),
+		metadata: meta.Normalize(),
+	})
+
+	return dir, address, nil
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if address == "" {
+		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
+	}
+	if sz == 0 {
+		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
+	}
+	err := rsstorage.WriteChunkedWithMetadata(ctx, s.chunker, dir, address, sz, meta, resolve)
+	if err != nil {
+		return "", "", err
+	}
+
+	return dir, address, nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	ok, chunked, _, _, err := s.Check(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	key := path.Join(dir, address)
+	if chunked != nil {
+		// Remove everything in the chunk directory, including any chunks
+		// left over from an incomplete write.
+		prefix := key + "/"
+		keys := make([]string, 0)
+		for _, k := range s.store.keys() {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		s.store.remove(keys...)
+		return nil
+	}
+
+	s.store.remove(key)
+	return nil
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	items := make([]types.StoredItem, 0)
+	for _, key := range s.store.keys() {
+		dir := path.Dir(key)
+		if dir == "." {
+			dir = ""
+		}
+		items = append(items, types.StoredItem{
+			Dir:     dir,
+			Address: path.Base(key),
+		})
+	}
+
+	return internal.FilterChunks(items), nil
+}
+
+// EnumerateItems streams the items in lexical order of their keys.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		filtered := func(item types.StoredItem) bool {
+			if !internal.MatchesPrefix(item, opts) {
+				return true
+			}
+			return yield(item, nil)
+		}
+
+		grouper := &internal.ChunkGrouper{}
+		for _, key := range s.store.keys() {
+			if key <= opts.After || !strings.HasPrefix(key, opts.Prefix) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				yield(types.StoredItem{}, err)
+				return
+			}
+			i, ok := s.store.get(key)
+			if !ok {
+				// Removed since it was listed
+				continue
+			}
+			dir := path.Dir(key)
+			if dir == "." {
+				dir = ""
+			}
+			file := types.StoredItem{
+				Dir:     dir,
+				Address: path.Base(key),
+				Size:    int64(len(i.data)),
+				ModTime: i.modTime,
+				Token:   key,
+			}
+			if !grouper.Add(file, filtered) {
+				return
+			}
+		}
+		grouper.Flush(filtered)
+	}
+}
+
+func (s *StorageServer) parts(ctx context.Context, dir, address string) ([]rsstorage.CopyPart, error) {
+	ok, chunked, _, _, err := s.Check(ctx, dir, address)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("the memory object with dir=%s and address=%s to copy does not exist", dir, address)
+	}
+	if chunked != nil {
+		if !chunked.Complete {
+			return nil, fmt.Errorf("the memory chunked object with dir=%s and address=%s to copy is incomplete", dir, address)
+		}
+		chunkDir := path.Join(dir, address)
+		parts := []rsstorage.CopyPart{rsstorage.NewCopyPart(chunkDir, "info.json")}
+		for i := uint64(1); i <= chunked.NumChunks; i++ {
+			chunkName := fmt.Sprintf("%08d", i)
+			parts = append(parts, rsstorage.NewCopyPart(chunkDir, chunkName))
+		}
+		return parts, nil
+	}
+	return []rsstorage.CopyPart{rsstorage.NewCopyPart(dir, address)}, nil
+}
+
+// copyParts copies the stored items directly to another memory storage
+// server. Since stored data is never modified, the data is shared rather
+// than duplicated.
+func (s *StorageServer) copyParts(ctx context.Context, dir, address string, server *StorageServer) error {
+	parts, err := s.parts(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+
+	// Clear any existing item at the destination first
+	err = server.Remove(ctx, dir, address)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		key := path.Join(part.Dir, part.Address)
+		i, ok := s.store.get(key)
+		if !ok {
+			return fmt.Errorf("the memory object part with dir=%s and address=%s to copy does not exist", part.Dir, part.Address)
+		}
+		server.store.set(key, i)
+	}
+	return nil
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	// Moving an item onto the same store is a no-op
+	if dest, ok := server.(*StorageServer); ok && dest.store == s.store {
+		return nil
+	}
+
+	// Copy the item
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+
+	// Then, remove the item
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	switch dest := server.(type) {
+	case *StorageServer:
+		// Copying an item onto the same store is a no-op
+		if dest.store == s.store {
+			return nil
+		}
+		return s.copyParts(ctx, dir, address, dest)
+	default:
+		// Don't do anything. Use a normal copy
+	}
+
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
+	if err == nil && !ok {
+		return fmt.Errorf("the memory object with dir=%s and address=%s to copy does not exist", dir, address)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	install := func(file io.ReadCloser) types.Resolver {
+		return func(writer io.Writer) (string, string, error) {
+			_, err := io.Copy(writer, file)
+			return "", "", err
+		}
+	}
+
+	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
+	err = rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
+	return err
+}
+
+func (s *StorageServer) Locate(dir, address string) string {
+	return fmt.Sprintf("memory://%s/%s", s.class, path.Join(dir, address))
+}
+
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s
+}
@@ -0,0 +1,358 @@
+package memory
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type MemoryStorageServerSuite struct{}
+
+var _ = check.Suite(&MemoryStorageServerSuite{})
+
+func newTestServer(class string) *StorageServer {
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	return NewStorageServer(StorageServerArgs{
+		ChunkSize: 352,
+		Waiter:    wn,
+		Notifier:  wn,
+		Class:     class,
+		MaxBytes:  datasize.KB * 10,
+	}).(*StorageServer)
+}
+
+func (s *MemoryStorageServerSuite) TestNew(c *check.C) {
+	server := newTestServer("classname")
+	c.Assert(server.chunker, check.NotNil)
+	c.Assert(server.Dir(), check.Equals, "memory:classname")
+	c.Assert(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+	c.Assert(server.Base(), check.Equals, server)
+}
+
+func (s *MemoryStorageServerSuite) TestPutGet(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+
+	ok, _, _, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	r, _, _, _, ok, err := server.Get(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(r, check.IsNil)
+
+	before := time.Now()
+	d, a, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "dir")
+	c.Check(a, check.Equals, "address")
+
+	ok, chunked, sz, mod, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(chunked, check.IsNil)
+	c.Check(sz, check.Equals, int64(9))
+	c.Check(mod.Before(before), check.Equals, false)
+
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "some data")
+
+	// Overwrite
+	_, _, err = server.Put(ctx, servertest.StringResolver("other data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "other data")
+}
+
+func (s *MemoryStorageServerSuite) TestPutDeferredAddress(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, server, "deferred-dir", "deferred-address"), check.Equals, "deferred")
+}
+
+func (s *MemoryStorageServerSuite) TestPutResolveErr(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+
+	resolve := func(w io.Writer) (string, string, error) {
+		_, _ = w.Write([]byte("partial"))
+		return "", "", errors.New("resolve error")
+	}
+	_, _, err := server.Put(ctx, resolve, "dir", "address")
+	c.Assert(err, check.ErrorMatches, "resolve error")
+
+	ok, _, _, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *MemoryStorageServerSuite) TestPutChunked(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "", sz)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-addressed chunked put commands")
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "address", 0)
+	c.Check(err, check.ErrorMatches, "cache only supports pre-sized chunked put commands")
+
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "address", sz)
+	c.Assert(err, check.IsNil)
+
+	ok, chunked, size, _, err := server.Check(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Assert(chunked, check.NotNil)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(chunked.NumChunks, check.Equals, uint64(6))
+	c.Check(size, check.Equals, int64(sz))
+
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, servertest.TestDESC)
+}
+
+func (s *MemoryStorageServerSuite) TestGetRange(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+
+	r, _, _, _, ok, err := server.GetRange(ctx, "dir", "missing", 0, 10)
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+
+	for _, address := range []string{"address", "chunked"} {
+		r, _, size, _, ok, err := server.GetRange(ctx, "dir", address, 300, 800)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(size, check.Equals, int64(sz))
+		b, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Check(string(b), check.Equals, servertest.TestDESC[300:1100])
+
+		_, _, _, _, _, err = server.GetRange(ctx, "dir", address, int64(sz)+1, -1)
+		c.Check(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+	}
+}
+
+func (s *MemoryStorageServerSuite) TestRemove(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+	sz := uint64(len(servertest.TestDESC))
+
+	// Removing a missing item is not an error
+	c.Assert(server.Remove(ctx, "dir", "missing"), check.IsNil)
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	c.Assert(server.Remove(ctx, "dir", "chunked"), check.IsNil)
+
+	items, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.HasLen, 0)
+	c.Check(server.store.keys(), check.HasLen, 0)
+}
+
+func (s *MemoryStorageServerSuite) TestEnumerate(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "", "PACKAGES")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("{}"), "af/test", "data.json")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "CHUNK", sz)
+	c.Assert(err, check.IsNil)
+
+	items, err := server.Enumerate(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{
+			Dir:     "dir",
+			Address: "CHUNK",
+			Chunked: true,
+		},
+		{
+			Dir:     "",
+			Address: "PACKAGES",
+		},
+		{
+			Dir:     "af/test",
+			Address: "data.json",
+		},
+	})
+}
+
+func (s *MemoryStorageServerSuite) TestEnumerateItems(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "", "PACKAGES")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("{}"), "af/test", "data.json")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "CHUNK", sz)
+	c.Assert(err, check.IsNil)
+
+	list := func(opts types.EnumerateOptions) []types.StoredItem {
+		items := make([]types.StoredItem, 0)
+		for item, err := range server.EnumerateItems(ctx, opts) {
+			c.Assert(err, check.IsNil)
+			c.Check(item.ModTime.IsZero(), check.Equals, false)
+			item.ModTime = time.Time{}
+			items = append(items, item)
+		}
+		return items
+	}
+
+	items := list(types.EnumerateOptions{})
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Address: "PACKAGES", Size: 9, Token: "PACKAGES"},
+		{Dir: "af/test", Address: "data.json", Size: 2, Token: "af/test/data.json"},
+		{Dir: "dir", Address: "CHUNK", Chunked: true, Size: int64(sz), Token: "dir/CHUNK/info.json"},
+	})
+
+	// Resumed after each item
+	for i, item := range items {
+		c.Check(list(types.EnumerateOptions{After: item.Token}), check.DeepEquals, items[i+1:])
+	}
+
+	c.Check(list(types.EnumerateOptions{Prefix: "af/"}), check.DeepEquals, items[1:2])
+	c.Check(list(types.EnumerateOptions{Prefix: "dir/CHUNK"}), check.DeepEquals, items[2:])
+	c.Check(list(types.EnumerateOptions{Prefix: "dir/CHUNK/"}), check.HasLen, 0)
+
+	// Stopped early
+	n := 0
+	for range server.EnumerateItems(ctx, types.EnumerateOptions{}) {
+		n++
+		break
+	}
+	c.Check(n, check.Equals, 1)
+
+	// Canceled
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, err := range server.EnumerateItems(canceled, types.EnumerateOptions{}) {
+		c.Check(err, check.Equals, context.Canceled)
+	}
+}
+
+func (s *MemoryStorageServerSuite) TestUsage(c *check.C) {
+	ctx := context.Background()
+	server := newTestServer("test")
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "", "address")
+	c.Assert(err, check.IsNil)
+
+	usage, err := server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.SizeBytes, check.Equals, datasize.KB*10)
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(9))
+	c.Check(usage.FreeBytes, check.Equals, datasize.KB*10-9)
+
+	// Without a budget, only the used bytes are reported
+	server.maxBytes = 0
+	usage, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.SizeBytes, check.Equals, datasize.ByteSize(0))
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(9))
+	c.Check(usage.FreeBytes, check.Equals, datasize.ByteSize(0))
+}
+
+func (s *MemoryStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	source := newTestServer("source")
+	dest := newTestServer("dest")
+	sz := uint64(len(servertest.TestDESC))
+
+	_, _, err := source.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	_, _, err = source.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+
+	err = source.Copy(ctx, "dir", "missing", dest)
+	c.Check(err, check.ErrorMatches, "the memory object with dir=dir and address=missing to copy does not exist")
+
+	// Copy to another memory server
+	c.Assert(source.Copy(ctx, "dir", "address", dest), check.IsNil)
+	c.Assert(source.Copy(ctx, "dir", "chunked", dest), check.IsNil)
+	c.Check(servertest.ReadItem(c, dest, "dir", "address"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, dest, "dir", "chunked"), check.Equals, servertest.TestDESC)
+	c.Check(servertest.ReadItem(c, source, "dir", "address"), check.Equals, "some data")
+
+	// Copying and moving onto the same server are no-ops
+	c.Assert(source.Copy(ctx, "dir", "address", source), check.IsNil)
+	c.Assert(source.Move(ctx, "dir", "address", source), check.IsNil)
+	c.Check(servertest.ReadItem(c, source, "dir", "address"), check.Equals, "some data")
+
+	// Move to a wrapped memory server uses a normal copy
+	wrapped := rsstorage.NewMetadataStorageServer(rsstorage.MetadataStorageServerArgs{
+		Name:   "wrapped",
+		Server: newTestServer("wrapped"),
+		Store:  &servertest.FakeCacheStore{},
+	})
+	c.Assert(source.Move(ctx, "dir", "chunked", wrapped), check.IsNil)
+	c.Check(servertest.ReadItem(c, wrapped, "dir", "chunked"), check.Equals, servertest.TestDESC)
+	ok, _, _, _, err := source.Check(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	// Copy to another server type
+	other := &rsstorage.DummyStorageServer{}
+	c.Assert(source.Copy(ctx, "dir", "address", other), check.IsNil)
+	c.Check(other.Placed, check.DeepEquals, []string{"dir-some data"})
+
+	// Metadata is dropped when the destination cannot store it
+	meta := types.Metadata{"content-type": "text/plain"}
+	_, _, err = source.PutWithMetadata(ctx, servertest.StringResolver("more data"), "dir", "meta", meta)
+	c.Assert(err, check.IsNil)
+	_, _, err = source.PutChunkedWithMetadata(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunkedmeta", sz, meta)
+	c.Assert(err, check.IsNil)
+	other = &rsstorage.DummyStorageServer{}
+	c.Assert(source.Copy(ctx, "dir", "meta", other), check.IsNil)
+	c.Assert(source.Move(ctx, "dir", "chunkedmeta", other), check.IsNil)
+	c.Check(other.Placed, check.DeepEquals, []string{"dir-more data", "dir-" + servertest.TestDESC})
+	c.Check(other.PutChunks, check.Equals, true)
+	ok, _, _, _, err = source.Check(ctx, "dir", "chunkedmeta")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *MemoryStorageServerSuite) TestLocate(c *check.C) {
+	server := newTestServer("test")
+	c.Check(server.Locate("dir", "address"), check.Equals, "memory://test/dir/address")
+	c.Check(server.Locate("", "address"), check.Equals, "memory://test/address")
+}
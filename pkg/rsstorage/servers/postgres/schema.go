@@ -0,0 +1,98 @@
+package postgres
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrations create the `large_objects` mapping table, and add the columns
+// that were introduced later to tables created by older versions.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS large_objects (
+		oid INTEGER PRIMARY KEY,
+		address TEXT UNIQUE NOT NULL
+	)`,
+	`ALTER TABLE large_objects ADD COLUMN IF NOT EXISTS metadata JSONB`,
+	`ALTER TABLE large_objects ADD COLUMN IF NOT EXISTS size BIGINT`,
+	// Addresses are filtered by prefix and sorted with the "C" collation
+	`CREATE INDEX IF NOT EXISTS large_objects_address_c ON large_objects (address COLLATE "C")`,
+}
+
+// Migrate creates the `large_objects` mapping table used by the storage
+// server, or brings an existing table up to date. It is safe to run more than
+// once, and should be run before the storage server is used.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) (err error) {
+	native, err := pool.Acquire(ctx)
+	if err != nil {
+		return
+	}
+	defer native.Release()
+
+	var tx pgx.Tx
+	if tx, err = native.Begin(ctx); err != nil {
+		return
+	}
+	defer pgxCommit(tx, "migrate", &err)
+
+	for _, migration := range migrations {
+		if _, err = tx.Exec(ctx, migration); err != nil {
+			err = fmt.Errorf("error migrating the large_objects table: %w", err)
+			return
+		}
+	}
+	if err = backfillSizes(ctx, tx); err != nil {
+		err = fmt.Errorf("error recording large object sizes: %w", err)
+	}
+	return
+}
+
+// backfillSizes records the size of the large objects that were written
+// before the `size` column was added.
+func backfillSizes(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, `SELECT oid FROM large_objects WHERE size IS NULL`)
+	if err != nil {
+		return err
+	}
+	oids, err := pgx.CollectRows(rows, pgx.RowTo[uint32])
+	if err != nil {
+		return err
+	}
+
+	los := tx.LargeObjects()
+	for _, oid := range oids {
+		lo, err := los.Open(ctx, oid, pgx.LargeObjectModeRead)
+		if err != nil {
+			return err
+		}
+		sz, err := lo.Seek(0, io.SeekEnd)
+		if err != nil {
+			return errors.Join(err, lo.Close())
+		}
+		if err = lo.Close(); err != nil {
+			return err
+		}
+		update := `UPDATE large_objects SET size = $1 WHERE oid = $2`
+		if _, err = tx.Exec(ctx, update, sz, oid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingColumn explains errors caused by a `large_objects` table that was
+// created before `column` was added, and returns other errors unchanged.
+func missingColumn(err error, column string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedColumn {
+		return fmt.Errorf("the large_objects table has no %s column; run Migrate to add it: %w", column, err)
+	}
+	return err
+}
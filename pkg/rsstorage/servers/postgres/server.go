@@ -9,14 +9,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
@@ -24,10 +27,14 @@ import (
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
 )
 
+// undefinedColumn is the PostgreSQL error code for a missing column.
+const undefinedColumn = "42703"
+
 type StorageServer struct {
-	pool    *pgxpool.Pool
-	class   string
-	chunker rsstorage.ChunkUtils
+	pool     *pgxpool.Pool
+	class    string
+	chunker  rsstorage.ChunkUtils
+	maxBytes datasize.ByteSize
 }
 
 type StorageServerArgs struct {
@@ -36,6 +43,21 @@ type StorageServerArgs struct {
 	Notifier  rsstorage.ChunkNotifier
 	Class     string
 	Pool      *pgxpool.Pool
+
+	// ChunkConcurrency is the number of chunks to upload at once, and
+	// ChunkReadAhead is the number of chunks to prefetch when reading. Each
+	// chunk in flight is buffered in memory. Values below two read and write
+	// one chunk at a time.
+	ChunkConcurrency int
+	ChunkReadAhead   int
+
+	// ResumeChunked resumes interrupted chunked writes instead of starting
+	// over. See `internal.DefaultChunkUtils.Resume`.
+	ResumeChunked bool
+
+	// MaxBytes is the byte budget reported by CalculateUsage. It is not
+	// enforced when writing data.
+	MaxBytes datasize.ByteSize
 }
 
 func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
@@ -53,7 +75,11 @@ func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
 			Notifier:    args.Notifier,
 			PollTimeout: rsstorage.DefaultChunkPollTimeout,
 			MaxAttempts: rsstorage.DefaultMaxChunkAttempts,
+			Concurrency: args.ChunkConcurrency,
+			ReadAhead:   args.ChunkReadAhead,
+			Resume:      args.ResumeChunked,
 		},
+		maxBytes: args.MaxBytes,
 	}
 }
 
@@ -201,6 +227,70 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (found b
 	return
 }
 
+// CheckWithMetadata checks for an item like `Check`. The metadata of an item
+// that is not chunked is kept in the `metadata` column of the
+// `large_objects` table. Without the column, items have no metadata. See
+// `Migrate`.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (found bool, chunked *types.ChunksInfo, sz int64, ts time.Time, meta types.Metadata, err error) {
+	found, chunked, sz, ts, err = s.Check(ctx, dir, address)
+	if err != nil || !found {
+		return
+	}
+	if chunked != nil {
+		meta = chunked.Metadata
+		return
+	}
+	meta, err = s.metadata(ctx, path.Join(s.class, dir, address))
+	return
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (f io.ReadCloser, chunks *types.ChunksInfo, sz int64, lastMod time.Time, meta types.Metadata, found bool, err error) {
+	location := path.Join(s.class, dir, address)
+	_, chunked, ok, err := s.lookup(ctx, location)
+	if err != nil || !ok {
+		return
+	}
+	if !chunked {
+		// Read the metadata first, since the large object is read in a
+		// transaction that is open until the reader is closed
+		meta, err = s.metadata(ctx, location)
+		if err != nil {
+			return
+		}
+	}
+
+	f, chunks, sz, lastMod, found, err = s.Get(ctx, dir, address)
+	if chunks != nil {
+		meta = chunks.Metadata
+	} else if !found {
+		meta = nil
+	}
+	return
+}
+
+// metadata reads the metadata for the large object at the given location.
+func (s *StorageServer) metadata(ctx context.Context, location string) (types.Metadata, error) {
+	var data []byte
+	query := `SELECT metadata FROM large_objects WHERE address = $1`
+	err := s.pool.QueryRow(ctx, query, location).Scan(&data)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || errors.As(err, &pgErr) && pgErr.Code == undefinedColumn {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var meta types.Metadata
+	err = json.Unmarshal(data, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding metadata for %s: %w", location, err)
+	}
+	return meta, nil
+}
+
 func (s *StorageServer) Get(
 	ctx context.Context,
 	dir string,
@@ -292,18 +382,129 @@ func (s *StorageServer) Get(
 	return
 }
 
+func (s *StorageServer) GetRange(
+	ctx context.Context,
+	dir string,
+	address string,
+	offset int64,
+	length int64,
+) (f io.ReadCloser, chunks *types.ChunksInfo, sz int64, lastMod time.Time, found bool, err error) {
+	// Look up the large object (see if it exists) in our mapping table
+	location := path.Join(s.class, dir, address)
+	dbOid, chunked, ok, err := s.lookup(ctx, location)
+	if err != nil || !ok {
+		return
+	}
+
+	if chunked {
+		// Read only the chunks that cover the range
+		f, chunks, sz, lastMod, err = rsstorage.ReadChunkedRange(ctx, s.chunker, dir, address, offset, length)
+		if err != nil {
+			return
+		}
+	} else {
+		var native *pgxpool.Conn
+		native, err = s.pool.Acquire(ctx)
+		if err != nil {
+			return
+		}
+
+		var tx pgx.Tx
+		if tx, err = native.Begin(ctx); err != nil {
+			native.Release()
+			return
+		}
+
+		// Clean up if we fail before handing the large object back to the caller
+		var lo *pgx.LargeObject
+		defer func() {
+			if err != nil {
+				if lo != nil {
+					_ = lo.Close()
+				}
+				pgxCommit(tx, fmt.Sprintf("GetRange %s", location), &err)
+				native.Release()
+			}
+		}()
+
+		// Open the large object
+		los := tx.LargeObjects()
+		slog.Debug("Opening (for ranged read) large object", "location", location, "oid", dbOid)
+		if lo, err = los.Open(ctx, dbOid, pgx.LargeObjectModeRead); err != nil {
+			return
+		}
+
+		// Get size by seeking to the end
+		sz, err = lo.Seek(0, io.SeekEnd)
+		if err != nil {
+			return
+		}
+
+		var n int64
+		n, err = internal.ClampRange(sz, offset, length)
+		if err != nil {
+			return
+		}
+
+		// Seek to the start of the range
+		_, err = lo.Seek(offset, io.SeekStart)
+		if err != nil {
+			return
+		}
+
+		// Get a closer that knows how to clean up the connection after we're done
+		// reading from the large object we pass back
+		f = internal.LimitReadCloser(newLargeObjectCloser(lo, s.pool, native, tx, "GetRange", location), n)
+	}
+
+	found = true
+	return
+}
+
+// lookup finds the OID for the large object at the given location. For chunked
+// assets, the OID of the asset's `info.json` is returned.
+func (s *StorageServer) lookup(ctx context.Context, location string) (dbOid uint32, chunked bool, found bool, err error) {
+	query := `SELECT oid FROM large_objects WHERE address = $1`
+	err = s.pool.QueryRow(ctx, query, location).Scan(&dbOid)
+	if err == nil {
+		found = true
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+
+	// If the item was not found, check to see if it was chunked. If so, the original address
+	// will be a directory containing an `info.json` file.
+	infoLocation := path.Join(location, "info.json")
+	err = s.pool.QueryRow(ctx, query, infoLocation).Scan(&dbOid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+		return
+	} else if err != nil {
+		return
+	}
+
+	chunked = true
+	found = true
+	return
+}
+
 func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
 	// No-op
 }
 
 func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
 	if address == "" {
 		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
 	}
 	if sz == 0 {
 		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
 	}
-	err := s.chunker.WriteChunked(ctx, dir, address, sz, resolve)
+	err := rsstorage.WriteChunkedWithMetadata(ctx, s.chunker, dir, address, sz, meta, resolve)
 	if err != nil {
 		return "", "", err
 	}
@@ -319,12 +520,62 @@ func (s *StorageServer) Type() types.StorageType {
 	return rsstorage.StorageTypePostgres
 }
 
-func (s *StorageServer) CalculateUsage() (types.Usage, error) {
-	// Currently unused.
-	return types.Usage{}, fmt.Errorf("server postgres.StorageServer does not implement CalculateUsage")
+// CalculateUsage sums the sizes of the large objects for the storage class,
+// and of their rows in the mapping table. The size and free values are
+// derived from the configured `MaxBytes` budget, and are zero when no budget
+// is configured.
+func (s *StorageServer) CalculateUsage() (usage types.Usage, err error) {
+	ctx := context.Background()
+	start := time.Now()
+
+	// The size of each large object is recorded when it is written. See
+	// `Migrate`.
+	query := `SELECT count(*),
+		coalesce(sum(size), 0),
+		coalesce(sum(pg_column_size(large_objects.*)), 0)
+		FROM large_objects WHERE address COLLATE "C" LIKE $1`
+	var count, objectBytes, rowBytes int64
+	row := s.pool.QueryRow(ctx, query, likePrefix(s.class+"/"))
+	if err = row.Scan(&count, &objectBytes, &rowBytes); err != nil {
+		err = fmt.Errorf("error calculating usage for class %s: %w", s.class, missingColumn(err, "size"))
+		return
+	}
+
+	used := datasize.ByteSize(objectBytes + rowBytes)
+	free := datasize.ByteSize(0)
+	if s.maxBytes > used {
+		free = s.maxBytes - used
+	}
+
+	elapsed := time.Since(start)
+	slog.Debug("Calculated Postgres usage", "class", s.class, "objects", count, "elapsed", elapsed)
+
+	usage = types.Usage{
+		SizeBytes:       s.maxBytes,
+		FreeBytes:       free,
+		UsedBytes:       used,
+		CalculationTime: elapsed,
+	}
+	return
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePrefix returns a LIKE pattern that matches the strings that start with
+// the prefix.
+func likePrefix(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
 }
 
-func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (dirOut, addrOut string, err error) {
+// PutWithMetadata writes an item like `Put`, and stores the metadata in the
+// `metadata` column of the `large_objects` table. Writing metadata fails if
+// the column is missing. See `Migrate`.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (dirOut, addrOut string, err error) {
 
 	var permanentLocation string
 
@@ -368,6 +619,16 @@ func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, ad
 		return
 	}
 
+	// Record the metadata, if any
+	if len(meta) > 0 {
+		update := `UPDATE large_objects SET metadata = $1 WHERE address = $2`
+		if _, err = tx.Exec(ctx, update, meta.Normalize(), tempLocation); err != nil {
+			slog.Debug("Error setting large object metadata in mapping table", "error", err)
+			err = missingColumn(err, "metadata")
+			return
+		}
+	}
+
 	// Copy the staging file to the large object
 	slog.Debug("Copying data to large object")
 	wdir, waddress, err := resolve(lo)
@@ -393,10 +654,18 @@ func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, ad
 		return
 	}
 
+	// Record the size
+	var sz int64
+	if sz, err = lo.Seek(0, io.SeekEnd); err != nil {
+		slog.Debug("Error getting large object size", "error", err)
+		return
+	}
+
 	// Rename the location
-	rename := `UPDATE large_objects SET address = $1 WHERE address = $2`
-	if _, err = tx.Exec(ctx, rename, permanentLocation, tempLocation); err != nil {
+	rename := `UPDATE large_objects SET address = $1, size = $2 WHERE address = $3`
+	if _, err = tx.Exec(ctx, rename, permanentLocation, sz, tempLocation); err != nil {
 		slog.Debug("Error setting large object record permanent address in mapping table", "error", err)
+		err = missingColumn(err, "size")
 		return
 	}
 
@@ -523,6 +792,80 @@ func (s *StorageServer) Enumerate(ctx context.Context) (items []types.StoredItem
 	return
 }
 
+// enumeratePageSize is the number of large objects read per query by
+// `EnumerateItems`.
+const enumeratePageSize = 1000
+
+// EnumerateItems streams the items of the class in address order, reading a
+// page of large objects at a time. Tokens are addresses relative to the
+// class. Modification times are not stored, so they are not set.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		filtered := func(item types.StoredItem) bool {
+			if !internal.MatchesPrefix(item, opts) {
+				return true
+			}
+			return yield(item, nil)
+		}
+
+		grouper := &internal.ChunkGrouper{}
+		after := opts.After
+		for {
+			files, err := s.enumeratePage(ctx, opts.Prefix, after)
+			if err != nil {
+				yield(types.StoredItem{}, fmt.Errorf("error enumerating class %s: %w", s.class, err))
+				return
+			}
+			for _, file := range files {
+				if !grouper.Add(file, filtered) {
+					return
+				}
+			}
+			if len(files) < enumeratePageSize {
+				break
+			}
+			after = files[len(files)-1].Token
+		}
+		grouper.Flush(filtered)
+	}
+}
+
+// enumeratePage reads a page of large objects with the prefix, after the
+// address `after`. Both are relative to the class.
+func (s *StorageServer) enumeratePage(ctx context.Context, prefix, after string) (files []types.StoredItem, err error) {
+	// Addresses are compared with the "C" collation to match the order of
+	// Go strings. See `CalculateUsage` for the size of each large object.
+	query := `SELECT address, coalesce(size, 0)
+		FROM large_objects
+		WHERE address COLLATE "C" > $1 AND address COLLATE "C" LIKE $2
+		ORDER BY address COLLATE "C"
+		LIMIT $3`
+	var rows pgx.Rows
+	if rows, err = s.pool.Query(ctx, query, s.class+"/"+after, likePrefix(s.class+"/"+prefix), enumeratePageSize); err != nil {
+		err = missingColumn(err, "size")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address string
+		var sz int64
+		if err = rows.Scan(&address, &sz); err != nil {
+			return
+		}
+		relPath := strings.TrimPrefix(address, s.class+"/")
+		dir, base := path.Split(relPath)
+		files = append(files, types.StoredItem{
+			Dir:     strings.TrimSuffix(dir, "/"),
+			Address: base,
+			Size:    sz,
+			Token:   relPath,
+		})
+	}
+	err = rows.Err()
+	return
+}
+
 func (s *StorageServer) move(ctx context.Context, dir, address string, server rsstorage.StorageServer) (err error) {
 	parts, err := s.parts(ctx, dir, address)
 	if err != nil {
@@ -611,12 +954,13 @@ func (s *StorageServer) Move(ctx context.Context, dir, address string, server rs
 }
 
 func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
-	f, chunked, sz, _, ok, err := s.Get(ctx, dir, address)
+	f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
 	if err == nil && !ok {
 		return fmt.Errorf("the PostgreSQL large object with dir=%s and address=%s to copy does not exist", dir, address)
 	} else if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	install := func(file io.ReadCloser) types.Resolver {
 		return func(writer io.Writer) (string, string, error) {
@@ -626,11 +970,7 @@ func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rs
 	}
 
 	// Use the server Base() in case the server is wrapped, e.g., `MetadataStorageServer`
-	if chunked != nil {
-		_, _, err = server.Base().PutChunked(ctx, install(f), dir, address, uint64(sz))
-	} else {
-		_, _, err = server.Base().Put(ctx, install(f), dir, address)
-	}
+	err = rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
 	return err
 }
 
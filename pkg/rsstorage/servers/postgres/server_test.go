@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gopkg.in/check.v1"
@@ -200,6 +201,134 @@ func (s *PgCacheServerSuite) TestGetChunkedOk(c *check.C) {
 	c.Assert(r.Close(), check.IsNil)
 }
 
+func (s *PgCacheServerSuite) TestGetRangeOk(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		pool: s.pool,
+	}
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	server.chunker = &internal.DefaultChunkUtils{
+		ChunkSize: 512,
+		Server:    server,
+		Waiter:    wn,
+		Notifier:  wn,
+	}
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte(servertest.TestDESC))
+		return "", "", err
+	}
+
+	// First, cache something
+	_, _, err := server.Put(ctx, resolve, "dir", "rangeaddress")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutChunked(ctx, resolve, "dir", "rangechunked", uint64(len(servertest.TestDESC)))
+	c.Assert(err, check.IsNil)
+
+	for _, address := range []string{"rangeaddress", "rangechunked"} {
+		// Next, get a range spanning several chunks
+		r, _, sz, _, ok, err := server.GetRange(ctx, "dir", address, 500, 600)
+		c.Assert(err, check.IsNil)
+		c.Assert(ok, check.Equals, true)
+		c.Check(sz, check.Equals, int64(1953))
+
+		// Check contents
+		bs := bytes.NewBufferString("")
+		_, err = io.Copy(bs, r)
+		c.Assert(err, check.IsNil)
+		c.Assert(r.Close(), check.IsNil)
+		c.Check(bs.String(), check.Equals, servertest.TestDESC[500:1100])
+
+		// Invalid range
+		_, _, _, _, ok, err = server.GetRange(ctx, "dir", address, 2000, -1)
+		c.Check(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+		c.Check(ok, check.Equals, false)
+	}
+
+	// Missing
+	_, _, _, _, ok, err := server.GetRange(ctx, "dir", "missing", 0, 10)
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *PgCacheServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		pool: s.pool,
+	}
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("this is a test"))
+		return "", "", err
+	}
+
+	_, _, err := server.PutWithMetadata(ctx, resolve, "dir", "cacheaddress", types.Metadata{"Content-Type": "text/plain"})
+	c.Assert(err, check.IsNil)
+	ok, _, sz, _, meta, err := server.CheckWithMetadata(ctx, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(14))
+	c.Check(meta, check.DeepEquals, types.Metadata{"content-type": "text/plain"})
+
+	r, _, _, _, meta, ok, err := server.GetWithMetadata(ctx, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(meta, check.DeepEquals, types.Metadata{"content-type": "text/plain"})
+	c.Assert(r.Close(), check.IsNil)
+
+	// Replaced when written again
+	_, _, err = server.Put(ctx, resolve, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	_, _, _, _, meta, err = server.CheckWithMetadata(ctx, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	c.Check(meta, check.IsNil)
+
+	// Items have no metadata without the column
+	_, err = s.pool.Exec(ctx, "ALTER TABLE large_objects DROP COLUMN metadata")
+	c.Assert(err, check.IsNil)
+	_, _, _, _, meta, err = server.CheckWithMetadata(ctx, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	c.Check(meta, check.IsNil)
+
+	// Metadata cannot be written without the column
+	_, _, err = server.PutWithMetadata(ctx, resolve, "dir", "cacheaddress", types.Metadata{"Content-Type": "text/plain"})
+	c.Check(err, check.ErrorMatches, "the large_objects table has no metadata column; run Migrate to add it: .*")
+	_, _, err = server.Put(ctx, resolve, "dir", "cacheaddress")
+	c.Check(err, check.IsNil)
+}
+
+func (s *PgCacheServerSuite) TestMigrate(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		pool: s.pool,
+	}
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("this is a test"))
+		return "", "", err
+	}
+
+	_, err := s.pool.Exec(ctx, "ALTER TABLE large_objects DROP COLUMN metadata")
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, resolve, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+
+	// Existing items are kept, and running it again is harmless
+	_, err = s.pool.Exec(ctx, "UPDATE large_objects SET size = NULL")
+	c.Assert(err, check.IsNil)
+	c.Assert(Migrate(ctx, s.pool), check.IsNil)
+	var sz int64
+	err = s.pool.QueryRow(ctx, "SELECT size FROM large_objects WHERE address = 'dir/cacheaddress'").Scan(&sz)
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, int64(14))
+	c.Assert(Migrate(ctx, s.pool), check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "cacheaddress"), check.Equals, "this is a test")
+	_, _, err = server.PutWithMetadata(ctx, resolve, "dir", "cacheaddress", types.Metadata{"Content-Type": "text/plain"})
+	c.Assert(err, check.IsNil)
+	_, _, _, _, meta, err := server.CheckWithMetadata(ctx, "dir", "cacheaddress")
+	c.Assert(err, check.IsNil)
+	c.Check(meta, check.DeepEquals, types.Metadata{"content-type": "text/plain"})
+}
+
 func (s *PgCacheServerSuite) TestPutResolveErr(c *check.C) {
 	server := &StorageServer{
 		pool: s.pool,
@@ -331,6 +460,60 @@ func put(server *StorageServer, dir, address string, c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *PgCacheServerSuite) TestCalculateUsage(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		pool:     s.pool,
+		class:    "cache",
+		maxBytes: datasize.MB,
+	}
+	other := &StorageServer{
+		pool:  s.pool,
+		class: "other",
+	}
+
+	usage, err := server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(0))
+	c.Check(usage.SizeBytes, check.Equals, datasize.MB)
+	c.Check(usage.FreeBytes, check.Equals, datasize.MB)
+
+	put(server, "", "cacheaddress", c)
+	put(server, "ad1", "cacheaddress2", c)
+	put(other, "", "cacheaddress", c)
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write(bytes.Repeat([]byte("a"), 10000))
+		return "", "", err
+	}
+	_, _, err = server.Put(ctx, resolve, "ad2", "large")
+	c.Assert(err, check.IsNil)
+
+	// Includes the large objects, and their rows in the mapping table
+	data := datasize.ByteSize(2*len("this is a test") + 10000)
+	usage, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes > data, check.Equals, true)
+	c.Check(usage.UsedBytes < data+1024, check.Equals, true)
+	c.Check(usage.SizeBytes, check.Equals, datasize.MB)
+	c.Check(usage.FreeBytes, check.Equals, datasize.MB-usage.UsedBytes)
+
+	// Without a budget
+	usage, err = other.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes > datasize.ByteSize(len("this is a test")), check.Equals, true)
+	c.Check(usage.SizeBytes, check.Equals, datasize.ByteSize(0))
+	c.Check(usage.FreeBytes, check.Equals, datasize.ByteSize(0))
+
+	// Wildcards in the class are matched literally
+	wildcard := &StorageServer{
+		pool:  s.pool,
+		class: "c_che",
+	}
+	usage, err = wildcard.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(0))
+}
+
 func (s *PgCacheServerSuite) TestEnumerate(c *check.C) {
 	ctx := context.Background()
 	server := &StorageServer{
@@ -409,6 +592,62 @@ func (s *PgCacheServerSuite) TestEnumerate(c *check.C) {
 	})
 }
 
+func (s *PgCacheServerSuite) TestEnumerateItems(c *check.C) {
+	ctx := context.Background()
+	server := &StorageServer{
+		pool:  s.pool,
+		class: "enumerate",
+	}
+	wn := &servertest.DummyWaiterNotifier{
+		Ch: make(chan bool, 1),
+	}
+	server.chunker = &internal.DefaultChunkUtils{
+		ChunkSize: 352,
+		Server:    server,
+		Waiter:    wn,
+		Notifier:  wn,
+	}
+
+	put(server, "", "cacheaddress", c)
+	put(server, "ad1", "cacheaddress2", c)
+	put(server, "ad1.b", "cacheaddress3", c)
+	resolve := func(w io.Writer) (string, string, error) {
+		buf := bytes.NewBufferString(servertest.TestDESC)
+		_, err := io.Copy(w, buf)
+		return "", "", err
+	}
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err := server.PutChunked(ctx, resolve, "dir", "DESCRIPTION", sz)
+	c.Assert(err, check.IsNil)
+
+	list := func(opts types.EnumerateOptions) []types.StoredItem {
+		items := make([]types.StoredItem, 0)
+		for item, err := range server.EnumerateItems(ctx, opts) {
+			c.Assert(err, check.IsNil)
+			items = append(items, item)
+		}
+		return items
+	}
+
+	items := list(types.EnumerateOptions{})
+	c.Check(items, check.DeepEquals, []types.StoredItem{
+		{Dir: "ad1.b", Address: "cacheaddress3", Size: 14, Token: "ad1.b/cacheaddress3"},
+		{Dir: "ad1", Address: "cacheaddress2", Size: 14, Token: "ad1/cacheaddress2"},
+		{Address: "cacheaddress", Size: 14, Token: "cacheaddress"},
+		{Dir: "dir", Address: "DESCRIPTION", Chunked: true, Size: int64(sz), Token: "dir/DESCRIPTION/info.json"},
+	})
+
+	for i, item := range items {
+		c.Check(list(types.EnumerateOptions{After: item.Token}), check.DeepEquals, items[i+1:])
+	}
+	c.Check(list(types.EnumerateOptions{Prefix: "ad1"}), check.DeepEquals, items[:2])
+	c.Check(list(types.EnumerateOptions{Prefix: "dir/"}), check.DeepEquals, items[3:])
+
+	// Wildcards in the prefix are matched literally
+	c.Check(list(types.EnumerateOptions{Prefix: "ad_"}), check.HasLen, 0)
+	c.Check(list(types.EnumerateOptions{Prefix: "%"}), check.HasLen, 0)
+}
+
 func (s *PgCacheServerSuite) TestCopy(c *check.C) {
 	ctx := context.Background()
 	sourceServer := &StorageServer{
@@ -737,6 +976,12 @@ func EphemeralPostgresPool(dbname string) (pool *pgxpool.Pool, err error) {
 		"	address TEXT UNIQUE NOT NULL " +
 		");"
 	_, err = pool.Exec(context.Background(), sql)
+	if err != nil {
+		return
+	}
+
+	// Bring the table up to date
+	err = Migrate(context.Background(), pool)
 
 	return
 }
@@ -0,0 +1,158 @@
+package replicated
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// DisagreementError reports an item that exists on several replicas with
+// different sizes. These items are not repaired automatically.
+type DisagreementError struct {
+	Dir     string
+	Address string
+
+	// Sizes records the size of the item on each replica, or -1 if the
+	// item is missing from the replica.
+	Sizes []int64
+}
+
+func (e *DisagreementError) Error() string {
+	return fmt.Sprintf("%s for dir=%s and address=%s: sizes %v", ErrDisagreement, e.Dir, e.Address, e.Sizes)
+}
+
+func (e *DisagreementError) Unwrap() error {
+	return ErrDisagreement
+}
+
+// RepairResult summarizes a repair.
+type RepairResult struct {
+	// Checked is the number of distinct items found across all replicas.
+	Checked int
+
+	// Repaired is the number of copies made to replicas that were missing
+	// an item.
+	Repaired int
+
+	// Skipped is the number of items that were skipped because a chunked
+	// write is still in progress.
+	Skipped int
+
+	// Disagreements lists the items that differ between replicas.
+	Disagreements []*DisagreementError
+}
+
+// Repair finds items that are missing from some replicas and copies them from
+// a replica that has them. Items that exist on several replicas with different
+// sizes are reported as a `DisagreementError` and are not changed.
+func (s *StorageServer) Repair(ctx context.Context) (RepairResult, error) {
+	result := RepairResult{}
+
+	// Find all items across all replicas
+	all := make(map[string]types.StoredItem)
+	for i, replica := range s.replicas {
+		items, err := replica.Enumerate(ctx)
+		if err != nil {
+			return result, s.replicaErr(i, err)
+		}
+		for _, item := range items {
+			all[path.Join(item.Dir, item.Address)] = item
+		}
+	}
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result.Checked = len(keys)
+
+	var errs error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return result, errors.Join(errs, err)
+		}
+		item := all[key]
+		repaired, skipped, err := s.repairItem(ctx, item.Dir, item.Address)
+		result.Repaired += repaired
+		if skipped {
+			result.Skipped++
+		}
+		var disagreement *DisagreementError
+		if errors.As(err, &disagreement) {
+			result.Disagreements = append(result.Disagreements, disagreement)
+		}
+		errs = errors.Join(errs, err)
+	}
+
+	return result, errs
+}
+
+// repairItem copies a single item to the replicas that are missing it. It
+// returns the number of copies made, and whether the item was skipped.
+func (s *StorageServer) repairItem(ctx context.Context, dir, address string) (int, bool, error) {
+	sizes := make([]int64, len(s.replicas))
+	source := -1
+	agree := true
+	for i, replica := range s.replicas {
+		ok, chunked, sz, _, err := replica.Check(ctx, dir, address)
+		if err != nil {
+			return 0, false, s.replicaErr(i, err)
+		} else if !ok {
+			sizes[i] = -1
+			continue
+		} else if chunked != nil && !chunked.Complete {
+			return 0, true, nil
+		}
+		sizes[i] = sz
+		if source < 0 {
+			source = i
+		} else if sz != sizes[source] {
+			agree = false
+		}
+	}
+
+	if !agree {
+		return 0, false, &DisagreementError{Dir: dir, Address: address, Sizes: sizes}
+	} else if source < 0 {
+		// Removed since it was enumerated
+		return 0, false, nil
+	}
+
+	var errs error
+	repaired := 0
+	for i, replica := range s.replicas {
+		if sizes[i] >= 0 {
+			continue
+		}
+		err := s.replicas[source].Copy(ctx, dir, address, replica)
+		if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+			continue
+		}
+		slog.Debug("repaired item on replica", "dir", dir, "address", address, "replica", i, "source", source)
+		repaired++
+	}
+	return repaired, false, errs
+}
+
+// NewRepairTask returns a scheduled task that repairs `server`.
+func NewRepairTask(name string, server *StorageServer, schedule rselection.Schedule) *rselection.FuncTask {
+	return rselection.NewFuncTask(name, schedule, func(ctx context.Context) error {
+		result, err := server.Repair(ctx)
+		slog.Debug("repaired replicas",
+			"task", name,
+			"checked", result.Checked,
+			"repaired", result.Repaired,
+			"skipped", result.Skipped,
+			"disagreements", len(result.Disagreements))
+		return err
+	})
+}
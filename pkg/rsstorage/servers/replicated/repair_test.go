@@ -0,0 +1,114 @@
+package replicated
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rselection"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+)
+
+type RepairSuite struct{}
+
+var _ = check.Suite(&RepairSuite{})
+
+func (s *RepairSuite) TestRepair(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	tertiary := memtest.NewServer("tertiary", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary, tertiary},
+	})
+	c.Assert(err, check.IsNil)
+
+	// In sync
+	_, _, err = server.Put(ctx, servertest.StringResolver("synced"), "dir", "synced")
+	c.Assert(err, check.IsNil)
+
+	// Missing from some replicas
+	_, _, err = primary.Put(ctx, servertest.StringResolver("primary"), "dir", "primary")
+	c.Assert(err, check.IsNil)
+	_, _, err = tertiary.Put(ctx, servertest.StringResolver("tertiary"), "", "tertiary")
+	c.Assert(err, check.IsNil)
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err = secondary.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+
+	// Disagree
+	_, _, err = primary.Put(ctx, servertest.StringResolver("short"), "dir", "conflict")
+	c.Assert(err, check.IsNil)
+	_, _, err = tertiary.Put(ctx, servertest.StringResolver("much longer"), "dir", "conflict")
+	c.Assert(err, check.IsNil)
+
+	result, err := server.Repair(ctx)
+	c.Assert(errors.Is(err, ErrDisagreement), check.Equals, true)
+	c.Check(err, check.ErrorMatches, "replicas disagree for dir=dir and address=conflict: sizes \\[5 -1 11\\]")
+	c.Check(result.Checked, check.Equals, 5)
+	c.Check(result.Repaired, check.Equals, 6)
+	c.Check(result.Skipped, check.Equals, 0)
+	c.Check(result.Disagreements, check.DeepEquals, []*DisagreementError{
+		{
+			Dir:     "dir",
+			Address: "conflict",
+			Sizes:   []int64{5, -1, 11},
+		},
+	})
+
+	for _, replica := range server.Replicas() {
+		c.Check(servertest.ReadItem(c, replica, "dir", "primary"), check.Equals, "primary")
+		c.Check(servertest.ReadItem(c, replica, "", "tertiary"), check.Equals, "tertiary")
+		c.Check(servertest.ReadItem(c, replica, "dir", "chunked"), check.Equals, servertest.TestDESC)
+	}
+	c.Check(servertest.Exists(c, secondary, "dir", "conflict"), check.Equals, false)
+
+	// Nothing left to repair
+	c.Assert(server.Remove(ctx, "dir", "conflict"), check.IsNil)
+	result, err = server.Repair(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(result.Checked, check.Equals, 4)
+	c.Check(result.Repaired, check.Equals, 0)
+}
+
+func (s *RepairSuite) TestRepairEnumerateError(c *check.C) {
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{
+			memtest.NewServer("primary", 352),
+			&rsstorage.DummyStorageServer{
+				MockDir: "dummy",
+				EnumErr: errors.New("enumerate error"),
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	_, err = server.Repair(context.Background())
+	c.Check(err, check.ErrorMatches, "replica 1 \\(dummy\\): enumerate error")
+}
+
+func (s *RepairSuite) TestRepairTask(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+
+	ticker := make(chan time.Time)
+	task := NewRepairTask("repair", server, &rselection.IntervalSchedule{Ticker: ticker})
+	c.Check(task.Name(), check.Equals, "repair")
+	c.Check(task.Type(), check.Equals, rselection.TaskTypeScheduled)
+	c.Check(task.Schedule().Next(), check.Equals, (<-chan time.Time)(ticker))
+
+	_, _, err = primary.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	task.Run(ctx, nil)
+	c.Check(servertest.ReadItem(c, secondary, "dir", "address"), check.Equals, "some data")
+}
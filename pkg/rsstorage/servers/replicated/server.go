@@ -0,0 +1,312 @@
+package replicated
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+var (
+	ErrNoReplicas   = errors.New("at least one replica is required")
+	ErrQuorum       = errors.New("write quorum not met")
+	ErrDisagreement = errors.New("replicas disagree")
+)
+
+// ReplicaError is an error returned by a single replica.
+type ReplicaError struct {
+	Replica int
+	Dir     string
+	Err     error
+}
+
+func (e *ReplicaError) Error() string {
+	return fmt.Sprintf("replica %d (%s): %s", e.Replica, e.Dir, e.Err)
+}
+
+func (e *ReplicaError) Unwrap() error {
+	return e.Err
+}
+
+// StorageServer mirrors writes to several storage servers (replicas). The
+// first replica is the primary. Reads are served by the primary, and fall back
+// to the other replicas in order when the primary misses or errors.
+type StorageServer struct {
+	rsstorage.StorageServer
+	replicas []rsstorage.StorageServer
+	quorum   int
+}
+
+type StorageServerArgs struct {
+	// Replicas are the storage servers to write to. The first replica is the
+	// primary.
+	Replicas []rsstorage.StorageServer
+
+	// WriteQuorum is the number of replicas that must accept a write for it
+	// to succeed. Defaults to all replicas.
+	WriteQuorum int
+}
+
+func NewStorageServer(args StorageServerArgs) (*StorageServer, error) {
+	if len(args.Replicas) == 0 {
+		return nil, ErrNoReplicas
+	}
+	quorum := args.WriteQuorum
+	if quorum <= 0 {
+		quorum = len(args.Replicas)
+	} else if quorum > len(args.Replicas) {
+		return nil, fmt.Errorf("write quorum %d exceeds the number of replicas %d", quorum, len(args.Replicas))
+	}
+	return &StorageServer{
+		StorageServer: args.Replicas[0],
+		replicas:      args.Replicas,
+		quorum:        quorum,
+	}, nil
+}
+
+// Replicas returns the storage servers that are mirrored.
+func (s *StorageServer) Replicas() []rsstorage.StorageServer {
+	return s.replicas
+}
+
+func (s *StorageServer) replicaErr(i int, err error) error {
+	return &ReplicaError{Replica: i, Dir: s.replicas[i].Dir(), Err: err}
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+// CheckWithMetadata checks for an item like `Check`, and also returns the
+// metadata stored by the replica that has it.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	var errs error
+	for i, replica := range s.replicas {
+		ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, replica, dir, address)
+		if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+		} else if ok {
+			return ok, chunked, sz, mod, meta, nil
+		}
+	}
+	return false, nil, 0, time.Time{}, nil, errs
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, chunked, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+// GetWithMetadata gets an item like `Get`, and also returns the metadata
+// stored by the replica that serves it.
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	var errs error
+	for i, replica := range s.replicas {
+		r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, replica, dir, address)
+		if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+		} else if ok {
+			if i > 0 {
+				slog.Debug("item served by secondary replica", "dir", dir, "address", address, "replica", i)
+			}
+			return r, chunked, sz, mod, meta, ok, nil
+		}
+	}
+	return nil, nil, 0, time.Time{}, nil, false, errs
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	var errs error
+	for i, replica := range s.replicas {
+		r, chunked, sz, mod, ok, err := rsstorage.GetRange(ctx, replica, dir, address, offset, length)
+		if errors.Is(err, rsstorage.ErrInvalidRange) {
+			return nil, nil, 0, time.Time{}, false, err
+		} else if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+		} else if ok {
+			return r, chunked, sz, mod, ok, nil
+		}
+	}
+	return nil, nil, 0, time.Time{}, false, errs
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+// PutWithMetadata writes an item like `Put`, and stores the metadata with it
+// on every replica. Replicas that cannot store metadata fail the write.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	return s.write(ctx, resolve, func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error) {
+		return rsstorage.PutWithMetadata(ctx, server, resolve, dir, address, meta)
+	})
+}
+
+// PutChunkedWithMetadata writes a chunked item like `PutChunked`, and stores
+// the metadata with it on every replica. Replicas that cannot store metadata
+// fail the write.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	return s.write(ctx, resolve, func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error) {
+		return rsstorage.PutChunkedWithMetadata(ctx, server, resolve, dir, address, sz, meta)
+	})
+}
+
+// write resolves an item once and writes it to every replica at the same
+// time. The write succeeds when at least `quorum` replicas accept it.
+// Otherwise, the item is removed from the replicas that accepted it.
+func (s *StorageServer) write(
+	ctx context.Context,
+	resolve types.Resolver,
+	put func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error),
+) (string, string, error) {
+	type result struct {
+		dir     string
+		address string
+		err     error
+	}
+	results := make([]result, len(s.replicas))
+	pipes := make([]*io.PipeWriter, len(s.replicas))
+	writers := make([]io.Writer, len(s.replicas))
+
+	// The dir and address returned by the resolver. These are set before the
+	// pipes are closed.
+	var resolvedDir, resolvedAddress string
+
+	// Write to each replica from a pipe
+	var wg sync.WaitGroup
+	for i, replica := range s.replicas {
+		pR, pW := io.Pipe()
+		pipes[i] = pW
+		writers[i] = &internal.BestEffortWriter{W: pW}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, a, err := put(replica, func(w io.Writer) (string, string, error) {
+				_, err := io.Copy(w, pR)
+				if err != nil {
+					return "", "", err
+				}
+				return resolvedDir, resolvedAddress, nil
+			})
+			// Unblock the resolver if the replica stops reading early
+			_ = pR.CloseWithError(internal.ErrNotResolved)
+			results[i] = result{dir: d, address: a, err: err}
+		}()
+	}
+
+	d, a, err := resolve(io.MultiWriter(writers...))
+	resolvedDir, resolvedAddress = d, a
+	for _, pW := range pipes {
+		if err != nil {
+			_ = pW.CloseWithError(err)
+		} else {
+			_ = pW.Close()
+		}
+	}
+	wg.Wait()
+	if err != nil {
+		return "", "", err
+	}
+
+	var errs error
+	written := make([]int, 0, len(s.replicas))
+	for i, r := range results {
+		if r.err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, r.err))
+		} else {
+			written = append(written, i)
+		}
+	}
+
+	if len(written) < s.quorum {
+		// Roll back the replicas that were written
+		for _, i := range written {
+			removeErr := s.replicas[i].Remove(ctx, results[i].dir, results[i].address)
+			if removeErr != nil {
+				errs = errors.Join(errs, s.replicaErr(i, removeErr))
+			}
+		}
+		return "", "", errors.Join(fmt.Errorf("%w: %d of %d replicas written, %d required", ErrQuorum, len(written), len(s.replicas), s.quorum), errs)
+	} else if errs != nil {
+		slog.Warn("item not written to all replicas", "dir", results[written[0]].dir, "address", results[written[0]].address, "error", errs)
+	}
+
+	return results[written[0]].dir, results[written[0]].address, nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	var errs error
+	for i, replica := range s.replicas {
+		err := replica.Remove(ctx, dir, address)
+		if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+		}
+	}
+	return errs
+}
+
+func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
+	for _, replica := range s.replicas {
+		replica.Flush(ctx, dir, address)
+	}
+}
+
+func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, error) {
+	var errs error
+	for i, replica := range s.replicas {
+		items, err := replica.Enumerate(ctx)
+		if err == nil {
+			return items, nil
+		}
+		errs = errors.Join(errs, s.replicaErr(i, err))
+	}
+	return nil, errs
+}
+
+func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	var errs error
+	for i, replica := range s.replicas {
+		ok, _, _, _, err := replica.Check(ctx, dir, address)
+		if err != nil {
+			errs = errors.Join(errs, s.replicaErr(i, err))
+			continue
+		} else if !ok {
+			continue
+		}
+		err = replica.Copy(ctx, dir, address, server)
+		if err == nil {
+			return nil
+		}
+		errs = errors.Join(errs, s.replicaErr(i, err))
+	}
+	if errs == nil {
+		return fmt.Errorf("the replicated item with dir=%s and address=%s to copy does not exist", dir, address)
+	}
+	return errs
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.Copy(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	return s.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s.StorageServer.Base()
+}
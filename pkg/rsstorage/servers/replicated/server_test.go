@@ -0,0 +1,273 @@
+package replicated
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type ReplicatedStorageServerSuite struct{}
+
+var _ = check.Suite(&ReplicatedStorageServerSuite{})
+
+// failingServer wraps a storage server and fails some operations.
+type failingServer struct {
+	rsstorage.StorageServer
+	putErr   error
+	checkErr error
+	getErr   error
+}
+
+func (f *failingServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	if f.checkErr != nil {
+		return false, nil, 0, time.Time{}, f.checkErr
+	}
+	return f.StorageServer.Check(ctx, dir, address)
+}
+
+func (f *failingServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	if f.getErr != nil {
+		return nil, nil, 0, time.Time{}, false, f.getErr
+	}
+	return f.StorageServer.Get(ctx, dir, address)
+}
+
+func (f *failingServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	if f.putErr != nil {
+		return "", "", f.putErr
+	}
+	return f.StorageServer.Put(ctx, resolve, dir, address)
+}
+
+func (s *ReplicatedStorageServerSuite) TestNew(c *check.C) {
+	_, err := NewStorageServer(StorageServerArgs{})
+	c.Check(err, check.Equals, ErrNoReplicas)
+
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	_, err = NewStorageServer(StorageServerArgs{
+		Replicas:    []rsstorage.StorageServer{primary, secondary},
+		WriteQuorum: 3,
+	})
+	c.Check(err, check.ErrorMatches, "write quorum 3 exceeds the number of replicas 2")
+
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(server.quorum, check.Equals, 2)
+	c.Check(server.Base(), check.Equals, primary)
+	c.Check(server.Dir(), check.Equals, "memory:primary")
+	c.Check(server.Replicas(), check.DeepEquals, []rsstorage.StorageServer{primary, secondary})
+}
+
+func (s *ReplicatedStorageServerSuite) TestPut(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+
+	d, a, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "dir")
+	c.Check(a, check.Equals, "address")
+	c.Check(servertest.ReadItem(c, primary, "dir", "address"), check.Equals, "some data")
+	c.Check(servertest.ReadItem(c, secondary, "dir", "address"), check.Equals, "some data")
+
+	// Deferred address
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err = server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.ReadItem(c, secondary, d, a), check.Equals, "deferred")
+
+	// Chunked
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, primary, "dir", "chunked"), check.Equals, servertest.TestDESC)
+	c.Check(servertest.ReadItem(c, secondary, "dir", "chunked"), check.Equals, servertest.TestDESC)
+
+	// Resolver error
+	failing := func(w io.Writer) (string, string, error) {
+		return "", "", errors.New("resolve error")
+	}
+	_, _, err = server.Put(ctx, failing, "dir", "failed")
+	c.Check(err, check.ErrorMatches, "resolve error")
+	c.Check(servertest.Exists(c, primary, "dir", "failed"), check.Equals, false)
+	c.Check(servertest.Exists(c, secondary, "dir", "failed"), check.Equals, false)
+
+	// Removed from all replicas
+	c.Assert(server.Remove(ctx, "dir", "address"), check.IsNil)
+	c.Check(servertest.Exists(c, primary, "dir", "address"), check.Equals, false)
+	c.Check(servertest.Exists(c, secondary, "dir", "address"), check.Equals, false)
+}
+
+func (s *ReplicatedStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	_, _, err = server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "address", meta)
+	c.Assert(err, check.IsNil)
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz, meta)
+	c.Assert(err, check.IsNil)
+	for _, replica := range server.Replicas() {
+		for _, address := range []string{"address", "chunked"} {
+			_, _, _, _, got, err := rsstorage.CheckWithMetadata(ctx, replica, "dir", address)
+			c.Assert(err, check.IsNil)
+			c.Check(got, check.DeepEquals, meta)
+		}
+	}
+
+	// Read from a secondary replica
+	c.Assert(primary.Remove(ctx, "dir", "address"), check.IsNil)
+	ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "some data")
+
+	// Replicas that cannot store metadata fail the write
+	plain := &failingServer{StorageServer: memtest.NewServer("plain", 352)}
+	server, err = NewStorageServer(StorageServerArgs{
+		Replicas:    []rsstorage.StorageServer{primary, plain},
+		WriteQuorum: 1,
+	})
+	c.Assert(err, check.IsNil)
+	_, _, err = server.PutWithMetadata(ctx, servertest.StringResolver("more data"), "dir", "more", meta)
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, primary, "dir", "more"), check.Equals, true)
+	c.Check(servertest.Exists(c, plain, "dir", "more"), check.Equals, false)
+}
+
+func (s *ReplicatedStorageServerSuite) TestPutQuorum(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := &failingServer{
+		StorageServer: memtest.NewServer("secondary", 352),
+		putErr:        errors.New("put error"),
+	}
+	tertiary := memtest.NewServer("tertiary", 352)
+
+	// Two of three replicas is enough
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas:    []rsstorage.StorageServer{primary, secondary, tertiary},
+		WriteQuorum: 2,
+	})
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, primary, "dir", "address"), check.Equals, true)
+	c.Check(servertest.Exists(c, tertiary, "dir", "address"), check.Equals, true)
+
+	// All three are required
+	server, err = NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary, tertiary},
+	})
+	c.Assert(err, check.IsNil)
+	_, _, err = server.Put(ctx, servertest.StringResolver("some data"), "dir", "other")
+	c.Assert(errors.Is(err, ErrQuorum), check.Equals, true)
+	c.Check(err, check.ErrorMatches, "(?s)write quorum not met: 2 of 3 replicas written, 3 required.*replica 1 \\(memory:secondary\\): put error")
+	var replicaErr *ReplicaError
+	c.Assert(errors.As(err, &replicaErr), check.Equals, true)
+	c.Check(replicaErr.Replica, check.Equals, 1)
+
+	// The successful writes are rolled back
+	c.Check(servertest.Exists(c, primary, "dir", "other"), check.Equals, false)
+	c.Check(servertest.Exists(c, tertiary, "dir", "other"), check.Equals, false)
+}
+
+func (s *ReplicatedStorageServerSuite) TestReadFallback(c *check.C) {
+	ctx := context.Background()
+	primary := &failingServer{
+		StorageServer: memtest.NewServer("primary", 352),
+	}
+	secondary := memtest.NewServer("secondary", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+
+	// Only on the secondary
+	_, _, err = secondary.Put(ctx, servertest.StringResolver("secondary data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, server, "dir", "address"), check.Equals, true)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "secondary data")
+	r, _, sz, _, ok, err := server.GetRange(ctx, "dir", "address", 10, -1)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(14))
+	b, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, "data")
+
+	// The primary errors
+	_, _, err = primary.StorageServer.Put(ctx, servertest.StringResolver("primary data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "primary data")
+	primary.checkErr = errors.New("check error")
+	primary.getErr = errors.New("get error")
+	c.Check(servertest.Exists(c, server, "dir", "address"), check.Equals, true)
+	c.Check(servertest.ReadItem(c, server, "dir", "address"), check.Equals, "secondary data")
+
+	// Missing everywhere reports the errors
+	_, _, _, _, ok, err = server.Get(ctx, "dir", "missing")
+	c.Check(ok, check.Equals, false)
+	c.Check(err, check.ErrorMatches, "replica 0 \\(memory:primary\\): get error")
+	primary.getErr = nil
+	_, _, _, _, ok, err = server.Get(ctx, "dir", "missing")
+	c.Check(ok, check.Equals, false)
+	c.Check(err, check.IsNil)
+}
+
+func (s *ReplicatedStorageServerSuite) TestCopyMove(c *check.C) {
+	ctx := context.Background()
+	primary := memtest.NewServer("primary", 352)
+	secondary := memtest.NewServer("secondary", 352)
+	dest := memtest.NewServer("dest", 352)
+	server, err := NewStorageServer(StorageServerArgs{
+		Replicas: []rsstorage.StorageServer{primary, secondary},
+	})
+	c.Assert(err, check.IsNil)
+
+	err = server.Copy(ctx, "dir", "missing", dest)
+	c.Check(err, check.ErrorMatches, "the replicated item with dir=dir and address=missing to copy does not exist")
+
+	// Copy from the secondary when missing from the primary
+	_, _, err = secondary.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "address", dest), check.IsNil)
+	c.Check(servertest.ReadItem(c, dest, "dir", "address"), check.Equals, "some data")
+	c.Check(servertest.Exists(c, secondary, "dir", "address"), check.Equals, false)
+}
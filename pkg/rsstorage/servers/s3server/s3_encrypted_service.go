@@ -99,6 +99,14 @@ func (s *EncryptedS3Wrapper) MoveObject(ctx context.Context, oldBucket, oldKey,
 	return out, nil
 }
 
+func (s *EncryptedS3Wrapper) ListObjectsPage(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	out, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("error encountered while listing objects: %w", err)
+	}
+	return out, nil
+}
+
 func (s *EncryptedS3Wrapper) ListObjects(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
 	// In AWS SDK v2, we need to handle pagination manually
 	// Create a paginator to iterate through all pages. S3API satisfies the
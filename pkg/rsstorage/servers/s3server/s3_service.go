@@ -57,6 +57,12 @@ type S3Wrapper interface {
 	KmsEncrypted() bool
 }
 
+// PageLister is implemented by S3 wrappers that can list a single page of
+// objects. `ListObjects` lists all the pages at once.
+type PageLister interface {
+	ListObjectsPage(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+}
+
 type DefaultS3Wrapper struct {
 	client S3API
 }
@@ -223,6 +229,14 @@ func (s *DefaultS3Wrapper) MoveObject(ctx context.Context, oldBucket, oldKey, ne
 	return out, nil
 }
 
+func (s *DefaultS3Wrapper) ListObjectsPage(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	out, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("error encountered while listing objects: %w", err)
+	}
+	return out, nil
+}
+
 func (s *DefaultS3Wrapper) ListObjects(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
 	// In AWS SDK v2, we need to handle pagination manually
 	// Create a paginator to iterate through all pages. S3API satisfies the
@@ -8,14 +8,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"log/slog"
 	"net/url"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	awsTypes "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/c2h5oh/datasize"
 	"github.com/google/uuid"
 
 	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
@@ -25,6 +32,44 @@ import (
 
 const AmzUnencryptedContentLengthHeader = "X-Amz-Unencrypted-Content-Length"
 
+// UserMetadataPrefix starts the S3 metadata keys that hold the user metadata
+// of an object. This keeps user metadata apart from the metadata recorded by
+// S3 clients, e.g., for client-side encryption.
+const UserMetadataPrefix = "user-"
+
+// toS3Metadata converts user metadata to S3 object metadata.
+func toS3Metadata(meta types.Metadata) map[string]string {
+	if len(meta) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(meta))
+	for k, v := range meta.Normalize() {
+		out[UserMetadataPrefix+k] = v
+	}
+	return out
+}
+
+// fromS3Metadata extracts the user metadata from S3 object metadata. S3
+// returns metadata keys in lower case.
+func fromS3Metadata(metadata map[string]string) types.Metadata {
+	var meta types.Metadata
+	for k, v := range metadata {
+		k = strings.ToLower(k)
+		if key, ok := strings.CutPrefix(k, UserMetadataPrefix); ok {
+			if meta == nil {
+				meta = make(types.Metadata)
+			}
+			meta[key] = v
+		}
+	}
+	return meta
+}
+
+const (
+	defaultUsageTimeout  = 5 * time.Minute
+	defaultUsageCacheTTL = time.Minute
+)
+
 type moveOrCopyFn func(ctx context.Context, oldBucket, oldKey, newBucket, newKey string) (*s3.CopyObjectOutput, error)
 
 type StorageServer struct {
@@ -34,6 +79,19 @@ type StorageServer struct {
 	move    moveOrCopyFn
 	copy    moveOrCopyFn
 	chunker rsstorage.ChunkUtils
+
+	maxBytes      datasize.ByteSize
+	usageTimeout  time.Duration
+	usageCacheTTL time.Duration
+	usage         *usageCache
+}
+
+// usageCache holds the last usage calculated, since listing a large bucket
+// is slow.
+type usageCache struct {
+	mutex      sync.Mutex
+	usage      types.Usage
+	calculated time.Time
 }
 
 type StorageServerArgs struct {
@@ -43,6 +101,28 @@ type StorageServerArgs struct {
 	ChunkSize uint64
 	Waiter    rsstorage.ChunkWaiter
 	Notifier  rsstorage.ChunkNotifier
+
+	// ChunkConcurrency is the number of chunks to upload at once, and
+	// ChunkReadAhead is the number of chunks to prefetch when reading. Each
+	// chunk in flight is buffered in memory. Values below two read and write
+	// one chunk at a time.
+	ChunkConcurrency int
+	ChunkReadAhead   int
+
+	// ResumeChunked resumes interrupted chunked writes instead of starting
+	// over. See `internal.DefaultChunkUtils.Resume`.
+	ResumeChunked bool
+
+	// MaxBytes is the byte budget reported by CalculateUsage. It is not
+	// enforced when writing data.
+	MaxBytes datasize.ByteSize
+
+	// UsageTimeout limits the time spent listing objects to calculate usage.
+	// Defaults to five minutes. UsageCacheTTL is how long a calculated usage
+	// is reused. Defaults to one minute, and usage is calculated on every
+	// call when negative.
+	UsageTimeout  time.Duration
+	UsageCacheTTL time.Duration
 }
 
 func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
@@ -66,7 +146,14 @@ func NewStorageServer(args StorageServerArgs) rsstorage.StorageServer {
 			Notifier:    args.Notifier,
 			PollTimeout: rsstorage.DefaultChunkPollTimeout,
 			MaxAttempts: rsstorage.DefaultMaxChunkAttempts,
+			Concurrency: args.ChunkConcurrency,
+			ReadAhead:   args.ChunkReadAhead,
+			Resume:      args.ResumeChunked,
 		},
+		maxBytes:      args.MaxBytes,
+		usageTimeout:  args.UsageTimeout,
+		usageCacheTTL: args.UsageCacheTTL,
+		usage:         &usageCache{},
 	}
 }
 
@@ -115,6 +202,11 @@ func (s *StorageServer) Validate(ctx context.Context) error {
 }
 
 func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	found, chunked, sz, mod, _, err := s.CheckWithMetadata(ctx, dir, address)
+	return found, chunked, sz, mod, err
+}
+
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
 	var chunked bool
 	var contentLength int64
 	addr := internal.NotEmptyJoin([]string{s.prefix, dir, address}, "/")
@@ -140,7 +232,7 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *
 			},
 		)
 		if err != nil && (errors.As(err, &nsk) || errors.As(err, &nf)) {
-			return false, nil, 0, time.Time{}, nil
+			return false, nil, 0, time.Time{}, nil, nil
 		}
 		chunked = true
 	}
@@ -148,14 +240,14 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *
 	// If `err` is still set, we know that neither the standard nor the chunked request
 	// were successful
 	if err != nil {
-		return false, nil, 0, time.Time{}, err
+		return false, nil, 0, time.Time{}, nil, err
 	}
 
 	if chunked {
 		// For chunked assets, download the `info.json`, decode it, and use it to craft a response.
 		resp, err := s.svc.GetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &infoAddr})
 		if err != nil {
-			return false, nil, 0, time.Time{}, err
+			return false, nil, 0, time.Time{}, nil, err
 		}
 		// TODO: handle this error gracefully
 		defer resp.Body.Close()
@@ -163,9 +255,9 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *
 		info := types.ChunksInfo{}
 		err = dec.Decode(&info)
 		if err != nil {
-			return false, nil, 0, time.Time{}, err
+			return false, nil, 0, time.Time{}, nil, err
 		}
-		return true, &info, int64(info.FileSize), info.ModTime, nil
+		return true, &info, int64(info.FileSize), info.ModTime, info.Metadata, nil
 	} else {
 		// Check some headers for the unencrypted content length for KMS encrypted objects.
 		if s.svc.KmsEncrypted() {
@@ -177,7 +269,7 @@ func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *
 		}
 
 		// For standard assets, the HeadObject response has the information we need.
-		return true, nil, contentLength, *resp.LastModified, nil
+		return true, nil, contentLength, *resp.LastModified, fromS3Metadata(resp.Metadata), nil
 	}
 }
 
@@ -189,12 +281,71 @@ func (s *StorageServer) Type() types.StorageType {
 	return rsstorage.StorageTypeS3
 }
 
+// CalculateUsage sums the sizes of the objects under the prefix, a page of
+// objects at a time when the S3 wrapper implements `PageLister`. The size and
+// free values are derived from the configured `MaxBytes` budget, and are zero
+// when no budget is configured.
 func (s *StorageServer) CalculateUsage() (types.Usage, error) {
-	// Currently unused.
-	return types.Usage{}, fmt.Errorf("server s3server.StorageServer does not implement CalculateUsage")
+	s.usage.mutex.Lock()
+	defer s.usage.mutex.Unlock()
+
+	ttl := s.usageCacheTTL
+	if ttl == 0 {
+		ttl = defaultUsageCacheTTL
+	}
+	if ttl > 0 && !s.usage.calculated.IsZero() && time.Since(s.usage.calculated) < ttl {
+		return s.usage.usage, nil
+	}
+
+	start := time.Now()
+
+	timeout := s.usageTimeout
+	if timeout == 0 {
+		timeout = defaultUsageTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	prefix := s.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	var used datasize.ByteSize
+	var objects int
+	err := s.listPages(ctx, &s3.ListObjectsV2Input{Bucket: &s.bucket, Prefix: &prefix}, func(page *s3.ListObjectsV2Output) bool {
+		for _, obj := range page.Contents {
+			used += datasize.ByteSize(aws.ToInt64(obj.Size))
+		}
+		objects += len(page.Contents)
+		return true
+	})
+	if err != nil {
+		return types.Usage{}, fmt.Errorf("error calculating usage for bucket %s and prefix %s: %w", s.bucket, s.prefix, err)
+	}
+	free := datasize.ByteSize(0)
+	if s.maxBytes > used {
+		free = s.maxBytes - used
+	}
+
+	elapsed := time.Since(start)
+	slog.Debug("Calculated S3 usage", "bucket", s.bucket, "prefix", s.prefix, "objects", objects, "elapsed", elapsed)
+
+	s.usage.usage = types.Usage{
+		SizeBytes:       s.maxBytes,
+		FreeBytes:       free,
+		UsedBytes:       used,
+		CalculationTime: elapsed,
+	}
+	s.usage.calculated = time.Now()
+	return s.usage.usage, nil
 }
 
 func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	r, c, sz, mod, _, ok, err := s.GetWithMetadata(ctx, dir, address)
+	return r, c, sz, mod, ok, err
+}
+
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
 	var chunked bool
 	var contentLength int64
 	addr := internal.NotEmptyJoin([]string{s.prefix, dir, address}, "/")
@@ -207,26 +358,26 @@ func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCl
 		// The item was not found, so check to see if it was chunked.
 		_, err = s.svc.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: &infoAddr})
 		if err != nil && (errors.As(err, &nsk) || errors.As(err, &nf)) {
-			return nil, nil, 0, time.Time{}, false, nil
+			return nil, nil, 0, time.Time{}, nil, false, nil
 		}
 		chunked = true
 
 		// If `err` is still set, we know that neither the standard nor the chunked request
 		// were successful
 		if err != nil {
-			return nil, nil, 0, time.Time{}, false, err
+			return nil, nil, 0, time.Time{}, nil, false, err
 		}
 	} else if err != nil {
-		return nil, nil, 0, time.Time{}, false, err
+		return nil, nil, 0, time.Time{}, nil, false, err
 	}
 
 	if chunked {
 		// For chunked assets, use the chunk utils to read the chunks sequentially
 		r, c, sz, mod, err := s.chunker.ReadChunked(ctx, dir, address)
 		if err != nil {
-			return nil, nil, 0, time.Time{}, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
+			return nil, nil, 0, time.Time{}, nil, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
 		}
-		return r, c, sz, mod, true, nil
+		return r, c, sz, mod, c.Metadata, true, nil
 	} else {
 		// Check some headers for the unencrypted content length for KMS encrypted objects.
 		if s.svc.KmsEncrypted() {
@@ -238,14 +389,120 @@ func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCl
 		}
 
 		// For standard assets, the GetObject response has the information we need.
-		return resp.Body, nil, contentLength, *resp.LastModified, true, nil
+		return resp.Body, nil, contentLength, *resp.LastModified, fromS3Metadata(resp.Metadata), true, nil
+	}
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	// A range starting at zero without a length is a normal read
+	if offset == 0 && length < 0 {
+		return s.Get(ctx, dir, address)
+	}
+
+	// An empty range cannot be expressed as an HTTP range, so just validate it
+	if length == 0 {
+		ok, chunked, sz, mod, err := s.Check(ctx, dir, address)
+		if err != nil || !ok {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+		_, err = internal.ClampRange(sz, offset, length)
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+		return io.NopCloser(strings.NewReader("")), chunked, sz, mod, true, nil
+	}
+
+	addr := internal.NotEmptyJoin([]string{s.prefix, dir, address}, "/")
+	infoAddr := filepath.Join(addr, "info.json")
+	input := &s3.GetObjectInput{Bucket: &s.bucket, Key: &addr}
+
+	// Objects encrypted on the client side must be downloaded from the start to be
+	// decrypted, so only request a range for unencrypted objects.
+	if !s.svc.KmsEncrypted() {
+		input.Range = aws.String(httpRange(offset, length))
+	}
+	resp, err := s.svc.GetObject(ctx, input)
+
+	var nsk *awsTypes.NoSuchKey
+	var nf *awsTypes.NotFound
+	var apiErr smithy.APIError
+	if err != nil && (errors.As(err, &nsk) || errors.As(err, &nf)) {
+		// The item was not found, so check to see if it was chunked.
+		_, err = s.svc.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: &infoAddr})
+		if err != nil && (errors.As(err, &nsk) || errors.As(err, &nf)) {
+			return nil, nil, 0, time.Time{}, false, nil
+		} else if err != nil {
+			return nil, nil, 0, time.Time{}, false, err
+		}
+
+		// For chunked assets, use the chunk utils to read only the chunks covering the range
+		r, c, sz, mod, err := rsstorage.ReadChunkedRange(ctx, s.chunker, dir, address, offset, length)
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, fmt.Errorf("error reading chunked directory files for %s: %w", address, err)
+		}
+		return r, c, sz, mod, true, nil
+	} else if err != nil && errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return nil, nil, 0, time.Time{}, false, rsstorage.ErrInvalidRange
+	} else if err != nil {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+
+	if s.svc.KmsEncrypted() {
+		// Check some headers for the unencrypted content length for KMS encrypted objects.
+		var contentLength int64
+		if cl, ok := resp.Metadata[AmzUnencryptedContentLengthHeader]; ok {
+			contentLength, _ = strconv.ParseInt(cl, 10, 64)
+		}
+		n, err := internal.ClampRange(contentLength, offset, length)
+		if err == nil {
+			// Skip the bytes before the range
+			_, err = io.CopyN(io.Discard, resp.Body, offset)
+		}
+		if err != nil {
+			return nil, nil, 0, time.Time{}, false, errors.Join(err, resp.Body.Close())
+		}
+		return internal.LimitReadCloser(resp.Body, n), nil, contentLength, *resp.LastModified, true, nil
+	}
+
+	// The full size of the object is reported in the Content-Range header
+	contentLength := offset + aws.ToInt64(resp.ContentLength)
+	if resp.ContentRange != nil {
+		if sz, err := rangeSize(*resp.ContentRange); err == nil {
+			contentLength = sz
+		}
 	}
+	return resp.Body, nil, contentLength, *resp.LastModified, true, nil
+}
+
+// httpRange formats an HTTP `Range` header value for a byte range. A negative
+// length requests all bytes through the end of the object.
+func httpRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// rangeSize parses the full object size from a `Content-Range` header value
+// like `bytes 0-99/1234`.
+func rangeSize(contentRange string) (int64, error) {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid content range '%s'", contentRange)
+	}
+	return strconv.ParseInt(contentRange[i+1:], 10, 64)
 }
 
 func (s *StorageServer) Flush(ctx context.Context, dir, address string) {
 }
 
 func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+// PutWithMetadata writes an item with its user metadata stored as S3 object
+// metadata. S3 only supports US-ASCII metadata.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
 	// Pipe the results so we can resolve the item and simultaneously
 	// write it to S3
 	r, w := io.Pipe()
@@ -276,9 +533,10 @@ func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, ad
 	_, err := s.svc.Upload(
 		newCtx,
 		&s3.PutObjectInput{
-			Bucket: &s.bucket,
-			Key:    &uploadAddr,
-			Body:   r,
+			Bucket:   &s.bucket,
+			Key:      &uploadAddr,
+			Body:     r,
+			Metadata: toS3Metadata(meta),
 		},
 	)
 
@@ -312,13 +570,17 @@ func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, ad
 }
 
 func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
 	if address == "" {
 		return "", "", fmt.Errorf("cache only supports pre-addressed chunked put commands")
 	}
 	if sz == 0 {
 		return "", "", fmt.Errorf("cache only supports pre-sized chunked put commands")
 	}
-	err := s.chunker.WriteChunked(ctx, dir, address, sz, resolve)
+	err := rsstorage.WriteChunkedWithMetadata(ctx, s.chunker, dir, address, sz, meta, resolve)
 	if err != nil {
 		return "", "", err
 	}
@@ -377,6 +639,92 @@ func (s *StorageServer) Enumerate(ctx context.Context) ([]types.StoredItem, erro
 	return internal.FilterChunks(items), nil
 }
 
+// EnumerateItems streams the items under the prefix in key order, listing a
+// page of objects at a time when the S3 wrapper implements `PageLister`.
+// Items are relative to the prefix, and their tokens are their keys
+// relative to the prefix.
+func (s *StorageServer) EnumerateItems(ctx context.Context, opts types.EnumerateOptions) iter.Seq2[types.StoredItem, error] {
+	return func(yield func(types.StoredItem, error) bool) {
+		keyPrefix := s.prefix
+		if keyPrefix != "" && !strings.HasSuffix(keyPrefix, "/") {
+			keyPrefix += "/"
+		}
+		listPrefix := keyPrefix + opts.Prefix
+		input := &s3.ListObjectsV2Input{Bucket: &s.bucket, Prefix: &listPrefix}
+		if opts.After != "" {
+			input.StartAfter = aws.String(keyPrefix + opts.After)
+		}
+
+		filtered := func(item types.StoredItem) bool {
+			if !internal.MatchesPrefix(item, opts) {
+				return true
+			}
+			return yield(item, nil)
+		}
+
+		grouper := &internal.ChunkGrouper{}
+		stopped := false
+		err := s.listPages(ctx, input, func(page *s3.ListObjectsV2Output) bool {
+			for _, obj := range page.Contents {
+				if !strings.HasPrefix(aws.ToString(obj.Key), listPrefix) {
+					continue
+				}
+				key := strings.TrimPrefix(aws.ToString(obj.Key), keyPrefix)
+				if key <= opts.After {
+					continue
+				}
+				dir, address := path.Split(key)
+				file := types.StoredItem{
+					Dir:     strings.TrimSuffix(dir, "/"),
+					Address: address,
+					Size:    aws.ToInt64(obj.Size),
+					ModTime: aws.ToTime(obj.LastModified),
+					ETag:    aws.ToString(obj.ETag),
+					Token:   key,
+				}
+				if !grouper.Add(file, filtered) {
+					stopped = true
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			yield(types.StoredItem{}, fmt.Errorf("error enumerating bucket %s and prefix %s: %w", s.bucket, listPrefix, err))
+			return
+		} else if stopped {
+			return
+		}
+		grouper.Flush(filtered)
+	}
+}
+
+// listPages lists the objects matching `input`, and calls `fn` with each
+// page until it returns false. Objects are listed a page at a time when the
+// S3 wrapper implements `PageLister`, and otherwise all at once.
+func (s *StorageServer) listPages(ctx context.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output) bool) error {
+	lister, paged := s.svc.(PageLister)
+	for {
+		var page *s3.ListObjectsV2Output
+		var err error
+		if paged {
+			page, err = lister.ListObjectsPage(ctx, input)
+		} else {
+			page, err = s.svc.ListObjects(ctx, input)
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(page) {
+			return nil
+		}
+		if !paged || !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			return nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+}
+
 func (s *StorageServer) moveOrCopy(ctx context.Context, dir, address string, server rsstorage.StorageServer, fn moveOrCopyFn) error {
 	// Get a list of parts to copy. This works for either single-part or chunked assets
 	parts, err := s.parts(ctx, dir, address)
@@ -477,12 +825,13 @@ func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rs
 
 	// Normal copy
 	if s3Copy {
-		f, chunked, sz, _, ok, err := s.Get(ctx, dir, address)
+		f, chunked, sz, _, meta, ok, err := s.GetWithMetadata(ctx, dir, address)
 		if err == nil && !ok {
 			return fmt.Errorf("the S3 object with dir=%s and address=%s to copy does not exist", dir, address)
 		} else if err != nil {
 			return err
 		}
+		defer f.Close()
 
 		install := func(file io.ReadCloser) types.Resolver {
 			return func(writer io.Writer) (string, string, error) {
@@ -492,11 +841,7 @@ func (s *StorageServer) Copy(ctx context.Context, dir, address string, server rs
 		}
 
 		// Use the server Base() in case the server is wrapped, e.g., `Metadatarsstorage.StorageServer`
-		if chunked != nil {
-			_, _, err = server.Base().PutChunked(ctx, install(f), dir, address, uint64(sz))
-		} else {
-			_, _, err = server.Base().Put(ctx, install(f), dir, address)
-		}
+		err = rsstorage.PutCopy(ctx, server.Base(), install(f), dir, address, chunked != nil, uint64(sz), meta)
 		if err != nil {
 			return err
 		}
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/c2h5oh/datasize"
 	"github.com/fortytw2/leaktest"
 	"gopkg.in/check.v1"
 
@@ -55,6 +59,7 @@ type fakeS3 struct {
 	get          *s3.GetObjectOutput
 	getErr       error
 	got          string
+	gotRange     string
 	getMap       map[string]GetResponse
 	delete       *s3.DeleteObjectOutput
 	deleteErr    error
@@ -62,6 +67,7 @@ type fakeS3 struct {
 	upload       *manager.UploadOutput
 	uploadErr    error
 	uploaded     string
+	uploadedMeta map[string]string
 	bucket       string
 	address      string
 	moveTo       string
@@ -70,6 +76,10 @@ type fakeS3 struct {
 	copyError    error
 	list         []string
 	listError    error
+	listSizes    map[string]int64
+	listPrefix   string
+	listDelay    time.Duration
+	listed       int
 	bucketIn     *s3.CreateBucketInput
 	bucketOut    *s3.CreateBucketOutput
 	bucketErr    error
@@ -120,6 +130,7 @@ func (s *fakeS3) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.G
 	}
 	if s.getErr == nil {
 		s.got = *input.Key
+		s.gotRange = aws.ToString(input.Range)
 	}
 	return s.get, s.getErr
 }
@@ -151,8 +162,18 @@ func (s *fakeS3) CopyObject(ctx context.Context, oldBucket, oldKey, newBucket, n
 func (s *fakeS3) ListObjects(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
 	var contents []types.Object
 
+	s.listed++
+	s.listPrefix = aws.ToString(input.Prefix)
+	if s.listDelay > 0 {
+		select {
+		case <-time.After(s.listDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
 	for _, key := range s.list {
-		contents = append(contents, types.Object{Key: &key})
+		contents = append(contents, types.Object{Key: &key, Size: aws.Int64(s.listSizes[key])})
 	}
 
 	return &s3.ListObjectsV2Output{
@@ -168,6 +189,7 @@ func (s *fakeS3) Upload(ctx context.Context, input *s3.PutObjectInput, opFns ...
 			return nil, err
 		}
 		s.uploaded = buf.String()
+		s.uploadedMeta = input.Metadata
 		s.bucket = *input.Bucket
 		s.address = *input.Key
 
@@ -198,6 +220,7 @@ func (s *S3StorageServerSuite) TestNew(c *check.C) {
 		bucket: "test",
 		prefix: "prefix",
 		svc:    svc,
+		usage:  &usageCache{},
 	})
 
 	c.Assert(server.Dir(), check.Equals, "s3:test")
@@ -412,6 +435,101 @@ func (s *S3StorageServerSuite) TestGet(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 }
 
+func (s *S3StorageServerSuite) TestGetRange(c *check.C) {
+	output := &testReadCloser{bytes.NewBufferString("test output")}
+	now := time.Now()
+	svc := &fakeS3{
+		get: &s3.GetObjectOutput{
+			Body:          output,
+			ContentLength: aws.Int64(10),
+			ContentRange:  aws.String("bytes 5-14/45"),
+			LastModified:  aws.Time(now),
+		},
+	}
+	server := &StorageServer{
+		svc:    svc,
+		prefix: "prefix",
+	}
+	ctx := context.Background()
+
+	// Ok
+	rs, ch, sz, mod, ok, err := server.GetRange(ctx, "dir", "address", 5, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(rs, check.FitsTypeOf, &testReadCloser{})
+	c.Assert(ch, check.IsNil)
+	c.Assert(sz, check.Equals, int64(45))
+	c.Assert(mod, servertest.TimeEquals, now)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(svc.got, check.Equals, "prefix/dir/address")
+	c.Assert(svc.gotRange, check.Equals, "bytes=5-14")
+
+	// Ok, through the end without a Content-Range header
+	svc.get.ContentRange = nil
+	_, _, sz, _, ok, err = server.GetRange(ctx, "dir", "address", 35, -1)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(sz, check.Equals, int64(45))
+	c.Assert(svc.gotRange, check.Equals, "bytes=35-")
+
+	// Invalid range
+	svc.getErr = &smithy.GenericAPIError{Code: "InvalidRange"}
+	rs, _, _, _, ok, err = server.GetRange(ctx, "dir", "address", 50, 10)
+	c.Assert(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+	c.Assert(rs, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	// Error
+	svc.getErr = errors.New("get error")
+	rs, _, _, _, ok, err = server.GetRange(ctx, "dir", "address", 5, 10)
+	c.Assert(err, check.ErrorMatches, "get error")
+	c.Assert(rs, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	// Missing
+	svc.getErr = &types.NoSuchKey{}
+	svc.headErr = &types.NoSuchKey{}
+	rs, _, _, _, ok, err = server.GetRange(ctx, "dir", "address", 5, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(rs, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	// Chunked
+	svc.headErr = nil
+	svc.headMap = map[string]HeadResponse{
+		"prefix/dir/address/info.json": {
+			head: &s3.HeadObjectOutput{},
+		},
+	}
+	server.chunker = &servertest.DummyChunkUtils{
+		Read: output,
+		ReadCh: &rtypes.ChunksInfo{
+			Complete: true,
+		},
+		ReadSz:  5454,
+		ReadMod: now,
+	}
+	rs, ch, sz, _, ok, err = server.GetRange(ctx, "dir", "address", 5, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(rs, check.DeepEquals, output)
+	c.Assert(ch, check.DeepEquals, &rtypes.ChunksInfo{
+		Complete: true,
+	})
+	c.Assert(sz, check.Equals, int64(5454))
+	c.Assert(ok, check.Equals, true)
+}
+
+func (s *S3StorageServerSuite) TestHttpRange(c *check.C) {
+	c.Check(httpRange(0, 10), check.Equals, "bytes=0-9")
+	c.Check(httpRange(100, 1), check.Equals, "bytes=100-100")
+	c.Check(httpRange(100, -1), check.Equals, "bytes=100-")
+
+	sz, err := rangeSize("bytes 0-99/1234")
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, int64(1234))
+	_, err = rangeSize("bytes 0-99")
+	c.Check(err, check.ErrorMatches, "invalid content range 'bytes 0-99'")
+}
+
 func (s *S3StorageServerSuite) TestPut(c *check.C) {
 	defer leaktest.Check(c)
 
@@ -453,6 +571,72 @@ func (s *S3StorageServerSuite) TestPut(c *check.C) {
 	c.Assert(err, check.ErrorMatches, "resolver error")
 }
 
+func (s *S3StorageServerSuite) TestMetadata(c *check.C) {
+	defer leaktest.Check(c)
+
+	resolver := func(w io.Writer) (string, string, error) {
+		_, err := io.Copy(w, bytes.NewBufferString("test input"))
+		return "", "", err
+	}
+	now := time.Now()
+	svc := &fakeS3{
+		upload: &manager.UploadOutput{},
+		head: &s3.HeadObjectOutput{
+			ContentLength: aws.Int64(10),
+			LastModified:  &now,
+			Metadata: map[string]string{
+				"user-content-type":  "text/plain",
+				"x-amz-key-v2":       "key",
+				"user-content-group": "docs",
+			},
+		},
+		get: &s3.GetObjectOutput{
+			Body:          &testReadCloser{bytes.NewBufferString("test input")},
+			ContentLength: aws.Int64(10),
+			LastModified:  &now,
+			Metadata:      map[string]string{"user-filename": "test.txt"},
+		},
+	}
+	server := &StorageServer{
+		svc:    svc,
+		bucket: "test-bucket",
+		prefix: "prefix",
+	}
+	ctx := context.Background()
+
+	// User metadata is stored with a prefix
+	_, _, err := server.PutWithMetadata(ctx, resolver, "dir", "address", rtypes.Metadata{
+		"Content-Type": "text/plain",
+		"filename":     "test.txt",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(svc.uploadedMeta, check.DeepEquals, map[string]string{
+		"user-content-type": "text/plain",
+		"user-filename":     "test.txt",
+	})
+
+	// No metadata
+	_, _, err = server.Put(ctx, resolver, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(svc.uploadedMeta, check.IsNil)
+
+	// Only user metadata is returned
+	ok, _, sz, _, meta, err := server.CheckWithMetadata(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(10))
+	c.Check(meta, check.DeepEquals, rtypes.Metadata{
+		"content-type":  "text/plain",
+		"content-group": "docs",
+	})
+
+	r, _, _, _, meta, ok, err := server.GetWithMetadata(ctx, "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(meta, check.DeepEquals, rtypes.Metadata{"filename": "test.txt"})
+	c.Assert(r.Close(), check.IsNil)
+}
+
 func (s *S3StorageServerSuite) TestPutDeferredAddress(c *check.C) {
 	defer leaktest.Check(c)
 
@@ -663,6 +847,125 @@ func (s *S3StorageServerSuite) TestEnumerateOk(c *check.C) {
 	})
 }
 
+// fakePagedS3 lists sorted pages of `pageSize` objects.
+type fakePagedS3 struct {
+	*fakeS3
+	pageSize int
+	etags    map[string]string
+	pages    []string
+}
+
+func (s *fakePagedS3) ListObjectsPage(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	after := aws.ToString(input.StartAfter)
+	if input.ContinuationToken != nil {
+		after = aws.ToString(input.ContinuationToken)
+	}
+	s.pages = append(s.pages, after)
+	if s.listError != nil {
+		return nil, s.listError
+	}
+
+	keys := slices.Sorted(slices.Values(s.list))
+	var contents []types.Object
+	for _, key := range keys {
+		if key <= after || !strings.HasPrefix(key, aws.ToString(input.Prefix)) {
+			continue
+		}
+		if len(contents) == s.pageSize {
+			return &s3.ListObjectsV2Output{
+				Contents:              contents,
+				IsTruncated:           aws.Bool(true),
+				NextContinuationToken: contents[len(contents)-1].Key,
+			}, nil
+		}
+		contents = append(contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(s.listSizes[key]),
+			ETag:         aws.String(s.etags[key]),
+			LastModified: aws.Time(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		})
+	}
+	return &s3.ListObjectsV2Output{Contents: contents, IsTruncated: aws.Bool(false)}, nil
+}
+
+func (s *S3StorageServerSuite) TestEnumerateItems(c *check.C) {
+	ctx := context.Background()
+	svc := &fakePagedS3{
+		fakeS3: &fakeS3{
+			list: []string{
+				"prefix/dir/address",
+				"prefix/dir/address3/00000001",
+				"prefix/dir/address3/00000002",
+				"prefix/dir/address3/info.json",
+				"prefix/nodir",
+				"other/address",
+			},
+			listSizes: map[string]int64{
+				"prefix/dir/address":            10,
+				"prefix/dir/address3/00000001":  4096,
+				"prefix/dir/address3/00000002":  100,
+				"prefix/dir/address3/info.json": 50,
+				"prefix/nodir":                  20,
+			},
+		},
+		pageSize: 2,
+		etags: map[string]string{
+			"prefix/dir/address": `"abc"`,
+			"prefix/nodir":       `"def"`,
+		},
+	}
+	server := &StorageServer{
+		bucket: "testbucket",
+		prefix: "prefix",
+		svc:    svc,
+	}
+
+	list := func(opts rtypes.EnumerateOptions) []rtypes.StoredItem {
+		items := make([]rtypes.StoredItem, 0)
+		for item, err := range server.EnumerateItems(ctx, opts) {
+			c.Assert(err, check.IsNil)
+			items = append(items, item)
+		}
+		return items
+	}
+
+	mod := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := list(rtypes.EnumerateOptions{})
+	c.Check(items, check.DeepEquals, []rtypes.StoredItem{
+		{Dir: "dir", Address: "address", Size: 10, ModTime: mod, ETag: `"abc"`, Token: "dir/address"},
+		{Dir: "dir", Address: "address3", Chunked: true, Size: 4196, ModTime: mod, Token: "dir/address3/info.json"},
+		{Address: "nodir", Size: 20, ModTime: mod, ETag: `"def"`, Token: "nodir"},
+	})
+	c.Check(svc.pages, check.DeepEquals, []string{"", "prefix/dir/address3/00000001", "prefix/dir/address3/info.json"})
+
+	// Resumed after each item
+	for i, item := range items {
+		c.Check(list(rtypes.EnumerateOptions{After: item.Token}), check.DeepEquals, items[i+1:])
+	}
+	c.Check(list(rtypes.EnumerateOptions{Prefix: "dir/"}), check.DeepEquals, items[:2])
+	c.Check(svc.listPrefix, check.Equals, "")
+
+	// Error
+	svc.listError = errors.New("list error")
+	for _, err := range server.EnumerateItems(ctx, rtypes.EnumerateOptions{Prefix: "dir/"}) {
+		c.Check(err, check.ErrorMatches, "error enumerating bucket testbucket and prefix prefix/dir/: list error")
+	}
+
+	// Without pages
+	svc.listError = nil
+	unpaged := &StorageServer{
+		bucket: "testbucket",
+		prefix: "prefix",
+		svc:    svc.fakeS3,
+	}
+	var tokens []string
+	for item, err := range unpaged.EnumerateItems(ctx, rtypes.EnumerateOptions{After: "dir/address"}) {
+		c.Assert(err, check.IsNil)
+		tokens = append(tokens, item.Token)
+	}
+	c.Check(tokens, check.DeepEquals, []string{"dir/address3/info.json", "nodir"})
+}
+
 type fakeMoveOrCopy struct {
 	result error
 	ops    []string
@@ -1027,9 +1330,78 @@ func (s *S3StorageServerSuite) TestUsage(c *check.C) {
 		Notifier:  wn,
 	})
 
+	// Error
+	svc.listError = errors.New("list error")
 	usage, err := server.CalculateUsage()
 	c.Assert(usage, check.DeepEquals, rtypes.Usage{})
-	c.Assert(err, check.NotNil)
+	c.Assert(err, check.ErrorMatches, "error calculating usage for bucket testbucket and prefix prefix: list error")
+	c.Check(svc.listPrefix, check.Equals, "prefix/")
+
+	// No budget
+	svc.listError = nil
+	svc.list = []string{"prefix/a", "prefix/b/info.json", "prefix/b/00000001"}
+	svc.listSizes = map[string]int64{"prefix/a": 100, "prefix/b/info.json": 50, "prefix/b/00000001": 4096}
+	usage, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(4246))
+	c.Check(usage.SizeBytes, check.Equals, datasize.ByteSize(0))
+	c.Check(usage.FreeBytes, check.Equals, datasize.ByteSize(0))
+
+	// With a budget, and a cached result
+	server = NewStorageServer(StorageServerArgs{
+		Bucket:        "testbucket",
+		Svc:           svc,
+		ChunkSize:     4096,
+		Waiter:        wn,
+		Notifier:      wn,
+		MaxBytes:      10 * datasize.KB,
+		UsageCacheTTL: time.Hour,
+	})
+	svc.listed = 0
+	usage, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(svc.listPrefix, check.Equals, "")
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(4246))
+	c.Check(usage.SizeBytes, check.Equals, 10*datasize.KB)
+	c.Check(usage.FreeBytes, check.Equals, datasize.ByteSize(10240-4246))
+	svc.list = nil
+	cached, err := server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(cached, check.DeepEquals, usage)
+	c.Check(svc.listed, check.Equals, 1)
+
+	// Usage is cached by default, and summed a page at a time
+	paged := &fakePagedS3{fakeS3: &fakeS3{}, pageSize: 2}
+	paged.list = []string{"prefix/a", "prefix/b/info.json", "prefix/b/00000001"}
+	paged.listSizes = map[string]int64{"prefix/a": 100, "prefix/b/info.json": 50, "prefix/b/00000001": 4096}
+	server = NewStorageServer(StorageServerArgs{
+		Bucket:    "testbucket",
+		Prefix:    "prefix",
+		Svc:       paged,
+		ChunkSize: 4096,
+		Waiter:    wn,
+		Notifier:  wn,
+	})
+	usage, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(usage.UsedBytes, check.Equals, datasize.ByteSize(4246))
+	c.Check(paged.pages, check.DeepEquals, []string{"", "prefix/b/00000001"})
+	_, err = server.CalculateUsage()
+	c.Assert(err, check.IsNil)
+	c.Check(paged.pages, check.HasLen, 2)
+
+	// Timeout
+	svc.listDelay = time.Minute
+	server = NewStorageServer(StorageServerArgs{
+		Bucket:       "testbucket",
+		Svc:          svc,
+		ChunkSize:    4096,
+		Waiter:       wn,
+		Notifier:     wn,
+		UsageTimeout: time.Millisecond,
+	})
+	_, err = server.CalculateUsage()
+	c.Check(errors.Is(err, context.DeadlineExceeded), check.Equals, true)
 }
 
 func (s *S3StorageServerSuite) TestValidate(c *check.C) {
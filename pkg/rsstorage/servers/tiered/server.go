@@ -0,0 +1,457 @@
+package tiered
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+// Tier identifies the storage tier that served an item.
+type Tier string
+
+const (
+	TierNone = Tier("")
+	TierFast = Tier("fast")
+	TierSlow = Tier("slow")
+)
+
+// StorageServer is a read-through storage server that pairs a fast tier (for
+// example, local disk) with a slow tier (for example, S3). The slow tier is
+// the source of truth. Reads are served from the fast tier when possible, and
+// items read from the slow tier are promoted into the fast tier. The fast tier
+// is kept within a size budget by evicting the least recently used items.
+type StorageServer struct {
+	rsstorage.StorageServer
+	fast         rsstorage.StorageServer
+	budget       datasize.ByteSize
+	writeThrough bool
+
+	// Tracks the items in the fast tier in least recently used order
+	mutex sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+	used  uint64
+}
+
+type StorageServerArgs struct {
+	// Fast is the storage server used as the fast tier.
+	Fast rsstorage.StorageServer
+
+	// Slow is the storage server used as the slow tier.
+	Slow rsstorage.StorageServer
+
+	// Budget is the maximum number of bytes to keep in the fast tier. Items
+	// larger than the budget are never promoted.
+	Budget datasize.ByteSize
+
+	// WriteThrough also writes new items to the fast tier when they are
+	// written to the slow tier.
+	WriteThrough bool
+}
+
+// entry is an item tracked in the fast tier.
+type entry struct {
+	dir     string
+	address string
+	size    uint64
+}
+
+func NewStorageServer(args StorageServerArgs) *StorageServer {
+	return &StorageServer{
+		StorageServer: args.Slow,
+		fast:          args.Fast,
+		budget:        args.Budget,
+		writeThrough:  args.WriteThrough,
+		lru:           list.New(),
+		items:         make(map[string]*list.Element),
+	}
+}
+
+// Scan tracks the items that are already present in the fast tier, for
+// example after a restart, and evicts items if the fast tier is over budget.
+func (s *StorageServer) Scan(ctx context.Context) error {
+	items, err := s.fast.Enumerate(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		ok, _, sz, _, err := s.fast.Check(ctx, item.Dir, item.Address)
+		if err != nil {
+			return err
+		} else if ok {
+			s.track(item.Dir, item.Address, uint64(sz))
+		}
+	}
+	return s.evict(ctx, "", "")
+}
+
+func (s *StorageServer) Check(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, error) {
+	_, ok, chunked, sz, mod, err := s.CheckTier(ctx, dir, address)
+	return ok, chunked, sz, mod, err
+}
+
+// CheckTier is like Check, but also reports the tier in which the item was
+// found. The tier is `TierNone` when the item is not found.
+func (s *StorageServer) CheckTier(ctx context.Context, dir, address string) (Tier, bool, *types.ChunksInfo, int64, time.Time, error) {
+	ok, chunked, sz, mod, err := s.fast.Check(ctx, dir, address)
+	if err != nil {
+		slog.Debug("unable to check fast tier", "dir", dir, "address", address, "error", err)
+	} else if ok {
+		s.track(dir, address, uint64(sz))
+		return TierFast, ok, chunked, sz, mod, nil
+	}
+
+	ok, chunked, sz, mod, err = s.StorageServer.Check(ctx, dir, address)
+	if err != nil || !ok {
+		return TierNone, ok, chunked, sz, mod, err
+	}
+	return TierSlow, ok, chunked, sz, mod, nil
+}
+
+func (s *StorageServer) Get(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	_, r, chunked, sz, mod, ok, err := s.GetTier(ctx, dir, address)
+	return r, chunked, sz, mod, ok, err
+}
+
+// GetTier is like Get, but also reports the tier that served the item. Items
+// that are promoted from the slow tier are reported as `TierSlow`, even though
+// the data is then read from the fast tier.
+func (s *StorageServer) GetTier(ctx context.Context, dir, address string) (Tier, io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	tier, err := s.ensureFast(ctx, dir, address)
+	if err != nil || tier == TierNone {
+		return TierNone, nil, nil, 0, time.Time{}, false, err
+	}
+
+	// Read from the fast tier when the item is there
+	if tier == TierFast || s.promoted(dir, address) {
+		r, chunked, sz, mod, ok, err := s.fast.Get(ctx, dir, address)
+		if err == nil && ok {
+			return tier, r, chunked, sz, mod, ok, nil
+		}
+		s.untrack(dir, address)
+	}
+
+	r, chunked, sz, mod, ok, err := s.StorageServer.Get(ctx, dir, address)
+	if err != nil || !ok {
+		return TierNone, nil, nil, 0, time.Time{}, ok, err
+	}
+	return TierSlow, r, chunked, sz, mod, ok, nil
+}
+
+func (s *StorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	tier, err := s.ensureFast(ctx, dir, address)
+	if err != nil || tier == TierNone {
+		return nil, nil, 0, time.Time{}, false, err
+	}
+
+	if tier == TierFast || s.promoted(dir, address) {
+		r, chunked, sz, mod, ok, err := rsstorage.GetRange(ctx, s.fast, dir, address, offset, length)
+		if errors.Is(err, rsstorage.ErrInvalidRange) {
+			return nil, nil, 0, time.Time{}, false, err
+		} else if err == nil && ok {
+			return r, chunked, sz, mod, ok, nil
+		}
+		s.untrack(dir, address)
+	}
+
+	return rsstorage.GetRange(ctx, s.StorageServer, dir, address, offset, length)
+}
+
+// ensureFast looks for an item in the fast tier, and promotes it from the slow
+// tier when it is missing and fits in the budget. It returns the tier in which
+// the item was found.
+func (s *StorageServer) ensureFast(ctx context.Context, dir, address string) (Tier, error) {
+	tier, ok, _, sz, _, err := s.CheckTier(ctx, dir, address)
+	if err != nil || !ok || tier == TierFast {
+		return tier, err
+	}
+
+	// Items that do not fit in the fast tier are only read from the slow tier
+	if uint64(sz) > uint64(s.budget) {
+		return tier, nil
+	}
+
+	err = s.StorageServer.Copy(ctx, dir, address, s.fast)
+	if err != nil {
+		slog.Warn("unable to promote item to fast tier", "dir", dir, "address", address, "error", err)
+		return tier, nil
+	}
+	s.track(dir, address, uint64(sz))
+	err = s.evict(ctx, dir, address)
+	if err != nil {
+		slog.Warn("unable to evict items from fast tier", "error", err)
+	}
+	return tier, nil
+}
+
+// CheckWithMetadata checks for an item like `Check`, and also returns its
+// metadata. The fast tier is only used when it stores metadata.
+func (s *StorageServer) CheckWithMetadata(ctx context.Context, dir, address string) (bool, *types.ChunksInfo, int64, time.Time, types.Metadata, error) {
+	if s.fastMetadata() {
+		ok, chunked, sz, mod, meta, err := rsstorage.CheckWithMetadata(ctx, s.fast, dir, address)
+		if err != nil {
+			slog.Debug("unable to check fast tier", "dir", dir, "address", address, "error", err)
+		} else if ok {
+			s.track(dir, address, uint64(sz))
+			return ok, chunked, sz, mod, meta, nil
+		}
+	}
+	return rsstorage.CheckWithMetadata(ctx, s.StorageServer, dir, address)
+}
+
+// GetWithMetadata gets an item like `Get`, and also returns its metadata.
+// The fast tier is only used when it stores metadata.
+func (s *StorageServer) GetWithMetadata(ctx context.Context, dir, address string) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, types.Metadata, bool, error) {
+	if !s.fastMetadata() {
+		return rsstorage.GetWithMetadata(ctx, s.StorageServer, dir, address)
+	}
+
+	tier, err := s.ensureFast(ctx, dir, address)
+	if err != nil || tier == TierNone {
+		return nil, nil, 0, time.Time{}, nil, false, err
+	}
+	if tier == TierFast || s.promoted(dir, address) {
+		r, chunked, sz, mod, meta, ok, err := rsstorage.GetWithMetadata(ctx, s.fast, dir, address)
+		if err == nil && ok {
+			return r, chunked, sz, mod, meta, ok, nil
+		}
+		s.untrack(dir, address)
+	}
+	return rsstorage.GetWithMetadata(ctx, s.StorageServer, dir, address)
+}
+
+// fastMetadata reports whether the fast tier stores metadata. Otherwise,
+// items promoted to the fast tier lose their metadata.
+func (s *StorageServer) fastMetadata() bool {
+	_, ok := s.fast.(rsstorage.ObjectMetadataServer)
+	return ok
+}
+
+func (s *StorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
+	return s.PutWithMetadata(ctx, resolve, dir, address, nil)
+}
+
+func (s *StorageServer) PutChunked(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64) (string, string, error) {
+	return s.PutChunkedWithMetadata(ctx, resolve, dir, address, sz, nil)
+}
+
+// PutWithMetadata writes an item like `Put`, and stores the metadata with it
+// in both tiers.
+func (s *StorageServer) PutWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, meta types.Metadata) (string, string, error) {
+	if !s.writeThrough {
+		dir, address, err := rsstorage.PutWithMetadata(ctx, s.StorageServer, resolve, dir, address, meta)
+		if err == nil {
+			s.invalidate(ctx, dir, address)
+		}
+		return dir, address, err
+	}
+	return s.putBoth(ctx, resolve, func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error) {
+		return rsstorage.PutWithMetadata(ctx, server, resolve, dir, address, meta)
+	})
+}
+
+// PutChunkedWithMetadata writes a chunked item like `PutChunked`, and stores
+// the metadata with it in both tiers.
+func (s *StorageServer) PutChunkedWithMetadata(ctx context.Context, resolve types.Resolver, dir, address string, sz uint64, meta types.Metadata) (string, string, error) {
+	if !s.writeThrough || sz > uint64(s.budget) {
+		dir, address, err := rsstorage.PutChunkedWithMetadata(ctx, s.StorageServer, resolve, dir, address, sz, meta)
+		if err == nil {
+			s.invalidate(ctx, dir, address)
+		}
+		return dir, address, err
+	}
+	return s.putBoth(ctx, resolve, func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error) {
+		return rsstorage.PutChunkedWithMetadata(ctx, server, resolve, dir, address, sz, meta)
+	})
+}
+
+// putBoth writes an item to the slow tier and to the fast tier at the same
+// time. Failing to write to the fast tier does not fail the write.
+func (s *StorageServer) putBoth(
+	ctx context.Context,
+	resolve types.Resolver,
+	put func(server rsstorage.StorageServer, resolve types.Resolver) (string, string, error),
+) (string, string, error) {
+	pR, pW := io.Pipe()
+
+	// The dir and address returned by the resolver. These are set before the
+	// pipe is closed.
+	var resolvedDir, resolvedAddress string
+
+	// Write to the fast tier from the pipe
+	type result struct {
+		dir     string
+		address string
+		err     error
+	}
+	fastDone := make(chan result, 1)
+	go func() {
+		d, a, err := put(s.fast, func(w io.Writer) (string, string, error) {
+			_, err := io.Copy(w, pR)
+			if err != nil {
+				return "", "", err
+			}
+			return resolvedDir, resolvedAddress, nil
+		})
+		// Unblock the slow tier write if the fast tier stops reading early
+		_ = pR.CloseWithError(internal.ErrNotResolved)
+		fastDone <- result{dir: d, address: a, err: err}
+	}()
+
+	dir, address, err := put(s.StorageServer, func(w io.Writer) (string, string, error) {
+		d, a, err := resolve(io.MultiWriter(w, &internal.BestEffortWriter{W: pW}))
+		resolvedDir, resolvedAddress = d, a
+		if err != nil {
+			_ = pW.CloseWithError(err)
+		} else {
+			_ = pW.Close()
+		}
+		return d, a, err
+	})
+
+	// The slow tier may fail without calling the resolver
+	_ = pW.CloseWithError(internal.ErrNotResolved)
+	fast := <-fastDone
+
+	if err != nil {
+		// Don't keep items in the fast tier that are missing from the slow tier
+		if fast.err == nil {
+			s.invalidate(ctx, fast.dir, fast.address)
+		}
+		return dir, address, err
+	} else if fast.err != nil {
+		// Don't keep a stale copy of the item in the fast tier
+		slog.Debug("unable to write item to fast tier", "dir", dir, "address", address, "error", fast.err)
+		s.invalidate(ctx, dir, address)
+		return dir, address, nil
+	}
+
+	ok, _, sz, _, checkErr := s.fast.Check(ctx, fast.dir, fast.address)
+	if checkErr == nil && ok {
+		s.track(fast.dir, fast.address, uint64(sz))
+		evictErr := s.evict(ctx, fast.dir, fast.address)
+		if evictErr != nil {
+			slog.Warn("unable to evict items from fast tier", "error", evictErr)
+		}
+	}
+	return dir, address, nil
+}
+
+func (s *StorageServer) Remove(ctx context.Context, dir, address string) error {
+	s.untrack(dir, address)
+	return errors.Join(
+		s.fast.Remove(ctx, dir, address),
+		s.StorageServer.Remove(ctx, dir, address),
+	)
+}
+
+func (s *StorageServer) Move(ctx context.Context, dir, address string, server rsstorage.StorageServer) error {
+	err := s.StorageServer.Move(ctx, dir, address, server)
+	if err != nil {
+		return err
+	}
+	s.untrack(dir, address)
+	return s.fast.Remove(ctx, dir, address)
+}
+
+func (s *StorageServer) Base() rsstorage.StorageServer {
+	return s.StorageServer.Base()
+}
+
+// Fast returns the storage server used as the fast tier.
+func (s *StorageServer) Fast() rsstorage.StorageServer {
+	return s.fast
+}
+
+// FastUsage returns the number of bytes tracked in the fast tier.
+func (s *StorageServer) FastUsage() datasize.ByteSize {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return datasize.ByteSize(s.used)
+}
+
+// invalidate removes an item from the fast tier.
+func (s *StorageServer) invalidate(ctx context.Context, dir, address string) {
+	s.untrack(dir, address)
+	err := s.fast.Remove(ctx, dir, address)
+	if err != nil {
+		slog.Debug("unable to remove item from fast tier", "dir", dir, "address", address, "error", err)
+	}
+}
+
+// track records an item in the fast tier as the most recently used item.
+func (s *StorageServer) track(dir, address string, sz uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := path.Join(dir, address)
+	if el, ok := s.items[key]; ok {
+		e := el.Value.(*entry)
+		s.used = s.used - e.size + sz
+		e.size = sz
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.items[key] = s.lru.PushFront(&entry{dir: dir, address: address, size: sz})
+	s.used += sz
+}
+
+func (s *StorageServer) promoted(dir, address string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.items[path.Join(dir, address)]
+	return ok
+}
+
+func (s *StorageServer) untrack(dir, address string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := path.Join(dir, address)
+	if el, ok := s.items[key]; ok {
+		s.used -= el.Value.(*entry).size
+		s.lru.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+// evict removes the least recently used items from the fast tier until it is
+// within budget. The item at `dir` and `address` is never evicted.
+func (s *StorageServer) evict(ctx context.Context, dir, address string) error {
+	keep := path.Join(dir, address)
+
+	s.mutex.Lock()
+	victims := make([]*entry, 0)
+	for el := s.lru.Back(); el != nil && s.used > uint64(s.budget); {
+		prev := el.Prev()
+		e := el.Value.(*entry)
+		key := path.Join(e.dir, e.address)
+		if key != keep {
+			victims = append(victims, e)
+			s.used -= e.size
+			s.lru.Remove(el)
+			delete(s.items, key)
+		}
+		el = prev
+	}
+	s.mutex.Unlock()
+
+	var err error
+	for _, e := range victims {
+		err = errors.Join(err, s.fast.Remove(ctx, e.dir, e.address))
+	}
+	return err
+}
@@ -0,0 +1,321 @@
+package tiered
+
+// Copyright (C) 2026 by Posit Software, PBC
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"gopkg.in/check.v1"
+
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/memtest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/internal/servertest"
+	"github.com/rstudio/platform-lib/v4/pkg/rsstorage/types"
+)
+
+func TestPackage(t *testing.T) { check.TestingT(t) }
+
+type TieredStorageServerSuite struct{}
+
+var _ = check.Suite(&TieredStorageServerSuite{})
+
+func (s *TieredStorageServerSuite) TestNew(c *check.C) {
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:   fast,
+		Slow:   slow,
+		Budget: datasize.KB,
+	})
+	c.Check(server.Base(), check.Equals, slow)
+	c.Check(server.Fast(), check.Equals, fast)
+	c.Check(server.Dir(), check.Equals, "memory:slow")
+	c.Check(server.Type(), check.Equals, rsstorage.StorageTypeMemory)
+}
+
+func (s *TieredStorageServerSuite) TestReadThrough(c *check.C) {
+	ctx := context.Background()
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:   fast,
+		Slow:   slow,
+		Budget: 20,
+	})
+
+	// Missing
+	tier, ok, _, _, _, err := server.CheckTier(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(tier, check.Equals, TierNone)
+	tier, r, _, _, _, ok, err := server.GetTier(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, false)
+	c.Check(r, check.IsNil)
+	c.Check(tier, check.Equals, TierNone)
+
+	// Writes only go to the slow tier
+	_, _, err = server.Put(ctx, servertest.StringResolver("aaaaaaaaaa"), "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, slow, "dir", "a"), check.Equals, true)
+	c.Check(servertest.Exists(c, fast, "dir", "a"), check.Equals, false)
+
+	tier, ok, _, sz, _, err := server.CheckTier(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(10))
+	c.Check(tier, check.Equals, TierSlow)
+
+	// The first read is served by the slow tier and promotes the item
+	tier, r, _, sz, _, ok, err = server.GetTier(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(10))
+	c.Check(tier, check.Equals, TierSlow)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "aaaaaaaaaa")
+	c.Check(servertest.Exists(c, fast, "dir", "a"), check.Equals, true)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(10))
+
+	// The next read is served by the fast tier
+	tier, r, _, _, _, ok, err = server.GetTier(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(tier, check.Equals, TierFast)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "aaaaaaaaaa")
+
+	// Ranges are also read through
+	_, _, err = server.Put(ctx, servertest.StringResolver("bbbbbbbbbb"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	r, _, sz, _, ok, err = server.GetRange(ctx, "dir", "b", 2, 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(sz, check.Equals, int64(10))
+	c.Check(servertest.ReadAll(c, r), check.Equals, "bbb")
+	c.Check(servertest.Exists(c, fast, "dir", "b"), check.Equals, true)
+	_, _, _, _, _, err = server.GetRange(ctx, "dir", "b", 20, 3)
+	c.Check(errors.Is(err, rsstorage.ErrInvalidRange), check.Equals, true)
+
+	// Overwriting an item removes the stale copy from the fast tier
+	_, _, err = server.Put(ctx, servertest.StringResolver("cccccccccc"), "dir", "b")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, fast, "dir", "b"), check.Equals, false)
+	tier, r, _, _, _, _, err = server.GetTier(ctx, "dir", "b")
+	c.Assert(err, check.IsNil)
+	c.Check(tier, check.Equals, TierSlow)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "cccccccccc")
+
+	// Remove from both tiers
+	c.Assert(server.Remove(ctx, "dir", "b"), check.IsNil)
+	c.Check(servertest.Exists(c, fast, "dir", "b"), check.Equals, false)
+	c.Check(servertest.Exists(c, slow, "dir", "b"), check.Equals, false)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(10))
+}
+
+func (s *TieredStorageServerSuite) TestEvict(c *check.C) {
+	ctx := context.Background()
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:   fast,
+		Slow:   slow,
+		Budget: 25,
+	})
+
+	for _, address := range []string{"a", "b", "c"} {
+		_, _, err := server.Put(ctx, servertest.StringResolver(strings.Repeat(address, 10)), "dir", address)
+		c.Assert(err, check.IsNil)
+	}
+	_, _, err := server.Put(ctx, servertest.StringResolver(strings.Repeat("d", 30)), "dir", "d")
+	c.Assert(err, check.IsNil)
+
+	// Read "a" and "b", then "a" again so that "b" is least recently used
+	for _, address := range []string{"a", "b", "a"} {
+		_, r, _, _, _, _, err := server.GetTier(ctx, "dir", address)
+		c.Assert(err, check.IsNil)
+		servertest.ReadAll(c, r)
+	}
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(20))
+
+	// Reading "c" evicts "b"
+	_, r, _, _, _, _, err := server.GetTier(ctx, "dir", "c")
+	c.Assert(err, check.IsNil)
+	servertest.ReadAll(c, r)
+	c.Check(servertest.Exists(c, fast, "dir", "a"), check.Equals, true)
+	c.Check(servertest.Exists(c, fast, "dir", "b"), check.Equals, false)
+	c.Check(servertest.Exists(c, fast, "dir", "c"), check.Equals, true)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(20))
+
+	// Items larger than the budget are never promoted
+	tier, r, _, _, _, ok, err := server.GetTier(ctx, "dir", "d")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(tier, check.Equals, TierSlow)
+	c.Check(servertest.ReadAll(c, r), check.Equals, strings.Repeat("d", 30))
+	c.Check(servertest.Exists(c, fast, "dir", "d"), check.Equals, false)
+
+	// Scan tracks items already in the fast tier
+	_, _, err = fast.Put(ctx, servertest.StringResolver(strings.Repeat("e", 10)), "dir", "e")
+	c.Assert(err, check.IsNil)
+	server = NewStorageServer(StorageServerArgs{
+		Fast:   fast,
+		Slow:   slow,
+		Budget: 25,
+	})
+	c.Assert(server.Scan(ctx), check.IsNil)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(20))
+	c.Check(servertest.Exists(c, fast, "dir", "a"), check.Equals, false)
+}
+
+func (s *TieredStorageServerSuite) TestWriteThrough(c *check.C) {
+	ctx := context.Background()
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:         fast,
+		Slow:         slow,
+		Budget:       datasize.KB * 10,
+		WriteThrough: true,
+	})
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, slow, "dir", "address"), check.Equals, true)
+	c.Check(servertest.Exists(c, fast, "dir", "address"), check.Equals, true)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(9))
+
+	// Deferred addresses
+	resolve := func(w io.Writer) (string, string, error) {
+		_, err := w.Write([]byte("deferred"))
+		return "deferred-dir", "deferred-address", err
+	}
+	d, a, err := server.Put(ctx, resolve, "", "")
+	c.Assert(err, check.IsNil)
+	c.Check(d, check.Equals, "deferred-dir")
+	c.Check(a, check.Equals, "deferred-address")
+	c.Check(servertest.Exists(c, slow, d, a), check.Equals, true)
+	c.Check(servertest.Exists(c, fast, d, a), check.Equals, true)
+
+	// Chunked
+	sz := uint64(len(servertest.TestDESC))
+	_, _, err = server.PutChunked(ctx, servertest.StringResolver(servertest.TestDESC), "dir", "chunked", sz)
+	c.Assert(err, check.IsNil)
+	tier, r, chunked, _, _, ok, err := server.GetTier(ctx, "dir", "chunked")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(tier, check.Equals, TierFast)
+	c.Check(chunked.Complete, check.Equals, true)
+	c.Check(servertest.ReadAll(c, r), check.Equals, servertest.TestDESC)
+	c.Check(servertest.Exists(c, slow, "dir", "chunked"), check.Equals, true)
+
+	// Resolver errors leave nothing in either tier
+	failing := func(w io.Writer) (string, string, error) {
+		_, _ = w.Write([]byte("partial"))
+		return "", "", errors.New("resolve error")
+	}
+	_, _, err = server.Put(ctx, failing, "dir", "failed")
+	c.Assert(err, check.ErrorMatches, "resolve error")
+	c.Check(servertest.Exists(c, slow, "dir", "failed"), check.Equals, false)
+	c.Check(servertest.Exists(c, fast, "dir", "failed"), check.Equals, false)
+}
+
+func (s *TieredStorageServerSuite) TestWriteThroughFastFails(c *check.C) {
+	ctx := context.Background()
+	fast := &rsstorage.DummyStorageServer{
+		PutErr: errors.New("fast put error"),
+	}
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:         fast,
+		Slow:         slow,
+		Budget:       datasize.KB,
+		WriteThrough: true,
+	})
+
+	// The write succeeds when the fast tier fails
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Check(servertest.Exists(c, slow, "dir", "address"), check.Equals, true)
+	c.Check(fast.RemoveCount, check.Equals, 1)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(0))
+}
+
+func (s *TieredStorageServerSuite) TestMetadata(c *check.C) {
+	ctx := context.Background()
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:   fast,
+		Slow:   slow,
+		Budget: 10 * datasize.KB,
+	})
+	meta := types.Metadata{"content-type": "text/plain"}
+
+	// Promoted items keep their metadata
+	_, _, err := server.PutWithMetadata(ctx, servertest.StringResolver("some data"), "dir", "a", meta)
+	c.Assert(err, check.IsNil)
+	ok, _, _, _, got, err := server.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	r, _, _, _, got, ok, err := server.GetWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "some data")
+	_, _, _, _, got, err = rsstorage.CheckWithMetadata(ctx, fast, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(got, check.DeepEquals, meta)
+
+	// Written to both tiers
+	server.writeThrough = true
+	data := servertest.TestDESC
+	_, _, err = server.PutChunkedWithMetadata(ctx, servertest.StringResolver(data), "dir", "chunked", uint64(len(data)), meta)
+	c.Assert(err, check.IsNil)
+	for _, tier := range []rsstorage.StorageServer{fast, slow} {
+		_, _, _, _, got, err = rsstorage.CheckWithMetadata(ctx, tier, "dir", "chunked")
+		c.Assert(err, check.IsNil)
+		c.Check(got, check.DeepEquals, meta)
+	}
+
+	// Metadata is read from the slow tier when the fast tier cannot store it
+	server = NewStorageServer(StorageServerArgs{
+		Fast:   &rsstorage.DummyStorageServer{},
+		Slow:   slow,
+		Budget: 10 * datasize.KB,
+	})
+	ok, _, _, _, got, err = server.CheckWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	r, _, _, _, got, ok, err = server.GetWithMetadata(ctx, "dir", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Check(got, check.DeepEquals, meta)
+	c.Check(servertest.ReadAll(c, r), check.Equals, "some data")
+}
+
+func (s *TieredStorageServerSuite) TestMove(c *check.C) {
+	ctx := context.Background()
+	fast := memtest.NewServer("fast", 352)
+	slow := memtest.NewServer("slow", 352)
+	dest := memtest.NewServer("dest", 352)
+	server := NewStorageServer(StorageServerArgs{
+		Fast:         fast,
+		Slow:         slow,
+		Budget:       datasize.KB,
+		WriteThrough: true,
+	})
+
+	_, _, err := server.Put(ctx, servertest.StringResolver("some data"), "dir", "address")
+	c.Assert(err, check.IsNil)
+	c.Assert(server.Move(ctx, "dir", "address", dest), check.IsNil)
+	c.Check(servertest.Exists(c, dest, "dir", "address"), check.Equals, true)
+	c.Check(servertest.Exists(c, slow, "dir", "address"), check.Equals, false)
+	c.Check(servertest.Exists(c, fast, "dir", "address"), check.Equals, false)
+	c.Check(server.FastUsage(), check.Equals, datasize.ByteSize(0))
+}
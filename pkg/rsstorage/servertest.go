@@ -93,6 +93,10 @@ func (f *DummyStorageServer) Get(ctx context.Context, dir, address string) (io.R
 	}
 }
 
+func (f *DummyStorageServer) GetRange(ctx context.Context, dir, address string, offset, length int64) (io.ReadCloser, *types.ChunksInfo, int64, time.Time, bool, error) {
+	return getRange(ctx, f, dir, address, offset, length)
+}
+
 func (f *DummyStorageServer) Put(ctx context.Context, resolve types.Resolver, dir, address string) (string, string, error) {
 	if f.PutDelay > 0 {
 		time.Sleep(f.PutDelay)
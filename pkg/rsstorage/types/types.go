@@ -5,6 +5,7 @@ package types
 import (
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/c2h5oh/datasize"
@@ -27,6 +28,11 @@ type Usage struct {
 	FreeBytes       datasize.ByteSize
 	UsedBytes       datasize.ByteSize
 	CalculationTime time.Duration
+
+	// LogicalBytes is the size of the stored items as seen by clients when
+	// it differs from the bytes physically used, for example when items are
+	// deduplicated or compressed. Zero when not reported.
+	LogicalBytes datasize.ByteSize
 }
 
 func (u Usage) String() string {
@@ -51,6 +57,31 @@ func (u Usage) ScaleUsed(unit datasize.ByteSize) datasize.ByteSize {
 	return datasize.ByteSize(u.UsedBytes) / unit
 }
 
+// Well known keys for object metadata.
+const (
+	MetadataContentType     = "content-type"
+	MetadataContentEncoding = "content-encoding"
+	MetadataFilename        = "filename"
+)
+
+// Metadata is user metadata stored with an object, e.g., its content type or
+// arbitrary string tags. Keys are case-insensitive and are stored in lower
+// case.
+type Metadata map[string]string
+
+// Normalize returns a copy of the metadata with lower case keys, or nil when
+// there is no metadata.
+func (m Metadata) Normalize() Metadata {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(Metadata, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
 // ChunkNotification that indicates a new chunk is ready. Used for notifying of
 // new chunk availability while downloading chunked assets.
 type ChunkNotification struct {
@@ -65,10 +96,81 @@ type ChunksInfo struct {
 	ModTime   time.Time `json:"mod_time"`
 	NumChunks uint64    `json:"num_chunks"`
 	Complete  bool      `json:"complete"`
+	// Resumes counts the number of times an interrupted write of this
+	// chunked asset was resumed.
+	Resumes uint64 `json:"resumes,omitempty"`
+	// Checksums records the hex-encoded SHA-256 of each chunk, in chunk
+	// order. They are recorded when the write completes, and are missing for
+	// assets written before checksums were recorded.
+	Checksums []string `json:"checksums,omitempty"`
+	// Metadata is the user metadata stored with the chunked asset.
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// ExpectedChunkSize returns the size in bytes of the chunk at `index`
+// (one-based), or zero if the index is out of range.
+func (i *ChunksInfo) ExpectedChunkSize(index uint64) uint64 {
+	if index < 1 || index > i.NumChunks {
+		return 0
+	}
+	if index == i.NumChunks {
+		return i.FileSize - (i.NumChunks-1)*i.ChunkSize
+	}
+	return i.ChunkSize
+}
+
+// Checksum returns the recorded checksum of the chunk at `index`
+// (one-based), or an empty string if it was not recorded.
+func (i *ChunksInfo) Checksum(index uint64) string {
+	if index < 1 || index > uint64(len(i.Checksums)) {
+		return ""
+	}
+	return i.Checksums[index-1]
+}
+
+// ResumableWriter is the writer passed to a Resolver when a chunked put
+// resumes an interrupted write. Offset reports the number of bytes that are
+// already stored. By default, the writer discards those bytes as the resolver
+// writes them. A resolver that can seek its source may instead call Skip
+// before writing anything and begin writing at Offset.
+type ResumableWriter interface {
+	io.Writer
+	Offset() uint64
+	Skip() error
 }
 
 type StoredItem struct {
 	Dir     string
 	Address string
 	Chunked bool
+
+	// The following are only set by streaming enumerations. Size is the size
+	// of the item, or of all its chunks when chunked. ETag is only set by S3
+	// for items that are not chunked.
+	Size    int64
+	ModTime time.Time
+	ETag    string
+
+	// Token resumes a streaming enumeration after this item, see
+	// `EnumerateOptions`. Tokens are specific to a storage server.
+	Token string
+}
+
+// EnumerateOptions filter a streaming enumeration.
+type EnumerateOptions struct {
+	// Prefix limits the enumeration to items whose path, `dir/address`,
+	// starts with this prefix.
+	Prefix string
+
+	// After resumes an enumeration after the item with this `Token`.
+	After string
+}
+
+// Path returns the path of the item, `dir/address`, or only the address when
+// the item has no dir.
+func (i StoredItem) Path() string {
+	if i.Dir == "" {
+		return i.Address
+	}
+	return i.Dir + "/" + i.Address
 }
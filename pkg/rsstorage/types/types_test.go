@@ -29,3 +29,30 @@ func (s *TypesSuite) TestTypes(c *check.C) {
 	c.Assert(usage.ScaleFree(datasize.GB), check.Equals, datasize.ByteSize(20))
 	c.Assert(usage.ScaleUsed(datasize.GB), check.Equals, datasize.ByteSize(180))
 }
+
+func (s *TypesSuite) TestExpectedChunkSize(c *check.C) {
+	info := ChunksInfo{
+		ChunkSize: 100,
+		FileSize:  1953,
+		NumChunks: 20,
+	}
+	c.Check(info.ExpectedChunkSize(0), check.Equals, uint64(0))
+	c.Check(info.ExpectedChunkSize(1), check.Equals, uint64(100))
+	c.Check(info.ExpectedChunkSize(19), check.Equals, uint64(100))
+	c.Check(info.ExpectedChunkSize(20), check.Equals, uint64(53))
+	c.Check(info.ExpectedChunkSize(21), check.Equals, uint64(0))
+}
+
+func (s *TypesSuite) TestChecksum(c *check.C) {
+	info := ChunksInfo{
+		NumChunks: 2,
+		Checksums: []string{"a", "b"},
+	}
+	c.Check(info.Checksum(0), check.Equals, "")
+	c.Check(info.Checksum(1), check.Equals, "a")
+	c.Check(info.Checksum(2), check.Equals, "b")
+	c.Check(info.Checksum(3), check.Equals, "")
+
+	info.Checksums = nil
+	c.Check(info.Checksum(1), check.Equals, "")
+}